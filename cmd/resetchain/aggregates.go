@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+// DefaultPowerReduction is the power reduction the processor uses when none is configured.
+const DefaultPowerReduction = tables.DefaultPowerReduction
+
+// aggregate is a table summing up the rows of another one, kept up to date by the processor.
+type aggregate struct {
+	name   string
+	source string
+
+	// seed returns the statement filling the aggregate from the live rows of source matching
+	// cond, as the processor does.
+	seed func(name, source, cond string, powerReduction int64) string
+}
+
+// aggregates are rebuilt the way the processor seeds them, for a single chain.
+var aggregates = []aggregate{
+	{
+		name:   "denom_totals",
+		source: "balances",
+		seed: func(name, source, cond string, _ int64) string {
+			return tables.SeedDenomTotals(name, source, cond)
+		},
+	},
+	{
+		name:   "delegator_totals",
+		source: "delegations",
+		seed: func(name, source, cond string, _ int64) string {
+			return tables.SeedDelegatorTotals(name, source, cond)
+		},
+	},
+	{
+		name:   "validator_powers",
+		source: "validators",
+		seed:   tables.SeedValidatorPowers,
+	},
+}
+
+// chainRows is the seed condition restricting aggregates to the chain $1.
+const chainRows = "chain_name = $1"
+
+const deleteAggregateRows = `DELETE FROM %s WHERE chain_name = $1`
+
+const (
+	// clearDelegatedTokens leaves the delegations whose validator is gone without tokens, as
+	// the processor does.
+	clearDelegatedTokens = `UPDATE %s SET delegated_tokens = NULL WHERE chain_name = $1`
+
+	chainDelegatedTokensWhere = `
+	WHERE d.chain_name = $1 AND d.delete_height IS NULL
+	AND v.chain_name = d.chain_name AND v.validator_address = d.validator_address AND v.delete_height IS NULL`
+)
+
+// RecomputeDelegatedTokens recomputes the delegated tokens of the delegations of chainName in
+// schema from the rows left by a rollback: history tables don't record them, and rows restored
+// from there keep the ones computed after the rollback height.
+// It's left as is if the delegations or validators table doesn't exist.
+func RecomputeDelegatedTokens(l *zap.SugaredLogger, db *sqlx.DB, schema, chainName string) error {
+	delegations, validators := schema+".delegations", schema+".validators"
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(clearDelegatedTokens, delegations), chainName)
+	if err == nil {
+		_, err = tx.Exec(tables.RecomputeDelegatedTokens(database.DialectCockroachDB, delegations, validators, chainDelegatedTokensWhere), chainName)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == relationshipNotFoundErrorCode {
+		_ = tx.Rollback()
+		l.Debugw("delegations or validators table doesn't exist", "table", delegations)
+		return nil
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("recomputing delegated tokens of %s: %w", delegations, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("recomputing delegated tokens of %s: %w", delegations, err)
+	}
+
+	l.Infow("delegated tokens recomputed", "table", delegations)
+	return nil
+}
+
+// RebuildAggregates recomputes the aggregate rows of chainName in schema from the rows left
+// by a reset or a rollback, powerReduction being the one the processor is configured with.
+// Aggregates which don't exist, or whose source table doesn't, are left as is.
+func RebuildAggregates(l *zap.SugaredLogger, db *sqlx.DB, schema, chainName string, powerReduction int64) error {
+	for _, a := range aggregates {
+		name, source := schema+"."+a.name, schema+"."+a.source
+
+		tx, err := db.Beginx()
+		if err != nil {
+			return fmt.Errorf("cannot begin transaction: %w", err)
+		}
+
+		_, err = tx.Exec(fmt.Sprintf(deleteAggregateRows, name), chainName)
+		if err == nil {
+			_, err = tx.Exec(a.seed(name, source, chainRows, powerReduction), chainName)
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == relationshipNotFoundErrorCode {
+			_ = tx.Rollback()
+			l.Debugw("aggregate or its source table doesn't exist", "table", name)
+			continue
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("rebuilding %s: %w", name, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("rebuilding %s: %w", name, err)
+		}
+
+		l.Infow("aggregate rebuilt", "table", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ArchiveEntry is a row deleted from Table, as a JSON object of its columns.
+type ArchiveEntry struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
+// Archive writes the rows deleted by the resetter to a gzip-compressed JSON lines file,
+// one ArchiveEntry per line.
+type Archive struct {
+	f   *os.File
+	gz  *gzip.Writer
+	enc *json.Encoder
+}
+
+// NewArchive creates the archive file at path, which must not exist.
+func NewArchive(path string) (*Archive, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create archive file: %w", err)
+	}
+
+	gz := gzip.NewWriter(f)
+
+	return &Archive{
+		f:   f,
+		gz:  gz,
+		enc: json.NewEncoder(gz),
+	}, nil
+}
+
+// Write appends row, deleted from table, to the archive.
+func (a *Archive) Write(table string, row json.RawMessage) error {
+	if err := a.enc.Encode(ArchiveEntry{Table: table, Row: row}); err != nil {
+		return fmt.Errorf("cannot write archive entry: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes the archive and closes its file.
+func (a *Archive) Close() error {
+	if err := a.gz.Close(); err != nil {
+		_ = a.f.Close()
+		return fmt.Errorf("cannot flush archive: %w", err)
+	}
+
+	if err := a.f.Close(); err != nil {
+		return fmt.Errorf("cannot close archive file: %w", err)
+	}
+
+	return nil
+}
+
+// ReadArchive calls fn with each entry of the archive file at path, in order.
+func ReadArchive(path string, fn func(ArchiveEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open archive file: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("cannot read archive file: %w", err)
+	}
+
+	dec := json.NewDecoder(gz)
+	for line := 1; ; line++ {
+		var e ArchiveEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read archive entry at line %d: %w", line, err)
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// restoreRow inserts a row archived from a table back into it, its columns being converted
+// from JSON by the database.
+const restoreRow = `INSERT INTO %[1]s SELECT * FROM jsonb_populate_record(NULL::%[1]s, $1)`
+
+// Restore inserts the rows archived in the file at path back into their tables,
+// in transactions of chunkSize rows, and returns the number of rows restored.
+// Restoring fails on rows written again since they were archived.
+func Restore(l *zap.SugaredLogger, db *sqlx.DB, path string, chunkSize int) (int, error) {
+	restored := 0
+	pending := 0
+
+	var tx *sqlx.Tx
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("cannot commit restored rows: %w", err)
+		}
+
+		restored += pending
+		l.Infow("restored chunk", "restored", restored)
+
+		tx, pending = nil, 0
+		return nil
+	}
+
+	err := ReadArchive(path, func(e ArchiveEntry) error {
+		if tx == nil {
+			var err error
+			if tx, err = db.Beginx(); err != nil {
+				return fmt.Errorf("cannot begin transaction: %w", err)
+			}
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf(restoreRow, e.Table), string(e.Row)); err != nil {
+			return fmt.Errorf("cannot restore row of table %s: %w", e.Table, err)
+		}
+
+		pending++
+		if pending < chunkSize {
+			return nil
+		}
+
+		return commit()
+	})
+
+	if err != nil {
+		if tx != nil {
+			_ = tx.Rollback()
+		}
+
+		return restored, err
+	}
+
+	if err := commit(); err != nil {
+		return restored, err
+	}
+
+	return restored, nil
+}
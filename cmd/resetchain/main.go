@@ -1,11 +1,13 @@
-// resetchain clears CockroachDB data for a specific chain.
+// resetchain clears CockroachDB data for a specific chain, or rolls it back to a given height.
 // It follows the best practices for performing bulk deletes: https://www.cockroachlabs.com/docs/stable/bulk-delete-data.html
 package main
 
 import (
 	"flag"
 	"fmt"
+	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/emerishq/tracelistener/database"
 	"github.com/emerishq/tracelistener/logging"
@@ -31,26 +33,84 @@ func main() {
 	db.DB.SetMaxOpenConns(10)
 	db.DB.SetMaxIdleConns(10)
 
-	tables := strings.Split(flags.tables, ",")
+	if flags.command == "restore" {
+		restored, err := Restore(logger, db.DB, flags.archive, flags.chunkSize)
+		if err != nil {
+			logger.Panicw("error", "error", err, "restored", restored)
+		}
+
+		logger.Infow("restore done", "restored", restored)
+		return
+	}
+
+	discovered, err := DiscoverTables(db.DB, flags.schema)
+	if err != nil {
+		logger.Panic("discovering tables", err)
+	}
+
 	resetter := Resetter{
 		Logger:    logger,
 		DB:        db.DB,
 		ChainName: flags.chain,
 		ChunkSize: flags.chunkSize,
-		Tables:    tables,
+		Tables:    SelectTables(logger, discovered, flags.tablesSlice()),
+		ToHeight:  flags.toHeight,
+
+		Schema:         flags.schema,
+		PowerReduction: flags.powerReduction,
+	}
+
+	if flags.dryRun {
+		counts, err := resetter.Count()
+		if err != nil {
+			logger.Panic("error", err)
+		}
+
+		printCounts(counts)
+		return
+	}
+
+	if flags.archive != "" {
+		resetter.Archive, err = NewArchive(flags.archive)
+		if err != nil {
+			logger.Panic("error", err)
+		}
 	}
 
 	err = resetter.Reset()
+
+	if resetter.Archive != nil {
+		if err := resetter.Archive.Close(); err != nil {
+			logger.Errorw("archive incomplete", "error", err, "path", flags.archive)
+		}
+	}
+
 	if err != nil {
 		logger.Panic("error", err)
 	}
 }
 
+func printCounts(counts []TableCount) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tRESTORED\tDELETED")
+	for _, c := range counts {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", c.Table, c.Restored, c.Deleted)
+	}
+
+	_ = w.Flush()
+}
+
 type Flags struct {
-	db        string
-	chain     string
-	chunkSize int
-	tables    string
+	db             string
+	schema         string
+	chain          string
+	chunkSize      int
+	tables         string
+	toHeight       uint64
+	dryRun         bool
+	archive        string
+	powerReduction int64
+	command        string
 }
 
 func (f Flags) Validate() error {
@@ -58,46 +118,72 @@ func (f Flags) Validate() error {
 		return fmt.Errorf("missing database connection string")
 	}
 
-	if len(f.chain) == 0 {
-		return fmt.Errorf("missing chain name")
-	}
-
 	if f.chunkSize <= 0 {
 		return fmt.Errorf("chunk size must be greater than 0")
 	}
 
-	if len(f.tables) == 0 {
-		return fmt.Errorf("missing tables to reset")
+	switch f.command {
+	case "":
+	case "restore":
+		if len(f.archive) == 0 {
+			return fmt.Errorf("missing archive to restore")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown command %s", f.command)
+	}
+
+	if len(f.chain) == 0 {
+		return fmt.Errorf("missing chain name")
+	}
+
+	if len(f.schema) == 0 {
+		return fmt.Errorf("missing schema")
 	}
 
 	return nil
 }
 
-var defaultTables = []string{
-	"balances@balances_chain_name_id_idx",
-	"connections@connections_chain_name_id_idx",
-	"delegations@delegations_chain_name_id_idx",
-	"unbonding_delegations@unbonding_delegations_chain_name_id_idx",
-	"denom_traces@denom_traces_chain_name_id_idx",
-	"channels@channels_chain_name_id_idx",
-	"auth@auth_chain_name_id_idx",
-	"clients@clients_chain_name_id_idx",
-	"validators@validators_chain_name_id_idx",
-	"liquidity_swaps@liquidity_swaps_chain_name_id_idx",
-	"liquidity_pools@liquidity_pools_chain_name_id_idx",
+func (f Flags) tablesSlice() []string {
+	if f.tables == "" {
+		return nil
+	}
+
+	s := strings.Split(f.tables, ",")
+	for i := 0; i < len(s); i++ {
+		s[i] = strings.TrimSpace(s[i])
+	}
+
+	return s
 }
 
 func setupFlag() Flags {
 	db := flag.String("db", "", "DB connection string, e.g. postgres://root@localhost:26257/tracelistener")
+	schema := flag.String("schema", DefaultSchema, "Database, or schema on PostgreSQL, holding tracelistener tables: the DatabaseName of its configuration")
 	chain := flag.String("chain", "", "Name of the chain to reset, e.g. cosmos-hub")
 	chunkSize := flag.Int("chunk", 5000, "Delete chunk size (default: 5000)")
-	tables := flag.String("tables", strings.Join(defaultTables, ","), "Comma separated list of tables to reset. If not specified, all tables will be reset.")
+	tables := flag.String("tables", "", "Comma separated list of tables to reset, optionally with an index hint as in table@index. If not specified, all the tables of the schema with a chain_name column will be reset, but aggregates, checkpoints, import cursors, block summaries, state changes and histories.")
+	toHeight := flag.Uint64("to-height", 0, "Roll the chain back to the given height instead of deleting all its rows: rows written or deleted after it are restored from history tables, the other rows deleted after it are un-deleted and the ones written after it deleted")
+	dryRun := flag.Bool("dry-run", false, "Print the number of rows which would be restored and deleted in each table, without changing them")
+	powerReduction := flag.Int64("power-reduction", DefaultPowerReduction, "Amount of tokens making a unit of consensus power, as configured for tracelistener, to rebuild validator powers with")
+	archive := flag.String("archive", "", "Write the deleted rows to the given gzip-compressed JSON lines file, which must not exist; with restore, the archive to load back")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [restore]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	return Flags{
-		db:        *db,
-		chain:     *chain,
-		chunkSize: *chunkSize,
-		tables:    *tables,
+		db:             *db,
+		schema:         *schema,
+		chain:          *chain,
+		chunkSize:      *chunkSize,
+		tables:         *tables,
+		toHeight:       *toHeight,
+		dryRun:         *dryRun,
+		archive:        *archive,
+		powerReduction: *powerReduction,
+		command:        flag.Arg(0),
 	}
 }
@@ -17,6 +17,7 @@ func TestFlags_Validate(t *testing.T) {
 			expectedError: false,
 			flags: Flags{
 				db:        "postgres://localhost",
+				schema:    "tracelistener",
 				chain:     "cosmos-hub",
 				chunkSize: 1,
 				tables:    "auth,clients,validators",
@@ -27,6 +28,7 @@ func TestFlags_Validate(t *testing.T) {
 			expectedError: true,
 			flags: Flags{
 				db:        "postgres://localhost",
+				schema:    "tracelistener",
 				chain:     "cosmos-hub",
 				chunkSize: -2,
 				tables:    "auth,clients,validators",
@@ -37,6 +39,7 @@ func TestFlags_Validate(t *testing.T) {
 			expectedError: true,
 			flags: Flags{
 				db:        "postgres://localhost",
+				schema:    "tracelistener",
 				chain:     "cosmos-hub",
 				chunkSize: 0,
 				tables:    "auth,clients,validators",
@@ -47,6 +50,7 @@ func TestFlags_Validate(t *testing.T) {
 			expectedError: true,
 			flags: Flags{
 				db:        "",
+				schema:    "tracelistener",
 				chain:     "cosmos-hub",
 				chunkSize: 2,
 				tables:    "auth,clients,validators",
@@ -57,11 +61,61 @@ func TestFlags_Validate(t *testing.T) {
 			expectedError: true,
 			flags: Flags{
 				db:        "postgres://localhost",
+				schema:    "tracelistener",
 				chain:     "",
 				chunkSize: 2,
 				tables:    "auth,clients,validators",
 			},
 		},
+		{
+			name:          "no tables given",
+			expectedError: false,
+			flags: Flags{
+				db:        "postgres://localhost",
+				schema:    "tracelistener",
+				chain:     "cosmos-hub",
+				chunkSize: 2,
+			},
+		},
+		{
+			name:          "empty schema",
+			expectedError: true,
+			flags: Flags{
+				db:        "postgres://localhost",
+				chain:     "cosmos-hub",
+				chunkSize: 2,
+			},
+		},
+		{
+			name:          "restore",
+			expectedError: false,
+			flags: Flags{
+				db:        "postgres://localhost",
+				chunkSize: 2,
+				archive:   "archive.jsonl.gz",
+				command:   "restore",
+			},
+		},
+		{
+			name:          "restore without archive",
+			expectedError: true,
+			flags: Flags{
+				db:        "postgres://localhost",
+				chunkSize: 2,
+				command:   "restore",
+			},
+		},
+		{
+			name:          "unknown command",
+			expectedError: true,
+			flags: Flags{
+				db:        "postgres://localhost",
+				schema:    "tracelistener",
+				chain:     "cosmos-hub",
+				chunkSize: 2,
+				command:   "something",
+			},
+		},
 	}
 
 	for _, test := range tt {
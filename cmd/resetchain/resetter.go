@@ -2,8 +2,10 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,7 +21,18 @@ type Resetter struct {
 	DB        *sqlx.DB
 	ChainName string
 	ChunkSize int
-	Tables    []string
+	Tables    []Table
+
+	// ToHeight, if not zero, rolls the chain back to that height instead of deleting all its rows.
+	ToHeight uint64
+
+	// Archive, if set, receives the rows deleted.
+	Archive *Archive
+
+	// Schema holds the aggregates and the checkpoints, PowerReduction is the one validator
+	// powers are computed with.
+	Schema         string
+	PowerReduction int64
 }
 
 func (r Resetter) Reset() error {
@@ -27,18 +40,27 @@ func (r Resetter) Reset() error {
 		"starting resetter",
 		"chainName", r.ChainName,
 		"chunkSize", strconv.Itoa(r.ChunkSize),
+		"toHeight", r.ToHeight,
+		"archive", r.Archive != nil,
 	)
 
 	var errs []string
 
 	for _, t := range r.Tables {
-		l := r.Logger.With("table", t)
+		l := r.Logger.With("table", t.String())
 		startTime := time.Now()
 		l.Info("start")
-		err := ResetTable(l, r.DB, t, r.ChainName, r.ChunkSize)
+
+		var err error
+		if r.ToHeight != 0 {
+			err = RollbackTable(l, r.DB, t, r.ChainName, r.ToHeight, r.ChunkSize, r.Archive)
+		} else {
+			err = ResetTable(l, r.DB, t, r.ChainName, r.ChunkSize, r.Archive)
+		}
+
 		if err != nil {
 			l.Errorw("completed with errors", "error", err, "took", time.Since(startTime))
-			errs = append(errs, fmt.Sprintf("resetting table %s: %s", t, err))
+			errs = append(errs, fmt.Sprintf("resetting table %s: %s", t.Name, err))
 		} else {
 			l.Infow("completed", "took", time.Since(startTime).String())
 		}
@@ -49,76 +71,489 @@ func (r Resetter) Reset() error {
 		return fmt.Errorf("completed with errors: %s", err)
 	}
 
+	// derived values are computed by the processor, they're recomputed from the rows rolled back
+	if r.ToHeight != 0 {
+		if err := RecomputeDelegatedTokens(r.Logger, r.DB, r.Schema, r.ChainName); err != nil {
+			return err
+		}
+	}
+
+	if err := RebuildAggregates(r.Logger, r.DB, r.Schema, r.ChainName, r.PowerReduction); err != nil {
+		return err
+	}
+
+	if r.ToHeight == 0 {
+		return nil
+	}
+
+	return RollbackCheckpoint(r.Logger, r.DB, r.Schema, r.ChainName, r.ToHeight)
+}
+
+// RollbackCheckpoint moves the checkpoint of chainName in schema back to height if it's past it,
+// so that tracelistener sees the blocks after height as a gap when it resumes.
+func RollbackCheckpoint(l *zap.SugaredLogger, db *sqlx.DB, schema, chainName string, height uint64) error {
+	res, err := db.Exec(fmt.Sprintf(rollbackCheckpoint, schema+".checkpoints"), chainName, height)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == relationshipNotFoundErrorCode {
+		l.Warn("checkpoints table doesn't exist")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("rolling back checkpoint: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		l.Infow("checkpoint rolled back", "height", height)
+	}
+
 	return nil
 }
 
+// TableCount holds the number of rows of a table a reset would restore and delete.
+type TableCount struct {
+	Table    string
+	Restored int
+	Deleted  int
+}
+
+// Count returns the number of rows Reset would restore and delete in each table, without
+// changing them.
+func (r Resetter) Count() ([]TableCount, error) {
+	res := make([]TableCount, 0, len(r.Tables))
+	for _, t := range r.Tables {
+		c := TableCount{Table: t.Name}
+
+		var err error
+		switch {
+		case r.ToHeight == 0:
+			c.Deleted, err = countChainRows(r.DB, t, r.ChainName, "", 0)
+		case !t.Height:
+			// left as is, see RollbackTable
+		default:
+			if len(t.HistoryKeys) > 0 {
+				c.Restored, err = countHistoryStates(r.DB, t, r.ChainName, r.ToHeight)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if t.DeleteHeight {
+				var undeleted int
+				undeleted, err = countChainRows(r.DB, t, r.ChainName, undeleteCondition(t), r.ToHeight)
+				if err != nil {
+					return nil, err
+				}
+				c.Restored += undeleted
+			}
+
+			// restored rows are written back at the rollback height or before,
+			// they aren't deleted
+			c.Deleted, err = countChainRows(r.DB, t, r.ChainName, rowsAfterHeight, r.ToHeight)
+			c.Deleted -= c.Restored
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, c)
+	}
+
+	return res, nil
+}
+
 type baseQueryParams struct {
 	LastId    int    `db:"last_id"`
 	ChainName string `db:"chain_name"`
 	Limit     int    `db:"limit"`
+	Height    uint64 `db:"height"`
 }
 
 const (
 	relationshipNotFoundErrorCode = "42P01"
 )
 
-func ResetTable(l *zap.SugaredLogger, db *sqlx.DB, table, chainName string, chunkSize int) error {
+const (
+	deleteRows = `DELETE FROM %s`
+
+	rowsAfterHeight = `height > :height`
+
+	// undeleteRows clears the soft delete of rows, which overwrote the height they had before
+	// with the deletion one: they're written back at the rollback height.
+	undeleteRows = `UPDATE %s SET delete_height = NULL, height = :height`
+
+	rowsDeletedAfterHeight = `delete_height > :height`
+
+	// withoutHistory leaves out the rows the history table of a table has recorded, which
+	// restoreFromHistory handles. Parameters are the history table and the history keys.
+	withoutHistory = `(%[2]s) NOT IN (SELECT %[2]s FROM %[1]s WHERE chain_name = :chain_name)`
+
+	rollbackCheckpoint = `UPDATE %s SET height = $2 WHERE chain_name = $1 AND height > $2`
+
+	// historyStates selects the rows of the chain written after height as the history table
+	// recorded them at height, as JSON objects of their columns, leaving out the ones which
+	// didn't exist then. Parameters are the table, its history table and the history keys.
+	historyStates = `
+		SELECT h.new_value FROM (
+			SELECT DISTINCT ON (%[3]s) operation, new_value
+			FROM %[2]s
+			WHERE chain_name = :chain_name AND height <= :height
+			AND (%[3]s) IN (SELECT %[3]s FROM %[1]s WHERE chain_name = :chain_name AND height > :height)
+			ORDER BY %[3]s, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	`
+
+	// restoreState writes back a row selected by historyStates, the JSON object $1, over the
+	// current one. Parameters are the table, the columns of the row, the history keys and
+	// the columns updates.
+	restoreState = `
+		INSERT INTO %[1]s (%[2]s)
+		SELECT %[2]s FROM jsonb_populate_record(NULL::%[1]s, $1)
+		ON CONFLICT (%[3]s) DO UPDATE SET %[4]s
+	`
+)
+
+// chunkQuery returns the statement running stmt on a chunk of the rows of the chain matching
+// cond, and returning their ids.
+// Rows of tables with an id column are processed in descending id order, below the last_id
+// cursor; 0 is returned for the rows of the other ones.
+// Archived rows are returned as JSON objects too.
+func chunkQuery(t Table, stmt, cond string, archived bool) string {
+	where := []string{"chain_name = :chain_name"}
+	if cond != "" {
+		where = append(where, cond)
+	}
+
+	id := "0"
+	lines := []string{fmt.Sprintf(stmt, t)}
+	if t.ID {
+		id = "id"
+		where = append([]string{"id <= :last_id"}, where...)
+		lines = append(lines, "WHERE "+strings.Join(where, " AND "), "ORDER BY id DESC")
+	} else {
+		lines = append(lines, "WHERE "+strings.Join(where, " AND "))
+	}
+
+	lines = append(lines, "LIMIT :limit")
+
+	if !archived {
+		lines = append(lines, "RETURNING "+id)
+		return strings.Join(lines, "\n")
+	}
+
+	lines = append(lines, "RETURNING *")
+	return fmt.Sprintf("WITH processed AS (\n%s\n)\nSELECT %s, to_jsonb(processed) FROM processed", strings.Join(lines, "\n"), id)
+}
+
+// countChainRows returns the number of rows of the chain in t matching cond.
+func countChainRows(db *sqlx.DB, t Table, chainName, cond string, height uint64) (int, error) {
+	q := fmt.Sprintf("SELECT count(*) FROM %s WHERE chain_name = :chain_name", t)
+	if cond != "" {
+		q += " AND " + cond
+	}
+
+	q, args, err := sqlx.Named(q, baseQueryParams{
+		ChainName: chainName,
+		Height:    height,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = db.Get(&count, db.Rebind(q), args...)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == relationshipNotFoundErrorCode {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("counting rows of %s: %w", t.Name, err)
+	}
+
+	return count, nil
+}
+
+// ResetTable deletes all the rows of chainName in t, archiving them if archive is set.
+func ResetTable(l *zap.SugaredLogger, db *sqlx.DB, t Table, chainName string, chunkSize int, archive *Archive) error {
+	params := baseQueryParams{
+		ChainName: chainName,
+		Limit:     chunkSize,
+	}
+
+	ok, err := initCursor(l, db, t, &params)
+	if !ok || err != nil {
+		return err
+	}
+
+	_, err = processChunks(l, db, t, chunkQuery(t, deleteRows, "", archive != nil), params, archive)
+	return err
+}
+
+// RollbackTable rolls the rows of chainName in t back to height: rows written or deleted after
+// height are written back with the values its history table recorded at height, if t has one,
+// then the soft delete of the other rows deleted after height is cleared, and the rows left
+// written after height are deleted and archived if archive is set, so that a replay or a
+// backfill can repopulate the table from there.
+// Soft deletes overwrite the height rows had, so without a history table un-deleted rows keep the
+// values they were deleted with, at height; nothing tells the ones created after height apart.
+// Tables without a height column are left as is.
+func RollbackTable(l *zap.SugaredLogger, db *sqlx.DB, t Table, chainName string, height uint64, chunkSize int, archive *Archive) error {
+	if !t.Height {
+		l.Warn("table has no height column, left as is")
+		return nil
+	}
+
+	params := baseQueryParams{
+		ChainName: chainName,
+		Limit:     chunkSize,
+		Height:    height,
+	}
+
+	ok, err := initCursor(l, db, t, &params)
+	if !ok || err != nil {
+		return err
+	}
+
+	if len(t.HistoryKeys) > 0 {
+		if _, err := restoreFromHistory(l.With("step", "restore"), db, t, params); err != nil {
+			return fmt.Errorf("restoring rows to height %d: %w", height, err)
+		}
+	}
+
+	if t.DeleteHeight {
+		if _, err := processChunks(l.With("step", "undelete"), db, t, chunkQuery(t, undeleteRows, undeleteCondition(t), false), params, nil); err != nil {
+			return fmt.Errorf("un-deleting rows deleted after height %d: %w", height, err)
+		}
+	}
+
+	if _, err := processChunks(l.With("step", "delete"), db, t, chunkQuery(t, deleteRows, rowsAfterHeight, archive != nil), params, archive); err != nil {
+		return fmt.Errorf("deleting rows written after height %d: %w", height, err)
+	}
+
+	return nil
+}
+
+// undeleteCondition returns the condition matching the rows of t RollbackTable un-deletes.
+func undeleteCondition(t Table) string {
+	if len(t.HistoryKeys) == 0 {
+		return rowsDeletedAfterHeight
+	}
+
+	return rowsDeletedAfterHeight + " AND " + fmt.Sprintf(withoutHistory, t.HistoryName(), strings.Join(t.HistoryKeys, ", "))
+}
+
+// countHistoryStates returns the number of rows of chainName in t restoreFromHistory would
+// write back.
+func countHistoryStates(db *sqlx.DB, t Table, chainName string, height uint64) (int, error) {
+	q, args, err := sqlx.Named(fmt.Sprintf("SELECT count(*) FROM (%s) AS s", historyStatesQuery(t)), baseQueryParams{
+		ChainName: chainName,
+		Height:    height,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := db.Get(&count, db.Rebind(q), args...); err != nil {
+		return 0, fmt.Errorf("counting rows of %s to restore: %w", t.Name, err)
+	}
+
+	return count, nil
+}
+
+func historyStatesQuery(t Table) string {
+	return fmt.Sprintf(historyStates, t.Name, t.HistoryName(), strings.Join(t.HistoryKeys, ", "))
+}
+
+// restoreFromHistory writes back the rows of the chain in t written after the height of params
+// with the values the history table of t recorded at that height, in transactions of chunk size
+// rows, and returns the number of rows restored.
+func restoreFromHistory(l *zap.SugaredLogger, db *sqlx.DB, t Table, params baseQueryParams) (int, error) {
+	query := historyStatesQuery(t) + "LIMIT :limit"
+	restored := 0
+
+	// restored rows are at height or before, the next chunk doesn't select them again
+	for {
+		var states []json.RawMessage
+		rows, err := db.NamedQuery(query, params)
+		if err != nil {
+			return restored, err
+		}
+
+		for rows.Next() {
+			// jsonb is scanned as text by some drivers
+			var state []byte
+			if err := rows.Scan(&state); err != nil {
+				_ = rows.Close()
+				return restored, fmt.Errorf("cannot scan history row: %w", err)
+			}
+
+			states = append(states, state)
+		}
+
+		if err := rows.Err(); err != nil {
+			return restored, err
+		}
+
+		if err := rows.Close(); err != nil {
+			return restored, fmt.Errorf("closing rows object: %w", err)
+		}
+
+		if len(states) == 0 {
+			return restored, nil
+		}
+
+		if err := writeStates(db, t, states); err != nil {
+			return restored, err
+		}
+
+		restored += len(states)
+		l.Infow("processed chunk", "rows", restored)
+	}
+}
+
+// writeStates writes states, selected by historyStates, over the rows of t in a transaction.
+func writeStates(db *sqlx.DB, t Table, states []json.RawMessage) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+
+	for _, state := range states {
+		var columns map[string]json.RawMessage
+		if err := json.Unmarshal(state, &columns); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("cannot decode history row: %w", err)
+		}
+
+		if _, ok := columns["height"]; !ok {
+			_ = tx.Rollback()
+			return fmt.Errorf("history row without height: %s", state)
+		}
+
+		names := make([]string, 0, len(columns))
+		for c := range columns {
+			names = append(names, c)
+		}
+		sort.Strings(names)
+
+		updates := make([]string, 0, len(names)+1)
+		for _, c := range names {
+			updates = append(updates, fmt.Sprintf("%[1]s = EXCLUDED.%[1]s", c))
+		}
+		if t.DeleteHeight {
+			updates = append(updates, "delete_height = NULL")
+		}
+
+		stmt := fmt.Sprintf(restoreState, t.Name, strings.Join(names, ", "), strings.Join(t.HistoryKeys, ", "), strings.Join(updates, ", "))
+		if _, err := tx.Exec(stmt, string(state)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("cannot restore row of table %s: %w", t.Name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit restored rows: %w", err)
+	}
+
+	return nil
+}
+
+// initCursor sets the last_id cursor of params to the last id of the chain in t, if it has
+// an id column, and returns false if there are no rows to process.
+func initCursor(l *zap.SugaredLogger, db *sqlx.DB, t Table, params *baseQueryParams) (bool, error) {
+	if !t.ID {
+		return true, nil
+	}
+
 	// get last id, we'll use it as a cursor
 	row := db.QueryRowx(fmt.Sprintf(`
 		SELECT id FROM %s
 		WHERE chain_name = $1
 		ORDER BY id DESC
 		LIMIT 1
-	`, table), chainName)
-	var lastID int
-	err := row.Scan(&lastID)
+	`, t), params.ChainName)
+	err := row.Scan(&params.LastId)
 	if errors.Is(err, sql.ErrNoRows) {
 		l.Warn("no rows matched")
-		return nil
+		return false, nil
 	}
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) && pgErr.Code == relationshipNotFoundErrorCode {
 		l.Warn("table doesn't exist")
-		return nil
+		return false, nil
 	}
 	if err != nil {
-		return fmt.Errorf("fetching latest id: %w", err)
+		return false, fmt.Errorf("fetching latest id: %w", err)
 	}
 
-	// loop until all rows are deleted
+	return true, nil
+}
+
+// processChunks runs query until no rows are left, advancing the last_id cursor of params,
+// and returns the number of rows processed.
+// Rows returned by query are written to archive, if set.
+func processChunks(l *zap.SugaredLogger, db *sqlx.DB, t Table, query string, params baseQueryParams, archive *Archive) (int, error) {
+	processed := 0
+
+	// loop until all rows are processed
 	for {
-		rows, err := db.NamedQuery(fmt.Sprintf(`
-			DELETE FROM %s
-			WHERE id <= :last_id AND chain_name = :chain_name
-			ORDER BY id DESC
-			LIMIT :limit
-			RETURNING id
-		`, table), baseQueryParams{
-			LastId:    lastID,
-			ChainName: chainName,
-			Limit:     chunkSize,
-		})
+		n, err := processChunk(db, t, query, &params, archive)
 		if err != nil {
-			return err
+			return processed, err
 		}
 
-		cont := rows.Next()
-		if !cont {
-			break
+		if n == 0 {
+			return processed, nil
 		}
 
-		err = rows.Scan(&lastID)
-		if err != nil {
-			return fmt.Errorf("cannot scan row at lastID=%d: %w", lastID, err)
+		processed += n
+		l.Infow("processed chunk", "lastId", strconv.Itoa(params.LastId), "rows", processed)
+	}
+}
+
+// processChunk runs query once and returns the number of rows it processed.
+// Chunks are processed in descending id order, any id processed is a cursor below the rows left.
+func processChunk(db *sqlx.DB, t Table, query string, params *baseQueryParams, archive *Archive) (int, error) {
+	rows, err := db.NamedQuery(query, *params)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	n := 0
+	for rows.Next() {
+		// jsonb is scanned as text by some drivers
+		var row []byte
+		dest := []interface{}{&params.LastId}
+		if archive != nil {
+			dest = append(dest, &row)
 		}
 
-		err = rows.Close()
-		if err != nil {
-			return fmt.Errorf("closing rows object: %w", err)
+		if err := rows.Scan(dest...); err != nil {
+			return n, fmt.Errorf("cannot scan row at lastID=%d: %w", params.LastId, err)
+		}
+
+		if archive != nil {
+			if err := archive.Write(t.Name, json.RawMessage(row)); err != nil {
+				return n, err
+			}
 		}
 
-		l.Infow("deleted chunk", "lastId", strconv.Itoa(lastID))
+		n++
 	}
 
-	return nil
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+
+	if err := rows.Close(); err != nil {
+		return n, fmt.Errorf("closing rows object: %w", err)
+	}
+
+	return n, nil
 }
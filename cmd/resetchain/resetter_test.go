@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cockroachdb/cockroach-go/v2/testserver"
 	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -66,6 +69,14 @@ func addRows(t *testing.T, db *database.Instance, tableName, chainName string, r
 	}
 }
 
+// currentDatabase returns the database tests connect to, holding the tables they create
+// as tracelistener's does on CockroachDB.
+func currentDatabase(t *testing.T, db *database.Instance) string {
+	var name string
+	require.NoError(t, db.DB.Get(&name, "SELECT current_database()"))
+	return name
+}
+
 func countRows(t *testing.T, db *database.Instance, tableName, chainName string) int {
 	var count int
 	err := db.DB.Get(&count, fmt.Sprintf("SELECT count(*) from %s WHERE chain_name = $1", tableName), chainName)
@@ -105,7 +116,7 @@ func TestResetTable_ChunkSize(t *testing.T) {
 			require.Equal(13, count)
 
 			// act
-			err := ResetTable(l, DB.DB, tableName, "chain-a", tt.chunkSize)
+			err := ResetTable(l, DB.DB, Table{Name: tableName, ID: true}, "chain-a", tt.chunkSize, nil)
 			require.NoError(err)
 
 			// require
@@ -118,12 +129,497 @@ func TestResetTable_ChunkSize(t *testing.T) {
 }
 
 func TestResetTable_IgnoreNonExistentTables(t *testing.T) {
-	err := ResetTable(l, DB.DB, "something_non_existent", "chain-a", 1)
+	err := ResetTable(l, DB.DB, Table{Name: "something_non_existent", ID: true}, "chain-a", 1, nil)
 	require.NoError(t, err)
 }
 
 func TestResetTable_AlreadyEmptyTable(t *testing.T) {
 	createTables(t, DB, "empty_table")
-	err := ResetTable(l, DB.DB, "empty_table", "chain-a", 1)
+	err := ResetTable(l, DB.DB, Table{Name: "empty_table", ID: true}, "chain-a", 1, nil)
+	require.NoError(t, err)
+}
+
+func createVersionedTable(t *testing.T, db *database.Instance, name string) {
+	_, err := db.DB.Exec(fmt.Sprintf(`
+		CREATE TABLE %s (id serial, chain_name text, height int, delete_height int)
+	`, name))
+	require.NoError(t, err)
+}
+
+func versionedTable(name string) Table {
+	return Table{Name: name, ID: true, Height: true, DeleteHeight: true}
+}
+
+func addVersionedRow(t *testing.T, db *database.Instance, tableName, chainName string, height int, deleteHeight *int) {
+	_, err := db.DB.Exec(fmt.Sprintf("INSERT INTO %s (chain_name, height, delete_height) VALUES ($1, $2, $3)", tableName), chainName, height, deleteHeight)
+	require.NoError(t, err)
+}
+
+func TestRollbackTable(t *testing.T) {
+	deleteHeight := func(h int) *int {
+		return &h
+	}
+
+	tests := []struct {
+		name      string
+		chunkSize int
+	}{
+		{
+			name:      "small chunk size (1)",
+			chunkSize: 1,
+		},
+		{
+			name:      "large chunk size (10k)",
+			chunkSize: 10000,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			tableName := fmt.Sprintf("test_table_%d", rand.Int())
+			createVersionedTable(t, DB, tableName)
+
+			// kept as they are
+			addVersionedRow(t, DB, tableName, "chain-a", 5, nil)
+			addVersionedRow(t, DB, tableName, "chain-a", 10, nil)
+			addVersionedRow(t, DB, tableName, "chain-a", 8, deleteHeight(8))
+			// deleted after height, un-deleted at height
+			addVersionedRow(t, DB, tableName, "chain-a", 15, deleteHeight(15))
+			// deleted
+			addVersionedRow(t, DB, tableName, "chain-a", 11, nil)
+			addVersionedRow(t, DB, tableName, "chain-a", 20, nil)
+			// other chain
+			addVersionedRow(t, DB, tableName, "chain-b", 20, deleteHeight(20))
+
+			err := RollbackTable(l, DB.DB, versionedTable(tableName), "chain-a", 10, tt.chunkSize, nil)
+			require.NoError(err)
+
+			type row struct {
+				Height       int  `db:"height"`
+				DeleteHeight *int `db:"delete_height"`
+			}
+
+			var rows []row
+			require.NoError(DB.DB.Select(&rows, fmt.Sprintf("SELECT height, delete_height FROM %s WHERE chain_name = $1 ORDER BY id", tableName), "chain-a"))
+			require.Equal([]row{
+				{Height: 5},
+				{Height: 10},
+				{Height: 8, DeleteHeight: deleteHeight(8)},
+				{Height: 10},
+			}, rows)
+
+			var other []row
+			require.NoError(DB.DB.Select(&other, fmt.Sprintf("SELECT height, delete_height FROM %s WHERE chain_name = $1", tableName), "chain-b"))
+			require.Equal([]row{{Height: 20, DeleteHeight: deleteHeight(20)}}, other, "chain-b rows changed but they were not supposed to")
+		})
+	}
+}
+
+// createHistoryTables creates a table with a history table, as sqlgen does, and returns it.
+func createHistoryTables(t *testing.T, db *database.Instance) Table {
+	name := fmt.Sprintf("test_table_%d", rand.Int())
+	_, err := db.DB.Exec(fmt.Sprintf(`
+		CREATE TABLE %s (id serial, chain_name text, height int, delete_height int, address text, amount text, UNIQUE (chain_name, address))
+	`, name))
+	require.NoError(t, err)
+
+	_, err = db.DB.Exec(fmt.Sprintf(`
+		CREATE TABLE %s_history (id serial, chain_name text, address text, height int, tx_hash text, operation text, old_value jsonb, new_value jsonb)
+	`, name))
+	require.NoError(t, err)
+
+	return Table{Name: name, ID: true, Height: true, DeleteHeight: true, HistoryKeys: []string{"chain_name", "address"}}
+}
+
+func TestRollbackTable_History(t *testing.T) {
+	require := require.New(t)
+
+	table := createHistoryTables(t, DB)
+
+	type row struct {
+		Address      string `db:"address"`
+		Height       int    `db:"height"`
+		DeleteHeight *int   `db:"delete_height"`
+		Amount       string `db:"amount"`
+	}
+
+	history := func(address string, height int, operation, amount string) {
+		var newValue interface{}
+		if operation == "write" {
+			newValue = fmt.Sprintf(`{"chain_name": "chain-a", "address": %q, "height": %d, "amount": %q}`, address, height, amount)
+		}
+
+		_, err := DB.DB.Exec(fmt.Sprintf("INSERT INTO %s (chain_name, address, height, operation, new_value) VALUES ('chain-a', $1, $2, $3, $4)", table.HistoryName()), address, height, operation, newValue)
+		require.NoError(err)
+	}
+
+	add := func(r row) {
+		_, err := DB.DB.Exec(fmt.Sprintf("INSERT INTO %s (chain_name, address, height, delete_height, amount) VALUES ('chain-a', $1, $2, $3, $4)", table.Name), r.Address, r.Height, r.DeleteHeight, r.Amount)
+		require.NoError(err)
+	}
+
+	deleteHeight := func(h int) *int {
+		return &h
+	}
+
+	// kept as it is
+	history("kept", 5, "write", "1")
+	add(row{Address: "kept", Height: 5, Amount: "1"})
+
+	// restored to its value at height
+	history("updated", 5, "write", "1")
+	history("updated", 12, "write", "2")
+	add(row{Address: "updated", Height: 12, Amount: "2"})
+
+	history("deleted", 5, "write", "1")
+	history("deleted", 15, "delete", "")
+	add(row{Address: "deleted", Height: 15, DeleteHeight: deleteHeight(15), Amount: "1"})
+
+	// deleted, they didn't exist at height
+	history("created", 12, "write", "3")
+	add(row{Address: "created", Height: 12, Amount: "3"})
+
+	history("transient", 11, "write", "4")
+	history("transient", 13, "delete", "")
+	add(row{Address: "transient", Height: 13, DeleteHeight: deleteHeight(13), Amount: "4"})
+
+	history("recreated", 3, "write", "5")
+	history("recreated", 8, "delete", "")
+	history("recreated", 14, "write", "6")
+	add(row{Address: "recreated", Height: 14, Amount: "6"})
+
+	r := Resetter{
+		Logger:    l,
+		DB:        DB.DB,
+		ChainName: "chain-a",
+		ChunkSize: 1,
+		Tables:    []Table{table},
+		ToHeight:  10,
+		Schema:    currentDatabase(t, DB),
+	}
+
+	counts, err := r.Count()
+	require.NoError(err)
+	require.Equal([]TableCount{{Table: table.Name, Restored: 2, Deleted: 3}}, counts)
+
+	require.NoError(r.Reset())
+
+	var rows []row
+	require.NoError(DB.DB.Select(&rows, fmt.Sprintf("SELECT address, height, delete_height, amount FROM %s ORDER BY address", table.Name)))
+	require.Equal([]row{
+		{Address: "deleted", Height: 5, Amount: "1"},
+		{Address: "kept", Height: 5, Amount: "1"},
+		{Address: "updated", Height: 5, Amount: "1"},
+	}, rows)
+}
+
+func TestRollbackTable_NoDeleteHeight(t *testing.T) {
+	tableName := fmt.Sprintf("test_table_%d", rand.Int())
+	_, err := DB.DB.Exec(fmt.Sprintf("CREATE TABLE %s (id serial, chain_name text, height int)", tableName))
 	require.NoError(t, err)
+
+	for _, h := range []int{5, 15} {
+		_, err := DB.DB.Exec(fmt.Sprintf("INSERT INTO %s (chain_name, height) VALUES ($1, $2)", tableName), "chain-a", h)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, RollbackTable(l, DB.DB, Table{Name: tableName, ID: true, Height: true}, "chain-a", 10, 1, nil))
+	require.Equal(t, 1, countRows(t, DB, tableName, "chain-a"))
+}
+
+func TestDiscoverTables(t *testing.T) {
+	require := require.New(t)
+
+	suffix := rand.Int()
+	versioned := fmt.Sprintf("test_versioned_%d", suffix)
+	createVersionedTable(t, DB, versioned)
+	_, err := DB.DB.Exec(fmt.Sprintf("CREATE INDEX %[1]s_chain_name_id_idx ON %[1]s (chain_name, id)", versioned))
+	require.NoError(err)
+
+	keyless := fmt.Sprintf("test_keyless_%d", suffix)
+	_, err = DB.DB.Exec(fmt.Sprintf("CREATE TABLE %s (chain_name text, height int)", keyless))
+	require.NoError(err)
+
+	other := fmt.Sprintf("test_other_%d", suffix)
+	_, err = DB.DB.Exec(fmt.Sprintf("CREATE TABLE %s (id serial, name text)", other))
+	require.NoError(err)
+
+	withHistory := createHistoryTables(t, DB)
+
+	view := fmt.Sprintf("test_view_%d", suffix)
+	_, err = DB.DB.Exec(fmt.Sprintf("CREATE VIEW %s AS SELECT id, chain_name FROM %s", view, versioned))
+	require.NoError(err)
+
+	schema := currentDatabase(t, DB)
+	discovered, err := DiscoverTables(DB.DB, schema)
+	require.NoError(err)
+
+	byName := map[string]Table{}
+	for _, t := range discovered {
+		byName[t.Name] = t
+	}
+
+	qualified := func(name string) string {
+		return schema + "." + name
+	}
+
+	withHistory.Name = qualified(withHistory.Name)
+
+	require.Equal(Table{Name: qualified(versioned), Index: versioned + "_chain_name_id_idx", ID: true, Height: true, DeleteHeight: true}, byName[qualified(versioned)])
+	require.Equal(Table{Name: qualified(keyless), Height: true}, byName[qualified(keyless)])
+	require.Equal(withHistory, byName[withHistory.Name])
+	require.NotContains(byName, withHistory.HistoryName(), "history tables aren't discovered")
+	require.NotContains(byName, qualified(other), "tables without chain_name aren't discovered")
+	require.NotContains(byName, qualified(view), "views aren't discovered")
+
+	selected := SelectTables(l, discovered, []string{keyless, qualified(versioned) + "@primary", "something_non_existent"})
+	require.Equal([]Table{
+		{Name: qualified(keyless), Height: true},
+		{Name: qualified(versioned), Index: "primary", ID: true, Height: true, DeleteHeight: true},
+	}, selected)
+}
+
+func TestResetter_Count(t *testing.T) {
+	require := require.New(t)
+
+	tableName := fmt.Sprintf("test_table_%d", rand.Int())
+	createVersionedTable(t, DB, tableName)
+
+	h := 15
+	addVersionedRow(t, DB, tableName, "chain-a", 5, nil)
+	addVersionedRow(t, DB, tableName, "chain-a", 11, nil)
+	addVersionedRow(t, DB, tableName, "chain-a", 15, &h)
+	addVersionedRow(t, DB, tableName, "chain-b", 20, nil)
+
+	r := Resetter{
+		Logger:    l,
+		DB:        DB.DB,
+		ChainName: "chain-a",
+		ChunkSize: 1,
+		Tables:    []Table{versionedTable(tableName)},
+	}
+
+	counts, err := r.Count()
+	require.NoError(err)
+	require.Equal([]TableCount{{Table: tableName, Deleted: 3}}, counts)
+
+	r.ToHeight = 10
+	counts, err = r.Count()
+	require.NoError(err)
+	require.Equal([]TableCount{{Table: tableName, Restored: 1, Deleted: 1}}, counts)
+
+	require.Equal(3, countRows(t, DB, tableName, "chain-a"), "rows changed by a dry run")
+}
+
+func TestResetTable_ArchiveRestore(t *testing.T) {
+	require := require.New(t)
+
+	versioned := fmt.Sprintf("test_table_%d", rand.Int())
+	createVersionedTable(t, DB, versioned)
+	addVersionedRow(t, DB, versioned, "chain-a", 5, nil)
+	addVersionedRow(t, DB, versioned, "chain-a", 11, nil)
+	addVersionedRow(t, DB, versioned, "chain-a", 12, nil)
+	addVersionedRow(t, DB, versioned, "chain-b", 12, nil)
+
+	keyless := fmt.Sprintf("test_keyless_%d", rand.Int())
+	_, err := DB.DB.Exec(fmt.Sprintf("CREATE TABLE %s (chain_name text PRIMARY KEY, height int, value jsonb, data bytea)", keyless))
+	require.NoError(err)
+	_, err = DB.DB.Exec(fmt.Sprintf(`INSERT INTO %s VALUES ('chain-a', 11, '{"a": [1, "b"]}', '\x0102'), ('chain-b', 11, '{}', NULL)`, keyless))
+	require.NoError(err)
+
+	type snapshot struct {
+		Versioned []string
+		Keyless   []string
+	}
+
+	snap := func() snapshot {
+		var s snapshot
+		require.NoError(DB.DB.Select(&s.Versioned, fmt.Sprintf("SELECT CAST(to_jsonb(t) AS STRING) FROM %s AS t ORDER BY id", versioned)))
+		require.NoError(DB.DB.Select(&s.Keyless, fmt.Sprintf("SELECT CAST(to_jsonb(t) AS STRING) FROM %s AS t ORDER BY chain_name", keyless)))
+		return s
+	}
+
+	before := snap()
+
+	path := filepath.Join(t.TempDir(), "archive.jsonl.gz")
+	archive, err := NewArchive(path)
+	require.NoError(err)
+
+	require.NoError(RollbackTable(l, DB.DB, versionedTable(versioned), "chain-a", 10, 1, archive))
+	require.NoError(ResetTable(l, DB.DB, Table{Name: keyless, Height: true}, "chain-a", 1, archive))
+	require.NoError(archive.Close())
+
+	require.Equal(1, countRows(t, DB, versioned, "chain-a"))
+	require.Equal(0, countRows(t, DB, keyless, "chain-a"))
+
+	var entries []ArchiveEntry
+	require.NoError(ReadArchive(path, func(e ArchiveEntry) error {
+		entries = append(entries, e)
+		return nil
+	}))
+	require.Len(entries, 3)
+
+	_, err = NewArchive(path)
+	require.Error(err, "existing archives aren't overwritten")
+
+	restored, err := Restore(l, DB.DB, path, 2)
+	require.NoError(err)
+	require.Equal(3, restored)
+	require.Equal(before, snap())
+
+	// restored rows are there again
+	_, err = Restore(l, DB.DB, path, 2)
+	require.Error(err)
+}
+
+func TestResetter_Bookkeeping(t *testing.T) {
+	require := require.New(t)
+
+	// tables live in a schema of the database, as on PostgreSQL
+	schema := fmt.Sprintf("test_schema_%d", rand.Int())
+	for _, stmt := range []string{
+		`CREATE SCHEMA %[1]s`,
+		`CREATE TABLE %[1]s.balances (id serial, chain_name text, height int, delete_height int, address text, denom text, amount_numeric decimal)`,
+		`CREATE TABLE %[1]s.denom_totals (chain_name text NOT NULL, denom text NOT NULL, height int NOT NULL, total_amount decimal NOT NULL, holders int NOT NULL, PRIMARY KEY (chain_name, denom))`,
+		`CREATE TABLE %[1]s.checkpoints (id serial, chain_name text NOT NULL UNIQUE, height int NOT NULL)`,
+		`CREATE TABLE %[1]s.block_summaries (chain_name text NOT NULL, height int NOT NULL)`,
+		`INSERT INTO %[1]s.balances (chain_name, height, address, denom, amount_numeric) VALUES ('chain-a', 5, 'a', 'stake', 10), ('chain-a', 12, 'b', 'stake', 20), ('chain-b', 12, 'c', 'stake', 40)`,
+		`INSERT INTO %[1]s.denom_totals VALUES ('chain-a', 'stake', 12, 30, 2), ('chain-b', 'stake', 12, 40, 1)`,
+		`INSERT INTO %[1]s.checkpoints (chain_name, height) VALUES ('chain-a', 12), ('chain-b', 12)`,
+		`INSERT INTO %[1]s.block_summaries VALUES ('chain-a', 5), ('chain-a', 12)`,
+	} {
+		_, err := DB.DB.Exec(fmt.Sprintf(stmt, schema))
+		require.NoError(err, stmt)
+	}
+
+	discovered, err := DiscoverTables(DB.DB, schema)
+	require.NoError(err)
+	require.Equal([]Table{{Name: schema + ".balances", ID: true, Height: true, DeleteHeight: true}}, discovered)
+
+	type total struct {
+		ChainName string `db:"chain_name"`
+		Amount    string `db:"total_amount"`
+		Holders   int    `db:"holders"`
+	}
+
+	totals := func() []total {
+		var res []total
+		require.NoError(DB.DB.Select(&res, fmt.Sprintf("SELECT chain_name, CAST(total_amount AS STRING) AS total_amount, holders FROM %s.denom_totals ORDER BY chain_name", schema)))
+		return res
+	}
+
+	checkpoint := func(chainName string) int {
+		var h int
+		require.NoError(DB.DB.Get(&h, fmt.Sprintf("SELECT height FROM %s.checkpoints WHERE chain_name = $1", schema), chainName))
+		return h
+	}
+
+	r := Resetter{
+		Logger:    l,
+		DB:        DB.DB,
+		ChainName: "chain-a",
+		ChunkSize: 1,
+		Tables:    discovered,
+		ToHeight:  10,
+		Schema:    schema,
+	}
+
+	// a rollback rebuilds the aggregates and moves the checkpoint back
+	require.NoError(r.Reset())
+	require.Equal([]total{{"chain-a", "10", 1}, {"chain-b", "40", 1}}, totals())
+	require.Equal(10, checkpoint("chain-a"))
+	require.Equal(12, checkpoint("chain-b"))
+	require.Equal(2, countRows(t, DB, schema+".block_summaries", "chain-a"))
+
+	// a reset empties the aggregates of the chain, but keeps its checkpoint
+	r.ToHeight = 0
+	require.NoError(r.Reset())
+	require.Equal([]total{{"chain-b", "40", 1}}, totals())
+	require.Equal(10, checkpoint("chain-a"))
+	require.Equal(2, countRows(t, DB, schema+".block_summaries", "chain-a"))
+}
+
+func TestResetter_DelegatedTokens(t *testing.T) {
+	require := require.New(t)
+
+	schema := fmt.Sprintf("test_derived_%d", rand.Int())
+	_, err := DB.DB.Exec(fmt.Sprintf("CREATE DATABASE %s", schema))
+	require.NoError(err)
+
+	delegations := tables.NewDelegationsTable(schema + ".delegations")
+	validators := tables.NewValidatorsTable(schema + ".validators")
+
+	stmts := append(append(delegations.Schema(), delegations.HistorySchema()...), validators.Schema()...)
+	stmts = append(stmts, fmt.Sprintf(`CREATE TABLE %s.delegator_totals (chain_name text NOT NULL, delegator_address text NOT NULL, height int NOT NULL, delegated_tokens decimal NOT NULL, PRIMARY KEY (chain_name, delegator_address))`, schema))
+	for _, stmt := range stmts {
+		_, err := DB.DB.Exec(stmt)
+		require.NoError(err, stmt)
+	}
+
+	_, err = DB.DB.NamedExec(validators.Upsert(), models.ValidatorRow{
+		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain-a", Height: 5},
+		ValidatorAddress:         "validator",
+		Tokens:                   "1000",
+		TokensNumeric:            "1000",
+		DelegatorShares:          "1000",
+		DelegatorSharesNumeric:   "1000",
+	})
+	require.NoError(err)
+
+	// delegations are written along with their history, delegated tokens being computed afterwards
+	delegate := func(height uint64, amount string) {
+		row := models.DelegationRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain-a", Height: height},
+			Delegator:                "delegator",
+			Validator:                "validator",
+			Amount:                   amount,
+			AmountNumeric:            models.Numeric(amount),
+		}
+
+		for _, stmt := range []string{delegations.InsertHistory(), delegations.Upsert()} {
+			_, err := DB.DB.NamedExec(stmt, row)
+			require.NoError(err, stmt)
+		}
+
+		_, err := DB.DB.Exec(fmt.Sprintf("UPDATE %s SET delegated_tokens = amount_numeric", delegations.Name()))
+		require.NoError(err)
+	}
+
+	delegate(5, "100")
+	delegate(12, "300")
+
+	_, err = DB.DB.Exec(fmt.Sprintf("INSERT INTO %s.delegator_totals VALUES ('chain-a', 'delegator', 12, 300)", schema))
+	require.NoError(err)
+
+	discovered, err := DiscoverTables(DB.DB, schema)
+	require.NoError(err)
+
+	r := Resetter{
+		Logger:    l,
+		DB:        DB.DB,
+		ChainName: "chain-a",
+		ChunkSize: 1,
+		Tables:    discovered,
+		ToHeight:  10,
+		Schema:    schema,
+	}
+	require.NoError(r.Reset())
+
+	// history tables don't record delegated tokens, they're recomputed from the restored shares
+	var restored struct {
+		Height uint64 `db:"height"`
+		Amount string `db:"amount"`
+		Tokens bool   `db:"tokens"`
+	}
+	require.NoError(DB.DB.Get(&restored, fmt.Sprintf("SELECT height, amount, delegated_tokens = 100 AS tokens FROM %s", delegations.Name())))
+	require.Equal(uint64(5), restored.Height)
+	require.Equal("100", restored.Amount)
+	require.True(restored.Tokens)
+
+	var total bool
+	require.NoError(DB.DB.Get(&total, fmt.Sprintf("SELECT delegated_tokens = 100 FROM %s.delegator_totals WHERE chain_name = 'chain-a'", schema)))
+	require.True(total)
 }
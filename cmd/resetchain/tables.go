@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Table is a table holding rows of several chains, told apart by their chain_name column.
+type Table struct {
+	Name string
+
+	// Index, if set, is the index on (chain_name, id) the statements are hinted to use.
+	Index string
+
+	// ID, Height and DeleteHeight are set if the table has the column of the same name.
+	ID           bool
+	Height       bool
+	DeleteHeight bool
+
+	// HistoryKeys, if the table has an append-only <table>_history table, holds the columns
+	// its rows are identified with in there.
+	HistoryKeys []string
+}
+
+const historySuffix = "_history"
+
+// HistoryName returns the name of the history table of t.
+func (t Table) HistoryName() string {
+	return t.Name + historySuffix
+}
+
+// historyColumns are the columns of history tables which don't identify a row of the table
+// they record the changes of.
+var historyColumns = map[string]bool{
+	"id":        true,
+	"height":    true,
+	"tx_hash":   true,
+	"operation": true,
+	"old_value": true,
+	"new_value": true,
+}
+
+// String returns the name of t along with its index hint, if any.
+func (t Table) String() string {
+	if t.Index == "" {
+		return t.Name
+	}
+
+	return t.Name + "@" + t.Index
+}
+
+// DefaultSchema is the database, or schema depending on the dialect, holding tracelistener
+// tables when its configuration doesn't name another one.
+const DefaultSchema = "tracelistener"
+
+// bookkeepingTables hold what tracelistener records about the rows of the chains rather than
+// the rows themselves, and are never discovered: aggregates are rebuilt instead, see
+// RebuildAggregates, the checkpoint is moved by a rollback, and the others are logs.
+// History tables, named <table>_history, are logs too.
+var bookkeepingTables = map[string]bool{
+	"denom_totals":     true,
+	"delegator_totals": true,
+	"validator_powers": true,
+	"checkpoints":      true,
+	"import_cursors":   true,
+	"block_summaries":  true,
+	"state_changes":    true,
+}
+
+const (
+	// schemaCondition matches the information_schema rows of the tables qualified with $1:
+	// the ones of the database $1 on CockroachDB, and of the schema $1 on PostgreSQL.
+	schemaCondition = `((%[1]s.table_catalog = $1 AND %[1]s.table_schema = 'public')
+		OR (%[1]s.table_catalog = current_database() AND %[1]s.table_schema = $1))`
+
+	// discoverColumns lists the columns of the tables of a schema, in order.
+	discoverColumns = `
+		SELECT c.table_name, c.column_name
+		FROM information_schema.columns AS c
+		JOIN information_schema.tables AS t
+		ON t.table_catalog = c.table_catalog AND t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE ` + schemaCondition + `
+		AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`
+
+	// discoverIndexes lists the (chain_name, id) indexes sqlgen creates for its tables.
+	discoverIndexes = `
+		SELECT DISTINCT s.table_name, s.index_name
+		FROM information_schema.statistics AS s
+		WHERE ` + schemaCondition + `
+		AND s.index_name = s.table_name || '_chain_name_id_idx'
+	`
+)
+
+// DiscoverTables returns the tables of schema holding rows of several chains, the ones created
+// by the processors and sqlgen, qualified with schema and sorted by name.
+// Bookkeeping tables are left out.
+func DiscoverTables(db *sqlx.DB, schema string) ([]Table, error) {
+	var columns []struct {
+		Table  string `db:"table_name"`
+		Column string `db:"column_name"`
+	}
+	if err := db.Select(&columns, fmt.Sprintf(discoverColumns, "c"), schema); err != nil {
+		return nil, fmt.Errorf("cannot list table columns: %w", err)
+	}
+
+	var indexes []struct {
+		Table string `db:"table_name"`
+		Index string `db:"index_name"`
+	}
+	if err := db.Select(&indexes, fmt.Sprintf(discoverIndexes, "s"), schema); err != nil {
+		return nil, fmt.Errorf("cannot list table indexes: %w", err)
+	}
+
+	tables := map[string]*Table{}
+	chainTables := map[string]bool{}
+	historyKeys := map[string][]string{}
+	for _, c := range columns {
+		t, ok := tables[c.Table]
+		if !ok {
+			t = &Table{Name: c.Table}
+			tables[c.Table] = t
+		}
+
+		if !historyColumns[c.Column] {
+			historyKeys[c.Table] = append(historyKeys[c.Table], c.Column)
+		}
+
+		switch c.Column {
+		case "chain_name":
+			chainTables[c.Table] = true
+		case "id":
+			t.ID = true
+		case "height":
+			t.Height = true
+		case "delete_height":
+			t.DeleteHeight = true
+		}
+	}
+
+	for _, i := range indexes {
+		if t, ok := tables[i.Table]; ok {
+			t.Index = i.Index
+		}
+	}
+
+	res := make([]Table, 0, len(chainTables))
+	for name := range chainTables {
+		if bookkeepingTables[name] || strings.HasSuffix(name, historySuffix) {
+			continue
+		}
+
+		t := *tables[name]
+		if _, ok := tables[name+historySuffix]; ok {
+			t.HistoryKeys = historyKeys[name+historySuffix]
+		}
+
+		t.Name = schema + "." + name
+		res = append(res, t)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+// SelectTables returns the tables among discovered named by names, qualified or not, all of
+// them if names is empty. Names can carry an index hint, as in table@index, which replaces the
+// discovered one. Tables which weren't discovered are skipped.
+func SelectTables(l *zap.SugaredLogger, discovered []Table, names []string) []Table {
+	if len(names) == 0 {
+		return discovered
+	}
+
+	byName := map[string]Table{}
+	for _, t := range discovered {
+		byName[t.Name] = t
+		byName[t.Name[strings.LastIndex(t.Name, ".")+1:]] = t
+	}
+
+	res := make([]Table, 0, len(names))
+	for _, n := range names {
+		name, index, hinted := strings.Cut(n, "@")
+
+		t, ok := byName[name]
+		if !ok {
+			l.Warnw("table doesn't exist", "table", name)
+			continue
+		}
+
+		if hinted {
+			t.Index = index
+		}
+
+		res = append(res, t)
+	}
+
+	return res
+}
@@ -3,22 +3,70 @@ package main
 import (
 	"fmt"
 	"strings"
+
+	"github.com/emerishq/tracelistener/database"
 )
 
 type YamlData struct {
 	Tables []TableConfig
 }
 
-type TableConfig struct {
+type ColumnConfig struct {
+	Name         string
+	Type         string
+	Primary      bool
+	SkipOnInsert bool `yaml:"skip_on_insert"`
+	Nullable     bool
+
+	// Field is the Go model field name, defaults to the camel-cased column name.
+	Field string
+	// GoType is the Go model field type, it can be omitted for types listed in defaultGoTypes.
+	GoType string `yaml:"go_type"`
+	// JSON is the json tag of the Go model field, defaults to the column name.
+	JSON string `yaml:"json"`
+	// Comment is set as the column comment in the database.
+	Comment string
+}
+
+// IndexConfig describes a secondary index of a table.
+type IndexConfig struct {
+	// Name defaults to <table>_<columns>_idx.
 	Name    string
-	Columns []struct {
-		Name         string
-		Type         string
-		Primary      bool
-		SkipOnInsert bool `yaml:"skip_on_insert"`
-		Nullable     bool
-	}
+	Columns []string `yaml:",flow"`
+	Unique  bool
+	// Where makes the index partial, e.g. "delete_height IS NULL".
+	Where string
+}
+
+// ModelConfig describes the Go struct generated for a table rows.
+type ModelConfig struct {
+	Name string
+	// Doc is appended to the struct name to form its doc comment.
+	Doc string
+}
+
+type TableConfig struct {
+	Name          string
+	Columns       []ColumnConfig
 	UniqueColumns []string `yaml:"unique_columns,flow"`
+
+	// Model enables the generation of a Go struct for the table rows, along
+	// with typed query helpers.
+	Model *ModelConfig
+
+	// History enables an append-only <table>_history table, which receives
+	// a versioned row for each write or delete operated on the table.
+	// It's only created and written when Processor.HistoryEnabled is set.
+	History bool
+
+	Indexes []IndexConfig
+
+	// CurrentView enables a <table>_current view exposing the live rows only,
+	// that is the ones with a NULL delete_height.
+	CurrentView bool `yaml:"current_view"`
+
+	// Comment is set as the table comment in the database.
+	Comment string
 }
 
 func (t TableConfig) Validate() error {
@@ -36,6 +84,12 @@ func (t TableConfig) Validate() error {
 		if c.Primary && c.Nullable {
 			return fmt.Errorf("primary column %s cannot be nullable", c.Name)
 		}
+		if strings.Contains(c.Comment, "`") {
+			return fmt.Errorf("column %s comment cannot contain backticks", c.Name)
+		}
+		if t.History && strings.HasSuffix(c.Type, "[]") {
+			return fmt.Errorf("array column %s is not supported in history tables", c.Name)
+		}
 		names[c.Name] = true
 	}
 
@@ -45,13 +99,37 @@ func (t TableConfig) Validate() error {
 		}
 	}
 
+	if t.History && len(t.UniqueColumns) == 0 {
+		return fmt.Errorf("history table %s must define unique columns", t.Name)
+	}
+
+	if err := t.validateIndexes(names); err != nil {
+		return err
+	}
+
+	if t.CurrentView && t.columnType("delete_height") == "" {
+		return fmt.Errorf("current view of table %s requires a delete_height column", t.Name)
+	}
+
+	if strings.Contains(t.Comment, "`") {
+		return fmt.Errorf("table %s comment cannot contain backticks", t.Name)
+	}
+
+	if t.Model != nil {
+		if err := t.validateModel(); err != nil {
+			return fmt.Errorf("model for table %s: %w", t.Name, err)
+		}
+	}
+
 	return nil
 }
 
-func (t TableConfig) ColumnsDefinition() []string {
+// ColumnsDefinition returns the column definitions and constraints of the table, with types
+// translated to d.
+func (t TableConfig) ColumnsDefinition(d database.Dialect) []string {
 	res := make([]string, 0, len(t.Columns))
 	for _, c := range t.Columns {
-		def := c.Name + " " + c.Type
+		def := c.Name + " " + d.ColumnType(c.Type)
 		if c.Primary {
 			def += " PRIMARY KEY"
 		}
@@ -97,6 +175,22 @@ func (t TableConfig) UpsertSet() []string {
 	return res
 }
 
+func (t TableConfig) QuotedUniqueColumns() []string {
+	res := make([]string, 0, len(t.UniqueColumns))
+	for _, c := range t.UniqueColumns {
+		res = append(res, fmt.Sprintf("%q", c))
+	}
+	return res
+}
+
+func (t TableConfig) DeleteSet() []string {
+	res := []string{"delete_height = :height", "height = :height"}
+	if t.columnType("last_tx_hash") != "" {
+		res = append(res, "last_tx_hash = :last_tx_hash")
+	}
+	return res
+}
+
 func (t TableConfig) WhereConditions() []string {
 	res := make([]string, 0, len(t.UniqueColumns))
 	for _, c := range t.UniqueColumns {
@@ -104,3 +198,98 @@ func (t TableConfig) WhereConditions() []string {
 	}
 	return res
 }
+
+func (t TableConfig) columnType(name string) string {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c.Type
+		}
+	}
+	return ""
+}
+
+func (t TableConfig) HistoryColumnsDefinition(d database.Dialect) []string {
+	res := []string{"id " + d.ColumnType("serial") + " PRIMARY KEY NOT NULL"}
+	for _, c := range t.UniqueColumns {
+		res = append(res, c+" "+d.ColumnType(t.columnType(c))+" NOT NULL")
+	}
+	return append(res,
+		"height "+d.ColumnType("integer")+" NOT NULL",
+		"tx_hash text",
+		"operation text NOT NULL",
+		"old_value jsonb",
+		"new_value jsonb",
+	)
+}
+
+func (t TableConfig) HistoryIndexColumns() []string {
+	return append(append([]string{}, t.UniqueColumns...), "height")
+}
+
+func (t TableConfig) HistoryInsertColumns() []string {
+	return append(append([]string{}, t.UniqueColumns...), "height", "tx_hash", "operation", "old_value", "new_value")
+}
+
+// HistoryKeyValues returns the select list of the key columns of a history entry,
+// casting each parameter to its column type translated to d since there are no
+// VALUES to infer it from.
+func (t TableConfig) HistoryKeyValues(d database.Dialect) []string {
+	res := make([]string, 0, len(t.UniqueColumns)+2)
+	for _, c := range append(append([]string{}, t.UniqueColumns...), "height") {
+		res = append(res, fmt.Sprintf("CAST(:%s AS %s)", c, d.ColumnType(t.columnType(c))))
+	}
+	return append(res, "CAST(:last_tx_hash AS text)")
+}
+
+// KeyConditions returns the conditions selecting the row a history entry
+// refers to, deleted or not, aliasing the source table as "t".
+func (t TableConfig) KeyConditions() []string {
+	res := make([]string, 0, len(t.UniqueColumns))
+	for _, c := range t.UniqueColumns {
+		res = append(res, fmt.Sprintf("t.%s = :%s", c, c))
+	}
+	return res
+}
+
+// OldValueConditions returns the conditions selecting the live row a history
+// entry refers to, aliasing the source table as "t".
+func (t TableConfig) OldValueConditions() []string {
+	return append(t.KeyConditions(), "t.delete_height IS NULL")
+}
+
+// NewValueObject returns the jsonb_build_object arguments rendering the
+// inserted row, casting each parameter to its column type translated to d.
+func (t TableConfig) NewValueObject(d database.Dialect) []string {
+	cols := t.InsertColumns()
+	res := make([]string, 0, len(cols))
+	for _, c := range cols {
+		res = append(res, fmt.Sprintf("'%s', CAST(:%s AS %s)", c, c, d.ColumnType(t.columnType(c))))
+	}
+	return res
+}
+
+// OldValueObject returns the json_object arguments rendering the live row aliased
+// as "t", for the dialects lacking to_jsonb.
+func (t TableConfig) OldValueObject() []string {
+	res := make([]string, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		res = append(res, fmt.Sprintf("'%s', t.%s", c.Name, c.Name))
+	}
+	return res
+}
+
+// HistoryStateColumns returns the select list rebuilding a row from the
+// new_value of a history entry aliased as "h".
+func (t TableConfig) HistoryStateColumns(d database.Dialect) []string {
+	cols := t.InsertColumns()
+	res := make([]string, 0, len(cols))
+	for _, c := range cols {
+		ct := d.ColumnType(t.columnType(c))
+		if ct == "jsonb" {
+			res = append(res, fmt.Sprintf("h.new_value->'%s' AS %s", c, c))
+			continue
+		}
+		res = append(res, fmt.Sprintf("CAST(h.new_value->>'%s' AS %s) AS %s", c, ct, c))
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+func TestTableConfig_ValidateHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name: "history with unique columns",
+			config: `
+name: balances
+columns:
+  - name: chain_name
+    type: text
+  - name: amount
+    type: text
+unique_columns:
+  - chain_name
+history: true
+`,
+			wantErr: false,
+		},
+		{
+			name: "history without unique columns",
+			config: `
+name: balances
+columns:
+  - name: chain_name
+    type: text
+history: true
+`,
+			wantErr: true,
+		},
+		{
+			name: "history with array column",
+			config: `
+name: channels
+columns:
+  - name: chain_name
+    type: text
+  - name: hops
+    type: text[]
+unique_columns:
+  - chain_name
+history: true
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var table TableConfig
+			require.NoError(t, yaml.Unmarshal([]byte(tt.config), &table))
+
+			err := table.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTableConfig_NewValueObject(t *testing.T) {
+	var table TableConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: balances
+columns:
+  - name: id
+    type: serial
+    skip_on_insert: true
+  - name: height
+    type: integer
+  - name: amount
+    type: text
+`), &table))
+
+	require.Equal(t, []string{
+		"'height', CAST(:height AS integer)",
+		"'amount', CAST(:amount AS text)",
+	}, table.NewValueObject(database.DialectCockroachDB))
+
+	require.Equal(t, []string{
+		"'height', CAST(:height AS bigint)",
+		"'amount', CAST(:amount AS text)",
+	}, table.NewValueObject(database.DialectPostgres))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+// dialectIdents maps dialects to the name of their constant in the database package.
+var dialectIdents = map[database.Dialect]string{
+	database.DialectCockroachDB: "database.DialectCockroachDB",
+	database.DialectPostgres:    "database.DialectPostgres",
+	database.DialectSQLite:      "database.DialectSQLite",
+}
+
+// DialectParam is passed to the templates rendering a statement for a given dialect.
+type DialectParam struct {
+	TemplateParam
+	Dialect database.Dialect
+}
+
+// switchFunc returns a template function rendering the named template for every dialect,
+// as a switch on r.dialect returning the statements differing from the CockroachDB ones.
+// A single differing statement is returned from an if statement instead.
+func switchFunc(t **template.Template) func(string, TemplateParam) (string, error) {
+	return func(name string, p TemplateParam) (string, error) {
+		render := func(d database.Dialect) (string, error) {
+			buf := bytes.Buffer{}
+			if err := (*t).ExecuteTemplate(&buf, name, DialectParam{TemplateParam: p, Dialect: d}); err != nil {
+				return "", err
+			}
+
+			return strings.TrimSpace(buf.String()), nil
+		}
+
+		def, err := render(database.DialectCockroachDB)
+		if err != nil {
+			return "", err
+		}
+
+		// Dialects rendering the same statement share their case.
+		var bodies []string
+		cases := map[string][]string{}
+		for _, d := range database.Dialects {
+			if d == database.DialectCockroachDB {
+				continue
+			}
+
+			body, err := render(d)
+			if err != nil {
+				return "", err
+			}
+
+			if body == def {
+				continue
+			}
+
+			if _, ok := cases[body]; !ok {
+				bodies = append(bodies, body)
+			}
+			cases[body] = append(cases[body], dialectIdents[d])
+		}
+
+		if len(bodies) == 0 {
+			return "return " + def, nil
+		}
+
+		sb := strings.Builder{}
+		if len(bodies) == 1 {
+			conds := make([]string, 0, len(cases[bodies[0]]))
+			for _, ident := range cases[bodies[0]] {
+				conds = append(conds, "r.dialect == "+ident)
+			}
+
+			sb.WriteString(fmt.Sprintf("if %s {\nreturn %s\n}\n\nreturn %s", strings.Join(conds, " || "), bodies[0], def))
+			return sb.String(), nil
+		}
+
+		sb.WriteString("switch r.dialect {\n")
+		for _, body := range bodies {
+			sb.WriteString(fmt.Sprintf("case %s:\nreturn %s\n", strings.Join(cases[body], ", "), body))
+		}
+		sb.WriteString("}\n\nreturn " + def)
+
+		return sb.String(), nil
+	}
+}
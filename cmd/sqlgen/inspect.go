@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	selectColumns = `
+	SELECT table_name, column_name, udt_name, is_nullable
+	FROM information_schema.columns
+	WHERE table_catalog = $1 AND table_schema = 'public'
+	ORDER BY table_name, ordinal_position
+	`
+
+	selectUniqueConstraints = `
+	SELECT tc.table_name, tc.constraint_name, kcu.column_name
+	FROM information_schema.table_constraints tc
+	JOIN information_schema.key_column_usage kcu
+		ON tc.constraint_catalog = kcu.constraint_catalog
+		AND tc.constraint_schema = kcu.constraint_schema
+		AND tc.constraint_name = kcu.constraint_name
+		AND tc.table_name = kcu.table_name
+	WHERE tc.table_catalog = $1 AND tc.table_schema = 'public' AND tc.constraint_type = 'UNIQUE'
+	ORDER BY tc.table_name, tc.constraint_name, kcu.ordinal_position
+	`
+)
+
+type schemaColumn struct {
+	Table    string `db:"table_name"`
+	Name     string `db:"column_name"`
+	Type     string `db:"udt_name"`
+	Nullable string `db:"is_nullable"`
+}
+
+type uniqueColumn struct {
+	Table      string `db:"table_name"`
+	Constraint string `db:"constraint_name"`
+	Column     string `db:"column_name"`
+}
+
+// InspectDatabase reads the schema of the tables in dbName through information_schema.
+// Only tables listed in tables are returned.
+func InspectDatabase(connString, dbName string, tables []string) (YamlData, error) {
+	db, err := sqlx.Connect("pgx", connString)
+	if err != nil {
+		return YamlData{}, fmt.Errorf("cannot connect to database, %w", err)
+	}
+
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var columns []schemaColumn
+	if err := db.Select(&columns, selectColumns, dbName); err != nil {
+		return YamlData{}, fmt.Errorf("cannot read columns, %w", err)
+	}
+
+	var uniques []uniqueColumn
+	if err := db.Select(&uniques, selectUniqueConstraints, dbName); err != nil {
+		return YamlData{}, fmt.Errorf("cannot read unique constraints, %w", err)
+	}
+
+	wanted := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		wanted[t] = true
+	}
+
+	byName := map[string]int{}
+	var ret YamlData
+
+	for _, c := range columns {
+		if !wanted[c.Table] {
+			continue
+		}
+
+		idx, ok := byName[c.Table]
+		if !ok {
+			ret.Tables = append(ret.Tables, TableConfig{Name: c.Table})
+			idx = len(ret.Tables) - 1
+			byName[c.Table] = idx
+		}
+
+		ret.Tables[idx].Columns = append(ret.Tables[idx].Columns, ColumnConfig{
+			Name:     c.Name,
+			Type:     sqlType(c.Type),
+			Nullable: c.Nullable == "YES",
+		})
+	}
+
+	// sqlgen tables have a single unique constraint, named following the
+	// <table>_<columns>_key convention; keep the first one found.
+	constraints := map[string]string{}
+	for _, u := range uniques {
+		idx, ok := byName[u.Table]
+		if !ok || !strings.HasSuffix(u.Constraint, "_key") {
+			continue
+		}
+
+		if c, found := constraints[u.Table]; found && c != u.Constraint {
+			continue
+		}
+
+		constraints[u.Table] = u.Constraint
+		ret.Tables[idx].UniqueColumns = append(ret.Tables[idx].UniqueColumns, u.Column)
+	}
+
+	return ret, nil
+}
+
+// sqlType converts an information_schema udt_name to a type usable in DDL statements.
+func sqlType(udt string) string {
+	if strings.HasPrefix(udt, "_") {
+		return udt[1:] + "[]"
+	}
+
+	return udt
+}
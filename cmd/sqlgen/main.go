@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
+	"go/format"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"gopkg.in/yaml.v2"
+
+	"github.com/emerishq/tracelistener/database"
 )
 
 type TemplateParam struct {
@@ -36,44 +43,254 @@ func main() {
 		panic(err)
 	}
 
-	t, err := template.New("template").
-		Funcs(template.FuncMap{
-			"Join": func(s []string) string {
-				return strings.Join(s, ", ")
-			},
-			"JoinAnd": func(s []string) string {
-				return strings.Join(s, " AND ")
-			}}).
-		Parse(tmpl)
+	files, err := Render(yamlData, f.OutputDir, f.ModelsDir)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := os.MkdirAll(f.OutputDir, os.ModePerm); err != nil {
-		panic(err)
+	if f.Check {
+		if stale := Check(files); len(stale) > 0 {
+			fmt.Fprintln(os.Stderr, "generated files are not up to date with", f.ConfigPath+", run make sqlgen:")
+			for _, s := range stale {
+				fmt.Fprintln(os.Stderr, "\t"+s)
+			}
+			os.Exit(1)
+		}
+
+		return
 	}
 
-	for _, table := range yamlData.Tables {
-		if err := table.Validate(); err != nil {
+	for _, dir := range []string{f.OutputDir, f.ModelsDir} {
+		if dir == "" {
+			continue
+		}
+
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 			panic(err)
 		}
+	}
 
-		out := path.Join(f.OutputDir, getFileName(table.Name))
-		outFile, err := os.Create(out)
-		if err != nil {
+	for p, content := range files {
+		if err := os.WriteFile(p, content, 0600); err != nil {
 			panic(err)
 		}
+	}
+
+	if f.MigrationsDir == "" {
+		return
+	}
 
+	if err := generateMigrations(f, yamlData, configFile); err != nil {
+		panic(err)
+	}
+}
+
+// Render validates yamlData and returns the generated table and model files, keyed by path.
+// Model files are not rendered if modelsDir is empty.
+func Render(yamlData YamlData, tablesDir, modelsDir string) (map[string][]byte, error) {
+	var t *template.Template
+	funcs := template.FuncMap{
+		"Switch": switchFunc(&t),
+		"Join": func(s []string) string {
+			return strings.Join(s, ", ")
+		},
+		"JoinAnd": func(s []string) string {
+			return strings.Join(s, " AND ")
+		}}
+
+	t, err := template.New("template").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	mt, err := template.New("model").Funcs(funcs).Parse(modelTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range yamlData.Tables {
+		if err := table.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	files := map[string][]byte{}
+	for _, table := range yamlData.Tables {
 		params := TemplateParam{
 			PackageName: "tables",
 			StructName:  getStructName(table.Name),
 			Config:      table,
 		}
 
-		if err := t.Execute(outFile, params); err != nil {
-			panic(err)
+		if err := renderFile(files, path.Join(tablesDir, getFileName(table.Name)), t, params); err != nil {
+			return nil, err
+		}
+
+		if table.Model == nil || modelsDir == "" {
+			continue
+		}
+
+		if err := renderFile(files, path.Join(modelsDir, getFileName(table.Name)), mt, params); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+func renderFile(files map[string][]byte, p string, t *template.Template, params TemplateParam) error {
+	buf := bytes.Buffer{}
+	if err := t.Execute(&buf, params); err != nil {
+		return err
+	}
+
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("cannot format %s, %w", p, err)
+	}
+
+	files[p] = content
+	return nil
+}
+
+// Check returns the paths of files whose content on disk differs from the generated one.
+func Check(files map[string][]byte) []string {
+	var stale []string
+	for p, content := range files {
+		existing, err := os.ReadFile(p)
+		if err != nil || !bytes.Equal(existing, content) {
+			stale = append(stale, p)
+		}
+	}
+
+	sort.Strings(stale)
+	return stale
+}
+
+const snapshotFileName = "sqlmodels.snapshot.yaml"
+
+// generateMigrations writes a migration for the differences between yamlData and
+// the previous schema, then renders all the migrations in f.OutputDir.
+// The previous schema is read from the database if f.DatabaseURL is set, from the
+// snapshot stored in f.MigrationsDir otherwise; the snapshot is then replaced by configFile.
+func generateMigrations(f Flags, yamlData YamlData, configFile []byte) error {
+	if err := os.MkdirAll(f.MigrationsDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	snapshotPath := path.Join(f.MigrationsDir, snapshotFileName)
+
+	var (
+		previous    YamlData
+		hasPrevious bool
+	)
+
+	switch {
+	case f.DatabaseURL != "":
+		names := make([]string, 0, len(yamlData.Tables))
+		for _, t := range yamlData.Tables {
+			names = append(names, t.Name)
+		}
+
+		var err error
+		previous, err = InspectDatabase(f.DatabaseURL, f.DatabaseName, names)
+		if err != nil {
+			return err
+		}
+
+		hasPrevious = true
+	default:
+		snapshot, err := os.ReadFile(snapshotPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err == nil {
+			if err := yaml.Unmarshal(snapshot, &previous); err != nil {
+				return fmt.Errorf("cannot read snapshot, %w", err)
+			}
+
+			hasPrevious = true
 		}
 	}
+
+	if hasPrevious {
+		if err := writeMigrations(f, previous, yamlData); err != nil {
+			return err
+		}
+	}
+
+	if f.DatabaseURL == "" {
+		if err := os.WriteFile(snapshotPath, configFile, 0600); err != nil {
+			return fmt.Errorf("cannot write snapshot, %w", err)
+		}
+	}
+
+	migrations, err := LoadMigrations(f.MigrationsDir)
+	if err != nil {
+		return err
+	}
+
+	t, err := template.New("migrations").Parse(migrationsTmpl)
+	if err != nil {
+		return err
+	}
+
+	outFile, err := os.Create(path.Join(f.OutputDir, "migrations_gen.go"))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = outFile.Close()
+	}()
+
+	return t.Execute(outFile, struct {
+		PackageName string
+		Migrations  []MigrationFile
+	}{
+		PackageName: "tables",
+		Migrations:  migrations,
+	})
+}
+
+// writeMigrations writes a migration for each dialect, if previous and yamlData differ.
+func writeMigrations(f Flags, previous, yamlData YamlData) error {
+	// Generated migrations are qualified with the schema placeholder, replaced at runtime
+	// with the configured database name.
+	changes := Diff(previous, yamlData, database.SchemaPlaceholder, database.DialectCockroachDB, f.DatabaseURL == "")
+	if len(changes) == 0 {
+		fmt.Println("no schema changes")
+		return nil
+	}
+
+	version, err := strconv.ParseInt(time.Now().UTC().Format("20060102150405"), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range database.Dialects {
+		if d != database.DialectCockroachDB {
+			changes = Diff(previous, yamlData, database.SchemaPlaceholder, d, f.DatabaseURL == "")
+		}
+
+		out, err := WriteMigration(f.MigrationsDir, version, f.MigrationName, d, changes)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("migration written to", out)
+		for _, c := range changes {
+			switch {
+			case c.Unsupported != "":
+				fmt.Fprintf(os.Stderr, "UNSUPPORTED %s %s: %s\n", d, c.Table, c.Unsupported)
+			case c.Review != "" && d == database.DialectCockroachDB:
+				fmt.Fprintf(os.Stderr, "REVIEW %s: %s\n", c.Table, c.Review)
+			}
+		}
+	}
+
+	return nil
 }
 
 const structSuffix = "Table"
@@ -94,8 +311,14 @@ func getFileName(tableName string) string {
 }
 
 type Flags struct {
-	ConfigPath string
-	OutputDir  string
+	ConfigPath    string
+	OutputDir     string
+	ModelsDir     string
+	Check         bool
+	MigrationsDir string
+	MigrationName string
+	DatabaseURL   string
+	DatabaseName  string
 }
 
 func (f Flags) Validate() error {
@@ -111,16 +334,34 @@ func (f Flags) Validate() error {
 		return fmt.Errorf("missing output directory")
 	}
 
+	if len(f.MigrationsDir) > 0 {
+		if err := validateName(f.MigrationName); err != nil {
+			return fmt.Errorf("validating migration name %s: %w", f.MigrationName, err)
+		}
+	}
+
 	return nil
 }
 
 func GetFlags() Flags {
 	configPath := flag.String("config", "", "path to config file (yaml)")
 	outputDir := flag.String("out", "./tracelistener/tables", "path to a folder where will be generated into (default ./tracelistener/tables)")
+	modelsDir := flag.String("models", "", "path to the models package folder, where model structs are generated; if empty, models are not generated")
+	check := flag.Bool("check", false, "check that generated files are up to date instead of writing them, exit with an error otherwise")
+	migrationsDir := flag.String("migrations", "", "path to a folder holding schema migrations and the previous schema snapshot; if empty, migrations are not generated")
+	migrationName := flag.String("migration-name", "sqlmodels", "name of the generated migration")
+	databaseURL := flag.String("db", "", "connection string of a database to compare the config against, instead of the previous schema snapshot")
+	databaseName := flag.String("db-name", "tracelistener", "name of the database holding the tables inspected through -db")
 	flag.Parse()
 
 	return Flags{
-		ConfigPath: *configPath,
-		OutputDir:  *outputDir,
+		ConfigPath:    *configPath,
+		OutputDir:     *outputDir,
+		ModelsDir:     *modelsDir,
+		Check:         *check,
+		MigrationsDir: *migrationsDir,
+		MigrationName: *migrationName,
+		DatabaseURL:   *databaseURL,
+		DatabaseName:  *databaseName,
 	}
 }
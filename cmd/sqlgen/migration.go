@@ -0,0 +1,653 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+// Change is a single schema change between two versions of the tables configuration.
+type Change struct {
+	Table string
+	Up    string
+	Down  string
+
+	// Review explains why the change could lose data or fail on existing rows,
+	// it is empty for safe changes.
+	Review string
+
+	// Unsupported explains why the dialect cannot apply the change, Up and Down
+	// are empty then.
+	Unsupported string
+}
+
+// Diff returns the changes needed to turn the old tables into the new ones for dialect d,
+// table names being qualified with dbName.
+// Tables only present in new are created along with their indexes, view, comments and history.
+// Tables only present in old are dropped if dropTables is true.
+func Diff(old, new YamlData, dbName string, d database.Dialect, dropTables bool) []Change {
+	var changes []Change
+
+	oldTables := make(map[string]TableConfig, len(old.Tables))
+	for _, t := range old.Tables {
+		oldTables[t.Name] = t
+	}
+
+	for _, nt := range new.Tables {
+		ot, ok := oldTables[nt.Name]
+		if !ok {
+			changes = append(changes, createTable(d, nt, qualifiedName(dbName, nt.Name))...)
+			continue
+		}
+
+		delete(oldTables, nt.Name)
+		changes = append(changes, diffTable(d, ot, nt, qualifiedName(dbName, nt.Name))...)
+	}
+
+	if !dropTables {
+		return changes
+	}
+
+	removed := make([]string, 0, len(oldTables))
+	for name := range oldTables {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+
+	for _, name := range removed {
+		t := qualifiedName(dbName, name)
+		changes = append(changes, Change{
+			Table:  name,
+			Up:     fmt.Sprintf("DROP TABLE IF EXISTS %s", t),
+			Down:   fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", t, strings.Join(oldTables[name].ColumnsDefinition(d), ", ")),
+			Review: fmt.Sprintf("drops table %s and all its data", name),
+		})
+	}
+
+	return changes
+}
+
+func diffTable(d database.Dialect, ot, nt TableConfig, table string) []Change {
+	var adds, alters, drops []Change
+
+	oldColumns := make(map[string]ColumnConfig, len(ot.Columns))
+	for _, c := range ot.Columns {
+		oldColumns[c.Name] = c
+	}
+
+	for _, nc := range nt.Columns {
+		oc, ok := oldColumns[nc.Name]
+		if !ok {
+			c := Change{
+				Table: nt.Name,
+				Up:    addColumnStatement(d, table, nc),
+				Down:  dropColumnStatement(d, table, nc.Name),
+			}
+			if !nc.Nullable {
+				c.Review = fmt.Sprintf("adds NOT NULL column %s without a default, fails if the table has rows", nc.Name)
+			}
+			if !nc.Nullable && d == database.DialectSQLite {
+				c = unsupported(nt.Name, fmt.Sprintf("adding NOT NULL column %s without a default", nc.Name))
+			}
+			adds = append(adds, c)
+			continue
+		}
+
+		delete(oldColumns, nc.Name)
+
+		switch {
+		case sameType(oc.Type, nc.Type):
+		case d == database.DialectSQLite:
+			alters = append(alters, unsupported(nt.Name, fmt.Sprintf("changing column %s type", nc.Name)))
+		default:
+			alters = append(alters, Change{
+				Table:  nt.Name,
+				Up:     fmt.Sprintf("ALTER TABLE IF EXISTS %s ALTER COLUMN %s TYPE %s", table, nc.Name, d.ColumnType(nc.Type)),
+				Down:   fmt.Sprintf("ALTER TABLE IF EXISTS %s ALTER COLUMN %s TYPE %s", table, nc.Name, d.ColumnType(oc.Type)),
+				Review: fmt.Sprintf("changes column %s type from %s to %s, existing values might not convert", nc.Name, oc.Type, nc.Type),
+			})
+		}
+
+		switch {
+		case oc.Nullable != nc.Nullable && d == database.DialectSQLite:
+			alters = append(alters, unsupported(nt.Name, fmt.Sprintf("changing column %s nullability", nc.Name)))
+		case oc.Nullable && !nc.Nullable:
+			alters = append(alters, Change{
+				Table:  nt.Name,
+				Up:     fmt.Sprintf("ALTER TABLE IF EXISTS %s ALTER COLUMN %s SET NOT NULL", table, nc.Name),
+				Down:   fmt.Sprintf("ALTER TABLE IF EXISTS %s ALTER COLUMN %s DROP NOT NULL", table, nc.Name),
+				Review: fmt.Sprintf("makes column %s NOT NULL, fails if it contains NULL values", nc.Name),
+			})
+		case !oc.Nullable && nc.Nullable:
+			alters = append(alters, Change{
+				Table: nt.Name,
+				Up:    fmt.Sprintf("ALTER TABLE IF EXISTS %s ALTER COLUMN %s DROP NOT NULL", table, nc.Name),
+				Down:  fmt.Sprintf("ALTER TABLE IF EXISTS %s ALTER COLUMN %s SET NOT NULL", table, nc.Name),
+			})
+		}
+	}
+
+	for _, oc := range ot.Columns {
+		if _, ok := oldColumns[oc.Name]; !ok {
+			continue
+		}
+
+		drops = append(drops, Change{
+			Table:  nt.Name,
+			Up:     dropColumnStatement(d, table, oc.Name),
+			Down:   addColumnStatement(d, table, oc),
+			Review: fmt.Sprintf("drops column %s and its data", oc.Name),
+		})
+	}
+
+	dropView, createView := diffView(d, ot, nt, table, len(adds)+len(alters)+len(drops) > 0)
+	dropIndexes, createIndexes := diffIndexes(d, ot, nt, table)
+
+	changes := make([]Change, 0, len(adds)+len(alters)+len(drops)+len(dropIndexes)+len(createIndexes)+2)
+	changes = append(changes, dropView...)
+	changes = append(changes, dropIndexes...)
+	changes = append(changes, adds...)
+	changes = append(changes, alters...)
+	changes = append(changes, diffUnique(d, ot, nt, table)...)
+	changes = append(changes, createIndexes...)
+	changes = append(changes, drops...)
+	changes = append(changes, createView...)
+	changes = append(changes, diffComments(d, ot, nt, table)...)
+
+	return append(changes, diffHistory(d, ot, nt, table)...)
+}
+
+// createTable returns the changes creating table nt, along with its indexes, current view,
+// comments and history table.
+func createTable(d database.Dialect, nt TableConfig, table string) []Change {
+	changes := []Change{{
+		Table: nt.Name,
+		Up:    fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(nt.ColumnsDefinition(d), ", ")),
+		Down:  fmt.Sprintf("DROP TABLE IF EXISTS %s", table),
+	}}
+
+	_, createIndexes := diffIndexes(d, TableConfig{}, nt, table)
+	changes = append(changes, createIndexes...)
+
+	_, createView := diffView(d, TableConfig{}, nt, table, false)
+	changes = append(changes, createView...)
+	changes = append(changes, diffComments(d, TableConfig{}, nt, table)...)
+
+	return append(changes, diffHistory(d, TableConfig{}, nt, table)...)
+}
+
+// diffView drops the current view before the columns it depends on change, and recreates it
+// afterwards. New views are created after the columns they depend on.
+func diffView(d database.Dialect, ot, nt TableConfig, table string, columnsChanged bool) ([]Change, []Change) {
+	view := table + "_current"
+	create := []Change{{
+		Table: nt.Name,
+		Up:    nt.formatView(d, view, table),
+		Down:  fmt.Sprintf("DROP VIEW IF EXISTS %s", view),
+	}}
+
+	switch {
+	case !ot.CurrentView && nt.CurrentView:
+		return nil, create
+	case !ot.CurrentView || (nt.CurrentView && !columnsChanged):
+		return nil, nil
+	}
+
+	drop := []Change{{
+		Table: nt.Name,
+		Up:    fmt.Sprintf("DROP VIEW IF EXISTS %s", view),
+		Down:  ot.formatView(d, view, table),
+	}}
+
+	if !nt.CurrentView {
+		return drop, nil
+	}
+
+	return drop, create
+}
+
+// diffComments sets the comments of the table and of its columns which changed, SQLite not
+// supporting comments.
+func diffComments(d database.Dialect, ot, nt TableConfig, table string) []Change {
+	if d == database.DialectSQLite {
+		return nil
+	}
+
+	var changes []Change
+	if ot.Comment != nt.Comment {
+		changes = append(changes, Change{
+			Table: nt.Name,
+			Up:    fmt.Sprintf("COMMENT ON TABLE %s IS %s", table, commentValue(nt.Comment)),
+			Down:  fmt.Sprintf("COMMENT ON TABLE %s IS %s", table, commentValue(ot.Comment)),
+		})
+	}
+
+	oldComments := make(map[string]string, len(ot.Columns))
+	for _, c := range ot.Columns {
+		oldComments[c.Name] = c.Comment
+	}
+
+	for _, c := range nt.Columns {
+		if oldComments[c.Name] == c.Comment {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Table: nt.Name,
+			Up:    fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", table, c.Name, commentValue(c.Comment)),
+			Down:  fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", table, c.Name, commentValue(oldComments[c.Name])),
+		})
+	}
+
+	return changes
+}
+
+// commentValue returns comment as an SQL string, NULL removing the comment if it's empty.
+func commentValue(comment string) string {
+	if comment == "" {
+		return "NULL"
+	}
+
+	return "'" + strings.ReplaceAll(comment, "'", "''") + "'"
+}
+
+// diffHistory creates the history table of the tables it's enabled for, and drops it for
+// the ones it's disabled for.
+func diffHistory(d database.Dialect, ot, nt TableConfig, table string) []Change {
+	history := table + "_history"
+	index := nt.Name + "_history_height_idx"
+	create := []Change{
+		{
+			Table: nt.Name,
+			Up:    fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", history, strings.Join(nt.HistoryColumnsDefinition(d), ", ")),
+			Down:  fmt.Sprintf("DROP TABLE IF EXISTS %s", history),
+		},
+		{
+			Table: nt.Name,
+			Up:    formatIndex(d, index, IndexConfig{Columns: nt.HistoryIndexColumns()}, history),
+			Down:  dropIndexStatement(d, history, index),
+		},
+	}
+
+	switch {
+	case !ot.History && nt.History:
+		return create
+	case ot.History && !nt.History:
+		return []Change{{
+			Table:  nt.Name,
+			Up:     fmt.Sprintf("DROP TABLE IF EXISTS %s", history),
+			Down:   fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", history, strings.Join(ot.HistoryColumnsDefinition(d), ", ")),
+			Review: fmt.Sprintf("drops table %s_history and all its data", nt.Name),
+		}}
+	}
+
+	return nil
+}
+
+// diffIndexes returns the changes dropping removed or modified indexes, and the ones
+// creating new or modified indexes. Indexes are matched by name.
+func diffIndexes(d database.Dialect, ot, nt TableConfig, table string) ([]Change, []Change) {
+	oldIndexes := make(map[string]string, len(ot.Indexes))
+	for _, idx := range ot.Indexes {
+		name := ot.indexName(idx)
+		oldIndexes[name] = formatIndex(d, name, idx, table)
+	}
+
+	var drops, creates []Change
+	newIndexes := make(map[string]bool, len(nt.Indexes))
+	for _, idx := range nt.Indexes {
+		name := nt.indexName(idx)
+		newIndexes[name] = true
+
+		stmt := formatIndex(d, name, idx, table)
+		old, ok := oldIndexes[name]
+		if old == stmt {
+			continue
+		}
+
+		if ok {
+			drops = append(drops, Change{
+				Table: nt.Name,
+				Up:    dropIndexStatement(d, table, name),
+				Down:  old,
+			})
+		}
+
+		c := Change{
+			Table: nt.Name,
+			Up:    stmt,
+			Down:  dropIndexStatement(d, table, name),
+		}
+		if idx.Unique {
+			c.Review = fmt.Sprintf("adds unique index %s, fails if existing rows are duplicated", name)
+		}
+		creates = append(creates, c)
+	}
+
+	for _, idx := range ot.Indexes {
+		name := ot.indexName(idx)
+		if newIndexes[name] {
+			continue
+		}
+
+		drops = append(drops, Change{
+			Table: nt.Name,
+			Up:    dropIndexStatement(d, table, name),
+			Down:  oldIndexes[name],
+		})
+	}
+
+	return drops, creates
+}
+
+func dropIndexStatement(d database.Dialect, table, name string) string {
+	if d != database.DialectCockroachDB {
+		// Indexes belong to the schema of their table outside of CockroachDB.
+		return fmt.Sprintf("DROP INDEX IF EXISTS %s", database.SameSchema(table, name))
+	}
+
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s@%s CASCADE", table, name)
+}
+
+func addColumnStatement(d database.Dialect, table string, c ColumnConfig) string {
+	if d == database.DialectSQLite {
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, columnDefinition(d, c))
+	}
+
+	return fmt.Sprintf("ALTER TABLE IF EXISTS %s ADD COLUMN IF NOT EXISTS %s", table, columnDefinition(d, c))
+}
+
+func dropColumnStatement(d database.Dialect, table, column string) string {
+	if d == database.DialectSQLite {
+		return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column)
+	}
+
+	return fmt.Sprintf("ALTER TABLE IF EXISTS %s DROP COLUMN IF EXISTS %s", table, column)
+}
+
+func unsupported(table, change string) Change {
+	return Change{
+		Table:       table,
+		Unsupported: change + " is not supported, rebuild the table by hand",
+	}
+}
+
+// diffUnique replaces the unique constraint, creating the new one before dropping the old one.
+// Unique constraints declared in CREATE TABLE are named <table>_<columns>_key.
+func diffUnique(d database.Dialect, ot, nt TableConfig, table string) []Change {
+	if strings.Join(ot.UniqueColumns, ",") == strings.Join(nt.UniqueColumns, ",") {
+		return nil
+	}
+
+	var changes []Change
+	if len(nt.UniqueColumns) > 0 {
+		idx := uniqueIndexName(nt.Name, nt.UniqueColumns)
+		changes = append(changes, Change{
+			Table:  nt.Name,
+			Up:     formatIndex(d, idx, IndexConfig{Columns: nt.UniqueColumns, Unique: true}, table),
+			Down:   dropIndexStatement(d, table, idx),
+			Review: fmt.Sprintf("adds unique constraint on (%s), fails if existing rows are duplicated", strings.Join(nt.UniqueColumns, ", ")),
+		})
+	}
+
+	if len(ot.UniqueColumns) == 0 {
+		return changes
+	}
+
+	idx := uniqueIndexName(ot.Name, ot.UniqueColumns)
+	c := Change{
+		Table:  nt.Name,
+		Up:     dropIndexStatement(d, table, idx),
+		Down:   formatIndex(d, idx, IndexConfig{Columns: ot.UniqueColumns, Unique: true}, table),
+		Review: fmt.Sprintf("drops unique constraint on (%s), upserts relying on it will fail", strings.Join(ot.UniqueColumns, ", ")),
+	}
+
+	switch d {
+	case database.DialectSQLite:
+		c = unsupported(nt.Name, fmt.Sprintf("dropping unique constraint on (%s)", strings.Join(ot.UniqueColumns, ", ")))
+	case database.DialectPostgres:
+		// PostgreSQL refuses to drop the index backing a constraint.
+		c.Up = fmt.Sprintf("ALTER TABLE IF EXISTS %s DROP CONSTRAINT IF EXISTS %s", table, idx)
+		c.Down = fmt.Sprintf("ALTER TABLE IF EXISTS %s ADD CONSTRAINT %s UNIQUE (%s)", table, idx, strings.Join(ot.UniqueColumns, ", "))
+	}
+
+	return append(changes, c)
+}
+
+func qualifiedName(dbName, table string) string {
+	if dbName == "" {
+		return table
+	}
+
+	return dbName + "." + table
+}
+
+func uniqueIndexName(table string, columns []string) string {
+	return table + "_" + strings.Join(columns, "_") + "_key"
+}
+
+func columnDefinition(d database.Dialect, c ColumnConfig) string {
+	def := c.Name + " " + d.ColumnType(c.Type)
+	if !c.Nullable {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+var typeAliases = map[string]string{
+	"integer":     "int8",
+	"int":         "int8",
+	"int8":        "int8",
+	"bigint":      "int8",
+	"serial":      "int8",
+	"string":      "text",
+	"text":        "text",
+	"varchar":     "text",
+	"text[]":      "_text",
+	"string[]":    "_text",
+	"_text":       "_text",
+	"boolean":     "bool",
+	"bool":        "bool",
+	"bytes":       "bytea",
+	"bytea":       "bytea",
+	"decimal":     "numeric",
+	"numeric":     "numeric",
+	"timestamptz": "timestamptz",
+}
+
+// normalizeType returns the database type name t refers to, along with its parameters if any.
+func normalizeType(t string) (string, string) {
+	t = strings.ToLower(strings.TrimSpace(t))
+
+	params := ""
+	if idx := strings.Index(t, "("); idx != -1 {
+		t, params = strings.TrimSpace(t[:idx]), t[idx:]
+	}
+
+	if alias, ok := typeAliases[t]; ok {
+		t = alias
+	}
+
+	return t, strings.ReplaceAll(params, " ", "")
+}
+
+// sameType reports whether a and b are the same database type; parameters are only
+// compared when both types have them, since information_schema doesn't report them.
+func sameType(a, b string) bool {
+	at, ap := normalizeType(a)
+	bt, bp := normalizeType(b)
+	if at != bt {
+		return false
+	}
+
+	return ap == "" || bp == "" || ap == bp
+}
+
+// MigrationFile is a migration stored as a pair of <version>_<name>.up.sql and
+// <version>_<name>.down.sql files, along with <version>_<name>.<dialect>.up.sql
+// and <version>_<name>.<dialect>.down.sql ones for the other dialects.
+type MigrationFile struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Dialects []DialectMigrationFile
+
+	// Idempotent is set for the migrations generated by sqlgen, and the hand-written ones
+	// whose up files start with idempotentDirective, see database.Migration.Idempotent.
+	Idempotent bool
+}
+
+// DialectMigrationFile holds the statements of a MigrationFile for a given dialect.
+type DialectMigrationFile struct {
+	Dialect database.Dialect
+	Up      string
+	Down    string
+}
+
+// Ident returns the name of the dialect constant, in generated code.
+func (m DialectMigrationFile) Ident() string {
+	return dialectIdents[m.Dialect]
+}
+
+const migrationHeader = "-- This file was generated by sqlgen, review it before committing.\n"
+
+// idempotentDirective starts the up files of hand-written migrations whose statements can be
+// skipped on missing tables. It's stripped from their statements, and so from their checksum.
+const idempotentDirective = "-- sqlgen: idempotent\n"
+
+// WriteMigration writes changes as a migration for dialect d in dir, and returns the up file path.
+func WriteMigration(dir string, version int64, name string, d database.Dialect, changes []Change) (string, error) {
+	up := strings.Builder{}
+	up.WriteString(migrationHeader)
+	for _, c := range changes {
+		up.WriteString("\n")
+		if c.Unsupported != "" {
+			up.WriteString("-- UNSUPPORTED: " + c.Unsupported + "\n")
+			continue
+		}
+		if c.Review != "" {
+			up.WriteString("-- REVIEW: " + c.Review + "\n")
+		}
+		up.WriteString(c.Up + ";\n")
+	}
+
+	down := strings.Builder{}
+	down.WriteString(migrationHeader)
+	for i := len(changes) - 1; i >= 0; i-- {
+		if changes[i].Unsupported != "" {
+			continue
+		}
+		down.WriteString("\n" + changes[i].Down + ";\n")
+	}
+
+	base := migrationBase(dir, version, name, d)
+	if err := os.WriteFile(base+".up.sql", []byte(up.String()), 0600); err != nil {
+		return "", fmt.Errorf("cannot write migration, %w", err)
+	}
+
+	if err := os.WriteFile(base+".down.sql", []byte(down.String()), 0600); err != nil {
+		return "", fmt.Errorf("cannot write migration, %w", err)
+	}
+
+	return base + ".up.sql", nil
+}
+
+func migrationBase(dir string, version int64, name string, d database.Dialect) string {
+	base := filepath.Join(dir, fmt.Sprintf("%d_%s", version, name))
+	if d == database.DialectCockroachDB {
+		return base
+	}
+
+	return base + "." + string(d)
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.up\.sql$`)
+
+// LoadMigrations reads all the migrations contained in dir, ordered by version.
+func LoadMigrations(dir string) ([]MigrationFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations directory, %w", err)
+	}
+
+	var ret []MigrationFile
+	for _, e := range entries {
+		m := migrationFileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s, %w", e.Name(), err)
+		}
+
+		mf := MigrationFile{
+			Version: version,
+			Name:    m[2],
+		}
+
+		mf.Up, mf.Down, err = readMigration(migrationBase(dir, version, mf.Name, database.DialectCockroachDB))
+		if err != nil {
+			return nil, err
+		}
+
+		mf.Idempotent = strings.HasPrefix(mf.Up, migrationHeader) || strings.HasPrefix(mf.Up, idempotentDirective)
+		mf.Up = strings.TrimPrefix(mf.Up, idempotentDirective)
+
+		for _, d := range database.Dialects {
+			if d == database.DialectCockroachDB {
+				continue
+			}
+
+			base := migrationBase(dir, version, mf.Name, d)
+			if _, err := os.Stat(base + ".up.sql"); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			dm := DialectMigrationFile{Dialect: d}
+			dm.Up, dm.Down, err = readMigration(base)
+			if err != nil {
+				return nil, err
+			}
+			dm.Up = strings.TrimPrefix(dm.Up, idempotentDirective)
+
+			mf.Dialects = append(mf.Dialects, dm)
+		}
+
+		ret = append(ret, mf)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Version < ret[j].Version
+	})
+
+	return ret, nil
+}
+
+// readMigration returns the content of the up and down files starting with base.
+func readMigration(base string) (string, string, error) {
+	up, err := os.ReadFile(base + ".up.sql")
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read migration, %w", err)
+	}
+
+	down, err := os.ReadFile(base + ".down.sql")
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read migration, %w", err)
+	}
+
+	if strings.Contains(string(up)+string(down), "`") {
+		return "", "", fmt.Errorf("migration %s cannot contain backticks", filepath.Base(base))
+	}
+
+	return string(up), string(down), nil
+}
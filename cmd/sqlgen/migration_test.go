@@ -0,0 +1,458 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+const diffBaseConfig = `
+tables:
+  - name: balances
+    columns:
+      - name: id
+        type: serial
+        primary: true
+      - name: chain_name
+        type: text
+      - name: address
+        type: text
+      - name: amount
+        type: text
+      - name: memo
+        type: text
+        nullable: true
+    unique_columns:
+      - chain_name
+      - address
+  - name: removed
+    columns:
+      - name: id
+        type: serial
+        primary: true
+`
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		dropTables bool
+		expected   []Change
+	}{
+		{
+			name:   "no changes",
+			config: diffBaseConfig,
+		},
+		{
+			name: "add nullable column, type aliases are equal",
+			config: `
+tables:
+  - name: balances
+    columns:
+      - name: id
+        type: int8
+        primary: true
+      - name: chain_name
+        type: string
+      - name: address
+        type: text
+      - name: amount
+        type: text
+      - name: memo
+        type: text
+        nullable: true
+      - name: denom
+        type: text
+        nullable: true
+    unique_columns:
+      - chain_name
+      - address
+`,
+			expected: []Change{
+				{
+					Table: "balances",
+					Up:    "ALTER TABLE IF EXISTS tracelistener.balances ADD COLUMN IF NOT EXISTS denom text",
+					Down:  "ALTER TABLE IF EXISTS tracelistener.balances DROP COLUMN IF EXISTS denom",
+				},
+			},
+		},
+		{
+			name: "destructive changes",
+			config: `
+tables:
+  - name: balances
+    columns:
+      - name: id
+        type: serial
+        primary: true
+      - name: chain_name
+        type: text
+      - name: address
+        type: text
+      - name: amount
+        type: numeric
+      - name: denom
+        type: text
+    unique_columns:
+      - chain_name
+      - address
+      - denom
+`,
+			dropTables: true,
+			expected: []Change{
+				{
+					Table:  "balances",
+					Up:     "ALTER TABLE IF EXISTS tracelistener.balances ADD COLUMN IF NOT EXISTS denom text NOT NULL",
+					Down:   "ALTER TABLE IF EXISTS tracelistener.balances DROP COLUMN IF EXISTS denom",
+					Review: "adds NOT NULL column denom without a default, fails if the table has rows",
+				},
+				{
+					Table:  "balances",
+					Up:     "ALTER TABLE IF EXISTS tracelistener.balances ALTER COLUMN amount TYPE numeric",
+					Down:   "ALTER TABLE IF EXISTS tracelistener.balances ALTER COLUMN amount TYPE text",
+					Review: "changes column amount type from text to numeric, existing values might not convert",
+				},
+				{
+					Table:  "balances",
+					Up:     "CREATE UNIQUE INDEX IF NOT EXISTS balances_chain_name_address_denom_key ON tracelistener.balances (chain_name, address, denom)",
+					Down:   "DROP INDEX IF EXISTS tracelistener.balances@balances_chain_name_address_denom_key CASCADE",
+					Review: "adds unique constraint on (chain_name, address, denom), fails if existing rows are duplicated",
+				},
+				{
+					Table:  "balances",
+					Up:     "DROP INDEX IF EXISTS tracelistener.balances@balances_chain_name_address_key CASCADE",
+					Down:   "CREATE UNIQUE INDEX IF NOT EXISTS balances_chain_name_address_key ON tracelistener.balances (chain_name, address)",
+					Review: "drops unique constraint on (chain_name, address), upserts relying on it will fail",
+				},
+				{
+					Table:  "balances",
+					Up:     "ALTER TABLE IF EXISTS tracelistener.balances DROP COLUMN IF EXISTS memo",
+					Down:   "ALTER TABLE IF EXISTS tracelistener.balances ADD COLUMN IF NOT EXISTS memo text",
+					Review: "drops column memo and its data",
+				},
+				{
+					Table:  "removed",
+					Up:     "DROP TABLE IF EXISTS tracelistener.removed",
+					Down:   "CREATE TABLE IF NOT EXISTS tracelistener.removed (id serial PRIMARY KEY NOT NULL)",
+					Review: "drops table removed and all its data",
+				},
+			},
+		},
+	}
+
+	var base YamlData
+	require.NoError(t, yaml.Unmarshal([]byte(diffBaseConfig), &base))
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var config YamlData
+			require.NoError(t, yaml.Unmarshal([]byte(tt.config), &config))
+
+			require.Equal(t, tt.expected, Diff(base, config, "tracelistener", database.DialectCockroachDB, tt.dropTables))
+		})
+	}
+}
+
+func TestDiff_Dialects(t *testing.T) {
+	var base, config YamlData
+	require.NoError(t, yaml.Unmarshal([]byte(diffBaseConfig), &base))
+	require.NoError(t, yaml.Unmarshal([]byte(`
+tables:
+  - name: balances
+    columns:
+      - name: id
+        type: serial
+        primary: true
+      - name: chain_name
+        type: text
+      - name: address
+        type: text
+      - name: amount
+        type: numeric
+      - name: height
+        type: integer
+        nullable: true
+    unique_columns:
+      - chain_name
+`), &config))
+
+	require.Equal(t, []Change{
+		{
+			Table: "balances",
+			Up:    "ALTER TABLE IF EXISTS tracelistener.balances ADD COLUMN IF NOT EXISTS height bigint",
+			Down:  "ALTER TABLE IF EXISTS tracelistener.balances DROP COLUMN IF EXISTS height",
+		},
+		{
+			Table:  "balances",
+			Up:     "ALTER TABLE IF EXISTS tracelistener.balances ALTER COLUMN amount TYPE numeric",
+			Down:   "ALTER TABLE IF EXISTS tracelistener.balances ALTER COLUMN amount TYPE text",
+			Review: "changes column amount type from text to numeric, existing values might not convert",
+		},
+		{
+			Table:  "balances",
+			Up:     "CREATE UNIQUE INDEX IF NOT EXISTS balances_chain_name_key ON tracelistener.balances (chain_name)",
+			Down:   "DROP INDEX IF EXISTS tracelistener.balances_chain_name_key",
+			Review: "adds unique constraint on (chain_name), fails if existing rows are duplicated",
+		},
+		{
+			Table:  "balances",
+			Up:     "ALTER TABLE IF EXISTS tracelistener.balances DROP CONSTRAINT IF EXISTS balances_chain_name_address_key",
+			Down:   "ALTER TABLE IF EXISTS tracelistener.balances ADD CONSTRAINT balances_chain_name_address_key UNIQUE (chain_name, address)",
+			Review: "drops unique constraint on (chain_name, address), upserts relying on it will fail",
+		},
+		{
+			Table:  "balances",
+			Up:     "ALTER TABLE IF EXISTS tracelistener.balances DROP COLUMN IF EXISTS memo",
+			Down:   "ALTER TABLE IF EXISTS tracelistener.balances ADD COLUMN IF NOT EXISTS memo text",
+			Review: "drops column memo and its data",
+		},
+	}, Diff(base, config, "tracelistener", database.DialectPostgres, false))
+
+	require.Equal(t, []Change{
+		{
+			Table: "balances",
+			Up:    "ALTER TABLE tracelistener.balances ADD COLUMN height integer",
+			Down:  "ALTER TABLE tracelistener.balances DROP COLUMN height",
+		},
+		{
+			Table:       "balances",
+			Unsupported: "changing column amount type is not supported, rebuild the table by hand",
+		},
+		{
+			Table:  "balances",
+			Up:     "CREATE UNIQUE INDEX IF NOT EXISTS tracelistener.balances_chain_name_key ON balances (chain_name)",
+			Down:   "DROP INDEX IF EXISTS tracelistener.balances_chain_name_key",
+			Review: "adds unique constraint on (chain_name), fails if existing rows are duplicated",
+		},
+		{
+			Table:       "balances",
+			Unsupported: "dropping unique constraint on (chain_name, address) is not supported, rebuild the table by hand",
+		},
+		{
+			Table:  "balances",
+			Up:     "ALTER TABLE tracelistener.balances DROP COLUMN memo",
+			Down:   "ALTER TABLE tracelistener.balances ADD COLUMN memo text",
+			Review: "drops column memo and its data",
+		},
+	}, Diff(base, config, "tracelistener", database.DialectSQLite, false))
+}
+
+func TestSameType(t *testing.T) {
+	require.True(t, sameType("integer", "int8"))
+	require.True(t, sameType("text[]", "_text"))
+	require.True(t, sameType("DECIMAL(30, 0)", "numeric"))
+	require.False(t, sameType("numeric(30,0)", "numeric(40,0)"))
+	require.False(t, sameType("text", "jsonb"))
+}
+
+func TestWriteMigration_LoadMigrations(t *testing.T) {
+	dir := t.TempDir()
+
+	changes := []Change{
+		{Up: "first up", Down: "first down"},
+		{Up: "second up", Down: "second down", Review: "destructive"},
+	}
+
+	_, err := WriteMigration(dir, 2, "second", database.DialectCockroachDB, changes[1:])
+	require.NoError(t, err)
+	_, err = WriteMigration(dir, 1, "first", database.DialectCockroachDB, changes[:1])
+	require.NoError(t, err)
+	_, err = WriteMigration(dir, 1, "first", database.DialectSQLite, []Change{{Unsupported: "not today"}})
+	require.NoError(t, err)
+
+	migrations, err := LoadMigrations(dir)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	require.Equal(t, int64(1), migrations[0].Version)
+	require.Equal(t, "first", migrations[0].Name)
+	require.Contains(t, migrations[0].Up, "first up;")
+	require.Contains(t, migrations[0].Down, "first down;")
+	require.Len(t, migrations[0].Dialects, 1)
+	require.Equal(t, database.DialectSQLite, migrations[0].Dialects[0].Dialect)
+	require.Contains(t, migrations[0].Dialects[0].Up, "-- UNSUPPORTED: not today\n")
+	require.NotContains(t, migrations[0].Dialects[0].Up, "first up")
+
+	require.Equal(t, int64(2), migrations[1].Version)
+	require.Contains(t, migrations[1].Up, "-- REVIEW: destructive\nsecond up;")
+	require.True(t, migrations[0].Idempotent)
+	require.True(t, migrations[1].Idempotent)
+}
+
+func TestLoadMigrations_HandWritten(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, up := range map[string]string{
+		"3_backfill": idempotentDirective + "UPDATE {schema}.a SET b = c WHERE b IS NULL;\n",
+		"4_rename":   "ALTER TABLE {schema}.a RENAME COLUMN b TO d;\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".up.sql"), []byte(up), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".down.sql"), nil, 0600))
+	}
+
+	migrations, err := LoadMigrations(dir)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	require.True(t, migrations[0].Idempotent)
+	require.Equal(t, "UPDATE {schema}.a SET b = c WHERE b IS NULL;\n", migrations[0].Up)
+	require.False(t, migrations[1].Idempotent)
+}
+
+func TestDiff_IndexesAndView(t *testing.T) {
+	var old, config YamlData
+	require.NoError(t, yaml.Unmarshal([]byte(`
+tables:
+  - name: balances
+    columns:
+      - name: id
+        type: serial
+        primary: true
+      - name: delete_height
+        type: integer
+        nullable: true
+      - name: address
+        type: text
+      - name: memo
+        type: text
+    indexes:
+      - columns: [address]
+      - columns: [memo]
+    current_view: true
+`), &old))
+	require.NoError(t, yaml.Unmarshal([]byte(`
+tables:
+  - name: balances
+    columns:
+      - name: id
+        type: serial
+        primary: true
+      - name: delete_height
+        type: integer
+        nullable: true
+      - name: address
+        type: text
+    indexes:
+      - columns: [address]
+        where: delete_height IS NULL
+      - columns: [id]
+    current_view: true
+`), &config))
+
+	oldView := "CREATE VIEW IF NOT EXISTS tracelistener.balances_current AS SELECT id, address, memo FROM tracelistener.balances WHERE delete_height IS NULL"
+	newView := "CREATE VIEW IF NOT EXISTS tracelistener.balances_current AS SELECT id, address FROM tracelistener.balances WHERE delete_height IS NULL"
+
+	require.Equal(t, []Change{
+		{
+			Table: "balances",
+			Up:    "DROP VIEW IF EXISTS tracelistener.balances_current",
+			Down:  oldView,
+		},
+		{
+			Table: "balances",
+			Up:    "DROP INDEX IF EXISTS tracelistener.balances@balances_address_idx CASCADE",
+			Down:  "CREATE INDEX IF NOT EXISTS balances_address_idx ON tracelistener.balances (address)",
+		},
+		{
+			Table: "balances",
+			Up:    "DROP INDEX IF EXISTS tracelistener.balances@balances_memo_idx CASCADE",
+			Down:  "CREATE INDEX IF NOT EXISTS balances_memo_idx ON tracelistener.balances (memo)",
+		},
+		{
+			Table: "balances",
+			Up:    "CREATE INDEX IF NOT EXISTS balances_address_idx ON tracelistener.balances (address) WHERE delete_height IS NULL",
+			Down:  "DROP INDEX IF EXISTS tracelistener.balances@balances_address_idx CASCADE",
+		},
+		{
+			Table: "balances",
+			Up:    "CREATE INDEX IF NOT EXISTS balances_id_idx ON tracelistener.balances (id)",
+			Down:  "DROP INDEX IF EXISTS tracelistener.balances@balances_id_idx CASCADE",
+		},
+		{
+			Table:  "balances",
+			Up:     "ALTER TABLE IF EXISTS tracelistener.balances DROP COLUMN IF EXISTS memo",
+			Down:   "ALTER TABLE IF EXISTS tracelistener.balances ADD COLUMN IF NOT EXISTS memo text NOT NULL",
+			Review: "drops column memo and its data",
+		},
+		{
+			Table: "balances",
+			Up:    newView,
+			Down:  "DROP VIEW IF EXISTS tracelistener.balances_current",
+		},
+	}, Diff(old, config, "tracelistener", database.DialectCockroachDB, false))
+}
+
+func TestDiff_NewTable(t *testing.T) {
+	var config YamlData
+	require.NoError(t, yaml.Unmarshal([]byte(`
+tables:
+  - name: balances
+    comment: it's balances
+    columns:
+      - name: id
+        type: serial
+        primary: true
+      - name: height
+        type: integer
+      - name: delete_height
+        type: integer
+        nullable: true
+      - name: address
+        type: text
+        comment: holder
+    unique_columns:
+      - address
+    history: true
+    indexes:
+      - columns: [height]
+    current_view: true
+`), &config))
+
+	require.Equal(t, []Change{
+		{
+			Table: "balances",
+			Up:    "CREATE TABLE IF NOT EXISTS tracelistener.balances (id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, address text NOT NULL, UNIQUE (address))",
+			Down:  "DROP TABLE IF EXISTS tracelistener.balances",
+		},
+		{
+			Table: "balances",
+			Up:    "CREATE INDEX IF NOT EXISTS balances_height_idx ON tracelistener.balances (height)",
+			Down:  "DROP INDEX IF EXISTS tracelistener.balances@balances_height_idx CASCADE",
+		},
+		{
+			Table: "balances",
+			Up:    "CREATE VIEW IF NOT EXISTS tracelistener.balances_current AS SELECT id, height, address FROM tracelistener.balances WHERE delete_height IS NULL",
+			Down:  "DROP VIEW IF EXISTS tracelistener.balances_current",
+		},
+		{
+			Table: "balances",
+			Up:    "COMMENT ON TABLE tracelistener.balances IS 'it''s balances'",
+			Down:  "COMMENT ON TABLE tracelistener.balances IS NULL",
+		},
+		{
+			Table: "balances",
+			Up:    "COMMENT ON COLUMN tracelistener.balances.address IS 'holder'",
+			Down:  "COMMENT ON COLUMN tracelistener.balances.address IS NULL",
+		},
+		{
+			Table: "balances",
+			Up:    "CREATE TABLE IF NOT EXISTS tracelistener.balances_history (id serial PRIMARY KEY NOT NULL, address text NOT NULL, height integer NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)",
+			Down:  "DROP TABLE IF EXISTS tracelistener.balances_history",
+		},
+		{
+			Table: "balances",
+			Up:    "CREATE INDEX IF NOT EXISTS balances_history_height_idx ON tracelistener.balances_history (address, height)",
+			Down:  "DROP INDEX IF EXISTS tracelistener.balances_history@balances_history_height_idx CASCADE",
+		},
+	}, Diff(YamlData{}, config, "tracelistener", database.DialectCockroachDB, false))
+
+	sqlite := Diff(YamlData{}, config, "tracelistener", database.DialectSQLite, false)
+	require.Len(t, sqlite, 5)
+	require.Equal(t, "CREATE INDEX IF NOT EXISTS tracelistener.balances_history_height_idx ON balances_history (address, height)", sqlite[4].Up)
+}
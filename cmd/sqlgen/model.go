@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// baseColumns are held by models.TracelistenerDatabaseRow, which is embedded in every model.
+var baseColumns = map[string]bool{
+	"id":            true,
+	"height":        true,
+	"delete_height": true,
+	"chain_name":    true,
+	"last_tx_hash":  true,
+}
+
+// defaultGoTypes maps column types to the Go type used when go_type is omitted.
+var defaultGoTypes = map[string]string{
+	"text":    "string",
+	"bool":    "bool",
+	"bytes":   "[]byte",
+	"text[]":  "StringArray",
+	"decimal": "Numeric",
+	"numeric": "Numeric",
+}
+
+var initialisms = map[string]string{
+	"id":  "ID",
+	"ibc": "IBC",
+	"url": "URL",
+}
+
+// ModelField is a field of a generated model struct.
+type ModelField struct {
+	Name   string
+	GoType string
+	Tag    string
+}
+
+func (t TableConfig) validateModel() error {
+	if err := validateGoIdentifier(t.Model.Name); err != nil {
+		return fmt.Errorf("name %s: %w", t.Model.Name, err)
+	}
+
+	fields := map[string]bool{}
+	for _, c := range t.Columns {
+		if baseColumns[c.Name] {
+			continue
+		}
+
+		f := c.fieldName()
+		if err := validateGoIdentifier(f); err != nil {
+			return fmt.Errorf("field %s: %w", f, err)
+		}
+
+		if fields[f] {
+			return fmt.Errorf("duplicate field %s", f)
+		}
+		fields[f] = true
+
+		if c.goType() == "" {
+			return fmt.Errorf("column %s of type %s requires go_type", c.Name, c.Type)
+		}
+	}
+
+	if t.columnType("delete_height") == "" {
+		return fmt.Errorf("delete_height column is required")
+	}
+
+	return nil
+}
+
+func validateGoIdentifier(name string) error {
+	if name == "" {
+		return fmt.Errorf("cannot be empty")
+	}
+
+	for i, r := range name {
+		if i == 0 && !unicode.IsUpper(r) {
+			return fmt.Errorf("must be exported")
+		}
+
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return fmt.Errorf("must be a valid Go identifier")
+		}
+	}
+
+	return nil
+}
+
+func (c ColumnConfig) fieldName() string {
+	if c.Field != "" {
+		return c.Field
+	}
+
+	return camelCase(c.Name)
+}
+
+func (c ColumnConfig) goType() string {
+	if c.GoType != "" {
+		return c.GoType
+	}
+
+	t := strings.ToLower(strings.TrimSpace(c.Type))
+	if idx := strings.Index(t, "("); idx != -1 {
+		t = strings.TrimSpace(t[:idx])
+	}
+
+	return defaultGoTypes[t]
+}
+
+func (c ColumnConfig) jsonTag() string {
+	if c.JSON != "" {
+		return c.JSON
+	}
+
+	return c.Name
+}
+
+func camelCase(s string) string {
+	sb := strings.Builder{}
+	for _, w := range strings.Split(s, "_") {
+		if w == "" {
+			continue
+		}
+
+		if i, ok := initialisms[w]; ok {
+			sb.WriteString(i)
+			continue
+		}
+
+		sb.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+
+	return sb.String()
+}
+
+func lowerCamelCase(s string) string {
+	for k, v := range initialisms {
+		if strings.HasPrefix(s, v) {
+			return k + s[len(v):]
+		}
+	}
+
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// ModelFields returns the model struct fields, TracelistenerDatabaseRow ones excluded.
+func (t TableConfig) ModelFields() []ModelField {
+	res := make([]ModelField, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		if baseColumns[c.Name] {
+			continue
+		}
+
+		res = append(res, ModelField{
+			Name:   c.fieldName(),
+			GoType: c.goType(),
+			Tag:    fmt.Sprintf("`db:%q json:%q`", c.Name, c.jsonTag()),
+		})
+	}
+	return res
+}
+
+// SelectColumns returns all the table columns, in the order they're defined.
+func (t TableConfig) SelectColumns() []string {
+	res := make([]string, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		res = append(res, c.Name)
+	}
+	return res
+}
+
+// uniqueArgNames returns the Go parameter names of the unique columns.
+func (t TableConfig) uniqueArgNames() []string {
+	res := make([]string, 0, len(t.UniqueColumns))
+	for _, u := range t.UniqueColumns {
+		if u == "chain_name" {
+			res = append(res, "chainName")
+			continue
+		}
+
+		for _, c := range t.Columns {
+			if c.Name == u {
+				res = append(res, lowerCamelCase(c.fieldName()))
+			}
+		}
+	}
+	return res
+}
+
+// UniqueParams returns the typed Go parameters of the unique columns.
+func (t TableConfig) UniqueParams() []string {
+	names := t.uniqueArgNames()
+	res := make([]string, 0, len(names))
+	for i, u := range t.UniqueColumns {
+		goType := "string"
+		for _, c := range t.Columns {
+			if c.Name == u && !baseColumns[u] {
+				goType = c.goType()
+			}
+		}
+		res = append(res, names[i]+" "+goType)
+	}
+	return res
+}
+
+// UniqueArgs returns the Go arguments of the unique columns.
+func (t TableConfig) UniqueArgs() []string {
+	return t.uniqueArgNames()
+}
+
+// UniquePlaceholders returns the conditions matching the unique columns with positional parameters.
+func (t TableConfig) UniquePlaceholders() []string {
+	res := make([]string, 0, len(t.UniqueColumns))
+	for i, c := range t.UniqueColumns {
+		res = append(res, fmt.Sprintf("%s=$%d", c, i+1))
+	}
+	return res
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// TestGeneratedFilesUpToDate fails whenever sqlmodels.yaml and the generated
+// tables and models disagree.
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	configFile, err := os.ReadFile("../../sqlmodels.yaml")
+	require.NoError(t, err)
+
+	var yamlData YamlData
+	require.NoError(t, yaml.Unmarshal(configFile, &yamlData))
+
+	files, err := Render(yamlData, "../../tracelistener/tables", "../../models")
+	require.NoError(t, err)
+
+	require.Empty(t, Check(files), "generated files are not up to date with sqlmodels.yaml, run make sqlgen")
+}
+
+func TestTableConfig_ValidateModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name: "default go types",
+			config: `
+name: balances
+model:
+  name: BalanceRow
+columns:
+  - name: delete_height
+    type: integer
+    nullable: true
+  - name: address
+    type: text
+  - name: hops
+    type: text[]
+  - name: amount
+    type: decimal(78, 18)
+`,
+		},
+		{
+			name: "missing go type",
+			config: `
+name: balances
+model:
+  name: BalanceRow
+columns:
+  - name: delete_height
+    type: integer
+    nullable: true
+  - name: entries
+    type: jsonb
+`,
+			wantErr: true,
+		},
+		{
+			name: "unexported model",
+			config: `
+name: balances
+model:
+  name: balanceRow
+columns:
+  - name: delete_height
+    type: integer
+    nullable: true
+`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate field",
+			config: `
+name: balances
+model:
+  name: BalanceRow
+columns:
+  - name: delete_height
+    type: integer
+    nullable: true
+  - name: address
+    type: text
+  - name: addr
+    type: text
+    field: Address
+`,
+			wantErr: true,
+		},
+		{
+			name: "missing delete_height",
+			config: `
+name: balances
+model:
+  name: BalanceRow
+columns:
+  - name: address
+    type: text
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var c TableConfig
+			require.NoError(t, yaml.Unmarshal([]byte(tt.config), &c))
+
+			err := c.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTableConfig_ModelFields(t *testing.T) {
+	var c TableConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: channels
+model:
+  name: IBCChannelRow
+columns:
+  - name: chain_name
+    type: text
+  - name: channel_id
+    type: text
+  - name: state
+    type: integer
+    go_type: int32
+  - name: delegator_address
+    type: text
+    field: Delegator
+    json: delegator
+unique_columns:
+  - chain_name
+  - channel_id
+`), &c))
+
+	require.Equal(t, []ModelField{
+		{Name: "ChannelID", GoType: "string", Tag: "`db:\"channel_id\" json:\"channel_id\"`"},
+		{Name: "State", GoType: "int32", Tag: "`db:\"state\" json:\"state\"`"},
+		{Name: "Delegator", GoType: "string", Tag: "`db:\"delegator_address\" json:\"delegator\"`"},
+	}, c.ModelFields())
+
+	require.Equal(t, []string{"chainName string", "channelID string"}, c.UniqueParams())
+	require.Equal(t, []string{"chain_name=$1", "channel_id=$2"}, c.UniquePlaceholders())
+}
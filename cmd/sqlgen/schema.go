@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+// Statements returned by the functions in this file are fmt format strings,
+// with %s standing for the table name, so that they can be used both in
+// generated code and in migrations.
+
+func (t TableConfig) validateIndexes(columns map[string]bool) error {
+	names := make(map[string]bool)
+	for _, idx := range t.Indexes {
+		if len(idx.Columns) == 0 {
+			return fmt.Errorf("index on table %s must define columns", t.Name)
+		}
+
+		for _, c := range idx.Columns {
+			if !columns[c] {
+				return fmt.Errorf("index column %s not defined in the columns section", c)
+			}
+		}
+
+		name := t.indexName(idx)
+		if err := validateName(name); err != nil {
+			return fmt.Errorf("validating index name %s: %w", name, err)
+		}
+
+		if names[name] {
+			return fmt.Errorf("duplicate index name %s", name)
+		}
+		names[name] = true
+
+		if strings.Contains(idx.Where, "`") {
+			return fmt.Errorf("index %s condition cannot contain backticks", name)
+		}
+	}
+
+	return nil
+}
+
+func (t TableConfig) indexName(idx IndexConfig) string {
+	if idx.Name != "" {
+		return idx.Name
+	}
+
+	return t.Name + "_" + strings.Join(idx.Columns, "_") + "_idx"
+}
+
+// indexStatement returns the CREATE INDEX statement of idx for d. SQLite expects the index name
+// to be qualified instead of the table one, so its statement takes them both as parameters.
+func indexStatement(d database.Dialect, name string, idx IndexConfig) string {
+	stmt := "CREATE INDEX IF NOT EXISTS "
+	if idx.Unique {
+		stmt = "CREATE UNIQUE INDEX IF NOT EXISTS "
+	}
+
+	if d == database.DialectSQLite {
+		name = "%s"
+	}
+
+	stmt += name + " ON %s (" + strings.Join(idx.Columns, ", ") + ")"
+	if idx.Where != "" {
+		stmt += " WHERE " + escapePercent(idx.Where)
+	}
+
+	return stmt
+}
+
+// indexArgs returns the indexStatement parameters for d, table being a Go expression.
+func indexArgs(d database.Dialect, name, table string) string {
+	if d == database.DialectSQLite {
+		return fmt.Sprintf("database.SameSchema(%s, %q), database.Unqualified(%s)", table, name, table)
+	}
+
+	return table
+}
+
+// formatIndex returns the CREATE INDEX statement of idx on table for d.
+func formatIndex(d database.Dialect, name string, idx IndexConfig, table string) string {
+	if d == database.DialectSQLite {
+		return fmt.Sprintf(indexStatement(d, name, idx), database.SameSchema(table, name), database.Unqualified(table))
+	}
+
+	return fmt.Sprintf(indexStatement(d, name, idx), table)
+}
+
+// IndexStatement returns the CREATE INDEX statement of idx for d.
+func (t TableConfig) IndexStatement(d database.Dialect, idx IndexConfig) string {
+	return indexStatement(d, t.indexName(idx), idx)
+}
+
+// IndexArgs returns the IndexStatement parameters of idx for d, in generated code.
+func (t TableConfig) IndexArgs(d database.Dialect, idx IndexConfig) string {
+	return indexArgs(d, t.indexName(idx), "r.tableName")
+}
+
+// ViewColumns returns the columns exposed by the current view, delete_height
+// being always NULL there.
+func (t TableConfig) ViewColumns() []string {
+	res := make([]string, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		if c.Name == "delete_height" {
+			continue
+		}
+		res = append(res, c.Name)
+	}
+	return res
+}
+
+// ViewStatement returns the CREATE VIEW statement of the current view for d, the
+// first %s standing for the view name and the second one for the table name.
+func (t TableConfig) ViewStatement(d database.Dialect) string {
+	stmt := "CREATE VIEW IF NOT EXISTS %s AS SELECT "
+	if d == database.DialectPostgres {
+		stmt = "CREATE OR REPLACE VIEW %s AS SELECT "
+	}
+
+	return stmt + strings.Join(t.ViewColumns(), ", ") + " FROM %s WHERE delete_height IS NULL"
+}
+
+// ViewArgs returns the arguments of the view statement of the generated code for d.
+func (t TableConfig) ViewArgs(d database.Dialect) string {
+	if d == database.DialectSQLite {
+		return "r.ViewName(), database.Unqualified(r.tableName)"
+	}
+
+	return "r.ViewName(), r.tableName"
+}
+
+// formatView returns the CREATE VIEW statement of view on table for d.
+// SQLite views can only reference tables of their own schema, unqualified.
+func (t TableConfig) formatView(d database.Dialect, view, table string) string {
+	if d == database.DialectSQLite {
+		table = database.Unqualified(table)
+	}
+
+	return fmt.Sprintf(t.ViewStatement(d), view, table)
+}
+
+// CommentStatements returns the COMMENT ON statements of the table and its columns for d,
+// SQLite not supporting comments.
+func (t TableConfig) CommentStatements(d database.Dialect) []string {
+	if d == database.DialectSQLite {
+		return nil
+	}
+
+	var res []string
+	if t.Comment != "" {
+		res = append(res, "COMMENT ON TABLE %s IS "+sqlString(t.Comment))
+	}
+
+	for _, c := range t.Columns {
+		if c.Comment == "" {
+			continue
+		}
+		res = append(res, "COMMENT ON COLUMN %s."+c.Name+" IS "+sqlString(c.Comment))
+	}
+
+	return res
+}
+
+// HasComments reports whether the table or one of its columns has a comment.
+func (t TableConfig) HasComments() bool {
+	return len(t.CommentStatements(database.DialectCockroachDB)) > 0
+}
+
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(escapePercent(s), "'", "''") + "'"
+}
+
+func escapePercent(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
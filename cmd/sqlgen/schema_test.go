@@ -0,0 +1,159 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+const schemaConfig = `
+name: balances
+comment: it's 100%% balances
+columns:
+  - name: id
+    type: serial
+    primary: true
+  - name: delete_height
+    type: integer
+    nullable: true
+  - name: address
+    type: text
+    comment: bech32 address
+indexes:
+  - columns: [address]
+    where: delete_height IS NULL
+  - name: balances_unique_idx
+    columns: [id, address]
+    unique: true
+current_view: true
+`
+
+func TestTableConfig_Schema(t *testing.T) {
+	var c TableConfig
+	require.NoError(t, yaml.Unmarshal([]byte(schemaConfig), &c))
+	require.NoError(t, c.Validate())
+
+	tests := []struct {
+		dialect  database.Dialect
+		indexes  []string
+		view     string
+		comments []string
+	}{
+		{
+			dialect: database.DialectCockroachDB,
+			indexes: []string{
+				"CREATE INDEX IF NOT EXISTS balances_address_idx ON %s (address) WHERE delete_height IS NULL",
+				"CREATE UNIQUE INDEX IF NOT EXISTS balances_unique_idx ON %s (id, address)",
+			},
+			view: "CREATE VIEW IF NOT EXISTS %s AS SELECT id, address FROM %s WHERE delete_height IS NULL",
+			comments: []string{
+				"COMMENT ON TABLE %s IS 'it''s 100%%%% balances'",
+				"COMMENT ON COLUMN %s.address IS 'bech32 address'",
+			},
+		},
+		{
+			dialect: database.DialectPostgres,
+			indexes: []string{
+				"CREATE INDEX IF NOT EXISTS balances_address_idx ON %s (address) WHERE delete_height IS NULL",
+				"CREATE UNIQUE INDEX IF NOT EXISTS balances_unique_idx ON %s (id, address)",
+			},
+			view: "CREATE OR REPLACE VIEW %s AS SELECT id, address FROM %s WHERE delete_height IS NULL",
+			comments: []string{
+				"COMMENT ON TABLE %s IS 'it''s 100%%%% balances'",
+				"COMMENT ON COLUMN %s.address IS 'bech32 address'",
+			},
+		},
+		{
+			dialect: database.DialectSQLite,
+			indexes: []string{
+				"CREATE INDEX IF NOT EXISTS %s ON %s (address) WHERE delete_height IS NULL",
+				"CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (id, address)",
+			},
+			view: "CREATE VIEW IF NOT EXISTS %s AS SELECT id, address FROM %s WHERE delete_height IS NULL",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.dialect), func(t *testing.T) {
+			indexes := make([]string, 0, len(c.Indexes))
+			for _, idx := range c.Indexes {
+				indexes = append(indexes, c.IndexStatement(tt.dialect, idx))
+			}
+
+			require.Equal(t, tt.indexes, indexes)
+			require.Equal(t, tt.view, c.ViewStatement(tt.dialect))
+			require.Equal(t, tt.comments, c.CommentStatements(tt.dialect))
+		})
+	}
+
+	require.Equal(t,
+		"CREATE INDEX IF NOT EXISTS tracelistener.balances_address_idx ON balances (address) WHERE delete_height IS NULL",
+		formatIndex(database.DialectSQLite, "balances_address_idx", c.Indexes[0], "tracelistener.balances"))
+}
+
+func TestTableConfig_ValidateSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{
+			name: "unknown index column",
+			config: `
+name: balances
+columns:
+  - name: address
+    type: text
+indexes:
+  - columns: [denom]
+`,
+		},
+		{
+			name: "index without columns",
+			config: `
+name: balances
+columns:
+  - name: address
+    type: text
+indexes:
+  - name: balances_idx
+`,
+		},
+		{
+			name: "duplicate index name",
+			config: `
+name: balances
+columns:
+  - name: address
+    type: text
+indexes:
+  - columns: [address]
+  - name: balances_address_idx
+    columns: [address]
+    where: address <> ''
+`,
+		},
+		{
+			name: "current view without delete_height",
+			config: `
+name: balances
+columns:
+  - name: address
+    type: text
+current_view: true
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var c TableConfig
+			require.NoError(t, yaml.Unmarshal([]byte(tt.config), &c))
+			require.Error(t, c.Validate())
+		})
+	}
+}
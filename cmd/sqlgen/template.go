@@ -6,25 +6,81 @@ package {{ .PackageName }}
 
 import (
 	"fmt"
+{{- if .Config.Model }}
+
+	"github.com/jmoiron/sqlx"
+{{- end }}
+
+	"github.com/emerishq/tracelistener/database"
+{{- if .Config.Model }}
+	"github.com/emerishq/tracelistener/models"
+{{- end }}
 )
 
 type {{ .StructName }} struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func New{{ .StructName }}(tableName string) {{ .StructName }} {
 	return {{ .StructName }}{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r {{ .StructName }}) WithDialect(dialect database.Dialect) {{ .StructName }} {
+	r.dialect = dialect
+	return r
+}
+
 func (r {{ .StructName }}) Name() string { return r.tableName }
 
+func (r {{ .StructName }}) UniqueColumns() []string {
+	return []string{ {{- Join .Config.QuotedUniqueColumns -}} }
+}
+
 func (r {{ .StructName }}) CreateTable() string {
-	return fmt.Sprintf(` + "`" + `
-		CREATE TABLE IF NOT EXISTS %s
-		({{ Join .Config.ColumnsDefinition }})
-	` + "`" + `, r.tableName)
+	{{ Switch "createTable" . }}
+}
+{{- if .Config.Indexes }}
+
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r {{ .StructName }}) CreateIndexes() []string {
+	{{ Switch "createIndexes" . }}
+}
+{{- end }}
+{{- if .Config.CurrentView }}
+
+// ViewName returns the name of the view exposing the live rows only.
+func (r {{ .StructName }}) ViewName() string { return r.tableName + "_current" }
+
+func (r {{ .StructName }}) CreateView() string {
+	{{ Switch "createView" . }}
+}
+{{- end }}
+{{- if .Config.HasComments }}
+
+// Comments returns the statements setting the table and columns comments.
+func (r {{ .StructName }}) Comments() []string {
+	{{ Switch "comments" . }}
+}
+{{- end }}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r {{ .StructName }}) Schema() []string {
+	stmts := []string{r.CreateTable()}
+{{- if .Config.Indexes }}
+	stmts = append(stmts, r.CreateIndexes()...)
+{{- end }}
+{{- if .Config.CurrentView }}
+	stmts = append(stmts, r.CreateView())
+{{- end }}
+{{- if .Config.HasComments }}
+	stmts = append(stmts, r.Comments()...)
+{{- end }}
+	return stmts
 }
 
 func (r {{ .StructName }}) Insert() string {
@@ -48,9 +104,227 @@ func (r {{ .StructName }}) Upsert() string {
 func (r {{ .StructName }}) Delete() string {
 	return fmt.Sprintf(` + "`" + `
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET {{ Join .Config.DeleteSet }}
 		WHERE {{ JoinAnd .Config.WhereConditions }}
 		AND delete_height IS NULL
+		AND height <= :height
+	` + "`" + `, r.tableName)
+}
+{{- if .Config.History }}
+
+func (r {{ .StructName }}) HistoryName() string { return r.tableName + "_history" }
+
+func (r {{ .StructName }}) CreateHistoryTable() string {
+	{{ Switch "createHistoryTable" . }}
+}
+
+func (r {{ .StructName }}) CreateHistoryIndex() string {
+	{{ Switch "createHistoryIndex" . }}
+}
+
+// HistorySchema returns the statements defining the history table, in execution order.
+func (r {{ .StructName }}) HistorySchema() []string {
+	return []string{r.CreateHistoryTable(), r.CreateHistoryIndex()}
+}
+
+// InsertHistory returns the statement recording a write in the history table,
+// skipped when the row is already at that height or a later one, like Upsert.
+func (r {{ .StructName }}) InsertHistory() string {
+	{{ Switch "insertHistory" . }}
+}
+
+// DeleteHistory returns the statement recording a delete in the history table,
+// skipped when there's no live row at that height or an earlier one, like Delete.
+func (r {{ .StructName }}) DeleteHistory() string {
+	{{ Switch "deleteHistory" . }}
+}
+
+// SelectHistoryAt returns a query rebuilding the rows matching filterColumn as
+// they were at a given height. Parameters are chain name, filterColumn value
+// and height, in this order.
+func (r {{ .StructName }}) SelectHistoryAt(filterColumn string) string {
+	{{ Switch "selectHistoryAt" . }}
+}
+{{- end }}
+{{- if .Config.Model }}
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r {{ .StructName }}) SelectByUnique(q sqlx.Queryer, {{ Join .Config.UniqueParams }}) (models.{{ .Config.Model.Name }}, error) {
+	var row models.{{ .Config.Model.Name }}
+	err := sqlx.Get(q, &row, fmt.Sprintf(` + "`" + `
+		SELECT {{ Join .Config.SelectColumns }}
+		FROM %s
+		WHERE {{ JoinAnd .Config.UniquePlaceholders }}
+		AND delete_height IS NULL
+	` + "`" + `, r.tableName), {{ Join .Config.UniqueArgs }})
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r {{ .StructName }}) ListByChain(q sqlx.Queryer, chainName string) ([]models.{{ .Config.Model.Name }}, error) {
+	var rows []models.{{ .Config.Model.Name }}
+	err := sqlx.Select(q, &rows, fmt.Sprintf(` + "`" + `
+		SELECT {{ Join .Config.SelectColumns }}
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	` + "`" + `, r.tableName), chainName)
+	return rows, err
+}
+{{- end }}
+{{- /* Statements rendered for each dialect, see switchFunc. */}}
+{{- define "createTable" }}
+fmt.Sprintf(` + "`" + `
+		CREATE TABLE IF NOT EXISTS %s
+		({{ Join (.Config.ColumnsDefinition .Dialect) }})
 	` + "`" + `, r.tableName)
+{{- end }}
+{{- define "createIndexes" }}
+[]string{
+{{- range .Config.Indexes }}
+		fmt.Sprintf(` + "`" + `{{ $.Config.IndexStatement $.Dialect . }}` + "`" + `, {{ $.Config.IndexArgs $.Dialect . }}),
+{{- end }}
+	}
+{{- end }}
+{{- define "createView" }}
+fmt.Sprintf(` + "`" + `{{ .Config.ViewStatement .Dialect }}` + "`" + `, {{ .Config.ViewArgs .Dialect }})
+{{- end }}
+{{- define "comments" }}
+{{- if .Config.CommentStatements .Dialect }}
+[]string{
+{{- range .Config.CommentStatements .Dialect }}
+		fmt.Sprintf(` + "`" + `{{ . }}` + "`" + `, r.tableName),
+{{- end }}
+	}
+{{- else }}
+nil
+{{- end }}
+{{- end }}
+{{- define "createHistoryTable" }}
+fmt.Sprintf(` + "`" + `
+		CREATE TABLE IF NOT EXISTS %s
+		({{ Join (.Config.HistoryColumnsDefinition .Dialect) }})
+	` + "`" + `, r.HistoryName())
+{{- end }}
+{{- define "createHistoryIndex" }}
+{{- if eq .Dialect "sqlite" }}
+fmt.Sprintf(` + "`" + `
+		CREATE INDEX IF NOT EXISTS %s
+		ON %s ({{ Join .Config.HistoryIndexColumns }})
+	` + "`" + `, database.SameSchema(r.HistoryName(), "{{ .Config.Name }}_history_height_idx"), database.Unqualified(r.HistoryName()))
+{{- else }}
+fmt.Sprintf(` + "`" + `
+		CREATE INDEX IF NOT EXISTS {{ .Config.Name }}_history_height_idx
+		ON %s ({{ Join .Config.HistoryIndexColumns }})
+	` + "`" + `, r.HistoryName())
+{{- end }}
+{{- end }}
+{{- define "insertHistory" }}
+{{- if eq .Dialect "sqlite" }}
+fmt.Sprintf(` + "`" + `
+		INSERT INTO %s ({{ Join .Config.HistoryInsertColumns }})
+		SELECT {{ Join (.Config.HistoryKeyValues .Dialect) }}, 'write',
+		(SELECT json_object({{ Join .Config.OldValueObject }}) FROM %s AS t WHERE {{ JoinAnd .Config.OldValueConditions }}),
+		json_object({{ Join (.Config.NewValueObject .Dialect) }})
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE {{ JoinAnd .Config.KeyConditions }} AND t.height >= :height)
+	` + "`" + `, r.HistoryName(), r.tableName, r.tableName)
+{{- else }}
+fmt.Sprintf(` + "`" + `
+		INSERT INTO %s ({{ Join .Config.HistoryInsertColumns }})
+		SELECT {{ Join (.Config.HistoryKeyValues .Dialect) }}, 'write',
+		(SELECT to_jsonb(t) FROM %s AS t WHERE {{ JoinAnd .Config.OldValueConditions }}),
+		jsonb_build_object({{ Join (.Config.NewValueObject .Dialect) }})
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE {{ JoinAnd .Config.KeyConditions }} AND t.height >= :height)
+	` + "`" + `, r.HistoryName(), r.tableName, r.tableName)
+{{- end }}
+{{- end }}
+{{- define "deleteHistory" }}
+fmt.Sprintf(` + "`" + `
+		INSERT INTO %s ({{ Join .Config.HistoryInsertColumns }})
+		SELECT {{ Join (.Config.HistoryKeyValues .Dialect) }}, 'delete',
+		{{ if eq .Dialect "sqlite" }}json_object({{ Join .Config.OldValueObject }}){{ else }}to_jsonb(t){{ end }},
+		NULL
+		FROM %s AS t
+		WHERE {{ JoinAnd .Config.OldValueConditions }} AND t.height <= :height
+	` + "`" + `, r.HistoryName(), r.tableName)
+{{- end }}
+{{- define "selectHistoryAt" }}
+{{- if eq .Dialect "sqlite" }}
+fmt.Sprintf(` + "`" + `
+		SELECT {{ Join (.Config.HistoryStateColumns .Dialect) }}
+		FROM (
+			SELECT operation, new_value,
+			ROW_NUMBER() OVER (PARTITION BY {{ Join .Config.UniqueColumns }} ORDER BY height DESC, id DESC) AS rn
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+		) AS h
+		WHERE h.rn = 1 AND h.operation <> 'delete'
+	` + "`" + `, r.HistoryName(), filterColumn)
+{{- else }}
+fmt.Sprintf(` + "`" + `
+		SELECT {{ Join (.Config.HistoryStateColumns .Dialect) }}
+		FROM (
+			SELECT DISTINCT ON ({{ Join .Config.UniqueColumns }}) operation, new_value
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+			ORDER BY {{ Join .Config.UniqueColumns }}, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	` + "`" + `, r.HistoryName(), filterColumn)
+{{- end }}
+{{- end }}
+`
+
+const modelTmpl = `// This file was automatically generated. Please do not edit manually.
+
+package models
+
+// {{ .Config.Model.Name }} {{ .Config.Model.Doc }}
+type {{ .Config.Model.Name }} struct {
+	TracelistenerDatabaseRow
+{{ range .Config.ModelFields }}
+	{{ .Name }} {{ .GoType }} {{ .Tag }}
+{{- end }}
+}
+
+// WithChainName implements the DatabaseEntrier interface.
+func (r {{ .Config.Model.Name }}) WithChainName(cn string) DatabaseEntrier {
+	r.ChainName = cn
+	return r
+}
+`
+
+const migrationsTmpl = `// This file was automatically generated. Please do not edit manually.
+
+package {{ .PackageName }}
+
+import (
+	"github.com/emerishq/tracelistener/database"
+)
+
+// Migrations contains the schema changes generated from sqlmodels.yaml, in version order.
+var Migrations = []database.Migration{ {{- if .Migrations }}
+{{- range .Migrations }}
+	{
+		Version: {{ .Version }},
+		Name:    "{{ .Name }}",
+		Up: ` + "`" + `{{ .Up }}` + "`" + `,
+		Down: ` + "`" + `{{ .Down }}` + "`" + `,
+{{- if .Idempotent }}
+		Idempotent: true,
+{{- end }}
+{{- if .Dialects }}
+		Dialects: map[database.Dialect]database.DialectMigration{
+{{- range .Dialects }}
+			{{ .Ident }}: {
+				Up: ` + "`" + `{{ .Up }}` + "`" + `,
+				Down: ` + "`" + `{{ .Down }}` + "`" + `,
+			},
+{{- end }}
+		},
+{{- end }}
+	},
+{{- end }}
+{{ end -}}
 }
 `
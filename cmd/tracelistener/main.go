@@ -4,7 +4,9 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -12,14 +14,18 @@ import (
 	"github.com/pkg/profile"
 	"go.uber.org/zap"
 
+	dbutils "github.com/emerishq/tracelistener/database"
 	"github.com/emerishq/tracelistener/exporter"
 	"github.com/emerishq/tracelistener/logging"
 	"github.com/emerishq/tracelistener/tracelistener"
 	"github.com/emerishq/tracelistener/tracelistener/blocktime"
+	"github.com/emerishq/tracelistener/tracelistener/bootstrap"
 	"github.com/emerishq/tracelistener/tracelistener/bulk"
 	"github.com/emerishq/tracelistener/tracelistener/config"
 	"github.com/emerishq/tracelistener/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/gap"
 	"github.com/emerishq/tracelistener/tracelistener/processor"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
 var (
@@ -62,14 +68,47 @@ func main() {
 
 	dpi.StartBackgroundProcessing()
 
-	database.RegisterMigration(dpi.DatabaseMigrations()...)
-	database.RegisterMigration(blocktime.CreateTable)
+	database.RegisterMigration(blocktime.CreateTable(cfg.DatabaseName))
+	database.RegisterVersionedMigration(tables.Migrations...)
+	database.RegisterSeed(dpi.DatabaseSeeds()...)
+
+	if len(ca.args) > 0 {
+		switch ca.args[0] {
+		case "migrate":
+			err = migrate(cfg, ca.args[1:])
+		case "verify":
+			err = verifyState(ca, cfg, dpi, logger, ca.args[1:])
+		default:
+			logger.Fatalw("unknown command", "command", ca.args[0])
+		}
+
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		return
+	}
 
-	di, err := database.New(cfg.DatabaseConnectionURL)
+	dialect, err := dbutils.ParseDialect(cfg.DatabaseDialect)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
+	di, err := database.NewWithOptions(cfg.DatabaseConnectionURL, database.Options{
+		Dialect: dialect,
+		Schema:  cfg.DatabaseName,
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if ca.deadLetterPath != "" {
+		replayDeadLetter(di, ca.deadLetterPath, logger)
+		return
+	}
+
+	deadLetter := database.NewDeadLetter(cfg.DeadLetterPath)
+
 	errChan := make(chan error)
 	watcher := tracelistener.TraceWatcher{
 		WatchedOps: []tracelistener.Operation{
@@ -82,14 +121,13 @@ func main() {
 		DataSourcePath: cfg.FIFOPath,
 	}
 
-	if ca.existingDatabasePath != "" {
-		importer := bulk.Importer{
-			Path:         ca.existingDatabasePath,
-			TraceWatcher: watcher,
-			Processor:    dpi,
-			Logger:       logger,
-			Database:     di,
-			Modules:      ca.bulkImportModulesSlice(),
+	if ca.existingDatabasePath != "" || ca.genesisPath != "" {
+		importer := newImporter(ca.existingDatabasePath, ca, cfg, dpi, di, watcher, logger)
+		importer.GenesisPath = ca.genesisPath
+
+		// a backfill runs along with the live tracelistener, which serves on that port
+		if !ca.bulkImportBackfill {
+			go importer.ListenAndServeHTTP(cfg.ExporterHTTPPort)
 		}
 
 		if err := importer.Do(); err != nil {
@@ -99,8 +137,48 @@ func main() {
 		return
 	}
 
-	blw := blocktime.New(
+	lastCommitted, err := di.Checkpoint(cfg.ChainName)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	logger.Infow("last committed height", "height", lastCommitted)
+
+	markDegraded(di, deadLetter, cfg.ChainName, logger)
+
+	// put the gap detector between the watcher and the processor
+	tracedOps := make(chan tracelistener.TraceOperation)
+	watcher.DataChan = tracedOps
+	detectedOps := tracedOps
+
+	exporterOpts := []exporter.Option{exporter.WithLogger(logger)}
+
+	// when bootstrapping, traces are held between the watcher and the gap detector
+	// until the chain state has been imported
+	var (
+		buffer   *bootstrap.Buffer
+		importer *bulk.Importer
+	)
+	if ca.bootstrapDatabasePath != "" {
+		importer = newImporter(ca.bootstrapDatabasePath, ca, cfg, dpi, di, watcher, logger)
+		exporterOpts = append(exporterOpts, exporter.WithHandler("/progress", importer.ProgressHandler))
+
+		buffer = bootstrap.NewBuffer(ca.bootstrapBufferLimit)
+		buffer.OnFull = func(limit int) {
+			logger.Warnw("bootstrap buffer full, live tracing is blocked until the import is done", "limit", limit)
+		}
+		buffer.OnReplay = func(buffered, replayed int) {
+			logger.Infow("buffered traces replayed, switching to live tracing", "buffered", buffered, "replayed", replayed)
+		}
+
+		bufferedOps := make(chan tracelistener.TraceOperation)
+		go buffer.Forward(tracedOps, bufferedOps)
+		detectedOps = bufferedOps
+	}
+
+	blw := blocktime.NewWithSchema(
 		di.Instance,
+		cfg.DatabaseName,
 		cfg.ChainName,
 		logger,
 	)
@@ -117,7 +195,7 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	traceExporter, err := exporter.New(exporter.WithLogger(logger))
+	traceExporter, err := exporter.New(exporterOpts...)
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -125,6 +203,29 @@ func main() {
 
 	go watcher.Watch(traceExporter)
 
+	if buffer != nil {
+		lastCommitted = bootstrapImport(importer, cfg, dpi, di, errChan, logger)
+		buffer.Release(lastCommitted)
+	}
+
+	// gap re-imports write along with live tracing, one at a time
+	writes := &sync.Mutex{}
+
+	go gap.Detector{
+		LastCommitted: lastCommitted,
+		OnGap: func(g gap.Gap) {
+			handleGap(g, cfg, di, writes, logger)
+		},
+	}.Forward(detectedOps, dpi.OpsChan())
+
+	// blocks are written in order by a single writer, database latency doesn't hold
+	// the main loop
+	go func() {
+		for b := range dpi.WritebackChan() {
+			writeBlock(di, dpi, deadLetter, writes, cfg.ChainName, b, logger)
+		}
+	}()
+
 	for {
 		select {
 		case e := <-errChan:
@@ -136,29 +237,215 @@ func main() {
 				"error", te.InnerError,
 				"data", te.Data,
 				"moduleName", te.Module)
-		case b := <-dpi.WritebackChan():
-			for _, p := range b {
-				wbUnits := p.SplitStatementToDBLimit()
-				for _, wbUnit := range wbUnits {
-					is := wbUnit.InterfaceSlice()
-					if len(is) == 0 {
-						continue
-					}
-
-					// Add a Jitter of [50..500] Millisecond in DB add.
-					if err := di.Add(wbUnit.Statement, is, database.Jitter(time.Millisecond*500, 10)); err != nil {
-						logger.Errorw("database error",
-							"error", err,
-							"statement", wbUnit.Statement,
-							"type", wbUnit.Type,
-							"data", fmt.Sprint(wbUnit.Data),
-						)
-					}
-
-				}
+		}
+	}
+}
+
+// newImporter returns an importer of the chain database at path, configured by the -import flags.
+func newImporter(path string, ca cliArgs, cfg *config.Config, dpi tracelistener.DataProcessor, di *database.Instance, watcher tracelistener.TraceWatcher, logger *zap.SugaredLogger) *bulk.Importer {
+	return &bulk.Importer{
+		Path:         path,
+		TraceWatcher: watcher,
+		Processor:    dpi,
+		Logger:       logger,
+		Database:     di,
+		Modules:      ca.bulkImportModulesSlice(),
+		Backend:      bulk.Backend(ca.bulkImportBackend),
+		Height:       ca.bulkImportHeight,
+		BatchSize:    ca.bulkImportBatchSize,
+		BatchBytes:   ca.bulkImportBatchBytes,
+		Writers:      ca.bulkImportWriters,
+		Backfill:     ca.bulkImportBackfill,
+		ChainName:    cfg.ChainName,
+	}
+}
+
+// bootstrapImport imports the chain state with importer while live traces are being buffered,
+// records its height as the last committed one and restarts live processing.
+// It returns the imported height.
+func bootstrapImport(importer *bulk.Importer, cfg *config.Config, dpi tracelistener.DataProcessor, di *database.Instance, errChan <-chan error, logger *zap.SugaredLogger) uint64 {
+	// the main loop isn't running yet, log watching errors meanwhile
+	importDone := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-importDone:
+				return
+			case e := <-errChan:
+				logger.Errorw("watching error", "error", e)
 			}
 		}
+	}()
+
+	err := importer.Do()
+	close(importDone)
+
+	if err != nil {
+		logger.Fatalw("bootstrap import error", "error", err)
+	}
+
+	height := uint64(importer.ImportedHeight())
+
+	// gaps are detected from the imported height on
+	if err := di.AddBlock(cfg.ChainName, tracelistener.BlockWriteback{Height: height}); err != nil {
+		logger.Fatalw("cannot record bootstrap checkpoint", "error", err, "height", height)
 	}
+
+	logger.Infow("bootstrap import done, replaying buffered traces", "height", height)
+
+	// the importer stopped background processing and set upserts as it needed
+	dpi.SetDBUpsertEnabled(true)
+	dpi.SetBackfill(false)
+	dpi.StartBackgroundProcessing()
+
+	return height
+}
+
+// writeBlock writes b to the database, retrying transient errors with backoff.
+// Blocks which cannot be written are stored in deadLetter, and the caches of dpi invalidated.
+// Each attempt holds writes.
+func writeBlock(di *database.Instance, dpi tracelistener.DataProcessor, deadLetter *database.DeadLetter, writes sync.Locker, chainName string, b tracelistener.BlockWriteback, logger *zap.SugaredLogger) {
+	err := database.DefaultBackoff.Do(func() error {
+		writes.Lock()
+		defer writes.Unlock()
+
+		return di.AddBlock(chainName, b)
+	}, func(retry int, delay time.Duration, err error) {
+		logger.Warnw("retryable database error",
+			"error", err,
+			"height", b.Height,
+			"retry", retry+1,
+			"delay", delay,
+		)
+	})
+
+	if err == nil {
+		return
+	}
+
+	logger.Errorw("database error",
+		"error", err,
+		"retryable", database.IsRetryable(err),
+		"height", b.Height,
+		"ops", len(b.Ops),
+		"dead_letter", deadLetter.Path(),
+	)
+
+	if err := deadLetter.Write(chainName, b, err); err != nil {
+		logger.Errorw("cannot write to dead-letter file, data is lost", "error", err, "height", b.Height)
+	}
+
+	// what the processor cached while flushing the block isn't in the database
+	dpi.InvalidateCaches()
+
+	// the database is likely unavailable, markDegraded records it again on restart
+	if err := di.MarkDegraded(chainName, b.Height, b.Height); err != nil {
+		logger.Errorw("cannot mark checkpoint as degraded", "error", err, "height", b.Height)
+	}
+}
+
+// markDegraded marks the checkpoint of chainName as degraded while the dead-letter
+// file holds blocks of chainName which haven't been replayed.
+func markDegraded(di *database.Instance, deadLetter *database.DeadLetter, chainName string, logger *zap.SugaredLogger) {
+	start, end, ok, err := database.DegradedRange(deadLetter.Path(), chainName)
+	if err != nil {
+		logger.Errorw("cannot read dead-letter file", "error", err, "path", deadLetter.Path())
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	logger.Warnw("dead-lettered blocks haven't been replayed, database is inconsistent with chain state",
+		"path", deadLetter.Path(),
+		"start", start,
+		"end", end,
+	)
+
+	if err := di.MarkDegraded(chainName, start, end); err != nil {
+		logger.Errorw("cannot mark checkpoint as degraded", "error", err, "start", start, "end", end)
+	}
+}
+
+// replayDeadLetter replays the dead-letter file at path, keeping the entries which fail again.
+func replayDeadLetter(di *database.Instance, path string, logger *zap.SugaredLogger) {
+	replayed, failed, err := di.ReplayDeadLetter(path)
+	if err != nil {
+		logger.Fatalw("cannot replay dead-letter file", "error", err, "path", path)
+	}
+
+	for _, e := range failed {
+		logger.Errorw("dead-letter entry failed again",
+			"error", e.Error,
+			"height", e.Height,
+			"module", e.Module,
+		)
+	}
+
+	logger.Infow("dead-letter replay done", "path", path, "replayed", replayed, "failed", len(failed))
+}
+
+// handleGap records g in the checkpoints table and, if configured, re-imports
+// the chain state in the background to fill it, while live traces keep being processed;
+// batches and blocks are written one at a time, holding writes.
+func handleGap(g gap.Gap, cfg *config.Config, di *database.Instance, writes sync.Locker, logger *zap.SugaredLogger) {
+	logger.Errorw("BLOCKS MISSED SINCE LAST RUN, DATABASE IS INCONSISTENT WITH CHAIN STATE",
+		"gap", g.String(),
+		"reimport_on_gap", cfg.ReimportOnGap,
+	)
+
+	if err := di.SetCheckpointStatus(cfg.ChainName, database.CheckpointStatusGap, g.Start, g.End); err != nil {
+		logger.Errorw("cannot record gap", "error", err)
+	}
+
+	if !cfg.ReimportOnGap {
+		return
+	}
+
+	go reimportGap(g, cfg, di, writes, logger)
+}
+
+// reimportGap backfills the modules of the enabled processors with the chain state at the
+// last height of g: rows written by live tracing since then are newer, and left as is.
+// Rows are upserted only, the ones deleted during g are left as they were.
+func reimportGap(g gap.Gap, cfg *config.Config, di *database.Instance, writes sync.Locker, logger *zap.SugaredLogger) {
+	// the live processor is busy with traces, use a dedicated one
+	dp, err := processor.New(logger, cfg)
+	if err != nil {
+		logger.Errorw("cannot create re-import processor", "error", err)
+		return
+	}
+
+	ip := dp.(*processor.Processor)
+	ip.StartBackgroundProcessing()
+
+	importer := bulk.Importer{
+		Path:      cfg.ReimportDatabasePath,
+		Processor: ip,
+		Logger:    logger,
+		Database:  di,
+		Modules:   ip.SDKModules(),
+		Height:    int64(g.End),
+		Backfill:  true,
+		WriteLock: writes,
+	}
+
+	logger.Infow("re-importing gap", "gap", g.String(), "modules", importer.Modules)
+
+	if err := importer.Do(); err != nil {
+		logger.Errorw("GAP RE-IMPORT FAILED, DATABASE IS INCONSISTENT WITH CHAIN STATE",
+			"gap", g.String(),
+			"error", err,
+		)
+		return
+	}
+
+	if err := di.SetCheckpointStatus(cfg.ChainName, database.CheckpointStatusReimportedUpserts, g.Start, g.End); err != nil {
+		logger.Errorw("cannot record gap re-import", "error", err)
+	}
+
+	logger.Warnw("gap re-imported, rows deleted during the gap are still there", "gap", g.String())
 }
 
 func buildLogger(c *config.Config) *zap.SugaredLogger {
@@ -185,8 +472,20 @@ func connectTendermint(b *blocktime.Watcher, l *zap.SugaredLogger) {
 
 type cliArgs struct {
 	existingDatabasePath       string
+	bootstrapDatabasePath      string
+	bootstrapBufferLimit       int
+	genesisPath                string
 	bulkImportModules          string
 	bulkImportSupportedModules bool
+	bulkImportBackend          string
+	bulkImportHeight           int64
+	bulkImportBatchSize        int
+	bulkImportBatchBytes       int
+	bulkImportWriters          int
+	bulkImportBackfill         bool
+	deadLetterPath             string
+	verifyRepairPath           string
+	args                       []string
 }
 
 func (c cliArgs) bulkImportModulesSlice() []string {
@@ -206,9 +505,26 @@ func readCLI() cliArgs {
 	ca := cliArgs{}
 
 	flag.StringVar(&ca.existingDatabasePath, "import", "", "import LevelDB database data from the path given, usually you want to process `application.db'; will import all modules listed by `-import-modules-list` if `-import-modules` is not specified")
+	flag.StringVar(&ca.bootstrapDatabasePath, "bootstrap", "", "import the chain state from the LevelDB database at the given path while buffering live traces, then replay the traces of later blocks and keep tracing; the -import-* flags apply")
+	flag.IntVar(&ca.bootstrapBufferLimit, "bootstrap-buffer", bootstrap.DefaultLimit, "number of live traces held in memory while bootstrapping, reading traces blocks once it's reached until the import is done")
+	flag.StringVar(&ca.genesisPath, "import-genesis", "", "import the chain state from the app state of the genesis file exported at the given path instead of a LevelDB database, at the height it was exported at; the -import-* flags but -import-height apply")
 	flag.StringVar(&ca.bulkImportModules, "import-modules", "", "comma-separated list of modules to be imported")
+	flag.StringVar(&ca.bulkImportBackend, "import-backend", "", fmt.Sprintf("database backend of the chain database to import, one of %v compiled in; detected from its files if empty", bulk.Backends()))
+	flag.Int64Var(&ca.bulkImportHeight, "import-height", 0, "import the chain state at the given height instead of the latest one, which must not have been pruned from the database given to -import")
+	flag.IntVar(&ca.bulkImportBatchSize, "import-batch-size", bulk.DefaultBatchSize, "number of keys imported between two database writes")
+	flag.IntVar(&ca.bulkImportBatchBytes, "import-batch-bytes", bulk.DefaultBatchBytes, "size in bytes of the keys and values imported between two database writes")
+	flag.IntVar(&ca.bulkImportWriters, "import-writers", 1, "number of batches written to the database concurrently; batches are written in order with a single writer, which delegated tokens and aggregates rely on")
+	flag.BoolVar(&ca.bulkImportBackfill, "import-backfill", false, "import into a database live tracing is already filling, only replacing rows older than the imported height; usually along with -import-modules, while tracelistener keeps running")
 	flag.BoolVar(&ca.bulkImportSupportedModules, "import-modules-list", false, "list supported modules in bulk import mode")
+	flag.StringVar(&ca.deadLetterPath, "replay-dead-letter", "", "replay database writes stored in the dead-letter file at the given path, then exit; entries failing again are kept in the file")
+	flag.StringVar(&ca.verifyRepairPath, "verify-repair", "", "with verify, write the statements bringing the database in line with the chain state to the given path, to be applied with -replay-dead-letter")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [migrate status|up|down [n] | verify [application.db]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	ca.args = flag.Args()
+
 	return ca
 }
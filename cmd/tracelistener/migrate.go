@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	dbutils "github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/config"
+	"github.com/emerishq/tracelistener/tracelistener/database"
+)
+
+// migrate runs the migrate subcommand: args are "status", "up", or "down" followed
+// by an optional amount of migrations to revert, one by default.
+func migrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command, use one of status, up, down")
+	}
+
+	dialect, err := dbutils.ParseDialect(cfg.DatabaseDialect)
+	if err != nil {
+		return err
+	}
+
+	di, err := database.OpenWithOptions(cfg.DatabaseConnectionURL, database.Options{
+		Dialect: dialect,
+		Schema:  cfg.DatabaseName,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = di.Instance.Close()
+	}()
+
+	switch args[0] {
+	case "up":
+		if err := di.RunMigrations(); err != nil {
+			return err
+		}
+
+		return printMigrationStatus(di)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid amount of migrations to revert %q", args[1])
+			}
+		}
+
+		m, err := di.Migrator()
+		if err != nil {
+			return err
+		}
+
+		reverted, err := m.Down(n)
+		for _, r := range reverted {
+			fmt.Printf("reverted %d %s\n", r.Version, r.Name)
+		}
+
+		return err
+	case "status":
+		return printMigrationStatus(di)
+	default:
+		return fmt.Errorf("unknown migrate command %s, use one of status, up, down", args[0])
+	}
+}
+
+func printMigrationStatus(di *database.Instance) error {
+	m, err := di.Migrator()
+	if err != nil {
+		return err
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range status {
+		appliedAt := "-"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+	}
+
+	return w.Flush()
+}
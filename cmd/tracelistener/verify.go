@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	dbutils "github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/config"
+	"github.com/emerishq/tracelistener/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/verify"
+)
+
+var errDrift = errors.New("database doesn't match the chain state")
+
+// verifyState runs the verify subcommand: args hold the path of the chain database to compare
+// the database with, which can be omitted along with -import-genesis.
+// The -import-* flags select the modules and height verified.
+func verifyState(ca cliArgs, cfg *config.Config, dpi tracelistener.DataProcessor, logger *zap.SugaredLogger, args []string) error {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if path == "" && ca.genesisPath == "" {
+		return fmt.Errorf("missing chain database path, use verify <application.db> or -import-genesis")
+	}
+
+	dialect, err := dbutils.ParseDialect(cfg.DatabaseDialect)
+	if err != nil {
+		return err
+	}
+
+	di, err := database.OpenWithOptions(cfg.DatabaseConnectionURL, database.Options{
+		Dialect: dialect,
+		Schema:  cfg.DatabaseName,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = di.Instance.Close()
+	}()
+
+	importer := newImporter(path, ca, cfg, dpi, di, tracelistener.TraceWatcher{}, logger)
+	importer.GenesisPath = ca.genesisPath
+
+	v := verify.Verifier{
+		Importer:  importer,
+		Database:  di,
+		ChainName: cfg.ChainName,
+		Logger:    logger,
+	}
+
+	if ca.verifyRepairPath != "" {
+		v.Repair = database.NewDeadLetter(ca.verifyRepairPath)
+	}
+
+	r, err := v.Do()
+	if err != nil {
+		return err
+	}
+
+	if err := printReport(r); err != nil {
+		return err
+	}
+
+	if !r.Drifted() {
+		return nil
+	}
+
+	if ca.verifyRepairPath != "" {
+		logger.Infow("repair statements written, apply them with -replay-dead-letter", "path", ca.verifyRepairPath)
+	}
+
+	return errDrift
+}
+
+func printReport(r verify.Report) error {
+	fmt.Printf("verified height %d\n", r.Height)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tEXPECTED\tMATCHING\tMISSING\tEXTRA\tDIFFERING\tNEWER")
+	for _, t := range r.Tables {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", t.Table, t.Expected, t.Matching, t.Missing, t.Extra, t.Differing, t.Newer)
+	}
+
+	return w.Flush()
+}
@@ -9,6 +9,7 @@ import (
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 const (
@@ -18,29 +19,52 @@ const (
 
 // Instance contains a database connection instance.
 type Instance struct {
-	DB *sqlx.DB
+	DB      *sqlx.DB
+	Dialect Dialect
+
+	connString string
 }
 
-// New returns an Instance connected to the database pointed by connString.
+// New returns an Instance connected to the CockroachDB database pointed by connString.
 func New(connString string) (*Instance, error) {
 	return NewWithDriver(connString, DriverPGX)
 }
 
-// NewWithDriver returns an Instance connected to the database pointed by connString with the given driver.
+// NewWithDriver returns an Instance connected to the CockroachDB database pointed by connString with the given driver.
 func NewWithDriver(connString string, driver string) (*Instance, error) {
+	return connect(connString, driver, DialectCockroachDB)
+}
+
+// NewWithDialect returns an Instance connected to the database pointed by connString, which speaks dialect.
+func NewWithDialect(connString string, dialect Dialect) (*Instance, error) {
+	return connect(connString, dialect.Driver(), dialect)
+}
+
+func connect(connString string, driver string, dialect Dialect) (*Instance, error) {
 	db, err := sqlx.Connect(driver, connString)
 	if err != nil {
 		return nil, err
 	}
 
 	i := &Instance{
-		DB: db,
+		DB:         db,
+		Dialect:    dialect,
+		connString: connString,
 	}
 
 	if err := i.DB.Ping(); err != nil {
 		return nil, fmt.Errorf("cannot ping db, %w", err)
 	}
 
+	if dialect == DialectSQLite {
+		// Attached schemas only exist on the connection which attached them,
+		// and SQLite serializes writes anyway: keep a single connection open.
+		i.DB.DB.SetMaxOpenConns(1)
+		i.DB.DB.SetMaxIdleConns(1)
+		i.DB.DB.SetConnMaxLifetime(0)
+		return i, nil
+	}
+
 	i.DB.DB.SetMaxOpenConns(25)
 	i.DB.DB.SetMaxIdleConns(25)
 	i.DB.DB.SetConnMaxLifetime(5 * time.Minute)
@@ -48,6 +72,53 @@ func NewWithDriver(connString string, driver string) (*Instance, error) {
 	return i, nil
 }
 
+// CreateSchema creates the namespace holding tables qualified with name if it doesn't exist:
+// a database on CockroachDB, a schema on PostgreSQL, and an attached database on SQLite.
+func (i *Instance) CreateSchema(name string) error {
+	var err error
+	switch i.Dialect {
+	case DialectSQLite:
+		var attached int
+		if err = i.DB.Get(&attached, `SELECT count(*) FROM pragma_database_list WHERE name = $1`, name); err != nil {
+			break
+		}
+
+		if attached == 0 {
+			_, err = i.DB.Exec(fmt.Sprintf(`ATTACH DATABASE $1 AS %s`, name), i.connString)
+		}
+	case DialectPostgres:
+		_, err = i.DB.Exec(fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, name))
+	default:
+		_, err = i.DB.Exec(fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s`, name))
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot create schema %s, %w", name, err)
+	}
+
+	return nil
+}
+
+// ExecuteTx runs f in a transaction, which is committed if f returns nil and rolled back otherwise.
+// On CockroachDB the transaction is retried following its client-side retry semantics.
+func (i *Instance) ExecuteTx(f func(*sqlx.Tx) error) error {
+	if i.Dialect == DialectCockroachDB || i.Dialect == "" {
+		return crdbsqlx.ExecuteTx(context.Background(), i.DB, nil, f)
+	}
+
+	tx, err := i.DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction, %w", err)
+	}
+
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // Close closes the connection held by i.
 func (i *Instance) Close() error {
 	return i.DB.Close()
@@ -56,7 +127,7 @@ func (i *Instance) Close() error {
 // Exec executes query with the given params.
 // If params is nil, query is assumed to be of the `SELECT` kind, and the resulting data will be written in dest.
 func (i *Instance) Exec(query string, params interface{}, dest interface{}) error {
-	return crdbsqlx.ExecuteTx(context.Background(), i.DB, nil, func(tx *sqlx.Tx) error {
+	return i.ExecuteTx(func(tx *sqlx.Tx) error {
 		if dest != nil {
 			if params != nil {
 				return tx.Select(dest, query, params)
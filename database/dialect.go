@@ -0,0 +1,111 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect is the SQL flavour spoken by a database.
+type Dialect string
+
+const (
+	// DialectCockroachDB is the default dialect, statements are written for it
+	// and translated for the other ones when needed.
+	DialectCockroachDB Dialect = "cockroachdb"
+
+	// DialectPostgres targets plain PostgreSQL, where tracelistener tables
+	// live in a schema instead of a database.
+	DialectPostgres Dialect = "postgres"
+
+	// DialectSQLite targets an embedded SQLite file, the connection string
+	// being its path. Schemas are the same file attached under another name.
+	DialectSQLite Dialect = "sqlite"
+
+	DriverSQLite = "sqlite"
+)
+
+// Dialects lists all the supported dialects.
+var Dialects = []Dialect{DialectCockroachDB, DialectPostgres, DialectSQLite}
+
+// ParseDialect returns the Dialect named s, an empty s meaning DialectCockroachDB.
+func ParseDialect(s string) (Dialect, error) {
+	if s == "" {
+		return DialectCockroachDB, nil
+	}
+
+	for _, d := range Dialects {
+		if string(d) == strings.ToLower(s) {
+			return d, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown database dialect %s", s)
+}
+
+// Driver returns the name of the database/sql driver used for d.
+func (d Dialect) Driver() string {
+	if d == DialectSQLite {
+		return DriverSQLite
+	}
+
+	return DriverPGX
+}
+
+// TransactionalDDL reports whether d can change schemas within a transaction, and roll
+// the changes back along with it. CockroachDB can't mix schema changes and writes to the
+// changed tables in the same transaction.
+func (d Dialect) TransactionalDDL() bool {
+	return d == DialectPostgres || d == DialectSQLite
+}
+
+var columnTypes = map[Dialect]map[string]string{
+	DialectPostgres: {
+		"serial":  "bigserial",
+		"integer": "bigint",
+		"int":     "bigint",
+		"string":  "text",
+		"bytes":   "bytea",
+	},
+	DialectSQLite: {
+		// Only INTEGER PRIMARY KEY columns are assigned a value automatically.
+		"serial":      "integer",
+		"bytes":       "blob",
+		"timestamptz": "timestamp",
+	},
+}
+
+// ColumnType translates the CockroachDB column type t to d.
+func (d Dialect) ColumnType(t string) string {
+	lt := strings.ToLower(strings.TrimSpace(t))
+	if ct, ok := columnTypes[d][lt]; ok {
+		return ct
+	}
+
+	// SQLite has no arrays, they're stored as text in the PostgreSQL array format.
+	if d == DialectSQLite && strings.HasSuffix(lt, "[]") {
+		return "text"
+	}
+
+	// SQLite rounds large decimals to floating point numbers, so they're kept as text.
+	if d == DialectSQLite && (strings.HasPrefix(lt, "decimal") || strings.HasPrefix(lt, "numeric")) {
+		return "text"
+	}
+
+	return t
+}
+
+// Unqualified returns name without its schema.
+func Unqualified(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// SameSchema returns name qualified with the schema of tableName, as SQLite expects
+// index names to be.
+func SameSchema(tableName, name string) string {
+	idx := strings.LastIndex(tableName, ".")
+	if idx == -1 {
+		return name
+	}
+
+	return tableName[:idx+1] + name
+}
@@ -2,19 +2,28 @@ package database
 
 import "fmt"
 
-// RunMigrations run all the migrations contained in "migrations" on the database pointed by dbConnString.
+// RunMigrations run all the migrations contained in "migrations" on the CockroachDB database pointed by dbConnString.
 func RunMigrations(dbConnString string, migrations []string) error {
 	c, err := New(dbConnString)
 	if err != nil {
 		return err
 	}
 
-	for i, m := range migrations {
-		_, err := c.DB.Exec(m)
+	if err := c.RunMigrations(migrations); err != nil {
+		return err
+	}
+
+	return c.DB.Close()
+}
+
+// RunMigrations run all the migrations contained in "migrations" on i.
+func (i *Instance) RunMigrations(migrations []string) error {
+	for idx, m := range migrations {
+		_, err := i.DB.Exec(m)
 		if err != nil {
-			return fmt.Errorf("error while running migration #%d, %w", i, err)
+			return fmt.Errorf("error while running migration #%d, %w", idx, err)
 		}
 	}
 
-	return c.DB.Close()
+	return nil
 }
@@ -0,0 +1,480 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+// pgerrUndefinedTable is the error code returned for missing relations.
+const pgerrUndefinedTable = "42P01"
+
+const (
+	createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS %s (
+		version INT8 PRIMARY KEY,
+		name text NOT NULL,
+		checksum text NOT NULL,
+		applied_at %s NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	createMigrationsLockTable = `
+	CREATE TABLE IF NOT EXISTS %s_lock (
+		id integer PRIMARY KEY,
+		owner text NOT NULL,
+		expires_at %s NOT NULL
+	)`
+
+	// Lock expiration is computed by the migrators, since date arithmetic
+	// differs between dialects.
+	acquireMigrationsLock = `
+	INSERT INTO %s_lock as ml
+		(id, owner, expires_at)
+	VALUES
+		(1, $1, $2)
+	ON CONFLICT
+		(id)
+	DO UPDATE SET
+		owner=EXCLUDED.owner,
+		expires_at=EXCLUDED.expires_at
+		WHERE ml.expires_at < $3 OR ml.owner = EXCLUDED.owner
+	`
+
+	releaseMigrationsLock = `DELETE FROM %s_lock WHERE id = 1 AND owner = $1`
+
+	selectAppliedMigrations = `SELECT version, name, checksum, applied_at FROM %s ORDER BY version`
+
+	insertAppliedMigration = `INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)`
+
+	deleteAppliedMigration = `DELETE FROM %s WHERE version = $1`
+)
+
+const (
+	// MigrationApplied means the migration has been applied and its checksum matches.
+	MigrationApplied = "applied"
+
+	// MigrationPending means the migration has not been applied yet.
+	MigrationPending = "pending"
+
+	// MigrationChecksumMismatch means the migration has been applied, but its
+	// statement changed afterwards.
+	MigrationChecksumMismatch = "checksum mismatch"
+
+	// MigrationUnknown means the migration has been applied, but it isn't known
+	// to the running binary anymore.
+	MigrationUnknown = "unknown"
+)
+
+// Migration is a versioned database schema change, applied at most once.
+type Migration struct {
+	// Version orders migrations, it must be unique.
+	Version int64
+
+	// Name describes the migration.
+	Name string
+
+	// Up applies the migration.
+	Up string
+
+	// Down reverts Up, an empty Down means the migration can't be reverted.
+	Down string
+
+	// Dialects overrides Up and Down for the dialects whose syntax differs
+	// from CockroachDB's.
+	Dialects map[Dialect]DialectMigration
+
+	// Idempotent marks migrations whose statements are skipped on missing tables,
+	// since tables are only created for enabled modules, and then with their latest
+	// schema. SQLite lacking ADD COLUMN IF NOT EXISTS, statements adding a column
+	// already created along with its table are skipped as well. sqlgen sets it on the
+	// migrations it generates and on the hand-written ones declaring it, any error
+	// fails the other migrations.
+	Idempotent bool
+}
+
+// DialectMigration holds the statements of a Migration for a given dialect.
+type DialectMigration struct {
+	Up   string
+	Down string
+}
+
+// ForDialect returns m with Up and Down replaced by the ones defined for d, if any.
+func (m Migration) ForDialect(d Dialect) Migration {
+	dm, ok := m.Dialects[d]
+	if !ok {
+		return m
+	}
+
+	m.Up, m.Down = dm.Up, dm.Down
+	return m
+}
+
+// SchemaPlaceholder stands for the schema holding the tables in the statements of
+// migrations which don't know it in advance, like the generated ones.
+const SchemaPlaceholder = "{schema}"
+
+// WithSchema returns m with SchemaPlaceholder replaced by schema in all its statements.
+func (m Migration) WithSchema(schema string) Migration {
+	m.Up = strings.ReplaceAll(m.Up, SchemaPlaceholder, schema)
+	m.Down = strings.ReplaceAll(m.Down, SchemaPlaceholder, schema)
+
+	if len(m.Dialects) == 0 {
+		return m
+	}
+
+	dialects := make(map[Dialect]DialectMigration, len(m.Dialects))
+	for d, dm := range m.Dialects {
+		dialects[d] = DialectMigration{
+			Up:   strings.ReplaceAll(dm.Up, SchemaPlaceholder, schema),
+			Down: strings.ReplaceAll(dm.Down, SchemaPlaceholder, schema),
+		}
+	}
+	m.Dialects = dialects
+
+	return m
+}
+
+// Checksum returns the hex-encoded SHA-256 sum of m.Up.
+func (m Migration) Checksum() string {
+	s := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(s[:])
+}
+
+// MigrationStatus describes the state of a migration on the database.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator applies and reverts versioned migrations, recording them in a table.
+// Concurrent migrators sharing the same table are serialized by a lock held
+// in a companion table, named after it with a "_lock" suffix.
+type Migrator struct {
+	db         *sqlx.DB
+	dialect    Dialect
+	table      string
+	migrations []Migration
+	owner      string
+
+	// LockTimeout is how long the Migrator waits for the lock.
+	LockTimeout time.Duration
+
+	// LockTTL is how long a lock is valid for, after which it's considered abandoned.
+	LockTTL time.Duration
+}
+
+// NewMigrator returns a Migrator recording migrations in table, which is created if missing.
+// Migrations are resolved for the dialect of i.
+// It returns an error if two migrations share the same version.
+func NewMigrator(i *Instance, table string, migrations []Migration) (*Migrator, error) {
+	ms := make([]Migration, len(migrations))
+	copy(ms, migrations)
+	sort.SliceStable(ms, func(a, b int) bool {
+		return ms[a].Version < ms[b].Version
+	})
+
+	for idx := 1; idx < len(ms); idx++ {
+		if ms[idx].Version == ms[idx-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d, %s and %s", ms[idx].Version, ms[idx-1].Name, ms[idx].Name)
+		}
+	}
+
+	for idx := range ms {
+		ms[idx] = ms[idx].ForDialect(i.Dialect)
+	}
+
+	for _, q := range []string{createMigrationsTable, createMigrationsLockTable} {
+		if _, err := i.DB.Exec(fmt.Sprintf(q, table, i.Dialect.ColumnType("timestamptz"))); err != nil {
+			return nil, fmt.Errorf("cannot create migrations table, %w", err)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &Migrator{
+		db:          i.DB,
+		dialect:     i.Dialect,
+		table:       table,
+		migrations:  ms,
+		owner:       fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		LockTimeout: 5 * time.Minute,
+		LockTTL:     10 * time.Minute,
+	}, nil
+}
+
+// Status returns the state of every known and applied migration, ordered by version.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mm := range m.migrations {
+		s := MigrationStatus{
+			Version: mm.Version,
+			Name:    mm.Name,
+			State:   MigrationPending,
+		}
+
+		if a, ok := applied[mm.Version]; ok {
+			at := a.AppliedAt
+			s.AppliedAt = &at
+			s.State = MigrationApplied
+			if a.Checksum != mm.Checksum() {
+				s.State = MigrationChecksumMismatch
+			}
+
+			delete(applied, mm.Version)
+		}
+
+		ret = append(ret, s)
+	}
+
+	for _, a := range applied {
+		at := a.AppliedAt
+		ret = append(ret, MigrationStatus{
+			Version:   a.Version,
+			Name:      a.Name,
+			State:     MigrationUnknown,
+			AppliedAt: &at,
+		})
+	}
+
+	sort.SliceStable(ret, func(a, b int) bool {
+		return ret[a].Version < ret[b].Version
+	})
+
+	return ret, nil
+}
+
+// Up applies all the pending migrations in version order, and returns them.
+// It refuses to run if an applied migration checksum doesn't match anymore.
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+
+		for _, mm := range m.migrations {
+			if a, ok := applied[mm.Version]; ok && a.Checksum != mm.Checksum() {
+				return fmt.Errorf("migration %d (%s) has been changed after being applied", mm.Version, mm.Name)
+			}
+		}
+
+		for _, mm := range m.migrations {
+			if _, ok := applied[mm.Version]; ok {
+				continue
+			}
+
+			err := m.run(mm.Up, mm.Idempotent, func(e execer) error {
+				if _, err := e.Exec(fmt.Sprintf(insertAppliedMigration, m.table), mm.Version, mm.Name, mm.Checksum()); err != nil {
+					return fmt.Errorf("cannot record migration, %w", err)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("cannot apply migration %d (%s), %w", mm.Version, mm.Name, err)
+			}
+
+			done = append(done, mm)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the last n applied migrations, newest first, and returns them.
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+
+		for idx := len(m.migrations) - 1; idx >= 0 && len(done) < n; idx-- {
+			mm := m.migrations[idx]
+			if _, ok := applied[mm.Version]; !ok {
+				continue
+			}
+
+			if mm.Down == "" {
+				return fmt.Errorf("migration %d (%s) cannot be reverted", mm.Version, mm.Name)
+			}
+
+			err := m.run(mm.Down, mm.Idempotent, func(e execer) error {
+				if _, err := e.Exec(fmt.Sprintf(deleteAppliedMigration, m.table), mm.Version); err != nil {
+					return fmt.Errorf("cannot record migration revert, %w", err)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("cannot revert migration %d (%s), %w", mm.Version, mm.Name, err)
+			}
+
+			done = append(done, mm)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// execer runs statements, on the database or within a transaction.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// run executes the statements q of a migration, then record, which keeps track of it.
+// On dialects supporting transactional DDL, they all run in a single transaction so that
+// a failed migration leaves neither changes nor record behind. CockroachDB runs them
+// one by one.
+func (m *Migrator) run(q string, idempotent bool, record func(execer) error) error {
+	if !m.dialect.TransactionalDDL() {
+		if err := exec(m.db, q, idempotent, false); err != nil {
+			return err
+		}
+
+		return record(m.db)
+	}
+
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction, %w", err)
+	}
+
+	if err := exec(tx, q, idempotent, true); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := record(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit migration, %w", err)
+	}
+
+	return nil
+}
+
+// skippedStatement is the savepoint statements of idempotent migrations are rolled back to
+// when skipped within a transaction, which PostgreSQL aborts on errors otherwise.
+const skippedStatement = "migration_statement"
+
+// exec runs the statements of a migration one by one, skipping the ones of idempotent
+// migrations failing on missing tables or existing columns, see Migration.Idempotent.
+// Within a transaction, skipped statements are rolled back to a savepoint.
+func exec(e execer, q string, idempotent bool, inTx bool) error {
+	savepoint := idempotent && inTx
+	for _, stmt := range splitStatements(q) {
+		if savepoint {
+			if _, err := e.Exec("SAVEPOINT " + skippedStatement); err != nil {
+				return err
+			}
+		}
+
+		_, err := e.Exec(stmt)
+		switch {
+		case err == nil:
+		case idempotent && skippableError(err):
+			if savepoint {
+				if _, err := e.Exec("ROLLBACK TO SAVEPOINT " + skippedStatement); err != nil {
+					return err
+				}
+			}
+		default:
+			return err
+		}
+
+		if savepoint {
+			if _, err := e.Exec("RELEASE SAVEPOINT " + skippedStatement); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func skippableError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgerrUndefinedTable
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "no such table") || strings.Contains(msg, "duplicate column name")
+}
+
+func (m *Migrator) applied() (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := m.db.Select(&rows, fmt.Sprintf(selectAppliedMigrations, m.table)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("cannot read applied migrations, %w", err)
+	}
+
+	ret := make(map[int64]appliedMigration, len(rows))
+	for _, r := range rows {
+		ret[r.Version] = r
+	}
+
+	return ret, nil
+}
+
+// withLock runs f while holding the migrations lock.
+func (m *Migrator) withLock(f func() error) error {
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		now := time.Now().UTC()
+		res, err := m.db.Exec(fmt.Sprintf(acquireMigrationsLock, m.table), m.owner, now.Add(m.LockTTL), now)
+		if err != nil {
+			return fmt.Errorf("cannot acquire migrations lock, %w", err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cannot acquire migrations lock, timed out after %s", m.LockTimeout)
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	fErr := f()
+
+	if _, err := m.db.Exec(fmt.Sprintf(releaseMigrationsLock, m.table), m.owner); err != nil && fErr == nil {
+		return fmt.Errorf("cannot release migrations lock, %w", err)
+	}
+
+	return fErr
+}
@@ -0,0 +1,241 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+var testVersionedMigrations = []database.Migration{
+	{
+		Version: 2,
+		Name:    "add third column",
+		Up:      `alter table testdb.table add column third text`,
+		Down:    `alter table testdb.table drop column third`,
+	},
+	{
+		Version: 1,
+		Name:    "create table",
+		Up: `create table testdb.table (
+			id serial primary key,
+			first text not null
+		)`,
+		Down: `drop table testdb.table`,
+	},
+}
+
+func TestMigrator(t *testing.T) {
+	ts, err := testserver.NewTestServer()
+	require.NoError(t, err)
+	require.NoError(t, ts.WaitForInit())
+	defer func() {
+		ts.Stop()
+	}()
+
+	i, err := database.New(ts.PGURL().String())
+	require.NoError(t, err)
+
+	_, err = i.DB.Exec(`create database testdb`)
+	require.NoError(t, err)
+
+	m, err := database.NewMigrator(i, "testdb.schema_migrations", testVersionedMigrations)
+	require.NoError(t, err)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	require.Equal(t, int64(1), status[0].Version)
+	require.Equal(t, database.MigrationPending, status[0].State)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	require.Equal(t, int64(1), applied[0].Version)
+
+	// applying again is a no-op
+	applied, err = m.Up()
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	status, err = m.Status()
+	require.NoError(t, err)
+	for _, s := range status {
+		require.Equal(t, database.MigrationApplied, s.State)
+		require.NotNil(t, s.AppliedAt)
+	}
+
+	_, err = i.DB.Exec(`insert into testdb.table (first, third) values ('a', 'b')`)
+	require.NoError(t, err)
+
+	reverted, err := m.Down(1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	require.Equal(t, int64(2), reverted[0].Version)
+
+	_, err = i.DB.Exec(`insert into testdb.table (first, third) values ('a', 'b')`)
+	require.Error(t, err)
+
+	// a changed migration is refused
+	changed := []database.Migration{testVersionedMigrations[1], testVersionedMigrations[0]}
+	changed[0].Up = `create table testdb.table (id serial primary key)`
+
+	m, err = database.NewMigrator(i, "testdb.schema_migrations", changed)
+	require.NoError(t, err)
+
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.Equal(t, database.MigrationChecksumMismatch, status[0].State)
+
+	_, err = m.Up()
+	require.Error(t, err)
+}
+
+func TestNewMigrator_DuplicateVersion(t *testing.T) {
+	_, err := database.NewMigrator(nil, "testdb.schema_migrations", []database.Migration{
+		{Version: 1, Name: "first"},
+		{Version: 1, Name: "second"},
+	})
+	require.Error(t, err)
+}
+
+func TestMigration_Checksum(t *testing.T) {
+	a := database.Migration{Version: 1, Up: "create table a (id int)"}
+	b := database.Migration{Version: 1, Up: "create table a (id int)", Name: "renamed"}
+	c := database.Migration{Version: 1, Up: "create table b (id int)"}
+
+	require.Equal(t, a.Checksum(), b.Checksum())
+	require.NotEqual(t, a.Checksum(), c.Checksum())
+}
+
+func TestMigration_WithSchema(t *testing.T) {
+	m := database.Migration{
+		Version: 1,
+		Up:      "ALTER TABLE {schema}.a ADD COLUMN b text",
+		Down:    "ALTER TABLE {schema}.a DROP COLUMN b",
+		Dialects: map[database.Dialect]database.DialectMigration{
+			database.DialectSQLite: {Up: "ALTER TABLE {schema}.a ADD COLUMN b"},
+		},
+	}
+
+	s := m.WithSchema("staging")
+	require.Equal(t, "ALTER TABLE staging.a ADD COLUMN b text", s.Up)
+	require.Equal(t, "ALTER TABLE staging.a DROP COLUMN b", s.Down)
+	require.Equal(t, "ALTER TABLE staging.a ADD COLUMN b", s.Dialects[database.DialectSQLite].Up)
+	require.Equal(t, "ALTER TABLE {schema}.a ADD COLUMN b", m.Dialects[database.DialectSQLite].Up)
+}
+
+func TestMigrator_SQLite(t *testing.T) {
+	i, err := database.NewWithDialect(filepath.Join(t.TempDir(), "test.db"), database.DialectSQLite)
+	require.NoError(t, err)
+	require.NoError(t, i.CreateSchema("testdb"))
+
+	migrations := []database.Migration{
+		{
+			Version: 1,
+			Name:    "create table",
+			Up:      `create table testdb.things (id serial primary key)`,
+			Down:    `drop table testdb.things`,
+			Dialects: map[database.Dialect]database.DialectMigration{
+				database.DialectSQLite: {
+					Up:   `create table testdb.things (id integer primary key, first text)`,
+					Down: `drop table testdb.things`,
+				},
+			},
+		},
+	}
+
+	m, err := database.NewMigrator(i, "testdb.schema_migrations", migrations)
+	require.NoError(t, err)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	require.Equal(t, migrations[0].Dialects[database.DialectSQLite].Up, applied[0].Up)
+
+	_, err = i.DB.Exec(`insert into testdb.things (first) values ('a')`)
+	require.NoError(t, err)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Equal(t, database.MigrationApplied, status[0].State)
+	require.NotNil(t, status[0].AppliedAt)
+
+	reverted, err := m.Down(1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+}
+
+func TestMigrator_SQLite_SkippedStatements(t *testing.T) {
+	i, err := database.NewWithDialect(filepath.Join(t.TempDir(), "test.db"), database.DialectSQLite)
+	require.NoError(t, err)
+	require.NoError(t, i.CreateSchema("testdb"))
+
+	// the table is created with its latest columns, as idempotent statements do
+	_, err = i.DB.Exec(`create table testdb.things (id integer primary key, first text, second text)`)
+	require.NoError(t, err)
+
+	m, err := database.NewMigrator(i, "testdb.schema_migrations", []database.Migration{
+		{
+			Version: 1,
+			Name:    "add second column",
+			Up: `-- adds second; then fills it
+ALTER TABLE testdb.things ADD COLUMN second text;
+
+UPDATE testdb.things SET second = 'a;b' WHERE second IS NULL;
+
+-- tables of disabled modules are missing
+UPDATE testdb.missing SET second = 'a';
+`,
+			Down:       `ALTER TABLE testdb.things DROP COLUMN second`,
+			Idempotent: true,
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = i.DB.Exec(`insert into testdb.things (first) values ('a')`)
+	require.NoError(t, err)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+
+	var second string
+	require.NoError(t, i.DB.Get(&second, `select second from testdb.things`))
+	require.Equal(t, "a;b", second)
+}
+
+func TestMigrator_SQLite_FailedMigration(t *testing.T) {
+	i, err := database.NewWithDialect(filepath.Join(t.TempDir(), "test.db"), database.DialectSQLite)
+	require.NoError(t, err)
+	require.NoError(t, i.CreateSchema("testdb"))
+
+	_, err = i.DB.Exec(`create table testdb.things (id integer primary key, first text)`)
+	require.NoError(t, err)
+
+	m, err := database.NewMigrator(i, "testdb.schema_migrations", []database.Migration{
+		{
+			Version: 1,
+			Name:    "add second column",
+			Up: `ALTER TABLE testdb.things ADD COLUMN second text;
+UPDATE testdb.missing SET second = 'a';
+`,
+			Down: `ALTER TABLE testdb.things DROP COLUMN second`,
+		},
+	})
+	require.NoError(t, err)
+
+	// errors aren't skipped for migrations which aren't idempotent, and their changes are rolled back
+	_, err = m.Up()
+	require.Error(t, err)
+
+	_, err = i.DB.Exec(`select second from testdb.things`)
+	require.Error(t, err)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Equal(t, database.MigrationPending, status[0].State)
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+// DecimalScale is the number of fractional digits decimals computed by the database are rounded to,
+// the same as the Cosmos SDK sdk.Dec.
+const DecimalScale = 18
+
+func init() {
+	// SQLite stores decimals as text and has no exact arithmetic on them,
+	// tokens_from_shares(shares, tokens, total_shares) computes shares * tokens / total_shares
+	// the way round(..., DecimalScale) does on CockroachDB and PostgreSQL.
+	sqlite.MustRegisterDeterministicScalarFunction("tokens_from_shares", 3, tokensFromShares)
+
+	// decimal_add(a, b), decimal_sub(a, b) and decimal_cmp(a, b) stand for a + b, a - b
+	// and the sign of a - b.
+	sqlite.MustRegisterDeterministicScalarFunction("decimal_add", 2, decimalOp(func(a, b *big.Rat) *big.Rat { return a.Add(a, b) }))
+	sqlite.MustRegisterDeterministicScalarFunction("decimal_sub", 2, decimalOp(func(a, b *big.Rat) *big.Rat { return a.Sub(a, b) }))
+	sqlite.MustRegisterDeterministicScalarFunction("decimal_cmp", 2, decimalCmp)
+}
+
+// decimalOp returns a SQLite function applying op to its two decimal arguments, the result having
+// as many fractional digits as the most precise of them.
+func decimalOp(op func(a, b *big.Rat) *big.Rat) func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+	return func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+
+		a, err := ratFromValue(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := ratFromValue(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		scale := scaleOf(args[0])
+		if s := scaleOf(args[1]); s > scale {
+			scale = s
+		}
+
+		return op(a, b).FloatString(scale), nil
+	}
+}
+
+func decimalCmp(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+
+	a, err := ratFromValue(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ratFromValue(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return int64(a.Cmp(b)), nil
+}
+
+// scaleOf returns the number of fractional digits of a decimal held by a SQLite value.
+func scaleOf(v driver.Value) int {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return 0
+	}
+
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		return len(s) - idx - 1
+	}
+
+	return 0
+}
+
+func tokensFromShares(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	values := make([]*big.Rat, 0, len(args))
+	for _, a := range args {
+		if a == nil {
+			return nil, nil
+		}
+
+		r, err := ratFromValue(a)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, r)
+	}
+
+	shares, tokens, totalShares := values[0], values[1], values[2]
+	if totalShares.Sign() == 0 {
+		return "0", nil
+	}
+
+	res := new(big.Rat).Mul(shares, tokens)
+	res.Quo(res, totalShares)
+
+	return res.FloatString(DecimalScale), nil
+}
+
+// ratFromValue parses a decimal held by a SQLite value.
+func ratFromValue(v driver.Value) (*big.Rat, error) {
+	r := new(big.Rat)
+	switch v := v.(type) {
+	case int64:
+		return r.SetInt64(v), nil
+	case float64:
+		if r.SetFloat64(v) == nil {
+			return nil, fmt.Errorf("cannot parse decimal %v", v)
+		}
+
+		return r, nil
+	case string:
+		if _, ok := r.SetString(v); !ok {
+			return nil, fmt.Errorf("cannot parse decimal %s", v)
+		}
+
+		return r, nil
+	case []byte:
+		if _, ok := r.SetString(string(v)); !ok {
+			return nil, fmt.Errorf("cannot parse decimal %s", v)
+		}
+
+		return r, nil
+	default:
+		return nil, fmt.Errorf("cannot parse decimal from %T", v)
+	}
+}
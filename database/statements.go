@@ -0,0 +1,100 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitStatements splits q on the semicolons found outside of quotes, dollar quotes and
+// comments, dropping the statements holding comments only.
+func splitStatements(q string) []string {
+	var (
+		ret   []string
+		start int
+		code  bool
+	)
+
+	rs := []rune(q)
+	for idx := 0; idx < len(rs); idx++ {
+		r := rs[idx]
+		switch {
+		case r == '\'' || r == '"':
+			idx = skipQuoted(rs, idx+1, []rune{r})
+			code = true
+		case r == '$' && dollarTag(rs, idx) != nil:
+			tag := dollarTag(rs, idx)
+			idx = skipQuoted(rs, idx+len(tag), tag)
+			code = true
+		case r == '-' && idx+1 < len(rs) && rs[idx+1] == '-':
+			for idx < len(rs) && rs[idx] != '\n' {
+				idx++
+			}
+		case r == '/' && idx+1 < len(rs) && rs[idx+1] == '*':
+			idx = skipBlockComment(rs, idx+2)
+		case r == ';':
+			if code {
+				ret = append(ret, strings.TrimSpace(string(rs[start:idx])))
+			}
+			start, code = idx+1, false
+		case !unicode.IsSpace(r):
+			code = true
+		}
+	}
+
+	if code {
+		ret = append(ret, strings.TrimSpace(string(rs[start:])))
+	}
+
+	return ret
+}
+
+// skipQuoted returns the index of the last rune of the closing quote of the string
+// starting at idx, or the last index of rs if it isn't closed.
+// Doubled quotes escape themselves, and end up being skipped as two strings.
+func skipQuoted(rs []rune, idx int, quote []rune) int {
+	for ; idx+len(quote) <= len(rs); idx++ {
+		if string(rs[idx:idx+len(quote)]) == string(quote) {
+			return idx + len(quote) - 1
+		}
+	}
+
+	return len(rs) - 1
+}
+
+// dollarTag returns the $tag$ opening a dollar-quoted string at idx, if any.
+// Positional parameters like $1 aren't tags, since tags can't start with a digit.
+func dollarTag(rs []rune, idx int) []rune {
+	for end := idx + 1; end < len(rs); end++ {
+		r := rs[end]
+		switch {
+		case r == '$':
+			return rs[idx : end+1]
+		case r == '_' || unicode.IsLetter(r) || (unicode.IsDigit(r) && end > idx+1):
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// skipBlockComment returns the index of the last rune of the block comment whose
+// content starts at idx, block comments nesting as they do on PostgreSQL and CockroachDB.
+func skipBlockComment(rs []rune, idx int) int {
+	depth := 1
+	for ; idx+1 < len(rs); idx++ {
+		switch {
+		case rs[idx] == '/' && rs[idx+1] == '*':
+			depth++
+			idx++
+		case rs[idx] == '*' && rs[idx+1] == '/':
+			depth--
+			idx++
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+
+	return len(rs) - 1
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want []string
+	}{
+		{
+			"single statement",
+			`CREATE TABLE a (id int)`,
+			[]string{`CREATE TABLE a (id int)`},
+		},
+		{
+			"semicolons in quotes",
+			`UPDATE a SET b = 'a;b'; UPDATE a SET "c;d" = 'it''s;'`,
+			[]string{`UPDATE a SET b = 'a;b'`, `UPDATE a SET "c;d" = 'it''s;'`},
+		},
+		{
+			"line comments",
+			"-- first; second\nUPDATE a SET b = 1;\n-- only a comment;\n",
+			[]string{"-- first; second\nUPDATE a SET b = 1"},
+		},
+		{
+			"block comments",
+			"/* first; /* nested; */ still; */ UPDATE a SET b = 1; /* only a comment; */",
+			[]string{"/* first; /* nested; */ still; */ UPDATE a SET b = 1"},
+		},
+		{
+			"dollar quotes",
+			"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE SQL; CREATE FUNCTION g() RETURNS int AS $body$ SELECT '$$;'; $body$ LANGUAGE SQL",
+			[]string{
+				"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE SQL",
+				"CREATE FUNCTION g() RETURNS int AS $body$ SELECT '$$;'; $body$ LANGUAGE SQL",
+			},
+		},
+		{
+			"positional parameters",
+			`UPDATE a SET b = $1 WHERE c = $2; DELETE FROM a WHERE b = $1`,
+			[]string{`UPDATE a SET b = $1 WHERE c = $2`, `DELETE FROM a WHERE b = $1`},
+		},
+		{
+			"empty statements",
+			" ; ;\n",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, splitStatements(tt.q))
+		})
+	}
+}
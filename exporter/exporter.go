@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/fs"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -46,6 +47,9 @@ type (
 		traceChan chan []byte
 		doneChan  chan struct{}
 		once      sync.Once
+
+		// handlers are served along with the exporter ones.
+		handlers map[string]http.HandlerFunc
 	}
 
 	Option func(*Exporter) error
@@ -81,6 +85,20 @@ func WithLogger(l *zap.SugaredLogger) Option {
 	}
 }
 
+// WithHandler serves h on pattern along with the exporter endpoints.
+func WithHandler(pattern string, h http.HandlerFunc) Option {
+	return func(e *Exporter) error {
+		if h == nil {
+			return fmt.Errorf("handler can not be nil")
+		}
+		if e.handlers == nil {
+			e.handlers = map[string]http.HandlerFunc{}
+		}
+		e.handlers[pattern] = h
+		return nil
+	}
+}
+
 func New(opts ...Option) (*Exporter, error) {
 	e := &Exporter{
 		muRunning: sync.Mutex{},
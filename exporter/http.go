@@ -15,6 +15,9 @@ func (e *Exporter) ListenAndServeHTTP(port string) {
 	mux.HandleFunc("/start", e.startHandler)
 	mux.HandleFunc("/stop", e.stopHandler)
 	mux.HandleFunc("/stat", e.statHandler)
+	for pattern, h := range e.handlers {
+		mux.HandleFunc(pattern, h)
+	}
 
 	if port == "" {
 		port = ":8111"
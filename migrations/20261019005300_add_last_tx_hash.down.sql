@@ -0,0 +1,12 @@
+-- Drops the last_tx_hash column of the module tables.
+ALTER TABLE IF EXISTS {schema}.validators DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.unbonding_delegations DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.denom_traces DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.delegations DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.cw20_token_infos DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.cw20_balances DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.connections DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.clients DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.channels DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.balances DROP COLUMN IF EXISTS last_tx_hash;
+ALTER TABLE IF EXISTS {schema}.auth DROP COLUMN IF EXISTS last_tx_hash;
@@ -0,0 +1 @@
+-- SQLite tables have always been created with last_tx_hash.
@@ -0,0 +1,2 @@
+-- sqlgen: idempotent
+-- SQLite tables have always been created with last_tx_hash.
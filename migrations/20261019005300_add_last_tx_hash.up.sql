@@ -0,0 +1,14 @@
+-- sqlgen: idempotent
+-- Adds last_tx_hash to the module tables created before it existed, which only live on
+-- CockroachDB and PostgreSQL. Tables created afterwards already have it.
+ALTER TABLE IF EXISTS {schema}.auth ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.balances ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.channels ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.clients ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.connections ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.cw20_balances ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.cw20_token_infos ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.delegations ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.denom_traces ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.unbonding_delegations ADD COLUMN IF NOT EXISTS last_tx_hash text;
+ALTER TABLE IF EXISTS {schema}.validators ADD COLUMN IF NOT EXISTS last_tx_hash text;
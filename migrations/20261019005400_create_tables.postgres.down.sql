@@ -0,0 +1,103 @@
+-- This file was generated by sqlgen, review it before committing.
+
+COMMENT ON TABLE {schema}.validators IS NULL;
+
+DROP VIEW IF EXISTS {schema}.validators_current;
+
+DROP INDEX IF EXISTS {schema}.validators_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.validators;
+
+COMMENT ON TABLE {schema}.clients IS NULL;
+
+DROP INDEX IF EXISTS {schema}.clients_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.clients;
+
+COMMENT ON TABLE {schema}.channels IS NULL;
+
+DROP INDEX IF EXISTS {schema}.channels_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.channels;
+
+COMMENT ON TABLE {schema}.denom_traces IS NULL;
+
+DROP INDEX IF EXISTS {schema}.denom_traces_path_idx;
+
+DROP INDEX IF EXISTS {schema}.denom_traces_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.denom_traces;
+
+DROP INDEX IF EXISTS {schema}.auth_history_height_idx;
+
+DROP TABLE IF EXISTS {schema}.auth_history;
+
+COMMENT ON TABLE {schema}.auth IS NULL;
+
+DROP INDEX IF EXISTS {schema}.auth_address_idx;
+
+DROP INDEX IF EXISTS {schema}.auth_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.auth;
+
+COMMENT ON TABLE {schema}.unbonding_delegations IS NULL;
+
+DROP INDEX IF EXISTS {schema}.unbonding_delegations_delegator_address_idx;
+
+DROP INDEX IF EXISTS {schema}.unbonding_delegations_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.unbonding_delegations;
+
+DROP INDEX IF EXISTS {schema}.delegations_history_height_idx;
+
+DROP TABLE IF EXISTS {schema}.delegations_history;
+
+COMMENT ON COLUMN {schema}.delegations.amount IS NULL;
+
+COMMENT ON TABLE {schema}.delegations IS NULL;
+
+DROP VIEW IF EXISTS {schema}.delegations_current;
+
+DROP INDEX IF EXISTS {schema}.delegations_validator_address_idx;
+
+DROP INDEX IF EXISTS {schema}.delegations_delegator_address_idx;
+
+DROP INDEX IF EXISTS {schema}.delegations_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.delegations;
+
+COMMENT ON TABLE {schema}.connections IS NULL;
+
+DROP INDEX IF EXISTS {schema}.connections_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.connections;
+
+COMMENT ON TABLE {schema}.cw20_token_infos IS NULL;
+
+DROP INDEX IF EXISTS {schema}.cw20_token_info_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.cw20_token_infos;
+
+COMMENT ON TABLE {schema}.cw20_balances IS NULL;
+
+DROP INDEX IF EXISTS {schema}.cw20_balances_address_idx;
+
+DROP INDEX IF EXISTS {schema}.cw20_balances_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.cw20_balances;
+
+DROP INDEX IF EXISTS {schema}.balances_history_height_idx;
+
+DROP TABLE IF EXISTS {schema}.balances_history;
+
+COMMENT ON COLUMN {schema}.balances.amount IS NULL;
+
+COMMENT ON TABLE {schema}.balances IS NULL;
+
+DROP VIEW IF EXISTS {schema}.balances_current;
+
+DROP INDEX IF EXISTS {schema}.balances_address_idx;
+
+DROP INDEX IF EXISTS {schema}.balances_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.balances;
@@ -0,0 +1,103 @@
+-- This file was generated by sqlgen, review it before committing.
+
+CREATE TABLE IF NOT EXISTS {schema}.balances (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, amount text NOT NULL, denom text NOT NULL, UNIQUE (chain_name, address, denom));
+
+CREATE INDEX IF NOT EXISTS balances_chain_name_id_idx ON {schema}.balances (chain_name, id);
+
+CREATE INDEX IF NOT EXISTS balances_address_idx ON {schema}.balances (address) WHERE delete_height IS NULL;
+
+CREATE OR REPLACE VIEW {schema}.balances_current AS SELECT id, height, chain_name, last_tx_hash, address, amount, denom FROM {schema}.balances WHERE delete_height IS NULL;
+
+COMMENT ON TABLE {schema}.balances IS 'bank balances, one row per address and denom';
+
+COMMENT ON COLUMN {schema}.balances.amount IS 'coins string, e.g. 100uatom';
+
+CREATE TABLE IF NOT EXISTS {schema}.balances_history (id bigserial PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, denom text NOT NULL, height bigint NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb);
+
+CREATE INDEX IF NOT EXISTS balances_history_height_idx ON {schema}.balances_history (chain_name, address, denom, height);
+
+CREATE TABLE IF NOT EXISTS {schema}.cw20_balances (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, contract_address text NOT NULL, address text NOT NULL, amount text NOT NULL, UNIQUE (chain_name, contract_address, address));
+
+CREATE INDEX IF NOT EXISTS cw20_balances_chain_name_id_idx ON {schema}.cw20_balances (chain_name, id);
+
+CREATE INDEX IF NOT EXISTS cw20_balances_address_idx ON {schema}.cw20_balances (address) WHERE delete_height IS NULL;
+
+COMMENT ON TABLE {schema}.cw20_balances IS 'cw20 token balances, one row per contract and holder';
+
+CREATE TABLE IF NOT EXISTS {schema}.cw20_token_infos (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, contract_address text NOT NULL, name text NOT NULL, symbol text NOT NULL, decimals bigint NOT NULL, total_supply text NOT NULL, UNIQUE (chain_name, contract_address));
+
+CREATE INDEX IF NOT EXISTS cw20_token_info_chain_name_id_idx ON {schema}.cw20_token_infos (chain_name, id);
+
+COMMENT ON TABLE {schema}.cw20_token_infos IS 'cw20 token metadata, one row per contract';
+
+CREATE TABLE IF NOT EXISTS {schema}.connections (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, connection_id text NOT NULL, client_id text NOT NULL, state text NOT NULL, counter_connection_id text NOT NULL, counter_client_id text NOT NULL, UNIQUE (chain_name, connection_id, client_id));
+
+CREATE INDEX IF NOT EXISTS connections_chain_name_id_idx ON {schema}.connections (chain_name, id);
+
+COMMENT ON TABLE {schema}.connections IS 'IBC connections';
+
+CREATE TABLE IF NOT EXISTS {schema}.delegations (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, amount text NOT NULL, UNIQUE (chain_name, delegator_address, validator_address));
+
+CREATE INDEX IF NOT EXISTS delegations_chain_name_id_idx ON {schema}.delegations (chain_name, id);
+
+CREATE INDEX IF NOT EXISTS delegations_delegator_address_idx ON {schema}.delegations (delegator_address) WHERE delete_height IS NULL;
+
+CREATE INDEX IF NOT EXISTS delegations_validator_address_idx ON {schema}.delegations (validator_address) WHERE delete_height IS NULL;
+
+CREATE OR REPLACE VIEW {schema}.delegations_current AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount FROM {schema}.delegations WHERE delete_height IS NULL;
+
+COMMENT ON TABLE {schema}.delegations IS 'staking delegations, one row per delegator and validator';
+
+COMMENT ON COLUMN {schema}.delegations.amount IS 'delegator shares, as a decimal string';
+
+CREATE TABLE IF NOT EXISTS {schema}.delegations_history (id bigserial PRIMARY KEY NOT NULL, chain_name text NOT NULL, delegator_address text NOT NULL, validator_address text NOT NULL, height bigint NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb);
+
+CREATE INDEX IF NOT EXISTS delegations_history_height_idx ON {schema}.delegations_history (chain_name, delegator_address, validator_address, height);
+
+CREATE TABLE IF NOT EXISTS {schema}.unbonding_delegations (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, entries jsonb NOT NULL, UNIQUE (chain_name, delegator_address, validator_address));
+
+CREATE INDEX IF NOT EXISTS unbonding_delegations_chain_name_id_idx ON {schema}.unbonding_delegations (chain_name, id);
+
+CREATE INDEX IF NOT EXISTS unbonding_delegations_delegator_address_idx ON {schema}.unbonding_delegations (delegator_address) WHERE delete_height IS NULL;
+
+COMMENT ON TABLE {schema}.unbonding_delegations IS 'staking unbonding delegations, one row per delegator and validator';
+
+CREATE TABLE IF NOT EXISTS {schema}.auth (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, sequence_number numeric NOT NULL, account_number numeric NOT NULL, UNIQUE (chain_name, address, account_number));
+
+CREATE INDEX IF NOT EXISTS auth_chain_name_id_idx ON {schema}.auth (chain_name, id);
+
+CREATE INDEX IF NOT EXISTS auth_address_idx ON {schema}.auth (address) WHERE delete_height IS NULL;
+
+COMMENT ON TABLE {schema}.auth IS 'accounts sequence and account numbers';
+
+CREATE TABLE IF NOT EXISTS {schema}.auth_history (id bigserial PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, account_number numeric NOT NULL, height bigint NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb);
+
+CREATE INDEX IF NOT EXISTS auth_history_height_idx ON {schema}.auth_history (chain_name, address, account_number, height);
+
+CREATE TABLE IF NOT EXISTS {schema}.denom_traces (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, path text NOT NULL, base_denom text NOT NULL, hash text NOT NULL, UNIQUE (chain_name, hash));
+
+CREATE INDEX IF NOT EXISTS denom_traces_chain_name_id_idx ON {schema}.denom_traces (chain_name, id);
+
+CREATE INDEX IF NOT EXISTS denom_traces_path_idx ON {schema}.denom_traces (path);
+
+COMMENT ON TABLE {schema}.denom_traces IS 'IBC transfer denom traces, one row per denom hash';
+
+CREATE TABLE IF NOT EXISTS {schema}.channels (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, channel_id text NOT NULL, counter_channel_id text NOT NULL, port text NOT NULL, state bigint NOT NULL, hops text[] NOT NULL, UNIQUE (chain_name, channel_id, port));
+
+CREATE INDEX IF NOT EXISTS channels_chain_name_id_idx ON {schema}.channels (chain_name, id);
+
+COMMENT ON TABLE {schema}.channels IS 'IBC channels';
+
+CREATE TABLE IF NOT EXISTS {schema}.clients (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, chain_id text NOT NULL, client_id text NOT NULL, latest_height numeric NOT NULL, trusting_period numeric NOT NULL, UNIQUE (chain_name, chain_id, client_id));
+
+CREATE INDEX IF NOT EXISTS clients_chain_name_id_idx ON {schema}.clients (chain_name, id);
+
+COMMENT ON TABLE {schema}.clients IS 'IBC clients';
+
+CREATE TABLE IF NOT EXISTS {schema}.validators (id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, validator_address text NOT NULL, operator_address text NOT NULL, consensus_pubkey_type text, consensus_pubkey_value bytea, jailed bool NOT NULL, status bigint NOT NULL, tokens text NOT NULL, delegator_shares text NOT NULL, moniker text, identity text, website text, security_contact text, details text, unbonding_height bigint, unbonding_time text, commission_rate text NOT NULL, max_rate text NOT NULL, max_change_rate text NOT NULL, update_time text NOT NULL, min_self_delegation text NOT NULL, UNIQUE (chain_name, operator_address));
+
+CREATE INDEX IF NOT EXISTS validators_chain_name_id_idx ON {schema}.validators (chain_name, id);
+
+CREATE OR REPLACE VIEW {schema}.validators_current AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, delegator_shares, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, max_rate, max_change_rate, update_time, min_self_delegation FROM {schema}.validators WHERE delete_height IS NULL;
+
+COMMENT ON TABLE {schema}.validators IS 'staking validators, one row per operator address';
@@ -0,0 +1,77 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP VIEW IF EXISTS {schema}.validators_current;
+
+DROP INDEX IF EXISTS {schema}.validators_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.validators;
+
+DROP INDEX IF EXISTS {schema}.clients_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.clients;
+
+DROP INDEX IF EXISTS {schema}.channels_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.channels;
+
+DROP INDEX IF EXISTS {schema}.denom_traces_path_idx;
+
+DROP INDEX IF EXISTS {schema}.denom_traces_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.denom_traces;
+
+DROP INDEX IF EXISTS {schema}.auth_history_height_idx;
+
+DROP TABLE IF EXISTS {schema}.auth_history;
+
+DROP INDEX IF EXISTS {schema}.auth_address_idx;
+
+DROP INDEX IF EXISTS {schema}.auth_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.auth;
+
+DROP INDEX IF EXISTS {schema}.unbonding_delegations_delegator_address_idx;
+
+DROP INDEX IF EXISTS {schema}.unbonding_delegations_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.unbonding_delegations;
+
+DROP INDEX IF EXISTS {schema}.delegations_history_height_idx;
+
+DROP TABLE IF EXISTS {schema}.delegations_history;
+
+DROP VIEW IF EXISTS {schema}.delegations_current;
+
+DROP INDEX IF EXISTS {schema}.delegations_validator_address_idx;
+
+DROP INDEX IF EXISTS {schema}.delegations_delegator_address_idx;
+
+DROP INDEX IF EXISTS {schema}.delegations_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.delegations;
+
+DROP INDEX IF EXISTS {schema}.connections_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.connections;
+
+DROP INDEX IF EXISTS {schema}.cw20_token_info_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.cw20_token_infos;
+
+DROP INDEX IF EXISTS {schema}.cw20_balances_address_idx;
+
+DROP INDEX IF EXISTS {schema}.cw20_balances_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.cw20_balances;
+
+DROP INDEX IF EXISTS {schema}.balances_history_height_idx;
+
+DROP TABLE IF EXISTS {schema}.balances_history;
+
+DROP VIEW IF EXISTS {schema}.balances_current;
+
+DROP INDEX IF EXISTS {schema}.balances_address_idx;
+
+DROP INDEX IF EXISTS {schema}.balances_chain_name_id_idx;
+
+DROP TABLE IF EXISTS {schema}.balances;
@@ -0,0 +1,2 @@
+-- Drops the state changes log.
+DROP TABLE IF EXISTS {schema}.state_changes;
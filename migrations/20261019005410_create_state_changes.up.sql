@@ -0,0 +1,12 @@
+-- sqlgen: idempotent
+-- Creates the state changes log, written when StateChangesEnabled is set.
+CREATE TABLE IF NOT EXISTS {schema}.state_changes (
+	id serial PRIMARY KEY NOT NULL,
+	chain_name text NOT NULL,
+	height integer NOT NULL,
+	tx_hash text,
+	table_name text NOT NULL,
+	unique_key jsonb NOT NULL,
+	operation text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS state_changes_chain_name_height_idx ON {schema}.state_changes (chain_name, height);
@@ -0,0 +1,31 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP VIEW IF EXISTS {schema}.validators_current;
+
+ALTER TABLE IF EXISTS {schema}.validators DROP COLUMN IF EXISTS max_change_rate_numeric;
+
+ALTER TABLE IF EXISTS {schema}.validators DROP COLUMN IF EXISTS max_rate_numeric;
+
+ALTER TABLE IF EXISTS {schema}.validators DROP COLUMN IF EXISTS commission_rate_numeric;
+
+ALTER TABLE IF EXISTS {schema}.validators DROP COLUMN IF EXISTS delegator_shares_numeric;
+
+ALTER TABLE IF EXISTS {schema}.validators DROP COLUMN IF EXISTS tokens_numeric;
+
+CREATE OR REPLACE VIEW {schema}.validators_current AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, delegator_shares, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, max_rate, max_change_rate, update_time, min_self_delegation FROM {schema}.validators WHERE delete_height IS NULL;
+
+DROP VIEW IF EXISTS {schema}.delegations_current;
+
+ALTER TABLE IF EXISTS {schema}.delegations DROP COLUMN IF EXISTS amount_numeric;
+
+CREATE OR REPLACE VIEW {schema}.delegations_current AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount FROM {schema}.delegations WHERE delete_height IS NULL;
+
+ALTER TABLE IF EXISTS {schema}.cw20_token_infos DROP COLUMN IF EXISTS total_supply_numeric;
+
+ALTER TABLE IF EXISTS {schema}.cw20_balances DROP COLUMN IF EXISTS amount_numeric;
+
+DROP VIEW IF EXISTS {schema}.balances_current;
+
+ALTER TABLE IF EXISTS {schema}.balances DROP COLUMN IF EXISTS amount_numeric;
+
+CREATE OR REPLACE VIEW {schema}.balances_current AS SELECT id, height, chain_name, last_tx_hash, address, amount, denom FROM {schema}.balances WHERE delete_height IS NULL;
@@ -0,0 +1,31 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP VIEW IF EXISTS {schema}.balances_current;
+
+ALTER TABLE IF EXISTS {schema}.balances ADD COLUMN IF NOT EXISTS amount_numeric decimal;
+
+CREATE OR REPLACE VIEW {schema}.balances_current AS SELECT id, height, chain_name, last_tx_hash, address, amount, amount_numeric, denom FROM {schema}.balances WHERE delete_height IS NULL;
+
+ALTER TABLE IF EXISTS {schema}.cw20_balances ADD COLUMN IF NOT EXISTS amount_numeric decimal;
+
+ALTER TABLE IF EXISTS {schema}.cw20_token_infos ADD COLUMN IF NOT EXISTS total_supply_numeric decimal;
+
+DROP VIEW IF EXISTS {schema}.delegations_current;
+
+ALTER TABLE IF EXISTS {schema}.delegations ADD COLUMN IF NOT EXISTS amount_numeric decimal;
+
+CREATE OR REPLACE VIEW {schema}.delegations_current AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric FROM {schema}.delegations WHERE delete_height IS NULL;
+
+DROP VIEW IF EXISTS {schema}.validators_current;
+
+ALTER TABLE IF EXISTS {schema}.validators ADD COLUMN IF NOT EXISTS tokens_numeric decimal;
+
+ALTER TABLE IF EXISTS {schema}.validators ADD COLUMN IF NOT EXISTS delegator_shares_numeric decimal;
+
+ALTER TABLE IF EXISTS {schema}.validators ADD COLUMN IF NOT EXISTS commission_rate_numeric decimal;
+
+ALTER TABLE IF EXISTS {schema}.validators ADD COLUMN IF NOT EXISTS max_rate_numeric decimal;
+
+ALTER TABLE IF EXISTS {schema}.validators ADD COLUMN IF NOT EXISTS max_change_rate_numeric decimal;
+
+CREATE OR REPLACE VIEW {schema}.validators_current AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation FROM {schema}.validators WHERE delete_height IS NULL;
@@ -0,0 +1,31 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP VIEW IF EXISTS {schema}.validators_current;
+
+ALTER TABLE {schema}.validators DROP COLUMN max_change_rate_numeric;
+
+ALTER TABLE {schema}.validators DROP COLUMN max_rate_numeric;
+
+ALTER TABLE {schema}.validators DROP COLUMN commission_rate_numeric;
+
+ALTER TABLE {schema}.validators DROP COLUMN delegator_shares_numeric;
+
+ALTER TABLE {schema}.validators DROP COLUMN tokens_numeric;
+
+CREATE VIEW IF NOT EXISTS {schema}.validators_current AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, delegator_shares, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, max_rate, max_change_rate, update_time, min_self_delegation FROM validators WHERE delete_height IS NULL;
+
+DROP VIEW IF EXISTS {schema}.delegations_current;
+
+ALTER TABLE {schema}.delegations DROP COLUMN amount_numeric;
+
+CREATE VIEW IF NOT EXISTS {schema}.delegations_current AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount FROM delegations WHERE delete_height IS NULL;
+
+ALTER TABLE {schema}.cw20_token_infos DROP COLUMN total_supply_numeric;
+
+ALTER TABLE {schema}.cw20_balances DROP COLUMN amount_numeric;
+
+DROP VIEW IF EXISTS {schema}.balances_current;
+
+ALTER TABLE {schema}.balances DROP COLUMN amount_numeric;
+
+CREATE VIEW IF NOT EXISTS {schema}.balances_current AS SELECT id, height, chain_name, last_tx_hash, address, amount, denom FROM balances WHERE delete_height IS NULL;
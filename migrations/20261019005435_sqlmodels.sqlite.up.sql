@@ -0,0 +1,31 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP VIEW IF EXISTS {schema}.balances_current;
+
+ALTER TABLE {schema}.balances ADD COLUMN amount_numeric text;
+
+CREATE VIEW IF NOT EXISTS {schema}.balances_current AS SELECT id, height, chain_name, last_tx_hash, address, amount, amount_numeric, denom FROM balances WHERE delete_height IS NULL;
+
+ALTER TABLE {schema}.cw20_balances ADD COLUMN amount_numeric text;
+
+ALTER TABLE {schema}.cw20_token_infos ADD COLUMN total_supply_numeric text;
+
+DROP VIEW IF EXISTS {schema}.delegations_current;
+
+ALTER TABLE {schema}.delegations ADD COLUMN amount_numeric text;
+
+CREATE VIEW IF NOT EXISTS {schema}.delegations_current AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric FROM delegations WHERE delete_height IS NULL;
+
+DROP VIEW IF EXISTS {schema}.validators_current;
+
+ALTER TABLE {schema}.validators ADD COLUMN tokens_numeric text;
+
+ALTER TABLE {schema}.validators ADD COLUMN delegator_shares_numeric text;
+
+ALTER TABLE {schema}.validators ADD COLUMN commission_rate_numeric text;
+
+ALTER TABLE {schema}.validators ADD COLUMN max_rate_numeric text;
+
+ALTER TABLE {schema}.validators ADD COLUMN max_change_rate_numeric text;
+
+CREATE VIEW IF NOT EXISTS {schema}.validators_current AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation FROM validators WHERE delete_height IS NULL;
@@ -0,0 +1,11 @@
+-- Clears the numeric columns filled by the up migration.
+
+UPDATE {schema}.validators SET tokens_numeric = NULL, delegator_shares_numeric = NULL, commission_rate_numeric = NULL, max_rate_numeric = NULL, max_change_rate_numeric = NULL;
+
+UPDATE {schema}.delegations SET amount_numeric = NULL;
+
+UPDATE {schema}.cw20_token_infos SET total_supply_numeric = NULL;
+
+UPDATE {schema}.cw20_balances SET amount_numeric = NULL;
+
+UPDATE {schema}.balances SET amount_numeric = NULL;
@@ -0,0 +1,23 @@
+-- sqlgen: idempotent
+-- Fills the numeric columns added by 20261019005435_sqlmodels from their text counterparts.
+-- Values which don't look like numbers are left NULL.
+
+UPDATE {schema}.balances SET amount_numeric = substr(amount, 1, length(amount) - length(denom))
+WHERE amount_numeric IS NULL AND length(amount) > length(denom) AND substr(amount, 1, length(amount) - length(denom)) NOT GLOB '*[^0-9]*';
+
+UPDATE {schema}.cw20_balances SET amount_numeric = trim(amount, '"')
+WHERE amount_numeric IS NULL AND trim(amount, '"') <> '' AND trim(amount, '"') NOT GLOB '*[^0-9]*';
+
+UPDATE {schema}.cw20_token_infos SET total_supply_numeric = trim(total_supply, '"')
+WHERE total_supply_numeric IS NULL AND trim(total_supply, '"') <> '' AND trim(total_supply, '"') NOT GLOB '*[^0-9]*';
+
+UPDATE {schema}.delegations SET amount_numeric = amount
+WHERE amount_numeric IS NULL AND amount <> '' AND amount NOT GLOB '*[^0-9.-]*';
+
+UPDATE {schema}.validators SET
+	tokens_numeric = CASE WHEN tokens <> '' AND tokens NOT GLOB '*[^0-9.-]*' THEN tokens END,
+	delegator_shares_numeric = CASE WHEN delegator_shares <> '' AND delegator_shares NOT GLOB '*[^0-9.-]*' THEN delegator_shares END,
+	commission_rate_numeric = CASE WHEN commission_rate <> '' AND commission_rate NOT GLOB '*[^0-9.-]*' THEN commission_rate END,
+	max_rate_numeric = CASE WHEN max_rate <> '' AND max_rate NOT GLOB '*[^0-9.-]*' THEN max_rate END,
+	max_change_rate_numeric = CASE WHEN max_change_rate <> '' AND max_change_rate NOT GLOB '*[^0-9.-]*' THEN max_change_rate END
+WHERE tokens_numeric IS NULL;
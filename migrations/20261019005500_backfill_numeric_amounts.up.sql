@@ -0,0 +1,23 @@
+-- sqlgen: idempotent
+-- Fills the numeric columns added by 20261019005435_sqlmodels from their text counterparts.
+-- Values which don't look like numbers are left NULL.
+
+UPDATE {schema}.balances SET amount_numeric = CAST(substring(amount FROM '^[0-9]+') AS DECIMAL)
+WHERE amount_numeric IS NULL AND amount ~ '^[0-9]+';
+
+UPDATE {schema}.cw20_balances SET amount_numeric = CAST(btrim(amount, '"') AS DECIMAL)
+WHERE amount_numeric IS NULL AND btrim(amount, '"') ~ '^[0-9]+$';
+
+UPDATE {schema}.cw20_token_infos SET total_supply_numeric = CAST(btrim(total_supply, '"') AS DECIMAL)
+WHERE total_supply_numeric IS NULL AND btrim(total_supply, '"') ~ '^[0-9]+$';
+
+UPDATE {schema}.delegations SET amount_numeric = CAST(amount AS DECIMAL)
+WHERE amount_numeric IS NULL AND amount ~ '^-?[0-9]+(\.[0-9]+)?$';
+
+UPDATE {schema}.validators SET
+	tokens_numeric = CASE WHEN tokens ~ '^-?[0-9]+(\.[0-9]+)?$' THEN CAST(tokens AS DECIMAL) END,
+	delegator_shares_numeric = CASE WHEN delegator_shares ~ '^-?[0-9]+(\.[0-9]+)?$' THEN CAST(delegator_shares AS DECIMAL) END,
+	commission_rate_numeric = CASE WHEN commission_rate ~ '^-?[0-9]+(\.[0-9]+)?$' THEN CAST(commission_rate AS DECIMAL) END,
+	max_rate_numeric = CASE WHEN max_rate ~ '^-?[0-9]+(\.[0-9]+)?$' THEN CAST(max_rate AS DECIMAL) END,
+	max_change_rate_numeric = CASE WHEN max_change_rate ~ '^-?[0-9]+(\.[0-9]+)?$' THEN CAST(max_change_rate AS DECIMAL) END
+WHERE tokens_numeric IS NULL;
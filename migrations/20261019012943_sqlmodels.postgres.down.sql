@@ -0,0 +1,7 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP VIEW IF EXISTS {schema}.delegations_current;
+
+ALTER TABLE IF EXISTS {schema}.delegations DROP COLUMN IF EXISTS delegated_tokens;
+
+CREATE OR REPLACE VIEW {schema}.delegations_current AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric FROM {schema}.delegations WHERE delete_height IS NULL;
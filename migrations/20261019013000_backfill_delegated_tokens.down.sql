@@ -0,0 +1,3 @@
+-- Clears the delegated_tokens column filled by the up migration.
+
+UPDATE {schema}.delegations SET delegated_tokens = NULL;
@@ -0,0 +1,8 @@
+-- sqlgen: idempotent
+-- Fills the delegated_tokens column added by 20261019012943_sqlmodels, converting the shares of
+-- every current delegation to tokens at the exchange rate of its validator.
+
+UPDATE {schema}.delegations AS d SET delegated_tokens = tokens_from_shares(d.amount_numeric, v.tokens_numeric, v.delegator_shares_numeric)
+FROM {schema}.validators AS v
+WHERE d.delegated_tokens IS NULL AND d.delete_height IS NULL
+AND v.chain_name = d.chain_name AND v.validator_address = d.validator_address AND v.delete_height IS NULL;
@@ -0,0 +1,7 @@
+-- This file was generated by sqlgen, review it before committing.
+
+COMMENT ON COLUMN {schema}.delegations.delegated_tokens IS NULL;
+
+COMMENT ON COLUMN {schema}.delegations.amount_numeric IS NULL;
+
+COMMENT ON COLUMN {schema}.balances.amount_numeric IS NULL;
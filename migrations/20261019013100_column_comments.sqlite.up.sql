@@ -0,0 +1 @@
+-- This file was generated by sqlgen, review it before committing.
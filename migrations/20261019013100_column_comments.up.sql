@@ -0,0 +1,7 @@
+-- This file was generated by sqlgen, review it before committing.
+
+COMMENT ON COLUMN {schema}.balances.amount_numeric IS 'coins amount, without the denom';
+
+COMMENT ON COLUMN {schema}.delegations.amount_numeric IS 'delegator shares';
+
+COMMENT ON COLUMN {schema}.delegations.delegated_tokens IS 'delegator shares converted to tokens at the validator exchange rate';
@@ -0,0 +1,4 @@
+-- Drops the aggregate tables.
+DROP TABLE IF EXISTS {schema}.validator_powers;
+DROP TABLE IF EXISTS {schema}.delegator_totals;
+DROP TABLE IF EXISTS {schema}.denom_totals;
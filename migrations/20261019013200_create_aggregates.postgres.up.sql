@@ -0,0 +1,28 @@
+-- sqlgen: idempotent
+-- Creates the aggregate tables, maintained at flush when AggregatesEnabled is set:
+-- denom_totals sums up balances, delegator_totals the delegated tokens of delegations,
+-- and validator_powers holds the consensus power of validators along with their rank.
+CREATE TABLE IF NOT EXISTS {schema}.denom_totals (
+	chain_name text NOT NULL,
+	denom text NOT NULL,
+	height bigint NOT NULL,
+	total_amount decimal NOT NULL,
+	holders bigint NOT NULL,
+	PRIMARY KEY (chain_name, denom)
+);
+CREATE TABLE IF NOT EXISTS {schema}.delegator_totals (
+	chain_name text NOT NULL,
+	delegator_address text NOT NULL,
+	height bigint NOT NULL,
+	delegated_tokens decimal NOT NULL,
+	PRIMARY KEY (chain_name, delegator_address)
+);
+CREATE TABLE IF NOT EXISTS {schema}.validator_powers (
+	chain_name text NOT NULL,
+	validator_address text NOT NULL,
+	height bigint NOT NULL,
+	voting_power bigint NOT NULL,
+	rank bigint,
+	PRIMARY KEY (chain_name, validator_address)
+);
+CREATE INDEX IF NOT EXISTS validator_powers_chain_name_rank_idx ON {schema}.validator_powers (chain_name, rank);
@@ -0,0 +1,28 @@
+-- sqlgen: idempotent
+-- Creates the aggregate tables, maintained at flush when AggregatesEnabled is set:
+-- denom_totals sums up balances, delegator_totals the delegated tokens of delegations,
+-- and validator_powers holds the consensus power of validators along with their rank.
+CREATE TABLE IF NOT EXISTS {schema}.denom_totals (
+	chain_name text NOT NULL,
+	denom text NOT NULL,
+	height integer NOT NULL,
+	total_amount text NOT NULL,
+	holders integer NOT NULL,
+	PRIMARY KEY (chain_name, denom)
+);
+CREATE TABLE IF NOT EXISTS {schema}.delegator_totals (
+	chain_name text NOT NULL,
+	delegator_address text NOT NULL,
+	height integer NOT NULL,
+	delegated_tokens text NOT NULL,
+	PRIMARY KEY (chain_name, delegator_address)
+);
+CREATE TABLE IF NOT EXISTS {schema}.validator_powers (
+	chain_name text NOT NULL,
+	validator_address text NOT NULL,
+	height integer NOT NULL,
+	voting_power integer NOT NULL,
+	rank integer,
+	PRIMARY KEY (chain_name, validator_address)
+);
+CREATE INDEX IF NOT EXISTS {schema}.validator_powers_chain_name_rank_idx ON validator_powers (chain_name, rank);
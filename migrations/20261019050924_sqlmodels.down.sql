@@ -0,0 +1,5 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP INDEX IF EXISTS {schema}.cw20_token_infos@cw20_token_infos_chain_name_id_idx CASCADE;
+
+CREATE INDEX IF NOT EXISTS cw20_token_info_chain_name_id_idx ON {schema}.cw20_token_infos (chain_name, id);
@@ -0,0 +1,5 @@
+-- This file was generated by sqlgen, review it before committing.
+
+DROP INDEX IF EXISTS {schema}.cw20_token_info_chain_name_id_idx;
+
+CREATE INDEX IF NOT EXISTS {schema}.cw20_token_infos_chain_name_id_idx ON cw20_token_infos (chain_name, id);
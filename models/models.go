@@ -1,7 +1,11 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,124 +16,154 @@ type TracelistenerDatabaseRow struct {
 	ID           uint64  `db:"id" json:"-"`
 	Height       uint64  `db:"height" json:"block_height"`
 	DeleteHeight *uint64 `db:"delete_height" json:"-"`
+	TxHash       *string `db:"last_tx_hash" json:"-"`
 }
 
-// DatabaseEntrier is implemented by each object that wants to be inserted in a database.
-// It is usually used in conjunction to TracelistenerDatabaseRow.
-type DatabaseEntrier interface {
-	// WithChainName sets the ChainName field of the TracelistenerDatabaseRow struct.
-	WithChainName(cn string) DatabaseEntrier
-}
-
-// BalanceRow represents a balance row inserted into the database.
-type BalanceRow struct {
-	TracelistenerDatabaseRow
+// NullString returns s as the value of a nullable text column, nil if s is empty.
+func NullString(s string) *string {
+	if s == "" {
+		return nil
+	}
 
-	Address string `db:"address" json:"address"`
-	Amount  string `db:"amount" json:"amount"`
-	Denom   string `db:"denom" json:"denom"`
+	return &s
 }
 
-// WithChainName implements the DatabaseEntrier interface.
-func (b BalanceRow) WithChainName(cn string) DatabaseEntrier {
-	b.ChainName = cn
-	return b
-}
+// Numeric is an exact decimal number, as stored in DECIMAL columns.
+// The empty Numeric is stored as NULL.
+type Numeric string
 
-// CW20BalanceRow represents a cw20 balance row inserted into the database.
-type CW20BalanceRow struct {
-	TracelistenerDatabaseRow
+// Value implements the driver.Valuer interface.
+func (n Numeric) Value() (driver.Value, error) {
+	if n == "" {
+		return nil, nil
+	}
 
-	ContractAddress string `db:"contract_address" json:"contract_address"`
-	Address         string `db:"address" json:"address"`
-	Amount          string `db:"amount" json:"amount"`
+	return string(n), nil
 }
 
-// WithChainName implements the DatabaseEntrier interface.
-func (b CW20BalanceRow) WithChainName(cn string) DatabaseEntrier {
-	b.ChainName = cn
-	return b
+// Scan implements the sql.Scanner interface.
+func (n *Numeric) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*n = ""
+	case string:
+		*n = Numeric(v)
+	case []byte:
+		*n = Numeric(v)
+	case int64:
+		*n = Numeric(strconv.FormatInt(v, 10))
+	case float64:
+		*n = Numeric(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into Numeric", src)
+	}
+
+	return nil
 }
 
-// CW20TokenInfoRow represents a cw20 token info row inserted into the database.
-type CW20TokenInfoRow struct {
-	TracelistenerDatabaseRow
+// StringArray is a list of strings, as stored in TEXT[] columns.
+// It's written in the PostgreSQL array text format, which CockroachDB and PostgreSQL parse
+// into arrays and SQLite, lacking them, stores as is in TEXT columns.
+type StringArray []string
 
-	ContractAddress string `db:"contract_address" json:"contract_address"`
-	Name            string `db:"name" json:"name"`
-	Symbol          string `db:"symbol" json:"symbol"`
-	Decimals        int    `db:"decimals" json:"decimals"`
-	TotalSupply     string `db:"total_supply" json:"total_supply"`
-}
+// Value implements the driver.Valuer interface.
+func (a StringArray) Value() (driver.Value, error) {
+	quoted := make([]string, 0, len(a))
+	for _, e := range a {
+		e = strings.ReplaceAll(e, `\`, `\\`)
+		e = strings.ReplaceAll(e, `"`, `\"`)
+		quoted = append(quoted, `"`+e+`"`)
+	}
 
-// WithChainName implements the DatabaseEntrier interface.
-func (b CW20TokenInfoRow) WithChainName(cn string) DatabaseEntrier {
-	b.ChainName = cn
-	return b
+	return "{" + strings.Join(quoted, ",") + "}", nil
 }
 
-// DelegationRow represents a delegation row inserted into the database.
-type DelegationRow struct {
-	TracelistenerDatabaseRow
+// Scan implements the sql.Scanner interface.
+func (a *StringArray) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case []string:
+		*a = append(StringArray(nil), v...)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into StringArray", src)
+	}
 
-	Delegator string `db:"delegator_address" json:"delegator"`
-	Validator string `db:"validator_address" json:"validator"`
-	Amount    string `db:"amount" json:"amount"`
-}
+	res, err := parseStringArray(s)
+	if err != nil {
+		return err
+	}
 
-// WithChainName implements the DatabaseEntrier interface.
-func (b DelegationRow) WithChainName(cn string) DatabaseEntrier {
-	b.ChainName = cn
-	return b
+	*a = res
+	return nil
 }
 
-// IBCChannelRow represents an IBC channel row inserted into the database.
-type IBCChannelRow struct {
-	TracelistenerDatabaseRow
+// parseStringArray parses a one-dimensional array in the PostgreSQL array text format.
+func parseStringArray(s string) (StringArray, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("cannot parse %q as an array", s)
+	}
 
-	ChannelID        string   `db:"channel_id" json:"channel_id"`
-	CounterChannelID string   `db:"counter_channel_id" json:"counter_channel_id"`
-	Hops             []string `db:"hops" json:"hops"`
-	Port             string   `db:"port" json:"port"`
-	State            int32    `db:"state" json:"state"`
-}
+	s = s[1 : len(s)-1]
+	if s == "" {
+		return StringArray{}, nil
+	}
 
-// WithChainName implements the DatabaseEntrier interface.
-func (c IBCChannelRow) WithChainName(cn string) DatabaseEntrier {
-	c.ChainName = cn
-	return c
-}
+	var res StringArray
+	var e strings.Builder
+	quoted, inQuotes := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			e.WriteByte(s[i])
+		case c == '"':
+			quoted = true
+			inQuotes = !inQuotes
+		case c == ',' && !inQuotes:
+			res = append(res, element(e.String(), quoted))
+			e.Reset()
+			quoted = false
+		default:
+			e.WriteByte(c)
+		}
+	}
 
-// IBCConnectionRow represents an IBC connection row inserted into the database.
-type IBCConnectionRow struct {
-	TracelistenerDatabaseRow
+	if inQuotes {
+		return nil, fmt.Errorf("cannot parse %q as an array, unterminated quote", s)
+	}
 
-	ConnectionID        string `db:"connection_id" json:"connection_id"`
-	ClientID            string `db:"client_id" json:"client_id"`
-	State               string `db:"state" json:"state"`
-	CounterConnectionID string `db:"counter_connection_id" json:"counter_connection_id"`
-	CounterClientID     string `db:"counter_client_id" json:"counter_client_id"`
+	return append(res, element(e.String(), quoted)), nil
 }
 
-// WithChainName implements the DatabaseEntrier interface.
-func (c IBCConnectionRow) WithChainName(cn string) DatabaseEntrier {
-	c.ChainName = cn
-	return c
-}
+// element returns an array element as parsed, unquoted elements being trimmed and NULL
+// being read as the empty string.
+func element(e string, quoted bool) string {
+	if quoted {
+		return e
+	}
 
-// IBCDenomTraceRow represents an IBC denom trace row inserted into the database.
-type IBCDenomTraceRow struct {
-	TracelistenerDatabaseRow
+	e = strings.TrimSpace(e)
+	if strings.EqualFold(e, "NULL") {
+		return ""
+	}
 
-	Path      string `json:"path" db:"path"`
-	BaseDenom string `json:"base_denom" db:"base_denom"`
-	Hash      string `json:"hash" db:"hash"`
+	return e
 }
 
-// WithChainName implements the DatabaseEntrier interface.
-func (c IBCDenomTraceRow) WithChainName(cn string) DatabaseEntrier {
-	c.ChainName = cn
-	return c
+// DatabaseEntrier is implemented by each object that wants to be inserted in a database.
+// It is usually used in conjunction to TracelistenerDatabaseRow.
+type DatabaseEntrier interface {
+	// WithChainName sets the ChainName field of the TracelistenerDatabaseRow struct.
+	WithChainName(cn string) DatabaseEntrier
 }
 
 // PoolRow represents a liquidity pool data inserted into the database.
@@ -174,50 +208,111 @@ func (bwp SwapRow) WithChainName(cn string) DatabaseEntrier {
 	return bwp
 }
 
-// AuthRow represents an account auth row inserted into the database.
-type AuthRow struct {
+// BlockTimeRow represents a row containing the last time a chain received a block.
+type BlockTimeRow struct {
 	TracelistenerDatabaseRow
 
-	Address        string `db:"address" json:"address"`
-	SequenceNumber uint64 `db:"sequence_number" json:"sequence_number"`
-	AccountNumber  uint64 `db:"account_number" json:"account_number"`
+	BlockTime time.Time `db:"block_time"`
+}
+
+// CheckpointRow represents the last block height whose data has been fully committed for a chain.
+type CheckpointRow struct {
+	TracelistenerDatabaseRow
+}
+
+// StateChangeRow represents a single change operated on a table row, recorded in the state changes log.
+type StateChangeRow struct {
+	TracelistenerDatabaseRow
+
+	TableName string `db:"table_name" json:"table_name"`
+	UniqueKey string `db:"unique_key" json:"unique_key"`
+	Operation string `db:"operation" json:"operation"`
 }
 
 // WithChainName implements the DatabaseEntrier interface.
-func (b AuthRow) WithChainName(cn string) DatabaseEntrier {
-	b.ChainName = cn
-	return b
+func (s StateChangeRow) WithChainName(cn string) DatabaseEntrier {
+	s.ChainName = cn
+	return s
 }
 
-// BlockTimeRow represents a row containing the last time a chain received a block.
-type BlockTimeRow struct {
+// ValidatorPowerRow represents the consensus power of a validator, as maintained in the validator powers table.
+type ValidatorPowerRow struct {
 	TracelistenerDatabaseRow
 
-	BlockTime time.Time `db:"block_time"`
+	ValidatorAddress string `db:"validator_address" json:"validator_address"`
+	VotingPower      int64  `db:"voting_power" json:"voting_power"`
+	Rank             *int64 `db:"rank" json:"rank,omitempty"`
+}
+
+// WithChainName implements the DatabaseEntrier interface.
+func (v ValidatorPowerRow) WithChainName(cn string) DatabaseEntrier {
+	v.ChainName = cn
+	return v
+}
+
+// ImportCursorRow represents how far a bulk import of a module has gone, its Height being the one
+// of the imported chain state.
+type ImportCursorRow struct {
+	TracelistenerDatabaseRow
+
+	Module        string `db:"module" json:"module"`
+	LastKey       []byte `db:"last_key" json:"last_key"`
+	KeysProcessed uint64 `db:"keys_processed" json:"keys_processed"`
+	Done          bool   `db:"done" json:"done"`
 }
 
-// IBCClientStateRow represents the state of client as a row inserted into the database.
-type IBCClientStateRow struct {
+// BlockSummaryRow represents the changes a block brought to the database, and how long writing them took.
+type BlockSummaryRow struct {
 	TracelistenerDatabaseRow
 
-	ChainID        string `db:"chain_id" json:"chain_id"`
-	ClientID       string `db:"client_id" json:"client_id"`
-	LatestHeight   uint64 `db:"latest_height" json:"latest_height"`
-	TrustingPeriod int64  `db:"trusting_period" json:"trusting_period"`
+	BlockTime       *time.Time `db:"block_time" json:"block_time,omitempty"`
+	TraceOps        Counts     `db:"trace_ops" json:"trace_ops"`
+	RowsWritten     Counts     `db:"rows_written" json:"rows_written"`
+	RowsDeleted     Counts     `db:"rows_deleted" json:"rows_deleted"`
+	FlushLatencyUs  int64      `db:"flush_latency_us" json:"flush_latency_us"`
+	CommitLatencyUs int64      `db:"commit_latency_us" json:"commit_latency_us"`
 }
 
 // WithChainName implements the DatabaseEntrier interface.
-func (b IBCClientStateRow) WithChainName(cn string) DatabaseEntrier {
+func (b BlockSummaryRow) WithChainName(cn string) DatabaseEntrier {
 	b.ChainName = cn
 	return b
 }
 
-type UnbondingDelegationRow struct {
-	TracelistenerDatabaseRow
+// Counts maps names, such as modules or tables, to a number of occurrences.
+// It's stored as a JSON object.
+type Counts map[string]uint64
+
+// Value implements the driver.Valuer interface.
+func (c Counts) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal counts, %w", err)
+	}
 
-	Delegator string                     `db:"delegator_address" json:"delegator"`
-	Validator string                     `db:"validator_address" json:"validator"`
-	Entries   UnbondingDelegationEntries `db:"entries" json:"entries"`
+	return string(data), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (c *Counts) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan counts from %T", src)
+	}
+
+	return json.Unmarshal(data, c)
 }
 
 type UnbondingDelegationEntry struct {
@@ -229,10 +324,14 @@ type UnbondingDelegationEntry struct {
 
 type UnbondingDelegationEntries []UnbondingDelegationEntry
 
-// WithChainName implements the DatabaseEntrier interface.
-func (b UnbondingDelegationRow) WithChainName(cn string) DatabaseEntrier {
-	b.ChainName = cn
-	return b
+// Value implements the driver.Valuer interface.
+func (entries UnbondingDelegationEntries) Value() (driver.Value, error) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal unbonding delegation entries, %w", err)
+	}
+
+	return data, nil
 }
 
 func (entries *UnbondingDelegationEntries) Scan(src interface{}) error {
@@ -248,38 +347,6 @@ func (entries *UnbondingDelegationEntries) Scan(src interface{}) error {
 	return json.Unmarshal(data, entries)
 }
 
-// ValidatorRow represents the state of a validator as a row inserted into the database.
-type ValidatorRow struct {
-	TracelistenerDatabaseRow
-
-	ValidatorAddress     string `db:"validator_address" json:"validator_address"`
-	OperatorAddress      string `db:"operator_address" json:"operator_address"`
-	ConsensusPubKeyType  string `db:"consensus_pubkey_type" json:"consensus_pubkey_type"`
-	ConsensusPubKeyValue []byte `db:"consensus_pubkey_value" json:"consensus_pubkey_value"`
-	Jailed               bool   `db:"jailed" json:"jailed"`
-	Status               int32  `db:"status" json:"status"`
-	Tokens               string `db:"tokens" json:"tokens"`
-	DelegatorShares      string `db:"delegator_shares" json:"delegator_shares"`
-	Moniker              string `db:"moniker" json:"moniker,omitempty"`
-	Identity             string `db:"identity" json:"identity,omitempty"`
-	Website              string `db:"website" json:"website,omitempty"`
-	SecurityContact      string `db:"security_contact" json:"security_contact,omitempty"`
-	Details              string `db:"details" json:"details,omitempty"`
-	UnbondingHeight      int64  `db:"unbonding_height" json:"unbonding_height"`
-	UnbondingTime        string `db:"unbonding_time" json:"unbonding_time"`
-	CommissionRate       string `db:"commission_rate" json:"commission_rate"`
-	MaxRate              string `db:"max_rate" json:"max_rate"`
-	MaxChangeRate        string `db:"max_change_rate" json:"max_change_rate"`
-	UpdateTime           string `db:"update_time" json:"update_time"`
-	MinSelfDelegation    string `db:"min_self_delegation" json:"min_self_delegation"`
-}
-
-// WithChainName implements the DatabaseEntrier interface.
-func (b ValidatorRow) WithChainName(cn string) DatabaseEntrier {
-	b.ChainName = cn
-	return b
-}
-
 type RedelegationRow struct {
 	TracelistenerDatabaseRow
 
@@ -298,6 +365,16 @@ type RedelegationEntry struct {
 
 type RedelegationEntries []RedelegationEntry
 
+// Value implements the driver.Valuer interface.
+func (entries RedelegationEntries) Value() (driver.Value, error) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal redelegation entries, %w", err)
+	}
+
+	return data, nil
+}
+
 // WithChainName implements the DatabaseEntrier interface.
 func (b RedelegationRow) WithChainName(cn string) DatabaseEntrier {
 	b.ChainName = cn
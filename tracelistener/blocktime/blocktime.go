@@ -11,13 +11,16 @@ import (
 
 	"github.com/emerishq/tracelistener/database"
 	"github.com/emerishq/tracelistener/models"
+	tldatabase "github.com/emerishq/tracelistener/tracelistener/database"
 )
 
 const (
 	tendermintWSPort = 26657
 	blEvents         = "tm.event='NewBlock'"
 
-	CreateTable = `CREATE TABLE IF NOT EXISTS tracelistener.blocktime (
+	tableName = "blocktime"
+
+	createTable = `CREATE TABLE IF NOT EXISTS %s (
 		id serial unique primary key,
 		chain_name text not null,
 		block_time timestamp not null,
@@ -25,7 +28,7 @@ const (
 	)`
 
 	insertBlocktime = `
-	INSERT INTO tracelistener.blocktime as tb
+	INSERT INTO %s as tb
 		(chain_name, block_time) 
 	VALUES 
 		(:chain_name, :block_time) 
@@ -38,16 +41,30 @@ const (
 	`
 )
 
+// CreateTable returns the statement creating the blocktime table in schema.
+func CreateTable(schema string) string {
+	return fmt.Sprintf(createTable, schema+"."+tableName)
+}
+
 type Watcher struct {
 	di        *database.Instance
+	table     string
+	summaries string
 	chainName string
 	l         *zap.SugaredLogger
 	tm        <-chan coretypes.ResultEvent
 }
 
 func New(di *database.Instance, chainName string, l *zap.SugaredLogger) *Watcher {
+	return NewWithSchema(di, "tracelistener", chainName, l)
+}
+
+// NewWithSchema returns a Watcher writing block times in the blocktime table of schema.
+func NewWithSchema(di *database.Instance, schema, chainName string, l *zap.SugaredLogger) *Watcher {
 	return &Watcher{
 		di:        di,
+		table:     schema + "." + tableName,
+		summaries: schema + "." + tldatabase.BlockSummariesTable,
 		chainName: chainName,
 		l:         l,
 	}
@@ -100,6 +117,20 @@ func (w *Watcher) ParseBlockData(data coretypes.ResultEvent) error {
 		return fmt.Errorf("cannot insert block time, %w", err)
 	}
 
+	if block.Block.Height == 0 {
+		return nil
+	}
+
+	if err := w.insertBlockSummaryTime(models.BlockSummaryRow{
+		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+			ChainName: w.chainName,
+			Height:    uint64(block.Block.Height),
+		},
+		BlockTime: &block.Block.Time,
+	}); err != nil {
+		return fmt.Errorf("cannot insert block summary time, %w", err)
+	}
+
 	return nil
 }
 
@@ -112,5 +143,11 @@ func (w *Watcher) lifecycle() {
 }
 
 func (w *Watcher) insertBlockTime(blo models.BlockTimeRow) error {
-	return w.di.Exec(insertBlocktime, blo, nil)
+	return w.di.Exec(fmt.Sprintf(insertBlocktime, w.table), blo, nil)
+}
+
+// insertBlockSummaryTime records the time of a block in its summary, which the processor
+// may not have written yet.
+func (w *Watcher) insertBlockSummaryTime(s models.BlockSummaryRow) error {
+	return w.di.Exec(fmt.Sprintf(tldatabase.UpsertBlockSummaryTime, w.summaries), s, nil)
 }
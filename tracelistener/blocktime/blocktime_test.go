@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
 	"github.com/gorilla/websocket"
 
 	"github.com/tendermint/tendermint/types"
@@ -19,6 +20,7 @@ import (
 	coretypes "github.com/tendermint/tendermint/rpc/core/types"
 
 	"github.com/emerishq/tracelistener/tracelistener/blocktime"
+	tldatabase "github.com/emerishq/tracelistener/tracelistener/database"
 
 	"github.com/cockroachdb/cockroach-go/v2/testserver"
 	"github.com/emerishq/tracelistener/database"
@@ -89,7 +91,7 @@ func TestWatcher_ParseBlockData(t *testing.T) {
 
 			require.NoError(t, database.RunMigrations(connString, []string{
 				"CREATE DATABASE tracelistener;",
-				blocktime.CreateTable,
+				blocktime.CreateTable("tracelistener"),
 			}))
 
 			w := blocktime.New(
@@ -191,7 +193,7 @@ func TestWatcher_InsertBlockTime(t *testing.T) {
 
 	require.NoError(t, database.RunMigrations(connString, []string{
 		"CREATE DATABASE tracelistener;",
-		blocktime.CreateTable,
+		blocktime.CreateTable("tracelistener"),
 	}))
 
 	w := blocktime.New(
@@ -232,6 +234,66 @@ func TestWatcher_InsertBlockTime(t *testing.T) {
 	}
 }
 
+func TestWatcher_BlockSummaryTime(t *testing.T) {
+	ts, err := testserver.NewTestServer()
+	require.NoError(t, err)
+	require.NoError(t, ts.WaitForInit())
+	defer func() {
+		ts.Stop()
+	}()
+
+	connString := ts.PGURL().String()
+
+	// creates the tracelistener database along with the block summaries table
+	i, err := tldatabase.New(connString)
+	require.NoError(t, err)
+
+	require.NoError(t, database.RunMigrations(connString, []string{
+		blocktime.CreateTable("tracelistener"),
+	}))
+
+	// the processor wrote the block before the watcher saw it
+	require.NoError(t, i.AddBlock("test", tracelistener.BlockWriteback{
+		Height:  10,
+		Summary: &models.BlockSummaryRow{FlushLatencyUs: 42},
+	}))
+
+	w := blocktime.New(
+		i.Instance,
+		"test",
+		zap.NewNop().Sugar(),
+	)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	block := func(height int64) coretypes.ResultEvent {
+		return coretypes.ResultEvent{
+			Data: types.EventDataNewBlock{
+				Block: &types.Block{
+					Header: types.Header{
+						Height: height,
+						Time:   now,
+					},
+				},
+			},
+		}
+	}
+
+	require.NoError(t, w.ParseBlockData(block(10)))
+	require.NoError(t, w.ParseBlockData(block(11)))
+
+	var rows []models.BlockSummaryRow
+	require.NoError(t, i.Instance.DB.Select(&rows, `SELECT chain_name, height, block_time, flush_latency_us FROM tracelistener.block_summaries ORDER BY height`))
+	require.Len(t, rows, 2)
+
+	require.Equal(t, uint64(10), rows[0].Height)
+	require.Equal(t, int64(42), rows[0].FlushLatencyUs)
+	require.Equal(t, now, *rows[0].BlockTime)
+
+	require.Equal(t, uint64(11), rows[1].Height)
+	require.Zero(t, rows[1].FlushLatencyUs)
+	require.Equal(t, now, *rows[1].BlockTime)
+}
+
 func TestNew(t *testing.T) {
 	l := zap.NewNop().Sugar()
 	cn := "chainName"
@@ -0,0 +1,98 @@
+// Package bootstrap hands off from a bulk import of the chain state to live tracing.
+package bootstrap
+
+import (
+	"github.com/emerishq/tracelistener/tracelistener"
+)
+
+// DefaultLimit is the default number of traces a Buffer holds.
+const DefaultLimit = 1000000
+
+// Buffer sits between a TraceWatcher and a DataProcessor, and holds the traces read
+// while the chain state is being imported.
+type Buffer struct {
+	// OnReplay is called once the buffered traces have been replayed, with the number
+	// of buffered traces and the number of them replayed.
+	OnReplay func(buffered, replayed int)
+
+	// OnFull is called when the buffer holds as many traces as it can, from then on
+	// it stops reading traces until Release is called.
+	OnFull func(limit int)
+
+	limit   int
+	release chan uint64
+}
+
+// NewBuffer returns a Buffer holding up to limit traces until Release is called,
+// DefaultLimit if limit isn't positive.
+func NewBuffer(limit int) *Buffer {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	return &Buffer{
+		limit:   limit,
+		release: make(chan uint64),
+	}
+}
+
+// Release makes Forward send the buffered traces of blocks after height, the one
+// the chain state has been imported at, and then everything it reads.
+func (b *Buffer) Release(height uint64) {
+	b.release <- height
+}
+
+// Forward buffers everything read from in until Release is called, then sends to out the buffered traces
+// whose block height is greater than the released one, followed by everything read from in.
+// Traces of the imported height and before are already part of the imported chain state.
+// Once the buffer is full, traces are left in in, blocking its sender, until Release is called.
+func (b *Buffer) Forward(in <-chan tracelistener.TraceOperation, out chan<- tracelistener.TraceOperation) {
+	var buf []tracelistener.TraceOperation
+	closed := false
+
+	// reading is nil while the buffer is full
+	reading := in
+
+	for {
+		select {
+		case data, ok := <-reading:
+			if !ok {
+				closed = true
+				reading = nil
+				continue
+			}
+
+			buf = append(buf, data)
+			if len(buf) < b.limit {
+				continue
+			}
+
+			reading = nil
+			if b.OnFull != nil {
+				b.OnFull(b.limit)
+			}
+		case height := <-b.release:
+			replayed := 0
+			for _, data := range buf {
+				if data.BlockHeight > height {
+					out <- data
+					replayed++
+				}
+			}
+
+			if b.OnReplay != nil {
+				b.OnReplay(len(buf), replayed)
+			}
+
+			buf = nil
+
+			if !closed {
+				for data := range in {
+					out <- data
+				}
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package bootstrap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/bootstrap"
+)
+
+func TestBuffer_Forward(t *testing.T) {
+	tests := []struct {
+		name             string
+		buffered         []uint64
+		height           uint64
+		live             []uint64
+		expected         []uint64
+		expectedReplayed int
+	}{
+		{
+			"traces of the imported height and before are dropped",
+			[]uint64{0, 9, 10, 10, 11, 11, 12},
+			10,
+			[]uint64{12, 13},
+			[]uint64{11, 11, 12, 12, 13},
+			3,
+		},
+		{
+			"nothing buffered",
+			nil,
+			10,
+			[]uint64{11},
+			[]uint64{11},
+			0,
+		},
+		{
+			"import ahead of the buffered traces",
+			[]uint64{10, 11},
+			12,
+			[]uint64{13},
+			[]uint64{13},
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan tracelistener.TraceOperation)
+			out := make(chan tracelistener.TraceOperation, len(tt.buffered)+len(tt.live))
+
+			var buffered, replayed int
+			b := bootstrap.NewBuffer(0)
+			b.OnReplay = func(b, r int) {
+				buffered, replayed = b, r
+			}
+
+			done := make(chan struct{})
+			go func() {
+				b.Forward(in, out)
+				close(done)
+			}()
+
+			for _, h := range tt.buffered {
+				in <- tracelistener.TraceOperation{BlockHeight: h}
+			}
+
+			b.Release(tt.height)
+
+			for _, h := range tt.live {
+				in <- tracelistener.TraceOperation{BlockHeight: h}
+			}
+			close(in)
+
+			<-done
+			require.Equal(t, len(tt.buffered), buffered)
+			require.Equal(t, tt.expectedReplayed, replayed)
+
+			close(out)
+			var heights []uint64
+			for data := range out {
+				heights = append(heights, data.BlockHeight)
+			}
+
+			require.Equal(t, tt.expected, heights)
+		})
+	}
+}
+
+func TestBuffer_Forward_Full(t *testing.T) {
+	in := make(chan tracelistener.TraceOperation)
+	out := make(chan tracelistener.TraceOperation, 4)
+
+	full := make(chan int, 1)
+	b := bootstrap.NewBuffer(2)
+	b.OnFull = func(limit int) {
+		full <- limit
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.Forward(in, out)
+		close(done)
+	}()
+
+	in <- tracelistener.TraceOperation{BlockHeight: 11}
+	in <- tracelistener.TraceOperation{BlockHeight: 12}
+	require.Equal(t, 2, <-full)
+
+	// the next trace waits for the buffered ones to be released
+	sent := make(chan struct{})
+	go func() {
+		in <- tracelistener.TraceOperation{BlockHeight: 13}
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		require.Fail(t, "trace buffered past the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	b.Release(10)
+	<-sent
+	close(in)
+	<-done
+
+	close(out)
+	var heights []uint64
+	for data := range out {
+		heights = append(heights, data.BlockHeight)
+	}
+
+	require.Equal(t, []uint64{11, 12, 13}, heights)
+}
@@ -0,0 +1,172 @@
+package bulk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	db2 "github.com/tendermint/tm-db"
+)
+
+// Backend is the database backend of a chain database, named after the node's db_backend setting.
+type Backend string
+
+const (
+	GoLevelDB Backend = "goleveldb"
+	RocksDB   Backend = "rocksdb"
+	BadgerDB  Backend = "badgerdb"
+	PebbleDB  Backend = "pebbledb"
+)
+
+// backendTags holds the build tag compiling each backend in, GoLevelDB always is.
+var backendTags = map[Backend]string{
+	RocksDB:  "rocksdb",
+	BadgerDB: "badgerdb",
+	PebbleDB: "pebbledb",
+}
+
+// backendOpener opens the database in dir, read-only unless create is true, in which case
+// it's created if missing.
+type backendOpener func(dir string, create bool) (db2.DB, error)
+
+// backends holds the openers of the backends compiled in, registered by their files.
+var backends = map[Backend]backendOpener{
+	GoLevelDB: openGoLevelDB,
+}
+
+// Backends returns the chain database backends compiled in.
+func Backends() []Backend {
+	res := make([]Backend, 0, len(backends))
+	for b := range backends {
+		res = append(res, b)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i] < res[j]
+	})
+
+	return res
+}
+
+// OpenDB opens the chain database at path with backend, detected from its files if empty.
+// The database is opened read-only unless create is true, in which case it's created if missing
+// with backend, which must then be set.
+// As with Tendermint, path may omit the .db suffix of the database directory.
+func OpenDB(path string, backend Backend, create bool) (db2.DB, error) {
+	dir := databaseDir(path)
+
+	if backend == "" {
+		if create {
+			return nil, fmt.Errorf("cannot create a chain database without a backend")
+		}
+
+		b, err := DetectBackend(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		backend = b
+	}
+
+	open, ok := backends[backend]
+	if !ok {
+		if tag, known := backendTags[backend]; known {
+			return nil, fmt.Errorf("backend %s not compiled in, build with -tags %s", backend, tag)
+		}
+
+		return nil, fmt.Errorf("unknown backend %s", backend)
+	}
+
+	db, err := open(dir, create)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s chain database, %w", backend, err)
+	}
+
+	return db, nil
+}
+
+// DetectBackend returns the backend of the chain database at path, from the files it's made of.
+func DetectBackend(path string) (Backend, error) {
+	dir := databaseDir(path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("cannot read chain database directory, %w", err)
+	}
+
+	options := ""
+	current := false
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case name == "KEYREGISTRY", strings.HasSuffix(name, ".vlog"):
+			return BadgerDB, nil
+		case strings.HasPrefix(name, "marker.format-version."):
+			return PebbleDB, nil
+		case strings.HasPrefix(name, "OPTIONS-"):
+			options = name
+		case name == "CURRENT":
+			current = true
+		}
+	}
+
+	// both RocksDB and pebble write an OPTIONS file stating their version
+	if options != "" {
+		bz, err := os.ReadFile(filepath.Join(dir, options))
+		if err != nil {
+			return "", fmt.Errorf("cannot read chain database options, %w", err)
+		}
+
+		switch {
+		case strings.Contains(string(bz), "pebble_version"):
+			return PebbleDB, nil
+		case strings.Contains(string(bz), "rocksdb_version"):
+			return RocksDB, nil
+		}
+	}
+
+	if current {
+		return GoLevelDB, nil
+	}
+
+	return "", fmt.Errorf("cannot detect the backend of the chain database in %s", dir)
+}
+
+// databaseDir returns the directory of the chain database at path, which Tendermint names
+// after the database with a .db suffix.
+func databaseDir(path string) string {
+	if _, err := os.Stat(path); err == nil || strings.HasSuffix(path, ".db") {
+		return path
+	}
+
+	if _, err := os.Stat(path + ".db"); err == nil {
+		return path + ".db"
+	}
+
+	return path
+}
+
+// splitDatabaseDir returns the name and parent directory tm-db opens dir with,
+// the backends adding the .db suffix themselves.
+func splitDatabaseDir(dir string) (string, string, error) {
+	if !strings.HasSuffix(dir, ".db") {
+		return "", "", fmt.Errorf("database directory %s must end with .db", dir)
+	}
+
+	return strings.TrimSuffix(filepath.Base(dir), ".db"), filepath.Dir(dir), nil
+}
+
+func openGoLevelDB(dir string, create bool) (db2.DB, error) {
+	name, parent, err := splitDatabaseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return db2.NewGoLevelDBWithOpts(name, parent, &opt.Options{
+		ErrorIfMissing: !create,
+		ReadOnly:       !create,
+	})
+}
@@ -0,0 +1,22 @@
+//go:build badgerdb
+
+package bulk
+
+import (
+	"github.com/dgraph-io/badger/v2"
+	db2 "github.com/tendermint/tm-db"
+)
+
+func init() {
+	backends[BadgerDB] = openBadgerDB
+}
+
+// openBadgerDB opens the BadgerDB database in dir, which unlike the other backends
+// Tendermint names without a .db suffix.
+func openBadgerDB(dir string, create bool) (db2.DB, error) {
+	opts := badger.DefaultOptions(dir).
+		WithReadOnly(!create).
+		WithLogger(nil)
+
+	return db2.NewBadgerDBWithOptions(opts)
+}
@@ -0,0 +1,274 @@
+//go:build pebbledb
+
+package bulk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+	db2 "github.com/tendermint/tm-db"
+)
+
+func init() {
+	backends[PebbleDB] = openPebbleDB
+}
+
+// openPebbleDB opens the pebble database in dir, which tm-db has no backend for.
+func openPebbleDB(dir string, create bool) (db2.DB, error) {
+	db, err := pebble.Open(dir, &pebble.Options{
+		ErrorIfNotExists: !create,
+		ReadOnly:         !create,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pebbleDB{db: db}, nil
+}
+
+var errEmptyKey = errors.New("key cannot be empty")
+
+// pebbleDB is a tm-db database stored by pebble.
+type pebbleDB struct {
+	db *pebble.DB
+}
+
+var _ db2.DB = (*pebbleDB)(nil)
+
+func (p *pebbleDB) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errEmptyKey
+	}
+
+	v, closer, err := p.db.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = closer.Close()
+	}()
+
+	return append([]byte{}, v...), nil
+}
+
+func (p *pebbleDB) Has(key []byte) (bool, error) {
+	v, err := p.Get(key)
+	return v != nil, err
+}
+
+func (p *pebbleDB) Set(key, value []byte) error {
+	return p.set(key, value, pebble.NoSync)
+}
+
+func (p *pebbleDB) SetSync(key, value []byte) error {
+	return p.set(key, value, pebble.Sync)
+}
+
+func (p *pebbleDB) set(key, value []byte, opts *pebble.WriteOptions) error {
+	if len(key) == 0 {
+		return errEmptyKey
+	}
+
+	if value == nil {
+		return fmt.Errorf("value cannot be nil")
+	}
+
+	return p.db.Set(key, value, opts)
+}
+
+func (p *pebbleDB) Delete(key []byte) error {
+	return p.delete(key, pebble.NoSync)
+}
+
+func (p *pebbleDB) DeleteSync(key []byte) error {
+	return p.delete(key, pebble.Sync)
+}
+
+func (p *pebbleDB) delete(key []byte, opts *pebble.WriteOptions) error {
+	if len(key) == 0 {
+		return errEmptyKey
+	}
+
+	return p.db.Delete(key, opts)
+}
+
+func (p *pebbleDB) Iterator(start, end []byte) (db2.Iterator, error) {
+	return newPebbleIterator(p.db, start, end, false)
+}
+
+func (p *pebbleDB) ReverseIterator(start, end []byte) (db2.Iterator, error) {
+	return newPebbleIterator(p.db, start, end, true)
+}
+
+func (p *pebbleDB) Close() error {
+	return p.db.Close()
+}
+
+func (p *pebbleDB) NewBatch() db2.Batch {
+	return &pebbleBatch{b: p.db.NewBatch()}
+}
+
+func (p *pebbleDB) Print() error {
+	it, err := p.Iterator(nil, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = it.Close()
+	}()
+
+	for ; it.Valid(); it.Next() {
+		fmt.Printf("[%X]:\t[%X]\n", it.Key(), it.Value())
+	}
+
+	return it.Error()
+}
+
+func (p *pebbleDB) Stats() map[string]string {
+	return map[string]string{
+		"database.type": "pebbleDB",
+	}
+}
+
+// pebbleBatch is a tm-db batch of pebble writes.
+type pebbleBatch struct {
+	b *pebble.Batch
+}
+
+func (b *pebbleBatch) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return errEmptyKey
+	}
+
+	if value == nil {
+		return fmt.Errorf("value cannot be nil")
+	}
+
+	if b.b == nil {
+		return fmt.Errorf("batch has been written or closed")
+	}
+
+	return b.b.Set(key, value, nil)
+}
+
+func (b *pebbleBatch) Delete(key []byte) error {
+	if len(key) == 0 {
+		return errEmptyKey
+	}
+
+	if b.b == nil {
+		return fmt.Errorf("batch has been written or closed")
+	}
+
+	return b.b.Delete(key, nil)
+}
+
+func (b *pebbleBatch) Write() error {
+	return b.write(pebble.NoSync)
+}
+
+func (b *pebbleBatch) WriteSync() error {
+	return b.write(pebble.Sync)
+}
+
+// write commits the batch, which can't be used afterwards.
+func (b *pebbleBatch) write(opts *pebble.WriteOptions) error {
+	if b.b == nil {
+		return fmt.Errorf("batch has been written or closed")
+	}
+
+	if err := b.b.Commit(opts); err != nil {
+		return err
+	}
+
+	return b.Close()
+}
+
+func (b *pebbleBatch) Close() error {
+	if b.b == nil {
+		return nil
+	}
+
+	err := b.b.Close()
+	b.b = nil
+	return err
+}
+
+// pebbleIterator is a tm-db iterator over a pebble key range.
+type pebbleIterator struct {
+	it         *pebble.Iterator
+	start, end []byte
+	reverse    bool
+}
+
+func newPebbleIterator(db *pebble.DB, start, end []byte, reverse bool) (*pebbleIterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errEmptyKey
+	}
+
+	it := db.NewIter(&pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+	})
+
+	if reverse {
+		it.Last()
+	} else {
+		it.First()
+	}
+
+	return &pebbleIterator{
+		it:      it,
+		start:   start,
+		end:     end,
+		reverse: reverse,
+	}, nil
+}
+
+func (i *pebbleIterator) Domain() ([]byte, []byte) {
+	return i.start, i.end
+}
+
+func (i *pebbleIterator) Valid() bool {
+	return i.it.Valid()
+}
+
+func (i *pebbleIterator) Next() {
+	i.assertValid()
+
+	if i.reverse {
+		i.it.Prev()
+	} else {
+		i.it.Next()
+	}
+}
+
+func (i *pebbleIterator) Key() []byte {
+	i.assertValid()
+	return append([]byte{}, i.it.Key()...)
+}
+
+func (i *pebbleIterator) Value() []byte {
+	i.assertValid()
+	return append([]byte{}, i.it.Value()...)
+}
+
+func (i *pebbleIterator) Error() error {
+	return i.it.Error()
+}
+
+func (i *pebbleIterator) Close() error {
+	return i.it.Close()
+}
+
+func (i *pebbleIterator) assertValid() {
+	if !i.Valid() {
+		panic("iterator is invalid")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build rocksdb
+
+package bulk
+
+import (
+	"github.com/tecbot/gorocksdb"
+	db2 "github.com/tendermint/tm-db"
+)
+
+func init() {
+	backends[RocksDB] = openRocksDB
+}
+
+// openRocksDB opens the RocksDB database in dir, which tm-db can't open read-only:
+// the node must be stopped while importing from it.
+func openRocksDB(dir string, create bool) (db2.DB, error) {
+	name, parent, err := splitDatabaseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := gorocksdb.NewDefaultOptions()
+	opts.SetCreateIfMissing(create)
+
+	return db2.NewRocksDBWithOptions(name, parent, opts)
+}
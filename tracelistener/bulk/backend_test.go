@@ -0,0 +1,116 @@
+package bulk_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bulk "github.com/emerishq/tracelistener/tracelistener/bulk"
+)
+
+// chainDBFixture copies the testdata chain database into a new one with backend,
+// and returns its path.
+func chainDBFixture(t *testing.T, backend bulk.Backend) string {
+	t.Helper()
+
+	src, err := bulk.OpenDB("./testdata/application.db", bulk.GoLevelDB, false)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, src.Close())
+	}()
+
+	path := filepath.Join(t.TempDir(), "application.db")
+	dst, err := bulk.OpenDB(path, backend, true)
+	require.NoError(t, err)
+
+	it, err := src.Iterator(nil, nil)
+	require.NoError(t, err)
+
+	batch := dst.NewBatch()
+	for ; it.Valid(); it.Next() {
+		require.NoError(t, batch.Set(it.Key(), it.Value()))
+	}
+	require.NoError(t, it.Error())
+	require.NoError(t, it.Close())
+
+	require.NoError(t, batch.WriteSync())
+	require.NoError(t, batch.Close())
+	require.NoError(t, dst.Close())
+
+	return path
+}
+
+func TestDetectBackend(t *testing.T) {
+	for _, backend := range bulk.Backends() {
+		backend := backend
+		t.Run(string(backend), func(t *testing.T) {
+			path := chainDBFixture(t, backend)
+
+			detected, err := bulk.DetectBackend(path)
+			require.NoError(t, err)
+			require.Equal(t, backend, detected)
+
+			// the .db suffix can be left out
+			detected, err = bulk.DetectBackend(filepath.Join(filepath.Dir(path), "application"))
+			require.NoError(t, err)
+			require.Equal(t, backend, detected)
+
+			db, err := bulk.OpenDB(path, "", false)
+			require.NoError(t, err)
+
+			has, err := db.Has([]byte("s/latest"))
+			require.NoError(t, err)
+			require.True(t, has)
+			require.NoError(t, db.Close())
+		})
+	}
+
+	t.Run("empty directory - error", func(t *testing.T) {
+		_, err := bulk.DetectBackend(t.TempDir())
+		require.Error(t, err)
+	})
+
+	t.Run("missing directory - error", func(t *testing.T) {
+		_, err := bulk.DetectBackend(filepath.Join(t.TempDir(), "missing.db"))
+		require.Error(t, err)
+	})
+}
+
+func TestOpenDB_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend bulk.Backend
+		create  bool
+	}{
+		{
+			"unknown backend",
+			"leveldb",
+			false,
+		},
+		{
+			"creating without a backend",
+			"",
+			true,
+		},
+		{
+			"missing database",
+			bulk.GoLevelDB,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "application.db")
+
+			_, err := bulk.OpenDB(path, tt.backend, tt.create)
+			require.Error(t, err)
+
+			_, err = os.Stat(path)
+			require.True(t, os.IsNotExist(err), "nothing is created")
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package bulk
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+)
+
+// batch holds the ops flushed from the processor caches, along with the cursors of the modules
+// whose keys produced them.
+type batch struct {
+	seq     uint64
+	ops     []tracelistener.WritebackOp
+	cursors []models.ImportCursorRow
+}
+
+// batcher flushes the processor caches once enough keys, or key and value bytes, have been
+// processed since the last flush.
+type batcher struct {
+	size  int
+	bytes int
+	flush func(cursors []models.ImportCursorRow) error
+
+	mu         sync.Mutex
+	batchSize  int
+	batchBytes int
+
+	// cursors holds the cursor of each module, touched the ones which moved since the last flush.
+	cursors map[string]models.ImportCursorRow
+	touched map[string]struct{}
+}
+
+// process calls fn to process key of module, whose value is n bytes long along with it,
+// and records it in the current batch, flushing it if full.
+// fn runs before the cursor of module moves past key, so a batch flushed meanwhile may
+// hold rows of keys its cursors don't cover yet, which a resumed import processes again,
+// but never cursors past keys whose rows it doesn't hold.
+func (b *batcher) process(module string, key []byte, n int, fn func()) error {
+	fn()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.cursors[module]
+	c.Module = module
+	c.LastKey = append([]byte(nil), key...)
+	c.KeysProcessed++
+	b.cursors[module] = c
+	b.touched[module] = struct{}{}
+
+	b.batchSize++
+	b.batchBytes += n
+	if b.batchSize < b.size && b.batchBytes < b.bytes {
+		return nil
+	}
+
+	return b.flushLocked()
+}
+
+// done records that all the keys of module have been processed.
+func (b *batcher) done(module string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.cursors[module]
+	c.Module = module
+	c.Done = true
+	b.cursors[module] = c
+	b.touched[module] = struct{}{}
+}
+
+// flushNow flushes the current batch.
+func (b *batcher) flushNow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.flushLocked()
+}
+
+func (b *batcher) flushLocked() error {
+	cursors := make([]models.ImportCursorRow, 0, len(b.touched))
+	for m := range b.touched {
+		cursors = append(cursors, b.cursors[m])
+	}
+
+	b.touched = map[string]struct{}{}
+	b.batchSize, b.batchBytes = 0, 0
+
+	return b.flush(cursors)
+}
+
+// cursorCommitter saves the cursors of written batches in the order batches have been flushed,
+// so that a cursor never gets past a batch which hasn't been written yet.
+type cursorCommitter struct {
+	save func([]models.ImportCursorRow) error
+	l    *zap.SugaredLogger
+
+	mu      sync.Mutex
+	next    uint64
+	written map[uint64][]models.ImportCursorRow
+}
+
+// commit records that the batch numbered seq has been written, saving its cursors
+// once all the previous batches have been written too.
+func (c *cursorCommitter) commit(seq uint64, cursors []models.ImportCursorRow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.written == nil {
+		c.written = map[uint64][]models.ImportCursorRow{}
+	}
+
+	c.written[seq] = cursors
+	for {
+		cs, ok := c.written[c.next]
+		if !ok {
+			return
+		}
+
+		delete(c.written, c.next)
+		c.next++
+
+		// a cursor left behind only makes a resumed import process some keys again
+		if err := c.save(cs); err != nil {
+			c.l.Errorw("cannot save import cursors", "error", err)
+		}
+	}
+}
@@ -1,19 +1,18 @@
 package bulk
 
 import (
+	"errors"
 	"fmt"
-	"path/filepath"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	types2 "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/emerishq/tracelistener/models"
 	"github.com/emerishq/tracelistener/tracelistener/database"
 	"github.com/emerishq/tracelistener/tracelistener/processor"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/sync/errgroup"
 
-	"github.com/cosmos/cosmos-sdk/types"
-
 	"github.com/cosmos/cosmos-sdk/store/rootmulti"
 	db2 "github.com/tendermint/tm-db"
 
@@ -21,7 +20,6 @@ import (
 
 	"github.com/emerishq/tracelistener/tracelistener"
 	gogotypes "github.com/gogo/protobuf/types"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 type Importer struct {
@@ -31,8 +29,70 @@ type Importer struct {
 	Logger       *zap.SugaredLogger
 	Database     *database.Instance
 	Modules      []string
+
+	// Upsert makes the importer overwrite existing rows instead of
+	// inserting new ones, used when importing over a populated database.
+	Upsert bool
+
+	// Backend is the database backend of the chain database at Path, detected from its files
+	// if empty.
+	Backend Backend
+
+	// GenesisPath makes the importer read the chain state from the app state of the exported
+	// genesis file at this path instead of the chain database at Path, at the height the
+	// genesis was exported at.
+	GenesisPath string
+
+	// Height is the version of the chain state to import, which must not have been pruned
+	// from the chain database; the latest one is imported if zero.
+	Height int64
+
+	// BatchSize and BatchBytes bound the number of keys, and their size along with their values,
+	// processed before the processor caches are flushed and written to the database.
+	// DefaultBatchSize and DefaultBatchBytes are used if zero.
+	BatchSize  int
+	BatchBytes int
+
+	// Writers is the number of batches written concurrently, one by default.
+	// Batches are written in order with a single writer, which values derived from other modules,
+	// like delegated tokens, rely on.
+	Writers int
+
+	// Backfill imports into a database live tracing is already filling: rows are upserted
+	// only where the stored ones are older, and history and state changes aren't recorded.
+	// Modules can be backfilled this way while tracelistener keeps running.
+	Backfill bool
+
+	// WriteLock, if set, is held while each batch is written to Database, so that the batches
+	// of a backfill don't interleave with the blocks live tracing writes meanwhile.
+	WriteLock sync.Locker
+
+	// Writeback, if set, is handed the rows of each batch instead of them being written to Database,
+	// which isn't used then unless ChainName is set. Calls are concurrent with more than one writer.
+	Writeback func(ops []tracelistener.WritebackOp)
+
+	// ChainName makes the importer record how far it has gone in the import cursors table,
+	// an interrupted import of the same height being resumed from there by the next one,
+	// unless it's a backfill.
+	ChainName string
+
+	// progress holds the *progress of the running import.
+	progress atomic.Value
+
+	// importedHeight is the height of the chain state imported by the last successful Do.
+	importedHeight int64
 }
 
+const (
+	// DefaultBatchSize is the number of keys processed between two writes by default.
+	DefaultBatchSize = 50_000
+
+	// DefaultBatchBytes is the size of the keys and values processed between two writes by default.
+	DefaultBatchBytes = 64 << 20
+)
+
+var errNoRowsAffected = errors.New("affected rows are zero")
+
 func ImportableModulesList() []string {
 	ml := make([]string, 0, len(tracelistener.SupportedSDKModuleList))
 	for k := range tracelistener.SupportedSDKModuleList {
@@ -52,7 +112,18 @@ func (i Importer) validateModulesList() error {
 	return nil
 }
 
-func (i *Importer) processWritebackData(data []tracelistener.WritebackOp) {
+// processWritebackData writes data to the database, returning the first error met.
+func (i *Importer) processWritebackData(data []tracelistener.WritebackOp) error {
+	if i.Writeback != nil {
+		i.Writeback(data)
+		return nil
+	}
+
+	if i.WriteLock != nil {
+		i.WriteLock.Lock()
+		defer i.WriteLock.Unlock()
+	}
+
 	for _, p := range data {
 		if len(p.Data) == 0 {
 			continue
@@ -74,12 +145,28 @@ func (i *Importer) processWritebackData(data []tracelistener.WritebackOp) {
 			totalUnitsAmt += uint64(len(wbUnit.Data))
 
 			if err := insertDB(i.Database.Instance.DB, wbUnit.Statement, is); err != nil {
+				// upserts don't touch rows which are already up to date
+				if i.Upsert && errors.Is(err, errNoRowsAffected) {
+					continue
+				}
+
+				// nothing failed to be written, there just wasn't anything to change
+				if errors.Is(err, errNoRowsAffected) {
+					i.Logger.Warnw("no rows written",
+						"statement", wbUnit.Statement,
+						"type", wbUnit.Type,
+					)
+					continue
+				}
+
 				i.Logger.Errorw("database error",
 					"error", err,
 					"statement", wbUnit.Statement,
 					"type", wbUnit.Type,
 					"data", fmt.Sprint(wbUnit.Data),
 				)
+
+				return fmt.Errorf("cannot write %s rows, %w", p.SourceModule, err)
 			}
 		}
 
@@ -93,6 +180,7 @@ func (i *Importer) processWritebackData(data []tracelistener.WritebackOp) {
 	}
 
 	i.Logger.Debugw("finished processing writeback data")
+	return nil
 }
 
 func insertDB(db *sqlx.DB, query string, params interface{}) error {
@@ -107,7 +195,7 @@ func insertDB(db *sqlx.DB, query string, params interface{}) error {
 	}
 
 	if re == 0 {
-		return fmt.Errorf("affected rows are zero")
+		return errNoRowsAffected
 	}
 
 	return nil
@@ -123,40 +211,68 @@ func (i *Importer) Do() error {
 	}
 
 	i.Processor.StopBackgroundProcessing()
-	i.Processor.SetDBUpsertEnabled(false)
 
-	i.Path = strings.TrimSuffix(i.Path, ".db")
+	src, err := i.openSource()
+	if err != nil {
+		return err
+	}
 
-	dbName := filepath.Base(i.Path)
-	path := filepath.Dir(i.Path)
+	height := src.height()
 
-	db, err := db2.NewGoLevelDBWithOpts(dbName, path, &opt.Options{
-		ErrorIfMissing: true,
-		ReadOnly:       true,
-	})
+	i.Logger.Infow("importing chain state", "height", height)
 
+	cursors, resumed, err := i.importCursors(height)
 	if err != nil {
-		return fmt.Errorf("cannot open chain database, %w", err)
+		_ = src.close()
+		return err
+	}
+
+	// rows of the batch being written when the previous run stopped might be there already
+	if resumed {
+		i.Logger.Infow("resuming import", "height", height)
+		i.Upsert = true
+	}
+
+	if i.Backfill {
+		i.Upsert = true
 	}
 
-	latestBlockHeight := getLatestVersion(db)
+	i.Processor.SetDBUpsertEnabled(i.Upsert)
+	i.Processor.SetBackfill(i.Backfill)
 
-	rm := rootmulti.NewStore(db)
-	keys := make([]types2.StoreKey, 0, len(i.Modules))
+	modulesLen := len(i.Modules)
 
-	for _, ci := range i.Modules {
-		key := types.NewKVStoreKey(ci)
-		keys = append(keys, key)
-		rm.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	batchSize, batchBytes, writers := i.BatchSize, i.BatchBytes, i.Writers
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if batchBytes <= 0 {
+		batchBytes = DefaultBatchBytes
+	}
+	if writers <= 0 {
+		writers = 1
 	}
 
-	if err := rm.LoadLatestVersion(); err != nil {
-		panic(err)
+	prog := newProgress(height)
+	for _, module := range i.Modules {
+		c := cursors[module]
+		mp := ModuleProgress{KeysProcessed: c.KeysProcessed, Done: c.Done}
+
+		size, err := src.size(module)
+		if err != nil {
+			i.Logger.Warnw("cannot read store size, progress will be partial", "module", module, "error", err)
+		}
+		mp.KeysTotal = size
+
+		prog.setModule(module, mp)
 	}
 
-	keysLen := len(keys)
+	i.progress.Store(prog)
+	stopProgress := make(chan struct{})
+	go i.logProgress(stopProgress)
+	defer close(stopProgress)
 
-	wbChan := make(chan []tracelistener.WritebackOp)
+	wbChan := make(chan tracelistener.BlockWriteback)
 
 	t0 := time.Now()
 	done := make(chan struct{})
@@ -179,17 +295,96 @@ func (i *Importer) Do() error {
 		}
 	}()
 
+	committer := cursorCommitter{
+		save: i.saveImportCursors,
+		l:    i.Logger,
+	}
+
+	// writers write flushed batches while modules are still being processed, flushing blocks
+	// when all of them are busy so that at most writers+1 batches are held in memory.
+	// The cursors of a batch which couldn't be written are never committed, neither are the
+	// ones of the following batches, and the first write error stops the import.
+	writes := make(chan batch)
+	writersWg := sync.WaitGroup{}
+	writeErrMu := sync.Mutex{}
+	var writeErr error
+	failedWrite := func() error {
+		writeErrMu.Lock()
+		defer writeErrMu.Unlock()
+		return writeErr
+	}
+
+	for w := 0; w < writers; w++ {
+		writersWg.Add(1)
+		go func() {
+			defer writersWg.Done()
+			for b := range writes {
+				if err := i.processWritebackData(b.ops); err != nil {
+					writeErrMu.Lock()
+					if writeErr == nil {
+						writeErr = err
+					}
+					writeErrMu.Unlock()
+					continue
+				}
+
+				committer.commit(b.seq, b.cursors)
+			}
+		}()
+	}
+
+	// the batcher advances its own copy of the cursors, workers start from the ones read
+	batcherCursors := make(map[string]models.ImportCursorRow, len(cursors))
+	for m, c := range cursors {
+		batcherCursors[m] = c
+	}
+
+	seq := uint64(0)
+	b := batcher{
+		size:    batchSize,
+		bytes:   batchBytes,
+		cursors: batcherCursors,
+		touched: map[string]struct{}{},
+		flush: func(cursors []models.ImportCursorRow) error {
+			if err := failedWrite(); err != nil {
+				return err
+			}
+
+			if err := i.Processor.Flush(); err != nil {
+				return fmt.Errorf("cannot flush processor cache, %w", err)
+			}
+
+			writes <- batch{seq: seq, ops: (<-wbChan).Ops, cursors: cursors}
+			seq++
+			return nil
+		},
+	}
+
 	processingTime := time.Now()
 
 	eg := errgroup.Group{}
-	for idx, key := range keys {
-		key := key
+	for idx, module := range i.Modules {
+		module := module
 		idx := idx
 		eg.Go(func() error {
-			i.Logger.Infow("processing started", "module", key.Name(), "index", idx+1, "total", keysLen)
+			c := cursors[module]
+			if c.Done {
+				i.Logger.Infow("module already imported", "module", module, "index", idx+1, "total", modulesLen)
+				return nil
+			}
+
+			// iteration resumes right after the last key processed
+			var start []byte
+			if c.LastKey != nil {
+				start = append(append([]byte(nil), c.LastKey...), 0)
+			}
+
+			i.Logger.Infow("processing started", "module", module, "index", idx+1, "total", modulesLen, "resumed", start != nil)
 
-			store := rm.GetKVStore(key)
-			ii := store.Iterator(nil, nil)
+			ii, err := src.iterator(module, start)
+			if err != nil {
+				return fmt.Errorf("cannot iterate %s store, %w", module, err)
+			}
 
 			processedRows := uint64(0)
 
@@ -198,17 +393,24 @@ func (i *Importer) Do() error {
 					Operation:          tracelistener.WriteOp.String(),
 					Key:                ii.Key(),
 					Value:              ii.Value(),
-					BlockHeight:        uint64(latestBlockHeight),
-					SuggestedProcessor: tracelistener.SDKModuleName(key.Name()),
+					BlockHeight:        uint64(height),
+					SuggestedProcessor: tracelistener.SDKModuleName(module),
 				}
 
-				pp := i.Processor.(*processor.Processor)
-				if err := pp.ProcessData(to); err != nil {
-					i.Logger.Errorw("processing error", "error", err)
+				err := b.process(module, to.Key, len(to.Key)+len(to.Value), func() {
+					pp := i.Processor.(*processor.Processor)
+					if err := pp.ProcessData(to); err != nil {
+						i.Logger.Errorw("processing error", "error", err)
+					}
+				})
+				if err != nil {
+					_ = ii.Close()
+					return err
 				}
 
 				i.Logger.Debugw("parsed data", "key", string(to.Key), "value", string(to.Value))
 				processedRows++
+				prog.processed(module)
 			}
 
 			if err := ii.Error(); err != nil {
@@ -219,53 +421,171 @@ func (i *Importer) Do() error {
 				return fmt.Errorf("cannot close iterator, %w", err)
 			}
 
-			i.Logger.Infow("processing done", "module", key.Name(), "total_rows", processedRows, "index", idx+1, "total", keysLen)
+			b.done(module)
+			prog.done(module)
+
+			i.Logger.Infow("processing done", "module", module, "total_rows", processedRows, "index", idx+1, "total", modulesLen)
 			return nil
 		})
 	}
 
-	if err := eg.Wait(); err != nil {
+	err = eg.Wait()
+	if err == nil {
+		// write what's left since the last batch
+		err = b.flushNow()
+	}
+
+	close(writes)
+	writersWg.Wait()
+	done <- struct{}{}
+	close(wbChan)
+
+	if err == nil {
+		err = failedWrite()
+	}
+
+	if err != nil {
+		_ = src.close()
 		return err
 	}
 
-	if err := db.Close(); err != nil {
-		return fmt.Errorf("database closing error, %w", err)
+	if err := src.close(); err != nil {
+		return err
 	}
 
-	/*
-		This is dumb but it works.
+	// the next import starts from scratch
+	if i.resumable() {
+		if err := i.Database.DeleteImportCursors(i.ChainName); err != nil {
+			return err
+		}
+	}
 
-		What's happening here?
-		Since we're importing we know beforehand how many database flushes will happen, hence we know how many times
-		the case
-		    case b := <-i.Processor.WritebackChan():
-		in the processor's select will happen: exactly len(keys) times.
+	i.importedHeight = height
 
-		To make sure we wait until this is true, we wait forever until len(wbChan) is equal to len(keys).
-		Since wbChan is a buffered channel with len(keys) elements, the Go runtime gives us synchronization for free.
-		After that, we write back data to the database.
-	*/
+	tn := time.Now()
+	i.Logger.Infow("import done", "total time", tn.Sub(t0), "processing time", tn.Sub(processingTime))
 
-	if err := i.Processor.Flush(); err != nil {
-		return fmt.Errorf("cannot flush processor cache, %w", err)
+	return nil
+}
+
+// openSource opens the chain state the import reads from, the exported genesis file at
+// GenesisPath if set, the chain database at Path otherwise.
+func (i *Importer) openSource() (source, error) {
+	if i.GenesisPath == "" {
+		return openChainDB(i.Path, i.Backend, i.Modules, i.Height)
 	}
 
-	data := <-wbChan
+	if i.Height != 0 {
+		return nil, fmt.Errorf("cannot import height %d from a genesis file, only its own height can be", i.Height)
+	}
 
-	done <- struct{}{}
+	return openGenesis(i.GenesisPath, i.Modules)
+}
 
-	i.processWritebackData(data)
-	close(wbChan)
+// ImportedHeight returns the height of the chain state imported by the last successful Do,
+// zero if none.
+func (i *Importer) ImportedHeight() int64 {
+	return i.importedHeight
+}
 
-	tn := time.Now()
-	i.Logger.Infow("import done", "total time", tn.Sub(t0), "processing time", tn.Sub(processingTime))
+// resumable returns true if the import records cursors to be resumed from.
+// Backfills don't, since they can be run again from scratch.
+func (i *Importer) resumable() bool {
+	return i.ChainName != "" && !i.Backfill
+}
 
-	return nil
+// importCursors returns the cursors of the imported modules by module, resumed if a previous
+// import of height left some, discarding the ones left by an import of another height.
+// Imports are only resumed when ChainName is set.
+func (i *Importer) importCursors(height int64) (map[string]models.ImportCursorRow, bool, error) {
+	res := map[string]models.ImportCursorRow{}
+	if !i.resumable() {
+		return res, false, nil
+	}
+
+	cursors, err := i.Database.ImportCursors(i.ChainName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, c := range cursors {
+		if c.Height != uint64(height) {
+			i.Logger.Warnw("discarding cursors of an import of another height", "height", c.Height)
+			if err := i.Database.DeleteImportCursors(i.ChainName); err != nil {
+				return nil, false, err
+			}
+
+			cursors = nil
+			break
+		}
+	}
+
+	// cursors of modules not imported this time are kept, and left untouched
+	resumed := false
+	for _, m := range i.Modules {
+		if c, ok := cursors[m]; ok {
+			res[m] = c
+			resumed = true
+			continue
+		}
+
+		res[m] = models.ImportCursorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+				ChainName: i.ChainName,
+				Height:    uint64(height),
+			},
+			Module: m,
+		}
+	}
+
+	return res, resumed, nil
+}
+
+func (i *Importer) saveImportCursors(cursors []models.ImportCursorRow) error {
+	if !i.resumable() {
+		return nil
+	}
+
+	return i.Database.SetImportCursors(cursors)
+}
+
+// loadVersion loads the stores mounted on rm at height, or at the latest height stored in db
+// if zero, and returns the height loaded.
+func loadVersion(db db2.DB, rm *rootmulti.Store, height int64) (int64, error) {
+	latest := getLatestVersion(db)
+	if height == 0 {
+		if err := rm.LoadLatestVersion(); err != nil {
+			return 0, fmt.Errorf("cannot load latest height %d, %w", latest, err)
+		}
+
+		return latest, nil
+	}
+
+	if height < 0 || height > latest {
+		return 0, fmt.Errorf("cannot load height %d, chain database heights go up to %d", height, latest)
+	}
+
+	has, err := db.Has([]byte(fmt.Sprintf(commitInfoKeyFmt, height)))
+	if err != nil {
+		return 0, fmt.Errorf("cannot read commit info of height %d, %w", height, err)
+	}
+
+	if !has {
+		return 0, fmt.Errorf("cannot load height %d, it has been pruned from the chain database", height)
+	}
+
+	// pruning removes IAVL versions but keeps commit infos, loading fails on the stores then.
+	if err := rm.LoadVersion(height); err != nil {
+		return 0, fmt.Errorf("cannot load height %d, it might have been pruned from the chain database, %w", height, err)
+	}
+
+	return height, nil
 }
 
 // vendored from cosmos-sdk/store/rootmulti/rootmulti.go
 const (
 	latestVersionKey = "s/latest"
+	commitInfoKeyFmt = "s/%d"
 )
 
 func getLatestVersion(db db2.DB) int64 {
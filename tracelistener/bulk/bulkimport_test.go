@@ -8,12 +8,15 @@ package bulk_test
 */
 
 import (
+	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/cockroachdb/cockroach-go/v2/testserver"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	dbutils "github.com/emerishq/tracelistener/database"
 	"github.com/emerishq/tracelistener/models"
 	"github.com/emerishq/tracelistener/tracelistener"
 
@@ -22,6 +25,7 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener/config"
 	"github.com/emerishq/tracelistener/tracelistener/database"
 	"github.com/emerishq/tracelistener/tracelistener/processor"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
 func TestImporterDo(t *testing.T) {
@@ -155,8 +159,8 @@ func TestImporterDo(t *testing.T) {
 					tt.connString = ts.PGURL().String()
 				}
 
-				database.RegisterMigration(dpi.DatabaseMigrations()...)
-				database.RegisterMigration(blocktime.CreateTable)
+				database.RegisterVersionedMigration(tables.Migrations...)
+				database.RegisterMigration(blocktime.CreateTable("tracelistener"))
 
 				di, err := database.New(tt.connString)
 				if tt.expectedDBErr {
@@ -281,3 +285,377 @@ func TestImporterDo(t *testing.T) {
 		})
 	}
 }
+
+// newSQLiteImport returns a processor configured by cfg along with the SQLite database
+// it writes to, both ready to import testdata.
+func newSQLiteImport(t *testing.T, cfg config.Config) (tracelistener.DataProcessor, *database.Instance) {
+	t.Helper()
+
+	cfg.ChainName = "gaia"
+	cfg.DatabaseDialect = "sqlite"
+
+	dpi, err := processor.New(zap.NewNop().Sugar(), &cfg)
+	require.NoError(t, err)
+
+	database.RegisterVersionedMigration(tables.Migrations...)
+	di, err := database.NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), database.Options{
+		Dialect: dbutils.DialectSQLite,
+	})
+	require.NoError(t, err)
+
+	dpi.StartBackgroundProcessing()
+
+	return dpi, di
+}
+
+// tableCounts returns the number of rows of tables in di.
+func tableCounts(t *testing.T, di *database.Instance, tables ...string) map[string]int {
+	t.Helper()
+
+	res := map[string]int{}
+	for _, table := range tables {
+		var c int
+		require.NoError(t, di.Instance.DB.Get(&c, fmt.Sprintf("SELECT count(*) FROM tracelistener.%s", table)))
+		res[table] = c
+	}
+
+	return res
+}
+
+func TestImporterDo_Batches_SQLite(t *testing.T) {
+	// counts returns the number of rows of the main tables after importing testdata with im.
+	counts := func(t *testing.T, im bulk.Importer) map[string]int {
+		t.Helper()
+
+		dpi, di := newSQLiteImport(t, config.Config{})
+
+		im.Path = "./testdata/application.db"
+		im.Processor = dpi
+		im.Logger = zap.NewNop().Sugar()
+		im.Database = di
+
+		require.NoError(t, im.Do())
+
+		return tableCounts(t, di, "auth", "balances", "delegations", "validators")
+	}
+
+	expected := counts(t, bulk.Importer{})
+	require.NotZero(t, expected["balances"])
+
+	tests := []struct {
+		name string
+		im   bulk.Importer
+	}{
+		{"small batches", bulk.Importer{BatchSize: 3}},
+		{"small batches written concurrently", bulk.Importer{BatchSize: 3, Writers: 4}},
+		{"batches bounded by size", bulk.Importer{BatchBytes: 256}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, expected, counts(t, tt.im))
+		})
+	}
+}
+
+func TestImporterDo_Resume_SQLite(t *testing.T) {
+	// run imports testdata into a database holding cursors, and returns the number of rows
+	// of the main tables.
+	run := func(t *testing.T, cursors ...models.ImportCursorRow) map[string]int {
+		t.Helper()
+
+		dpi, di := newSQLiteImport(t, config.Config{})
+		require.NoError(t, di.SetImportCursors(cursors))
+
+		im := bulk.Importer{
+			Path:      "./testdata/application.db",
+			Processor: dpi,
+			Logger:    zap.NewNop().Sugar(),
+			Database:  di,
+			ChainName: "gaia",
+			BatchSize: 3,
+		}
+
+		require.NoError(t, im.Do())
+
+		// cursors are only needed to resume an interrupted import
+		left, err := di.ImportCursors("gaia")
+		require.NoError(t, err)
+		require.Empty(t, left)
+
+		p := im.Progress()
+		require.EqualValues(t, 2, p.Height)
+		for m, mp := range p.Modules {
+			require.True(t, mp.Done, m)
+		}
+
+		return tableCounts(t, di, "auth", "balances", "delegations", "validators")
+	}
+	cursor := func(module string, height uint64, done bool) models.ImportCursorRow {
+		return models.ImportCursorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+				ChainName: "gaia",
+				Height:    height,
+			},
+			Module: module,
+			Done:   done,
+		}
+	}
+
+	expected := run(t)
+	require.NotZero(t, expected["balances"])
+
+	// testdata/application.db latest height is 2.
+	tests := []struct {
+		name     string
+		cursors  []models.ImportCursorRow
+		expected map[string]int
+	}{
+		{
+			"module already imported is skipped",
+			[]models.ImportCursorRow{cursor("bank", 2, true)},
+			map[string]int{"auth": expected["auth"], "balances": 0, "delegations": expected["delegations"], "validators": expected["validators"]},
+		},
+		{
+			"module not started yet is imported",
+			[]models.ImportCursorRow{cursor("bank", 2, false)},
+			expected,
+		},
+		{
+			"cursors of another height are discarded",
+			[]models.ImportCursorRow{cursor("bank", 1, true)},
+			expected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, run(t, tt.cursors...))
+		})
+	}
+}
+
+func TestImporterDo_Backfill_SQLite(t *testing.T) {
+	dpi, di := newSQLiteImport(t, config.Config{Processor: config.ProcessorConfig{StateChangesEnabled: true, HistoryEnabled: true}})
+
+	im := bulk.Importer{
+		Path:      "./testdata/application.db",
+		Processor: dpi,
+		Logger:    zap.NewNop().Sugar(),
+		Database:  di,
+		ChainName: "gaia",
+	}
+	require.NoError(t, im.Do())
+
+	var addresses []string
+	require.NoError(t, di.Instance.DB.Select(&addresses, "SELECT address FROM tracelistener.balances ORDER BY address LIMIT 3"))
+	require.Len(t, addresses, 3)
+	updated, deleted, missing := addresses[0], addresses[1], addresses[2]
+
+	// live tracing wrote blocks after the imported height 2, and a module is being enabled
+	_, err := di.Instance.DB.Exec("UPDATE tracelistener.balances SET height = 100, amount = '42stake' WHERE address = $1", updated)
+	require.NoError(t, err)
+	_, err = di.Instance.DB.Exec("UPDATE tracelistener.balances SET height = 100, delete_height = 100 WHERE address = $1", deleted)
+	require.NoError(t, err)
+	_, err = di.Instance.DB.Exec("DELETE FROM tracelistener.balances WHERE address = $1", missing)
+	require.NoError(t, err)
+	_, err = di.Instance.DB.Exec("DELETE FROM tracelistener.auth")
+	require.NoError(t, err)
+
+	before := tableCounts(t, di, "auth", "balances", "balances_history", "state_changes")
+
+	backfill := func(modules ...string) {
+		dpi, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+			ChainName:       "gaia",
+			DatabaseDialect: "sqlite",
+			Processor:       config.ProcessorConfig{StateChangesEnabled: true},
+		})
+		require.NoError(t, err)
+		dpi.StartBackgroundProcessing()
+
+		require.NoError(t, (&bulk.Importer{
+			Path:      "./testdata/application.db",
+			Processor: dpi,
+			Logger:    zap.NewNop().Sugar(),
+			Database:  di,
+			Modules:   modules,
+			ChainName: "gaia",
+			Backfill:  true,
+		}).Do())
+	}
+
+	backfill("bank")
+
+	after := tableCounts(t, di, "auth", "balances", "balances_history", "state_changes")
+	require.Equal(t, before["balances"]+1, after["balances"], "missing row is backfilled")
+	require.Zero(t, after["auth"], "other modules are left alone")
+	require.Equal(t, before["balances_history"], after["balances_history"])
+	require.Equal(t, before["state_changes"], after["state_changes"])
+
+	var amount string
+	require.NoError(t, di.Instance.DB.Get(&amount, "SELECT amount FROM tracelistener.balances WHERE address = $1", updated))
+	require.Equal(t, "42stake", amount, "newer row is kept")
+
+	var deleteHeight *uint64
+	require.NoError(t, di.Instance.DB.Get(&deleteHeight, "SELECT delete_height FROM tracelistener.balances WHERE address = $1", deleted))
+	require.NotNil(t, deleteHeight, "row deleted later stays deleted")
+
+	var height uint64
+	require.NoError(t, di.Instance.DB.Get(&height, "SELECT height FROM tracelistener.balances WHERE address = $1", missing))
+	require.EqualValues(t, 2, height)
+
+	// backfilling again changes nothing
+	backfill("bank")
+	require.Equal(t, after, tableCounts(t, di, "auth", "balances", "balances_history", "state_changes"))
+}
+
+func TestImporterDo_Genesis_SQLite(t *testing.T) {
+	tests := []struct {
+		name    string
+		im      bulk.Importer
+		wantErr bool
+	}{
+		{
+			"genesis - no error",
+			bulk.Importer{
+				GenesisPath: "./testdata/genesis.json",
+			},
+			false,
+		},
+		{
+			"height given - error",
+			bulk.Importer{
+				GenesisPath: "./testdata/genesis.json",
+				Height:      2,
+			},
+			true,
+		},
+		{
+			"missing genesis file - error",
+			bulk.Importer{
+				GenesisPath: "./testdata/missing.json",
+			},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dpi, di := newSQLiteImport(t, config.Config{})
+
+			tt.im.Processor = dpi
+			tt.im.Logger = zap.NewNop().Sugar()
+			tt.im.Database = di
+			tt.im.ChainName = "gaia"
+
+			err := tt.im.Do()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.EqualValues(t, 42, tt.im.ImportedHeight(), "genesis exported at initial height - 1")
+
+			// module accounts aren't stored, channels and unbonding delegations have
+			// array columns SQLite can't write
+			require.Equal(t, map[string]int{
+				"auth":         2,
+				"balances":     3,
+				"validators":   1,
+				"delegations":  1,
+				"connections":  1,
+				"denom_traces": 1,
+			}, tableCounts(t, di, "auth", "balances", "validators", "delegations", "connections", "denom_traces"))
+
+			var balances []models.BalanceRow
+			require.NoError(t, di.Instance.DB.Select(&balances, "SELECT chain_name, height, address, amount, denom FROM tracelistener.balances ORDER BY address, denom"))
+
+			balance := func(address, amount, denom string) models.BalanceRow {
+				return models.BalanceRow{
+					TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+						ChainName: "gaia",
+						Height:    42,
+					},
+					Address: address,
+					Amount:  amount,
+					Denom:   denom,
+				}
+			}
+
+			require.Equal(t, []models.BalanceRow{
+				balance("4fea76427b8345861e80a3540a8a9d936fd39391", "1000stake", "stake"),
+				balance("4fea76427b8345861e80a3540a8a9d936fd39391", "42uatom", "uatom"),
+				balance("93354845030274cd4bf1686abd60ab28ec52e1a7", "500000stake", "stake"),
+			}, balances)
+
+			var validator string
+			require.NoError(t, di.Instance.DB.Get(&validator, "SELECT validator_address FROM tracelistener.validators"))
+			require.Equal(t, "28830cb550d76d286c72c1d91782fdca52cbd539", validator)
+
+			var hash string
+			require.NoError(t, di.Instance.DB.Get(&hash, "SELECT hash FROM tracelistener.denom_traces"))
+			require.NotEmpty(t, hash)
+		})
+	}
+}
+
+func TestImporterDo_Backends_SQLite(t *testing.T) {
+	tables := []string{"auth", "balances", "delegations", "validators"}
+
+	// counts returns the number of rows of the main tables after importing the chain database at path.
+	counts := func(t *testing.T, path string, backend bulk.Backend) map[string]int {
+		dpi, di := newSQLiteImport(t, config.Config{})
+
+		im := bulk.Importer{
+			Path:      path,
+			Backend:   backend,
+			Processor: dpi,
+			Logger:    zap.NewNop().Sugar(),
+			Database:  di,
+		}
+		require.NoError(t, im.Do())
+		require.EqualValues(t, 2, im.ImportedHeight())
+
+		return tableCounts(t, di, tables...)
+	}
+
+	expected := counts(t, "./testdata/application.db", bulk.GoLevelDB)
+	require.NotZero(t, expected["balances"])
+
+	for _, backend := range bulk.Backends() {
+		backend := backend
+		t.Run(string(backend), func(t *testing.T) {
+			path := chainDBFixture(t, backend)
+
+			require.Equal(t, expected, counts(t, path, backend), "backend given")
+			require.Equal(t, expected, counts(t, path, ""), "backend detected")
+		})
+	}
+}
+
+func TestImporterDo_WriteError_SQLite(t *testing.T) {
+	dpi, di := newSQLiteImport(t, config.Config{})
+
+	_, err := di.Instance.DB.Exec("DROP TABLE tracelistener.balances")
+	require.NoError(t, err)
+
+	im := bulk.Importer{
+		Path:      "./testdata/application.db",
+		Modules:   []string{"bank"},
+		Processor: dpi,
+		Logger:    zap.NewNop().Sugar(),
+		Database:  di,
+		ChainName: "gaia",
+		BatchSize: 1,
+	}
+
+	require.Error(t, im.Do())
+
+	// cursors never get past the batches which failed
+	cursors, err := di.ImportCursors("gaia")
+	require.NoError(t, err)
+	for _, c := range cursors {
+		require.False(t, c.Done, c.Module)
+	}
+}
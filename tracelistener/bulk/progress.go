@@ -0,0 +1,197 @@
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos/iavl"
+	db2 "github.com/tendermint/tm-db"
+)
+
+// progressLogInterval is how often import progress is logged.
+const progressLogInterval = 30 * time.Second
+
+// Progress reports how far an import has gone.
+type Progress struct {
+	Height        int64                     `json:"height"`
+	StartTime     time.Time                 `json:"start_time"`
+	KeysProcessed uint64                    `json:"keys_processed"`
+	KeysTotal     uint64                    `json:"keys_total"`
+	Percent       float64                   `json:"percent"`
+	ETA           string                    `json:"eta,omitempty"`
+	Modules       map[string]ModuleProgress `json:"modules"`
+}
+
+// ModuleProgress reports how far the import of a module has gone.
+// KeysTotal is the size of the module IAVL tree, zero if it cannot be read.
+type ModuleProgress struct {
+	KeysProcessed uint64 `json:"keys_processed"`
+	KeysTotal     uint64 `json:"keys_total"`
+	Done          bool   `json:"done"`
+}
+
+// progress tracks the keys processed by an import.
+type progress struct {
+	mu sync.Mutex
+	p  Progress
+
+	// resumed is the number of keys processed by previous runs, which don't count
+	// towards the current rate.
+	resumed uint64
+}
+
+func newProgress(height int64) *progress {
+	return &progress{
+		p: Progress{
+			Height:    height,
+			StartTime: time.Now(),
+			Modules:   map[string]ModuleProgress{},
+		},
+	}
+}
+
+// setModule sets the progress of module before it's imported, resumed if keys have already been processed.
+func (p *progress) setModule(module string, mp ModuleProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.p.Modules[module] = mp
+	p.p.KeysTotal += mp.KeysTotal
+	p.p.KeysProcessed += mp.KeysProcessed
+	p.resumed += mp.KeysProcessed
+}
+
+func (p *progress) processed(module string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mp := p.p.Modules[module]
+	mp.KeysProcessed++
+	p.p.Modules[module] = mp
+	p.p.KeysProcessed++
+}
+
+func (p *progress) done(module string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mp := p.p.Modules[module]
+	mp.Done = true
+	p.p.Modules[module] = mp
+}
+
+// snapshot returns the current progress, its ETA extrapolated from the rate keys have been processed
+// at since the import started.
+func (p *progress) snapshot() Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res := p.p
+	res.Modules = make(map[string]ModuleProgress, len(p.p.Modules))
+	for m, mp := range p.p.Modules {
+		res.Modules[m] = mp
+	}
+
+	if res.KeysTotal == 0 {
+		return res
+	}
+
+	res.Percent = float64(res.KeysProcessed) / float64(res.KeysTotal) * 100
+
+	processed := res.KeysProcessed - p.resumed
+	if processed == 0 || res.KeysProcessed >= res.KeysTotal {
+		return res
+	}
+
+	elapsed := time.Since(res.StartTime)
+	remaining := float64(res.KeysTotal - res.KeysProcessed)
+	res.ETA = time.Duration(float64(elapsed) / float64(processed) * remaining).Round(time.Second).String()
+
+	return res
+}
+
+// Progress returns how far the running import has gone, or the zero Progress if none is running.
+func (i *Importer) Progress() Progress {
+	p, ok := i.progress.Load().(*progress)
+	if !ok {
+		return Progress{}
+	}
+
+	return p.snapshot()
+}
+
+// ProgressHandler serves the import progress as JSON.
+func (i *Importer) ProgressHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(i.Progress()); err != nil {
+		i.Logger.Errorw("cannot write import progress", "error", err)
+	}
+}
+
+// ListenAndServeHTTP serves the import progress on /progress.
+func (i *Importer) ListenAndServeHTTP(port string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/progress", i.ProgressHandler)
+
+	if port == "" {
+		port = ":8111"
+	}
+	if !strings.HasPrefix(port, ":") {
+		port = fmt.Sprintf(":%s", port)
+	}
+
+	if err := (&http.Server{
+		Addr:         port,
+		Handler:      mux,
+		ReadTimeout:  100 * time.Second,
+		WriteTimeout: 100 * time.Second,
+	}).ListenAndServe(); err != nil {
+		i.Logger.Errorw("progress server failed to start", "error", err.Error())
+	}
+}
+
+// logProgress logs the import progress every progressLogInterval until stop is closed.
+func (i *Importer) logProgress(stop <-chan struct{}) {
+	t := time.NewTicker(progressLogInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			p := i.Progress()
+			i.Logger.Infow("import progress",
+				"height", p.Height,
+				"keys_processed", p.KeysProcessed,
+				"keys_total", p.KeysTotal,
+				"percent", p.Percent,
+				"eta", p.ETA,
+			)
+		}
+	}
+}
+
+// storeSize returns the number of keys of the IAVL store of module at height.
+func storeSize(db db2.DB, module string, height int64) (uint64, error) {
+	tree, err := iavl.NewMutableTree(db2.NewPrefixDB(db, []byte(storeKeyPrefix(module))), 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tree.LazyLoadVersion(height); err != nil {
+		return 0, err
+	}
+
+	return uint64(tree.Size()), nil
+}
+
+// storeKeyPrefix returns the prefix of the IAVL store of module in the chain database,
+// vendored from cosmos-sdk/store/rootmulti/store.go.
+func storeKeyPrefix(module string) string {
+	return "s/k:" + module + "/"
+}
@@ -0,0 +1,42 @@
+package bulk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress_Snapshot(t *testing.T) {
+	p := newProgress(10)
+	p.setModule("bank", ModuleProgress{KeysTotal: 6, KeysProcessed: 2})
+	p.setModule("staking", ModuleProgress{KeysTotal: 4})
+
+	s := p.snapshot()
+	require.EqualValues(t, 10, s.KeysTotal)
+	require.EqualValues(t, 2, s.KeysProcessed)
+	require.Equal(t, 20.0, s.Percent)
+	require.Empty(t, s.ETA, "no key processed by this run")
+
+	p.p.StartTime = time.Now().Add(-3 * time.Second)
+	for i := 0; i < 3; i++ {
+		p.processed("staking")
+	}
+
+	s = p.snapshot()
+	require.EqualValues(t, 5, s.KeysProcessed)
+	require.EqualValues(t, 3, s.Modules["staking"].KeysProcessed)
+	require.Equal(t, 50.0, s.Percent)
+	require.Equal(t, "5s", s.ETA)
+
+	p.processed("staking")
+	p.done("staking")
+
+	s = p.snapshot()
+	require.True(t, s.Modules["staking"].Done)
+	require.False(t, s.Modules["bank"].Done)
+
+	// snapshots are copies
+	s.Modules["bank"] = ModuleProgress{}
+	require.EqualValues(t, 2, p.snapshot().Modules["bank"].KeysProcessed)
+}
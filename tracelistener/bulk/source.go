@@ -0,0 +1,87 @@
+package bulk
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	types2 "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/types"
+	db2 "github.com/tendermint/tm-db"
+)
+
+// source is the chain state an import reads modules from.
+type source interface {
+	// height returns the height of the chain state.
+	height() int64
+
+	// size returns the number of keys of module.
+	size(module string) (uint64, error)
+
+	// iterator returns an iterator over the keys of module, starting from start or from
+	// the first one if nil.
+	iterator(module string, start []byte) (db2.Iterator, error)
+
+	close() error
+}
+
+// chainDB reads the chain state from the stores of a chain database.
+type chainDB struct {
+	db   db2.DB
+	rm   *rootmulti.Store
+	keys map[string]types2.StoreKey
+	h    int64
+}
+
+// openChainDB opens the chain database at path with backend, detected if empty, and loads
+// modules at height, the latest one if zero.
+func openChainDB(path string, backend Backend, modules []string, height int64) (*chainDB, error) {
+	db, err := OpenDB(path, backend, false)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &chainDB{
+		db:   db,
+		rm:   rootmulti.NewStore(db),
+		keys: make(map[string]types2.StoreKey, len(modules)),
+	}
+
+	for _, m := range modules {
+		key := types.NewKVStoreKey(m)
+		c.keys[m] = key
+		c.rm.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	}
+
+	c.h, err = loadVersion(db, c.rm, height)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *chainDB) height() int64 {
+	return c.h
+}
+
+func (c *chainDB) size(module string) (uint64, error) {
+	return storeSize(c.db, module, c.h)
+}
+
+func (c *chainDB) iterator(module string, start []byte) (db2.Iterator, error) {
+	key, ok := c.keys[module]
+	if !ok {
+		return nil, fmt.Errorf("module %s not loaded", module)
+	}
+
+	return c.rm.GetKVStore(key).Iterator(start, nil), nil
+}
+
+func (c *chainDB) close() error {
+	if err := c.db.Close(); err != nil {
+		return fmt.Errorf("database closing error, %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+//go:build sdk_v44
+
+package bulk
+
+import (
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	db2 "github.com/tendermint/tm-db"
+)
+
+func TestLoadVersion(t *testing.T) {
+	// testdata/application.db holds heights 1 and 2.
+	tests := []struct {
+		name     string
+		height   int64
+		expected int64
+		wantErr  bool
+	}{
+		{"latest height", 0, 2, false},
+		{"historical height", 1, 1, false},
+		{"height not reached yet", 3, 0, true},
+		{"negative height", -1, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := db2.NewGoLevelDBWithOpts("application", "testdata", &opt.Options{
+				ErrorIfMissing: true,
+				ReadOnly:       true,
+			})
+			require.NoError(t, err)
+			defer db.Close()
+
+			rm := rootmulti.NewStore(db)
+			key := types.NewKVStoreKey("bank")
+			rm.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+
+			height, err := loadVersion(db, rm, tt.height)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, height)
+			require.Equal(t, tt.expected, rm.LastCommitID().Version)
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"fmt"
+	"regexp"
+
 	"github.com/go-playground/validator/v10"
 
 	"github.com/emerishq/tracelistener/configuration"
+	"github.com/emerishq/tracelistener/database"
 	"github.com/emerishq/tracelistener/validation"
 )
 
@@ -16,30 +20,77 @@ type Config struct {
 	JSONLogs              bool
 	EnableCpuProfiling    bool
 
+	// DatabaseDialect is one of cockroachdb (default), postgres or sqlite,
+	// DatabaseConnectionURL being the database file path for the latter.
+	DatabaseDialect string
+
+	// DatabaseName is the database, or schema for postgres and sqlite, holding
+	// tracelistener tables.
+	DatabaseName string `validate:"required"`
+
 	// Processors configs
 	Processor ProcessorConfig
 
 	// Exporter http port
 	ExporterHTTPPort string
+
+	// DeadLetterPath is the JSON lines file where database writes which
+	// cannot be retried are stored.
+	DeadLetterPath string
+
+	// ReimportOnGap makes tracelistener re-import chain state from
+	// ReimportDatabasePath when blocks have been missed since the last run.
+	// ReimportDatabasePath must be a snapshot or a copy of the chain database,
+	// not the one the traced node is running on.
+	ReimportOnGap        bool
+	ReimportDatabasePath string `validate:"required_if=ReimportOnGap true"`
 }
 
 type ProcessorConfig struct {
 	ProcessorsEnabled []string
+
+	// StateChangesEnabled makes the processor record each flushed change
+	// in the state changes table.
+	StateChangesEnabled bool
+
+	// HistoryEnabled makes the processor record every write and delete of the
+	// tables declaring history in sqlmodels.yaml in their history table.
+	HistoryEnabled bool
+
+	// AggregatesEnabled makes the processor maintain the denom totals,
+	// delegator totals and validator powers tables.
+	AggregatesEnabled bool
+
+	// PowerReduction is the amount of staking tokens making a unit of
+	// consensus power, 10^6 by default.
+	PowerReduction int64
 }
 
+var databaseName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
 func (c Config) Validate() error {
 	err := validator.New().Struct(c)
-	if err == nil {
-		return nil
+	if err != nil {
+		return validation.MissingFieldsErr(err, false)
+	}
+
+	if _, err := database.ParseDialect(c.DatabaseDialect); err != nil {
+		return err
+	}
+
+	if !databaseName.MatchString(c.DatabaseName) {
+		return fmt.Errorf("invalid database name %s, only lowercase letters, digits and underscores are allowed", c.DatabaseName)
 	}
 
-	return validation.MissingFieldsErr(err, false)
+	return nil
 }
 
 func Read() (*Config, error) {
 	var c Config
 
 	return &c, configuration.ReadConfig(&c, "tracelistener", map[string]string{
-		"FIFOPath": "./.tracelistener.fifo",
+		"FIFOPath":       "./.tracelistener.fifo",
+		"DeadLetterPath": "./tracelistener.deadletter.jsonl",
+		"DatabaseName":   "tracelistener",
 	})
 }
@@ -25,12 +25,34 @@ func TestConfig_Validate(t *testing.T) {
 			config.Config{
 				FIFOPath:              "fifo",
 				DatabaseConnectionURL: "db",
+				DatabaseName:          "tracelistener",
 				ChainName:             "cn",
 				Debug:                 false,
 				JSONLogs:              true,
 			},
 			false,
 		},
+		{
+			"configuration with an unknown database dialect",
+			config.Config{
+				FIFOPath:              "fifo",
+				DatabaseConnectionURL: "db",
+				DatabaseDialect:       "mysql",
+				DatabaseName:          "tracelistener",
+				ChainName:             "cn",
+			},
+			true,
+		},
+		{
+			"configuration with an invalid database name",
+			config.Config{
+				FIFOPath:              "fifo",
+				DatabaseConnectionURL: "db",
+				DatabaseName:          "tracelistener; DROP DATABASE defaultdb",
+				ChainName:             "cn",
+			},
+			true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/models"
+)
+
+// BlockSummariesTable holds a row per block, summarizing the changes it brought and how long
+// tracelistener took to write them.
+const BlockSummariesTable = "block_summaries"
+
+// Statements are formatted with the qualified name of the block summaries table.
+const (
+	createBlockSummariesTable = `
+	CREATE TABLE IF NOT EXISTS %s (
+		chain_name text not null,
+		height bigint not null,
+		block_time timestamp,
+		trace_ops jsonb not null default '{}',
+		rows_written jsonb not null default '{}',
+		rows_deleted jsonb not null default '{}',
+		flush_latency_us bigint not null default 0,
+		commit_latency_us bigint not null default 0,
+		primary key (chain_name, height)
+	)`
+
+	createBlockSummariesTableSQLite = `
+	CREATE TABLE IF NOT EXISTS %s (
+		chain_name text not null,
+		height integer not null,
+		block_time timestamp,
+		trace_ops text not null default '{}',
+		rows_written text not null default '{}',
+		rows_deleted text not null default '{}',
+		flush_latency_us integer not null default 0,
+		commit_latency_us integer not null default 0,
+		primary key (chain_name, height)
+	)`
+
+	dropBlockSummariesTable = `DROP TABLE IF EXISTS %s`
+
+	// upsertBlockSummary leaves block_time alone, since it's written by the blocktime watcher
+	// which may see the block first.
+	upsertBlockSummary = `
+	INSERT INTO %s
+		(chain_name, height, trace_ops, rows_written, rows_deleted, flush_latency_us, commit_latency_us)
+	VALUES
+		(:chain_name, :height, :trace_ops, :rows_written, :rows_deleted, :flush_latency_us, :commit_latency_us)
+	ON CONFLICT
+		(chain_name, height)
+	DO UPDATE SET
+		trace_ops=EXCLUDED.trace_ops,
+		rows_written=EXCLUDED.rows_written,
+		rows_deleted=EXCLUDED.rows_deleted,
+		flush_latency_us=EXCLUDED.flush_latency_us,
+		commit_latency_us=EXCLUDED.commit_latency_us
+	`
+
+	// UpsertBlockSummaryTime is the statement the blocktime watcher records the time
+	// of a block with.
+	UpsertBlockSummaryTime = `
+	INSERT INTO %s
+		(chain_name, height, block_time)
+	VALUES
+		(:chain_name, :height, :block_time)
+	ON CONFLICT
+		(chain_name, height)
+	DO UPDATE SET
+		block_time=EXCLUDED.block_time
+	`
+)
+
+// addBlockSummary writes s in tx.
+func (i *Instance) addBlockSummary(tx *sqlx.Tx, s models.BlockSummaryRow) error {
+	if _, err := tx.NamedExec(fmt.Sprintf(upsertBlockSummary, i.QualifiedName(BlockSummariesTable)), s); err != nil {
+		return fmt.Errorf("cannot write block summary, %w", err)
+	}
+
+	return nil
+}
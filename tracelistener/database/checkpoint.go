@@ -0,0 +1,229 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+)
+
+// Statements are formatted with the qualified name of the checkpoints table.
+const (
+	createCheckpointsTable = `
+	CREATE TABLE IF NOT EXISTS %s (
+		id serial unique primary key,
+		chain_name text not null,
+		height integer not null,
+		unique(chain_name)
+	)`
+
+	createCheckpointsTableSQLite = `
+	CREATE TABLE IF NOT EXISTS %s (
+		id integer primary key,
+		chain_name text not null,
+		height integer not null,
+		unique(chain_name)
+	)`
+
+	dropCheckpointsTable = `DROP TABLE IF EXISTS %s`
+
+	addCheckpointStatus = `
+	ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'ok',
+		ADD COLUMN IF NOT EXISTS gap_start integer,
+		ADD COLUMN IF NOT EXISTS gap_end integer
+	`
+
+	dropCheckpointStatus = `
+	ALTER TABLE %s
+		DROP COLUMN IF EXISTS status,
+		DROP COLUMN IF EXISTS gap_start,
+		DROP COLUMN IF EXISTS gap_end
+	`
+
+	// SQLite alters one column per statement, and doesn't support IF [NOT] EXISTS there.
+	addCheckpointStatusSQLite = `
+	ALTER TABLE %[1]s ADD COLUMN status text NOT NULL DEFAULT 'ok';
+	ALTER TABLE %[1]s ADD COLUMN gap_start integer;
+	ALTER TABLE %[1]s ADD COLUMN gap_end integer
+	`
+
+	dropCheckpointStatusSQLite = `
+	ALTER TABLE %[1]s DROP COLUMN status;
+	ALTER TABLE %[1]s DROP COLUMN gap_start;
+	ALTER TABLE %[1]s DROP COLUMN gap_end
+	`
+
+	selectCheckpoint = `SELECT height FROM %s WHERE chain_name = $1`
+
+	updateCheckpointStatus = `
+	UPDATE %s
+	SET status = $2, gap_start = $3, gap_end = $4
+	WHERE chain_name = $1
+	`
+
+	// markCheckpointDegraded widens the range of heights already marked as degraded,
+	// unless a gap is being recorded.
+	markCheckpointDegraded = `
+	INSERT INTO %s as tc
+		(chain_name, height, status, gap_start, gap_end)
+	VALUES
+		($1, 0, $2, $3, $4)
+	ON CONFLICT
+		(chain_name)
+	DO UPDATE SET
+		status = EXCLUDED.status,
+		gap_start = CASE WHEN tc.status = EXCLUDED.status AND tc.gap_start < EXCLUDED.gap_start THEN tc.gap_start ELSE EXCLUDED.gap_start END,
+		gap_end = CASE WHEN tc.status = EXCLUDED.status AND tc.gap_end > EXCLUDED.gap_end THEN tc.gap_end ELSE EXCLUDED.gap_end END
+		WHERE tc.status <> $5
+	`
+
+	clearCheckpointDegraded = `
+	UPDATE %s
+	SET status = $2, gap_start = NULL, gap_end = NULL
+	WHERE chain_name = $1 AND status = $3
+	`
+
+	upsertCheckpoint = `
+	INSERT INTO %s as tc
+		(chain_name, height)
+	VALUES
+		(:chain_name, :height)
+	ON CONFLICT
+		(chain_name)
+	DO UPDATE SET
+		height=EXCLUDED.height
+		WHERE EXCLUDED.height > tc.height
+	`
+)
+
+const checkpointsTable = "checkpoints"
+
+const (
+	// CheckpointStatusOK means no gap has been detected in the chain data.
+	CheckpointStatusOK = "ok"
+
+	// CheckpointStatusGap means some blocks have not been processed, and
+	// data is not consistent with the chain state.
+	CheckpointStatusGap = "gap"
+
+	// CheckpointStatusReimportedUpserts means a gap has been detected and the
+	// chain state at its last height has been upserted from the chain database
+	// afterwards: rows deleted during the gap haven't been, and data is not
+	// fully consistent with the chain state.
+	CheckpointStatusReimportedUpserts = "reimported_upserts"
+
+	// CheckpointStatusDegraded means some blocks could not be written and
+	// have been stored in the dead-letter file, data is not consistent with
+	// the chain state until they are replayed.
+	CheckpointStatusDegraded = "degraded"
+)
+
+// Checkpoint returns the last fully-committed height for chainName, or zero
+// if no block has been committed yet.
+func (i *Instance) Checkpoint(chainName string) (uint64, error) {
+	var height uint64
+	err := i.Instance.DB.Get(&height, fmt.Sprintf(selectCheckpoint, i.QualifiedName(checkpointsTable)), chainName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("cannot read checkpoint, %w", err)
+	}
+
+	return height, nil
+}
+
+// SetCheckpointStatus records status for chainName, along with the range of
+// heights [gapStart, gapEnd] it refers to.
+func (i *Instance) SetCheckpointStatus(chainName, status string, gapStart, gapEnd uint64) error {
+	if _, err := i.Instance.DB.Exec(fmt.Sprintf(updateCheckpointStatus, i.QualifiedName(checkpointsTable)), chainName, status, gapStart, gapEnd); err != nil {
+		return fmt.Errorf("cannot update checkpoint status, %w", err)
+	}
+
+	return nil
+}
+
+// MarkDegraded records that the blocks in [start, end] for chainName have been
+// dead-lettered, extending the range already recorded if any.
+// The checkpoint keeps moving forward, the status is left alone while a gap is recorded.
+func (i *Instance) MarkDegraded(chainName string, start, end uint64) error {
+	if _, err := i.Instance.DB.Exec(
+		fmt.Sprintf(markCheckpointDegraded, i.QualifiedName(checkpointsTable)),
+		chainName, CheckpointStatusDegraded, start, end, CheckpointStatusGap,
+	); err != nil {
+		return fmt.Errorf("cannot mark checkpoint as degraded, %w", err)
+	}
+
+	return nil
+}
+
+// ClearDegraded resets the status of chainName once its dead-lettered blocks have been replayed.
+func (i *Instance) ClearDegraded(chainName string) error {
+	if _, err := i.Instance.DB.Exec(
+		fmt.Sprintf(clearCheckpointDegraded, i.QualifiedName(checkpointsTable)),
+		chainName, CheckpointStatusOK, CheckpointStatusDegraded,
+	); err != nil {
+		return fmt.Errorf("cannot clear degraded checkpoint status, %w", err)
+	}
+
+	return nil
+}
+
+// AddBlock writes all the ops contained in b in a single transaction, and
+// records b.Height as the last fully-committed height for chainName, along with b.Summary
+// if any, its commit latency being the time spent writing the ops.
+// On CockroachDB the transaction is retried following its client-side retry semantics.
+func (i *Instance) AddBlock(chainName string, b tracelistener.BlockWriteback) error {
+	return i.Instance.ExecuteTx(func(tx *sqlx.Tx) error {
+		start := time.Now()
+		for _, op := range b.Ops {
+			for _, wbUnit := range op.SplitStatementToDBLimit() {
+				is := wbUnit.InterfaceSlice()
+				if len(is) == 0 {
+					continue
+				}
+
+				if _, err := tx.NamedExec(wbUnit.Statement, is); err != nil {
+					return fmt.Errorf("cannot write %s operation from module %s, %w", wbUnit.Type, op.SourceModule, err)
+				}
+			}
+		}
+
+		if b.Height == 0 {
+			return nil
+		}
+
+		if b.Summary != nil {
+			s := *b.Summary
+			s.ChainName = chainName
+			s.Height = b.Height
+			s.CommitLatencyUs = time.Since(start).Microseconds()
+			if err := i.addBlockSummary(tx, s); err != nil {
+				return err
+			}
+		}
+
+		return i.upsertCheckpoint(tx, chainName, b.Height)
+	})
+}
+
+// upsertCheckpoint moves the checkpoint of chainName forward to height in tx.
+func (i *Instance) upsertCheckpoint(tx *sqlx.Tx, chainName string, height uint64) error {
+	if _, err := tx.NamedExec(fmt.Sprintf(upsertCheckpoint, i.QualifiedName(checkpointsTable)), models.CheckpointRow{
+		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+			ChainName: chainName,
+			Height:    height,
+		},
+	}); err != nil {
+		return fmt.Errorf("cannot update checkpoint, %w", err)
+	}
+
+	return nil
+}
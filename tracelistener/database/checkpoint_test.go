@@ -0,0 +1,243 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/stretchr/testify/require"
+
+	dbutils "github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+const (
+	createTestBalances = `CREATE TABLE IF NOT EXISTS %s (
+		id %s primary key,
+		chain_name text not null,
+		height integer not null,
+		address text not null unique
+	)`
+
+	insertTestBalances = `INSERT INTO %s (chain_name, height, address)
+		VALUES (:chain_name, :height, :address)`
+)
+
+func TestInstance_AddBlock(t *testing.T) {
+	ts, err := testserver.NewTestServer()
+	require.NoError(t, err)
+	require.NoError(t, ts.WaitForInit())
+	defer ts.Stop()
+
+	i, err := New(ts.PGURL().String())
+	require.NoError(t, err)
+
+	testAddBlock(t, i)
+}
+
+func TestInstance_AddBlock_SQLite(t *testing.T) {
+	i, err := NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), Options{
+		Dialect: dbutils.DialectSQLite,
+		Schema:  "staging",
+	})
+	require.NoError(t, err)
+
+	testAddBlock(t, i)
+}
+
+func testAddBlock(t *testing.T, i *Instance) {
+	table := i.QualifiedName("test_balances")
+	_, err := i.Instance.DB.Exec(fmt.Sprintf(createTestBalances, table, i.Instance.Dialect.ColumnType("serial")))
+	require.NoError(t, err)
+
+	insert := fmt.Sprintf(insertTestBalances, table)
+
+	row := func(address string, height uint64) models.DatabaseEntrier {
+		return models.BalanceRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+				ChainName: "chain",
+				Height:    height,
+			},
+			Address: address,
+		}
+	}
+
+	checkpoint := func() uint64 {
+		var h uint64
+		require.NoError(t, i.Instance.DB.Get(&h, fmt.Sprintf(`SELECT height FROM %s WHERE chain_name = 'chain'`, i.QualifiedName(checkpointsTable))))
+		return h
+	}
+
+	count := func() int {
+		var c int
+		require.NoError(t, i.Instance.DB.Get(&c, fmt.Sprintf(`SELECT count(*) FROM %s`, table)))
+		return c
+	}
+
+	require.NoError(t, i.AddBlock("chain", tracelistener.BlockWriteback{
+		Height: 10,
+		Ops: []tracelistener.WritebackOp{
+			{
+				Type:      tracelistener.Write,
+				Statement: insert,
+				Data:      []models.DatabaseEntrier{row("a", 10), row("b", 10)},
+			},
+		},
+		Summary: &models.BlockSummaryRow{
+			TraceOps:       models.Counts{"bank": 3},
+			RowsWritten:    models.Counts{"test_balances": 2},
+			FlushLatencyUs: 42,
+		},
+	}))
+
+	require.Equal(t, uint64(10), checkpoint())
+	require.Equal(t, 2, count())
+
+	var summary models.BlockSummaryRow
+	require.NoError(t, i.Instance.DB.Get(&summary, fmt.Sprintf(
+		`SELECT chain_name, height, block_time, trace_ops, rows_written, rows_deleted, flush_latency_us, commit_latency_us FROM %s`,
+		i.QualifiedName(BlockSummariesTable),
+	)))
+	require.Equal(t, "chain", summary.ChainName)
+	require.Equal(t, uint64(10), summary.Height)
+	require.Nil(t, summary.BlockTime)
+	require.Equal(t, models.Counts{"bank": 3}, summary.TraceOps)
+	require.Equal(t, models.Counts{"test_balances": 2}, summary.RowsWritten)
+	require.Empty(t, summary.RowsDeleted)
+	require.Equal(t, int64(42), summary.FlushLatencyUs)
+	require.GreaterOrEqual(t, summary.CommitLatencyUs, int64(0))
+
+	// second op violates the unique constraint, nothing must be committed
+	require.Error(t, i.AddBlock("chain", tracelistener.BlockWriteback{
+		Height: 11,
+		Ops: []tracelistener.WritebackOp{
+			{
+				Type:      tracelistener.Write,
+				Statement: insert,
+				Data:      []models.DatabaseEntrier{row("c", 11)},
+			},
+			{
+				Type:      tracelistener.Write,
+				Statement: insert,
+				Data:      []models.DatabaseEntrier{row("a", 11)},
+			},
+		},
+	}))
+
+	require.Equal(t, uint64(10), checkpoint())
+	require.Equal(t, 2, count())
+
+	// a block without ops still moves the checkpoint forward
+	require.NoError(t, i.AddBlock("chain", tracelistener.BlockWriteback{Height: 12}))
+	require.Equal(t, uint64(12), checkpoint())
+}
+
+func TestInstance_AddBlock_Channels_SQLite(t *testing.T) {
+	i, err := NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), Options{
+		Dialect: dbutils.DialectSQLite,
+		Schema:  "staging",
+	})
+	require.NoError(t, err)
+
+	channels := tables.NewChannelsTable(i.QualifiedName("channels")).WithDialect(dbutils.DialectSQLite)
+	for _, stmt := range channels.Schema() {
+		_, err := i.Instance.DB.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	row := func(height uint64, hops ...string) models.IBCChannelRow {
+		return models.IBCChannelRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+				ChainName: "chain",
+				Height:    height,
+			},
+			ChannelID:        "channel-0",
+			CounterChannelID: "channel-1",
+			Port:             "transfer",
+			State:            3,
+			Hops:             hops,
+		}
+	}
+
+	tests := []struct {
+		name string
+		row  models.IBCChannelRow
+	}{
+		{"single hop", row(10, "connection-0")},
+		{"hops needing quotes", row(11, `connection "0"`, `a\b`, "c,d")},
+		{"no hops", row(12)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, i.AddBlock("chain", tracelistener.BlockWriteback{
+				Height: tt.row.Height,
+				Ops: []tracelistener.WritebackOp{
+					{
+						Type:      tracelistener.Write,
+						Statement: channels.Upsert(),
+						Data:      []models.DatabaseEntrier{tt.row},
+					},
+				},
+			}))
+
+			stored, err := channels.SelectByUnique(i.Instance.DB, "chain", "channel-0", "transfer")
+			require.NoError(t, err)
+			require.Equal(t, tt.row.Height, stored.Height)
+			require.Equal(t, len(tt.row.Hops), len(stored.Hops))
+			for idx := range tt.row.Hops {
+				require.Equal(t, tt.row.Hops[idx], stored.Hops[idx])
+			}
+		})
+	}
+}
+
+func TestTables_NullTxHash_SQLite(t *testing.T) {
+	i, err := NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), Options{
+		Dialect: dbutils.DialectSQLite,
+		Schema:  "staging",
+	})
+	require.NoError(t, err)
+
+	balances := tables.NewBalancesTable(i.QualifiedName("balances")).WithDialect(dbutils.DialectSQLite)
+	for _, stmt := range balances.Schema() {
+		_, err := i.Instance.DB.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	// rows written before tx hashes were recorded, or outside of any transaction, have none
+	_, err = i.Instance.DB.Exec(fmt.Sprintf(`INSERT INTO %s (chain_name, height, address, amount, denom) VALUES ('chain', 1, 'a', '10', 'stake')`, balances.Name()))
+	require.NoError(t, err)
+
+	_, err = i.Instance.DB.NamedExec(balances.Upsert(), models.BalanceRow{
+		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+			ChainName: "chain",
+			Height:    2,
+			TxHash:    models.NullString("hash"),
+		},
+		Address: "b",
+		Amount:  "20",
+		Denom:   "stake",
+	})
+	require.NoError(t, err)
+
+	stored, err := balances.SelectByUnique(i.Instance.DB, "chain", "a", "stake")
+	require.NoError(t, err)
+	require.Nil(t, stored.TxHash)
+
+	rows, err := balances.ListByChain(i.Instance.DB, "chain")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	byAddress := map[string]models.BalanceRow{}
+	for _, r := range rows {
+		byAddress[r.Address] = r
+	}
+
+	require.Nil(t, byAddress["a"].TxHash)
+	require.NotNil(t, byAddress["b"].TxHash)
+	require.Equal(t, "hash", *byAddress["b"].TxHash)
+}
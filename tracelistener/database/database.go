@@ -8,26 +8,75 @@ import (
 )
 
 type Instance struct {
-	Instance   *dbutils.Instance
-	connString string
+	Instance *dbutils.Instance
+	schema   string
+}
+
+// Options selects the dialect spoken by the database, and the schema holding
+// tracelistener tables. Zero values stand for CockroachDB and DefaultSchema.
+type Options struct {
+	Dialect dbutils.Dialect
+	Schema  string
 }
 
 func New(connString string) (*Instance, error) {
+	return NewWithOptions(connString, Options{})
+}
+
+// NewWithOptions returns an Instance connected to connString after running migrations.
+func NewWithOptions(connString string, opts Options) (*Instance, error) {
+	ii, err := OpenWithOptions(connString, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ii.RunMigrations(); err != nil {
+		panic(err)
+	}
 
-	i, err := dbutils.New(connString)
+	return ii, nil
+}
+
+// Open returns an Instance connected to connString without running migrations.
+func Open(connString string) (*Instance, error) {
+	return OpenWithOptions(connString, Options{})
+}
+
+// OpenWithOptions returns an Instance connected to connString without running migrations.
+// The schema is created if missing.
+func OpenWithOptions(connString string, opts Options) (*Instance, error) {
+	if opts.Dialect == "" {
+		opts.Dialect = dbutils.DialectCockroachDB
+	}
+
+	if opts.Schema == "" {
+		opts.Schema = DefaultSchema
+	}
+
+	i, err := dbutils.NewWithDialect(connString, opts.Dialect)
 
 	if err != nil {
 		return nil, err
 	}
 
-	ii := &Instance{
-		Instance:   i,
-		connString: connString,
+	if err := i.CreateSchema(opts.Schema); err != nil {
+		return nil, err
 	}
 
-	ii.runMigrations()
+	return &Instance{
+		Instance: i,
+		schema:   opts.Schema,
+	}, nil
+}
 
-	return ii, nil
+// Schema returns the database, or schema depending on the dialect, holding tracelistener tables.
+func (i *Instance) Schema() string {
+	return i.schema
+}
+
+// QualifiedName returns name qualified with the instance schema.
+func (i *Instance) QualifiedName(name string) string {
+	return i.schema + "." + name
 }
 
 func (i *Instance) Add(query string, data []interface{}, sleepFunc func()) error {
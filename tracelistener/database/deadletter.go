@@ -0,0 +1,348 @@
+package database
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+)
+
+var dbMapper = reflectx.NewMapperFunc("db", strings.ToLower)
+
+// DeadLetterEntry is a WritebackOp which could not be written to the database.
+type DeadLetterEntry struct {
+	Time      time.Time                `json:"time"`
+	ChainName string                   `json:"chain_name"`
+	Height    uint64                   `json:"height"`
+	Module    string                   `json:"module"`
+	Type      string                   `json:"type"`
+	Statement string                   `json:"statement"`
+	Rows      []map[string]interface{} `json:"rows"`
+	Error     string                   `json:"error"`
+}
+
+// DeadLetter appends failed database writes to a JSON lines file, one line per WritebackOp.
+type DeadLetter struct {
+	path string
+	m    sync.Mutex
+}
+
+// NewDeadLetter returns a DeadLetter writing to path.
+func NewDeadLetter(path string) *DeadLetter {
+	return &DeadLetter{
+		path: path,
+	}
+}
+
+// Path returns the file path d writes to.
+func (d *DeadLetter) Path() string {
+	return d.path
+}
+
+// Write appends all the ops contained in b to the dead-letter file, along with cause.
+func (d *DeadLetter) Write(chainName string, b tracelistener.BlockWriteback, cause error) error {
+	entries := make([]DeadLetterEntry, 0, len(b.Ops))
+	now := time.Now().UTC()
+
+	for _, op := range b.Ops {
+		if len(op.Data) == 0 {
+			continue
+		}
+
+		rows := make([]map[string]interface{}, 0, len(op.Data))
+		for _, data := range op.Data {
+			rows = append(rows, rowColumns(data))
+		}
+
+		entries = append(entries, DeadLetterEntry{
+			Time:      now,
+			ChainName: chainName,
+			Height:    b.Height,
+			Module:    op.SourceModule,
+			Type:      op.Type.String(),
+			Statement: op.Statement,
+			Rows:      rows,
+			Error:     cause.Error(),
+		})
+	}
+
+	return d.append(entries)
+}
+
+// WriteEntries appends entries to the dead-letter file as they are.
+func (d *DeadLetter) WriteEntries(entries []DeadLetterEntry) error {
+	return d.append(entries)
+}
+
+func (d *DeadLetter) append(entries []DeadLetterEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open dead-letter file, %w", err)
+	}
+
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("cannot write dead-letter entry, %w", err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close dead-letter file, %w", err)
+	}
+
+	return nil
+}
+
+// ReadDeadLetter reads all the entries contained in the dead-letter file at path.
+func ReadDeadLetter(path string) ([]DeadLetterEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open dead-letter file, %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var entries []DeadLetterEntry
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 1024*1024), 1024*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(strings.TrimSpace(s.Text())) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(strings.NewReader(s.Text()))
+		dec.UseNumber()
+
+		var e DeadLetterEntry
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("cannot decode dead-letter entry at line %d, %w", line, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read dead-letter file, %w", err)
+	}
+
+	return entries, nil
+}
+
+// ReplayDeadLetter runs again every block contained in the dead-letter file at path,
+// each one in its own transaction along with the checkpoint of its chain.
+// Blocks which fail again are kept in the file, the others are removed from it;
+// once the file is empty, the degraded status of their chains is cleared.
+// Upserts and deletes are guarded on height, so rows written after a block are left untouched.
+func (i *Instance) ReplayDeadLetter(path string) (replayed int, failed []DeadLetterEntry, err error) {
+	entries, err := ReadDeadLetter(path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	chains := map[string]struct{}{}
+	for _, block := range deadLetterBlocks(entries) {
+		chains[block[0].ChainName] = struct{}{}
+
+		err := i.Instance.ExecuteTx(func(tx *sqlx.Tx) error {
+			return i.replayBlock(tx, block)
+		})
+
+		if err != nil {
+			for _, e := range block {
+				e.Error = err.Error()
+				failed = append(failed, e)
+			}
+			continue
+		}
+
+		replayed += len(block)
+	}
+
+	if len(failed) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return replayed, nil, fmt.Errorf("cannot remove dead-letter file, %w", err)
+		}
+
+		for chainName := range chains {
+			if err := i.ClearDegraded(chainName); err != nil {
+				return replayed, nil, err
+			}
+		}
+
+		return replayed, nil, nil
+	}
+
+	tmp := NewDeadLetter(path + ".tmp")
+	if err := tmp.append(failed); err != nil {
+		return replayed, failed, err
+	}
+
+	if err := os.Rename(tmp.Path(), path); err != nil {
+		return replayed, failed, fmt.Errorf("cannot replace dead-letter file, %w", err)
+	}
+
+	return replayed, failed, nil
+}
+
+// replayBlock runs the entries of a single block in tx, and moves the checkpoint
+// of its chain forward to its height.
+func (i *Instance) replayBlock(tx *sqlx.Tx, block []DeadLetterEntry) error {
+	for _, e := range block {
+		if len(e.Rows) == 0 {
+			continue
+		}
+
+		rows := make([]interface{}, 0, len(e.Rows))
+		for _, r := range e.Rows {
+			rows = append(rows, replayRow(r))
+		}
+
+		if _, err := tx.NamedExec(e.Statement, rows); err != nil {
+			return fmt.Errorf("cannot replay %s operation from module %s, %w", e.Type, e.Module, err)
+		}
+	}
+
+	b := block[0]
+	if b.ChainName == "" || b.Height == 0 {
+		return nil
+	}
+
+	return i.upsertCheckpoint(tx, b.ChainName, b.Height)
+}
+
+// deadLetterBlocks groups consecutive entries by chain name and height, in file order.
+func deadLetterBlocks(entries []DeadLetterEntry) [][]DeadLetterEntry {
+	var blocks [][]DeadLetterEntry
+	for _, e := range entries {
+		last := len(blocks) - 1
+		if last >= 0 && blocks[last][0].ChainName == e.ChainName && blocks[last][0].Height == e.Height {
+			blocks[last] = append(blocks[last], e)
+			continue
+		}
+
+		blocks = append(blocks, []DeadLetterEntry{e})
+	}
+
+	return blocks
+}
+
+// DegradedRange returns the lowest and highest heights of chainName contained in the
+// dead-letter file at path, ok being false when there's none.
+func DegradedRange(path, chainName string) (start, end uint64, ok bool, err error) {
+	entries, err := ReadDeadLetter(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, false, nil
+	}
+
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	for _, e := range entries {
+		if e.ChainName != chainName {
+			continue
+		}
+
+		if !ok || e.Height < start {
+			start = e.Height
+		}
+
+		if !ok || e.Height > end {
+			end = e.Height
+		}
+
+		ok = true
+	}
+
+	return start, end, ok, nil
+}
+
+// rowColumns returns the values of d keyed by database column name.
+// Byte slices are hex-encoded in the PostgreSQL bytea text format.
+func rowColumns(d models.DatabaseEntrier) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(d))
+	ret := map[string]interface{}{}
+
+	for _, fi := range dbMapper.TypeMap(v.Type()).Index {
+		if fi.Embedded || strings.Contains(fi.Path, ".") {
+			continue
+		}
+
+		value := reflectx.FieldByIndexesReadOnly(v, fi.Index).Interface()
+		if b, ok := value.([]byte); ok {
+			value = `\x` + hex.EncodeToString(b)
+		}
+
+		ret[fi.Path] = value
+	}
+
+	return ret
+}
+
+// replayRow converts values decoded from JSON back into types the database driver handles.
+func replayRow(r map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(r))
+	for k, v := range r {
+		switch vv := v.(type) {
+		case json.Number:
+			if n, err := vv.Int64(); err == nil {
+				ret[k] = n
+				continue
+			}
+
+			ret[k] = vv.String()
+		case []interface{}:
+			ret[k] = replayList(vv)
+		default:
+			ret[k] = v
+		}
+	}
+
+	return ret
+}
+
+// replayList converts a list decoded from JSON back into a text array, or into the JSON
+// it was encoded from when it holds objects, like unbonding delegation entries.
+func replayList(l []interface{}) interface{} {
+	s := make([]string, 0, len(l))
+	for _, e := range l {
+		switch e.(type) {
+		case map[string]interface{}, []interface{}:
+			bz, err := json.Marshal(l)
+			if err != nil {
+				return l
+			}
+
+			return string(bz)
+		}
+
+		s = append(s, fmt.Sprint(e))
+	}
+
+	return s
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbutils "github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+func TestDeadLetter_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	d := NewDeadLetter(path)
+
+	b := tracelistener.BlockWriteback{
+		Height: 42,
+		Ops: []tracelistener.WritebackOp{
+			{
+				Type:         tracelistener.Write,
+				Statement:    insertTestBalances,
+				SourceModule: "bank",
+				Data: []models.DatabaseEntrier{
+					models.BalanceRow{
+						TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+							ChainName: "chain",
+							Height:    42,
+						},
+						Address: "address",
+					},
+				},
+			},
+			{
+				Type:      tracelistener.Delete,
+				Statement: "empty op",
+			},
+		},
+	}
+
+	require.NoError(t, d.Write("chain", b, errors.New("unique violation")))
+	require.NoError(t, d.Write("chain", tracelistener.BlockWriteback{Height: 43}, errors.New("no ops")))
+
+	entries, err := ReadDeadLetter(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	e := entries[0]
+	require.Equal(t, uint64(42), e.Height)
+	require.Equal(t, "chain", e.ChainName)
+	require.Equal(t, "bank", e.Module)
+	require.Equal(t, "Write", e.Type)
+	require.Equal(t, insertTestBalances, e.Statement)
+	require.Equal(t, "unique violation", e.Error)
+	require.Len(t, e.Rows, 1)
+
+	row := replayRow(e.Rows[0])
+	require.Equal(t, "address", row["address"])
+	require.Equal(t, "chain", row["chain_name"])
+	require.Equal(t, int64(42), row["height"])
+	require.Nil(t, row["delete_height"])
+}
+
+func TestRowColumns(t *testing.T) {
+	row := rowColumns(models.ValidatorRow{
+		ConsensusPubKeyValue: []byte{0xca, 0xfe},
+	})
+
+	require.Equal(t, `\xcafe`, row["consensus_pubkey_value"])
+	require.Contains(t, row, "chain_name")
+	require.NotContains(t, row, "tracelistenerdatabaserow")
+}
+
+func TestReplayRow(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{
+			"integer",
+			json.Number("42"),
+			int64(42),
+		},
+		{
+			"decimal",
+			json.Number("4.2"),
+			"4.2",
+		},
+		{
+			"text array",
+			[]interface{}{"connection-0", "connection-1"},
+			[]string{"connection-0", "connection-1"},
+		},
+		{
+			"objects list",
+			[]interface{}{map[string]interface{}{"balance": "100"}},
+			`[{"balance":"100"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, replayRow(map[string]interface{}{"column": tt.value})["column"])
+		})
+	}
+}
+
+func TestInstance_ReplayDeadLetter_SQLite(t *testing.T) {
+	i, err := NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), Options{
+		Dialect: dbutils.DialectSQLite,
+		Schema:  "staging",
+	})
+	require.NoError(t, err)
+
+	balances := tables.NewBalancesTable(i.QualifiedName("balances")).WithDialect(dbutils.DialectSQLite)
+	for _, stmt := range balances.Schema() {
+		_, err := i.Instance.DB.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	balance := func(address, amount string, height uint64) models.DatabaseEntrier {
+		return models.BalanceRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+				ChainName: "chain",
+				Height:    height,
+			},
+			Address: address,
+			Amount:  amount,
+			Denom:   "stake",
+		}
+	}
+
+	block := func(height uint64, ops ...tracelistener.WritebackOp) tracelistener.BlockWriteback {
+		return tracelistener.BlockWriteback{Height: height, Ops: ops}
+	}
+
+	upsert := func(rows ...models.DatabaseEntrier) tracelistener.WritebackOp {
+		return tracelistener.WritebackOp{Type: tracelistener.Write, Statement: balances.Upsert(), SourceModule: "bank", Data: rows}
+	}
+
+	del := func(rows ...models.DatabaseEntrier) tracelistener.WritebackOp {
+		return tracelistener.WritebackOp{Type: tracelistener.Delete, Statement: balances.Delete(), SourceModule: "bank", Data: rows}
+	}
+
+	require.NoError(t, i.AddBlock("chain", block(10, upsert(balance("a", "10", 10), balance("b", "10", 10), balance("d", "10", 10)))))
+	require.NoError(t, i.AddBlock("chain", block(12, upsert(balance("a", "30", 12), balance("d", "30", 12)))))
+
+	path := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	d := NewDeadLetter(path)
+
+	// block 11 failed while block 12 was written, block 13 fails again on replay
+	require.NoError(t, d.Write("chain", block(11,
+		upsert(balance("a", "20", 11)),
+		del(balance("b", "", 11), balance("d", "", 11)),
+	), errors.New("connection refused")))
+	require.NoError(t, d.Write("chain", block(13,
+		upsert(balance("c", "10", 13)),
+		tracelistener.WritebackOp{Type: tracelistener.Write, Statement: "INSERT INTO missing (height) VALUES (:height)", Data: []models.DatabaseEntrier{balance("c", "10", 13)}},
+	), errors.New("connection refused")))
+
+	require.NoError(t, i.MarkDegraded("chain", 13, 13))
+	require.NoError(t, i.MarkDegraded("chain", 11, 11))
+
+	status := func() (string, uint64, uint64) {
+		var s struct {
+			Status   string        `db:"status"`
+			GapStart sql.NullInt64 `db:"gap_start"`
+			GapEnd   sql.NullInt64 `db:"gap_end"`
+		}
+		require.NoError(t, i.Instance.DB.Get(&s, fmt.Sprintf(`SELECT status, gap_start, gap_end FROM %s WHERE chain_name = 'chain'`, i.QualifiedName(checkpointsTable))))
+		return s.Status, uint64(s.GapStart.Int64), uint64(s.GapEnd.Int64)
+	}
+
+	st, start, end := status()
+	require.Equal(t, CheckpointStatusDegraded, st)
+	require.Equal(t, uint64(11), start)
+	require.Equal(t, uint64(13), end)
+
+	start, end, ok, err := DegradedRange(path, "chain")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(11), start)
+	require.Equal(t, uint64(13), end)
+
+	replayed, failed, err := i.ReplayDeadLetter(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, replayed)
+	require.Len(t, failed, 2)
+
+	get := func(address string) models.BalanceRow {
+		row, err := balances.SelectByUnique(i.Instance.DB, "chain", address, "stake")
+		require.NoError(t, err)
+		return row
+	}
+
+	// rows written after block 11 are left untouched
+	a := get("a")
+	require.Equal(t, uint64(12), a.Height)
+	require.Equal(t, "30", a.Amount)
+	require.Nil(t, get("d").DeleteHeight)
+
+	var deleteHeight uint64
+	require.NoError(t, i.Instance.DB.Get(&deleteHeight, fmt.Sprintf(`SELECT delete_height FROM %s WHERE address = 'b'`, balances.Name())))
+	require.Equal(t, uint64(11), deleteHeight)
+
+	// block 13 is rolled back as a whole
+	_, err = balances.SelectByUnique(i.Instance.DB, "chain", "c", "stake")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	checkpoint, err := i.Checkpoint("chain")
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), checkpoint)
+
+	st, _, _ = status()
+	require.Equal(t, CheckpointStatusDegraded, st)
+
+	entries, err := ReadDeadLetter(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// once fixed, block 13 moves the checkpoint forward and clears the status
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, d.WriteEntries(entries[:1]))
+
+	replayed, failed, err = i.ReplayDeadLetter(path)
+	require.NoError(t, err)
+	require.Equal(t, 1, replayed)
+	require.Empty(t, failed)
+
+	require.Equal(t, "10", get("c").Amount)
+
+	checkpoint, err = i.Checkpoint("chain")
+	require.NoError(t, err)
+	require.Equal(t, uint64(13), checkpoint)
+
+	st, _, _ = status()
+	require.Equal(t, CheckpointStatusOK, st)
+
+	_, _, ok, err = DegradedRange(path, "chain")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/models"
+)
+
+// importCursorsTable records how far the bulk import of each module has gone, so that an
+// interrupted import can be resumed.
+const importCursorsTable = "import_cursors"
+
+// Statements are formatted with the qualified name of the import cursors table.
+const (
+	createImportCursorsTable = `
+	CREATE TABLE IF NOT EXISTS %s (
+		chain_name text not null,
+		module text not null,
+		height bigint not null,
+		last_key bytea,
+		keys_processed bigint not null default 0,
+		done boolean not null default false,
+		primary key (chain_name, module)
+	)`
+
+	createImportCursorsTableSQLite = `
+	CREATE TABLE IF NOT EXISTS %s (
+		chain_name text not null,
+		module text not null,
+		height integer not null,
+		last_key blob,
+		keys_processed integer not null default 0,
+		done boolean not null default false,
+		primary key (chain_name, module)
+	)`
+
+	dropImportCursorsTable = `DROP TABLE IF EXISTS %s`
+
+	selectImportCursors = `
+	SELECT chain_name, module, height, last_key, keys_processed, done
+	FROM %s
+	WHERE chain_name = $1
+	`
+
+	upsertImportCursor = `
+	INSERT INTO %s
+		(chain_name, module, height, last_key, keys_processed, done)
+	VALUES
+		(:chain_name, :module, :height, :last_key, :keys_processed, :done)
+	ON CONFLICT
+		(chain_name, module)
+	DO UPDATE SET
+		height=EXCLUDED.height,
+		last_key=EXCLUDED.last_key,
+		keys_processed=EXCLUDED.keys_processed,
+		done=EXCLUDED.done
+	`
+
+	deleteImportCursors = `DELETE FROM %s WHERE chain_name = $1`
+)
+
+// ImportCursors returns the import cursors of chainName by module.
+func (i *Instance) ImportCursors(chainName string) (map[string]models.ImportCursorRow, error) {
+	var rows []models.ImportCursorRow
+	if err := i.Instance.DB.Select(&rows, fmt.Sprintf(selectImportCursors, i.QualifiedName(importCursorsTable)), chainName); err != nil {
+		return nil, fmt.Errorf("cannot read import cursors, %w", err)
+	}
+
+	res := make(map[string]models.ImportCursorRow, len(rows))
+	for _, r := range rows {
+		res[r.Module] = r
+	}
+
+	return res, nil
+}
+
+// SetImportCursors records cursors in a single transaction.
+func (i *Instance) SetImportCursors(cursors []models.ImportCursorRow) error {
+	if len(cursors) == 0 {
+		return nil
+	}
+
+	return i.Instance.ExecuteTx(func(tx *sqlx.Tx) error {
+		for _, c := range cursors {
+			if _, err := tx.NamedExec(fmt.Sprintf(upsertImportCursor, i.QualifiedName(importCursorsTable)), c); err != nil {
+				return fmt.Errorf("cannot write import cursor of module %s, %w", c.Module, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteImportCursors removes the import cursors of chainName, the next import starting from scratch.
+func (i *Instance) DeleteImportCursors(chainName string) error {
+	if _, err := i.Instance.DB.Exec(fmt.Sprintf(deleteImportCursors, i.QualifiedName(importCursorsTable)), chainName); err != nil {
+		return fmt.Errorf("cannot delete import cursors, %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbutils "github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+)
+
+func TestInstance_ImportCursors_SQLite(t *testing.T) {
+	i, err := NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), Options{
+		Dialect: dbutils.DialectSQLite,
+	})
+	require.NoError(t, err)
+
+	cursor := func(chainName, module string, lastKey []byte, processed uint64, done bool) models.ImportCursorRow {
+		return models.ImportCursorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+				ChainName: chainName,
+				Height:    42,
+			},
+			Module:        module,
+			LastKey:       lastKey,
+			KeysProcessed: processed,
+			Done:          done,
+		}
+	}
+
+	cursors, err := i.ImportCursors("chain")
+	require.NoError(t, err)
+	require.Empty(t, cursors)
+
+	require.NoError(t, i.SetImportCursors([]models.ImportCursorRow{
+		cursor("chain", "bank", []byte{0x02, 0x01}, 10, false),
+		cursor("chain", "staking", nil, 0, false),
+		cursor("other", "bank", []byte{0x02}, 1, false),
+	}))
+
+	// later cursors replace earlier ones
+	require.NoError(t, i.SetImportCursors([]models.ImportCursorRow{
+		cursor("chain", "staking", []byte{0x21}, 5, true),
+	}))
+
+	cursors, err = i.ImportCursors("chain")
+	require.NoError(t, err)
+	require.Equal(t, map[string]models.ImportCursorRow{
+		"bank":    cursor("chain", "bank", []byte{0x02, 0x01}, 10, false),
+		"staking": cursor("chain", "staking", []byte{0x21}, 5, true),
+	}, cursors)
+
+	require.NoError(t, i.DeleteImportCursors("chain"))
+
+	cursors, err = i.ImportCursors("chain")
+	require.NoError(t, err)
+	require.Empty(t, cursors)
+
+	cursors, err = i.ImportCursors("other")
+	require.NoError(t, err)
+	require.Len(t, cursors, 1)
+}
@@ -1,21 +1,149 @@
 package database
 
-import dbutils "github.com/emerishq/tracelistener/database"
+import (
+	"fmt"
 
-const createDatabase = `
-CREATE DATABASE IF NOT EXISTS tracelistener;
-`
+	dbutils "github.com/emerishq/tracelistener/database"
+)
 
-var migrationList = []string{
-	createDatabase,
+// DefaultSchema is the database, or schema depending on the dialect, holding tracelistener
+// tables when none is configured.
+const DefaultSchema = "tracelistener"
+
+// migrationsTable records the versioned migrations applied to the database.
+const migrationsTable = "schema_migrations"
+
+// migrationList contains idempotent statements executed at every start,
+// before versioned migrations. Tables are created by versioned migrations instead.
+var migrationList []string
+
+// seedList contains idempotent statements executed at every start, after versioned migrations,
+// filling tables derived from other ones.
+var seedList []string
+
+// versionedMigrationList contains migrations applied once each, tracked in migrationsTable,
+// along with the ones returned by builtinMigrations.
+var versionedMigrationList []dbutils.Migration
+
+// builtinMigrations returns the migrations of the tables owned by this package, for schema.
+func builtinMigrations(schema string) []dbutils.Migration {
+	checkpoints := schema + "." + checkpointsTable
+	blockSummaries := schema + "." + BlockSummariesTable
+	importCursors := schema + "." + importCursorsTable
+
+	return []dbutils.Migration{
+		{
+			Version: 1,
+			Name:    "create checkpoints table",
+			Up:      fmt.Sprintf(createCheckpointsTable, checkpoints),
+			Down:    fmt.Sprintf(dropCheckpointsTable, checkpoints),
+			Dialects: map[dbutils.Dialect]dbutils.DialectMigration{
+				dbutils.DialectSQLite: {
+					Up:   fmt.Sprintf(createCheckpointsTableSQLite, checkpoints),
+					Down: fmt.Sprintf(dropCheckpointsTable, checkpoints),
+				},
+			},
+		},
+		{
+			Version: 2,
+			Name:    "add checkpoint status",
+			Up:      fmt.Sprintf(addCheckpointStatus, checkpoints),
+			Down:    fmt.Sprintf(dropCheckpointStatus, checkpoints),
+			Dialects: map[dbutils.Dialect]dbutils.DialectMigration{
+				dbutils.DialectSQLite: {
+					Up:   fmt.Sprintf(addCheckpointStatusSQLite, checkpoints),
+					Down: fmt.Sprintf(dropCheckpointStatusSQLite, checkpoints),
+				},
+			},
+		},
+		{
+			Version: 3,
+			Name:    "create block summaries table",
+			Up:      fmt.Sprintf(createBlockSummariesTable, blockSummaries),
+			Down:    fmt.Sprintf(dropBlockSummariesTable, blockSummaries),
+			Dialects: map[dbutils.Dialect]dbutils.DialectMigration{
+				dbutils.DialectSQLite: {
+					Up:   fmt.Sprintf(createBlockSummariesTableSQLite, blockSummaries),
+					Down: fmt.Sprintf(dropBlockSummariesTable, blockSummaries),
+				},
+			},
+		},
+		{
+			Version: 4,
+			Name:    "create import cursors table",
+			Up:      fmt.Sprintf(createImportCursorsTable, importCursors),
+			Down:    fmt.Sprintf(dropImportCursorsTable, importCursors),
+			Dialects: map[dbutils.Dialect]dbutils.DialectMigration{
+				dbutils.DialectSQLite: {
+					Up:   fmt.Sprintf(createImportCursorsTableSQLite, importCursors),
+					Down: fmt.Sprintf(dropImportCursorsTable, importCursors),
+				},
+			},
+		},
+	}
+}
+
+// RunMigrations executes idempotent statements, then applies pending versioned migrations.
+func (i *Instance) RunMigrations() error {
+	if err := i.Instance.RunMigrations(migrationList); err != nil {
+		return err
+	}
+
+	m, err := i.Migrator()
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.Up(); err != nil {
+		return fmt.Errorf("cannot apply versioned migrations, %w", err)
+	}
+
+	if err := i.Instance.RunMigrations(seedList); err != nil {
+		return fmt.Errorf("cannot run seeds, %w", err)
+	}
+
+	return nil
 }
 
-func (i *Instance) runMigrations() {
-	if err := dbutils.RunMigrations(i.connString, migrationList); err != nil {
-		panic(err)
+// Migrator returns a dbutils.Migrator handling all the registered versioned migrations,
+// with dbutils.SchemaPlaceholder replaced by the instance schema.
+func (i *Instance) Migrator() (*dbutils.Migrator, error) {
+	ms := builtinMigrations(i.schema)
+	for _, m := range versionedMigrationList {
+		ms = append(ms, m.WithSchema(i.schema))
 	}
+
+	return dbutils.NewMigrator(i.Instance, i.QualifiedName(migrationsTable), ms)
 }
 
+// RegisterMigration adds idempotent statements to be executed at every start.
 func RegisterMigration(migration ...string) {
 	migrationList = append(migrationList, migration...)
 }
+
+// RegisterSeed adds idempotent statements to be executed at every start, after versioned migrations.
+func RegisterSeed(seed ...string) {
+	seedList = append(seedList, seed...)
+}
+
+// RegisterVersionedMigration adds migrations to be applied once each, in version order.
+// Migrations already registered with the same version and checksum are skipped.
+func RegisterVersionedMigration(migration ...dbutils.Migration) {
+	for _, m := range migration {
+		if registeredMigration(m) {
+			continue
+		}
+
+		versionedMigrationList = append(versionedMigrationList, m)
+	}
+}
+
+func registeredMigration(m dbutils.Migration) bool {
+	for _, rm := range versionedMigrationList {
+		if rm.Version == m.Version && rm.Checksum() == m.Checksum() {
+			return true
+		}
+	}
+
+	return false
+}
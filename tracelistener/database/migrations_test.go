@@ -0,0 +1,162 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbutils "github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+func TestRunMigrations_BackfillNumericAmounts_SQLite(t *testing.T) {
+	i, err := OpenWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), Options{
+		Dialect: dbutils.DialectSQLite,
+	})
+	require.NoError(t, err)
+
+	balances := tables.NewBalancesTable(i.QualifiedName("balances")).WithDialect(dbutils.DialectSQLite)
+	cw20Balances := tables.NewCw20BalancesTable(i.QualifiedName("cw20_balances")).WithDialect(dbutils.DialectSQLite)
+	delegations := tables.NewDelegationsTable(i.QualifiedName("delegations")).WithDialect(dbutils.DialectSQLite)
+	validators := tables.NewValidatorsTable(i.QualifiedName("validators")).WithDialect(dbutils.DialectSQLite)
+
+	for _, schema := range [][]string{balances.Schema(), cw20Balances.Schema(), delegations.Schema(), validators.Schema()} {
+		for _, stmt := range schema {
+			_, err := i.Instance.DB.Exec(stmt)
+			require.NoError(t, err, stmt)
+		}
+	}
+
+	row := models.TracelistenerDatabaseRow{ChainName: "chain", Height: 1}
+
+	// rows written before the numeric columns existed
+	rows := []struct {
+		stmt string
+		row  interface{}
+	}{
+		{balances.Insert(), models.BalanceRow{TracelistenerDatabaseRow: row, Address: "a", Amount: "100uatom", Denom: "uatom"}},
+		{balances.Insert(), models.BalanceRow{TracelistenerDatabaseRow: row, Address: "b", Amount: "invalid", Denom: "uatom"}},
+		{cw20Balances.Insert(), models.CW20BalanceRow{TracelistenerDatabaseRow: row, ContractAddress: "c", Address: "a", Amount: `"42"`}},
+		{delegations.Insert(), models.DelegationRow{TracelistenerDatabaseRow: row, Delegator: "a", Validator: "v", Amount: "10.500000000000000000"}},
+		{validators.Insert(), models.ValidatorRow{TracelistenerDatabaseRow: row, ValidatorAddress: "v", OperatorAddress: "v", Tokens: "500", DelegatorShares: "1000.000000000000000000", CommissionRate: "0.100000000000000000"}},
+	}
+
+	for _, r := range rows {
+		_, err := i.Instance.DB.NamedExec(r.stmt, r.row)
+		require.NoError(t, err)
+	}
+
+	defer func(ms []dbutils.Migration) {
+		versionedMigrationList = ms
+	}(versionedMigrationList)
+	versionedMigrationList = tables.Migrations
+
+	require.NoError(t, i.RunMigrations())
+
+	var amounts []models.Numeric
+	require.NoError(t, i.Instance.DB.Select(&amounts, `SELECT amount_numeric FROM tracelistener.balances ORDER BY address`))
+	require.Equal(t, []models.Numeric{"100", ""}, amounts)
+
+	var cw20Amount string
+	require.NoError(t, i.Instance.DB.Get(&cw20Amount, `SELECT amount_numeric FROM tracelistener.cw20_balances`))
+	require.Equal(t, "42", cw20Amount)
+
+	var shares string
+	require.NoError(t, i.Instance.DB.Get(&shares, `SELECT amount_numeric FROM tracelistener.delegations`))
+	require.Equal(t, "10.500000000000000000", shares)
+
+	var v models.ValidatorRow
+	require.NoError(t, i.Instance.DB.Get(&v, `SELECT tokens_numeric, commission_rate_numeric FROM tracelistener.validators`))
+	require.Equal(t, models.Numeric("500"), v.TokensNumeric)
+	require.Equal(t, models.Numeric("0.100000000000000000"), v.CommissionRateNumeric)
+
+	var tokens string
+	require.NoError(t, i.Instance.DB.Get(&tokens, `SELECT delegated_tokens FROM tracelistener.delegations`))
+	require.Equal(t, "5.250000000000000000", tokens)
+}
+
+// TestRunMigrations_MatchGeneratedSchema_SQLite checks the migrations create the tables defined by sqlmodels.yaml.
+// Columns added by later migrations come last, so only the set of columns is compared.
+func TestRunMigrations_MatchGeneratedSchema_SQLite(t *testing.T) {
+	open := func() *Instance {
+		i, err := OpenWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), Options{
+			Dialect: dbutils.DialectSQLite,
+		})
+		require.NoError(t, err)
+
+		return i
+	}
+
+	type generatedTable interface {
+		Name() string
+		Schema() []string
+	}
+
+	type historyTable interface {
+		HistoryName() string
+		HistorySchema() []string
+	}
+
+	migrated := open()
+	defer func(ms []dbutils.Migration) {
+		versionedMigrationList = ms
+	}(versionedMigrationList)
+	versionedMigrationList = tables.Migrations
+	require.NoError(t, migrated.RunMigrations())
+
+	generated := open()
+	q := generated.QualifiedName
+	ts := []generatedTable{
+		tables.NewAuthTable(q("auth")).WithDialect(dbutils.DialectSQLite),
+		tables.NewBalancesTable(q("balances")).WithDialect(dbutils.DialectSQLite),
+		tables.NewChannelsTable(q("channels")).WithDialect(dbutils.DialectSQLite),
+		tables.NewClientsTable(q("clients")).WithDialect(dbutils.DialectSQLite),
+		tables.NewConnectionsTable(q("connections")).WithDialect(dbutils.DialectSQLite),
+		tables.NewCw20BalancesTable(q("cw20_balances")).WithDialect(dbutils.DialectSQLite),
+		tables.NewCw20TokenInfosTable(q("cw20_token_infos")).WithDialect(dbutils.DialectSQLite),
+		tables.NewDelegationsTable(q("delegations")).WithDialect(dbutils.DialectSQLite),
+		tables.NewDenomTracesTable(q("denom_traces")).WithDialect(dbutils.DialectSQLite),
+		tables.NewUnbondingDelegationsTable(q("unbonding_delegations")).WithDialect(dbutils.DialectSQLite),
+		tables.NewValidatorsTable(q("validators")).WithDialect(dbutils.DialectSQLite),
+	}
+
+	var names []string
+	for _, table := range ts {
+		stmts := table.Schema()
+		names = append(names, table.Name())
+		if h, ok := table.(historyTable); ok {
+			stmts = append(stmts, h.HistorySchema()...)
+			names = append(names, h.HistoryName())
+		}
+
+		for _, stmt := range stmts {
+			_, err := generated.Instance.DB.Exec(stmt)
+			require.NoError(t, err, stmt)
+		}
+	}
+
+	columns := func(i *Instance, table string) []string {
+		rows, err := i.Instance.DB.Queryx(`SELECT name, type FROM pragma_table_info(?, ?)`,
+			table[len(i.Schema())+1:], i.Schema())
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var name, typ string
+			require.NoError(t, rows.Scan(&name, &typ))
+			res = append(res, name+" "+typ)
+		}
+		require.NoError(t, rows.Err())
+
+		return res
+	}
+
+	for _, name := range names {
+		want := columns(generated, name)
+		require.NotEmpty(t, want, name)
+		require.ElementsMatch(t, want, columns(migrated, name), name)
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// Backoff describes an exponential backoff policy with equal jitter.
+type Backoff struct {
+	// Initial is the base delay before the first retry.
+	Initial time.Duration
+
+	// Max caps the delay between two attempts.
+	Max time.Duration
+
+	// Attempts is the total amount of attempts, the first one included.
+	Attempts int
+}
+
+// DefaultBackoff is the policy used for database writes.
+var DefaultBackoff = Backoff{
+	Initial:  50 * time.Millisecond,
+	Max:      10 * time.Second,
+	Attempts: 10,
+}
+
+// Delay returns how long to wait before the given retry, counting from zero.
+// Half of the exponential delay is always waited, the other half is random.
+func (b Backoff) Delay(retry int) time.Duration {
+	d := b.Initial
+	for n := 0; n < retry && d < b.Max; n++ {
+		d *= 2
+	}
+
+	if d > b.Max {
+		d = b.Max
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1)) //nolint:gosec
+}
+
+// Do calls f until it succeeds, returns a non-retryable error, or the attempts are
+// over. onRetry, if not nil, is called before each sleep.
+// The last error returned by f is returned.
+func (b Backoff) Do(f func() error, onRetry func(retry int, delay time.Duration, err error)) error {
+	var err error
+	for retry := 0; ; retry++ {
+		err = f()
+		if err == nil || !IsRetryable(err) || retry+1 >= b.Attempts {
+			return err
+		}
+
+		delay := b.Delay(retry)
+		if onRetry != nil {
+			onRetry(retry, delay, err)
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// IsRetryable reports whether err is a transient database error, like a serialization
+// failure or a lost connection, that might go away by running the same statements again.
+// Every other error, like constraint violations or invalid data, is permanent.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if len(pgErr.Code) < 2 {
+			return false
+		}
+
+		switch pgErr.Code[:2] {
+		case "08", // connection exception
+			"40", // transaction rollback, serialization failures and deadlocks included
+			"53", // insufficient resources
+			"57": // operator intervention, e.g. the node is shutting down
+			return true
+		}
+
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
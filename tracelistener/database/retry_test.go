@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			"nil error",
+			nil,
+			false,
+		},
+		{
+			"serialization failure",
+			&pgconn.PgError{Code: "40001"},
+			true,
+		},
+		{
+			"wrapped serialization failure",
+			fmt.Errorf("cannot write, %w", &pgconn.PgError{Code: "40001"}),
+			true,
+		},
+		{
+			"connection failure",
+			&pgconn.PgError{Code: "08006"},
+			true,
+		},
+		{
+			"bad connection",
+			driver.ErrBadConn,
+			true,
+		},
+		{
+			"unique violation",
+			&pgconn.PgError{Code: "23505"},
+			false,
+		},
+		{
+			"invalid text representation",
+			&pgconn.PgError{Code: "22P02"},
+			false,
+		},
+		{
+			"generic error",
+			errors.New("error"),
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{
+		Initial:  10 * time.Millisecond,
+		Max:      100 * time.Millisecond,
+		Attempts: 10,
+	}
+
+	for retry, max := range []time.Duration{10, 20, 40, 80, 100, 100} {
+		max *= time.Millisecond
+		d := b.Delay(retry)
+		require.GreaterOrEqual(t, d, max/2)
+		require.LessOrEqual(t, d, max)
+	}
+}
+
+func TestBackoff_Do(t *testing.T) {
+	b := Backoff{
+		Initial:  time.Millisecond,
+		Max:      time.Millisecond,
+		Attempts: 3,
+	}
+
+	retryable := &pgconn.PgError{Code: "40001"}
+	permanent := &pgconn.PgError{Code: "23505"}
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		calls := 0
+		require.NoError(t, b.Do(func() error {
+			calls++
+			if calls < 3 {
+				return retryable
+			}
+			return nil
+		}, nil))
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("attempts are over", func(t *testing.T) {
+		calls, retries := 0, 0
+		err := b.Do(func() error {
+			calls++
+			return retryable
+		}, func(int, time.Duration, error) {
+			retries++
+		})
+		require.ErrorIs(t, err, retryable)
+		require.Equal(t, 3, calls)
+		require.Equal(t, 2, retries)
+	})
+
+	t.Run("permanent error is not retried", func(t *testing.T) {
+		calls := 0
+		err := b.Do(func() error {
+			calls++
+			return permanent
+		}, nil)
+		require.ErrorIs(t, err, permanent)
+		require.Equal(t, 1, calls)
+	})
+}
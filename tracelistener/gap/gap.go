@@ -0,0 +1,60 @@
+// Package gap detects blocks missed by tracelistener between two runs.
+package gap
+
+import (
+	"fmt"
+
+	"github.com/emerishq/tracelistener/tracelistener"
+)
+
+// Gap is a range of block heights, bounds included, whose traces have never been processed.
+type Gap struct {
+	Start uint64
+	End   uint64
+}
+
+// String implements the fmt.Stringer interface.
+func (g Gap) String() string {
+	return fmt.Sprintf("[%d, %d]", g.Start, g.End)
+}
+
+// Detect compares the last height committed before a restart with the first
+// height observed in the new trace stream, and returns the heights in between
+// which will never be traced.
+// A zero lastCommitted means nothing has been committed yet, hence there's no gap.
+func Detect(lastCommitted, firstSeen uint64) (Gap, bool) {
+	if lastCommitted == 0 || firstSeen <= lastCommitted+1 {
+		return Gap{}, false
+	}
+
+	return Gap{
+		Start: lastCommitted + 1,
+		End:   firstSeen - 1,
+	}, true
+}
+
+// Detector sits between a TraceWatcher and a DataProcessor, and checks the
+// first traced block height against LastCommitted.
+type Detector struct {
+	// LastCommitted is the last fully-committed height before the current run.
+	LastCommitted uint64
+
+	// OnGap is called once if a gap is found.
+	OnGap func(g Gap)
+}
+
+// Forward sends everything read from in to out, checking the first non-zero
+// block height it sees for gaps.
+func (d Detector) Forward(in <-chan tracelistener.TraceOperation, out chan<- tracelistener.TraceOperation) {
+	checked := false
+	for data := range in {
+		if !checked && data.BlockHeight != 0 {
+			checked = true
+			if g, found := Detect(d.LastCommitted, data.BlockHeight); found && d.OnGap != nil {
+				d.OnGap(g)
+			}
+		}
+
+		out <- data
+	}
+}
@@ -0,0 +1,89 @@
+package gap_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/gap"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name          string
+		lastCommitted uint64
+		firstSeen     uint64
+		expectedGap   gap.Gap
+		expectedFound bool
+	}{
+		{
+			"no checkpoint",
+			0,
+			100,
+			gap.Gap{},
+			false,
+		},
+		{
+			"next block",
+			99,
+			100,
+			gap.Gap{},
+			false,
+		},
+		{
+			"block already committed is traced again",
+			100,
+			100,
+			gap.Gap{},
+			false,
+		},
+		{
+			"one block missing",
+			98,
+			100,
+			gap.Gap{Start: 99, End: 99},
+			true,
+		},
+		{
+			"many blocks missing",
+			10,
+			100,
+			gap.Gap{Start: 11, End: 99},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, found := gap.Detect(tt.lastCommitted, tt.firstSeen)
+			require.Equal(t, tt.expectedFound, found)
+			require.Equal(t, tt.expectedGap, g)
+		})
+	}
+}
+
+func TestDetector_Forward(t *testing.T) {
+	in := make(chan tracelistener.TraceOperation)
+	out := make(chan tracelistener.TraceOperation, 3)
+
+	var gaps []gap.Gap
+	d := gap.Detector{
+		LastCommitted: 10,
+		OnGap: func(g gap.Gap) {
+			gaps = append(gaps, g)
+		},
+	}
+
+	go func() {
+		in <- tracelistener.TraceOperation{BlockHeight: 0}
+		in <- tracelistener.TraceOperation{BlockHeight: 15}
+		in <- tracelistener.TraceOperation{BlockHeight: 20}
+		close(in)
+	}()
+
+	d.Forward(in, out)
+
+	require.Len(t, out, 3)
+	require.Equal(t, []gap.Gap{{Start: 11, End: 14}}, gaps)
+}
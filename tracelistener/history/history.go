@@ -0,0 +1,66 @@
+// Package history rebuilds past chain state out of the append-only history
+// tables written by tracelistener.
+package history
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+// Reader queries history tables through db.
+type Reader struct {
+	db          *sqlx.DB
+	balances    tables.BalancesTable
+	delegations tables.DelegationsTable
+	auth        tables.AuthTable
+}
+
+// NewReader returns a Reader querying the tracelistener schema of db.
+func NewReader(db *sqlx.DB) Reader {
+	return NewReaderWithSchema(db, "tracelistener", database.DialectCockroachDB)
+}
+
+// NewReaderWithSchema returns a Reader querying the tables held in schema by db, which speaks dialect.
+func NewReaderWithSchema(db *sqlx.DB, schema string, dialect database.Dialect) Reader {
+	return Reader{
+		db:          db,
+		balances:    tables.NewBalancesTable(schema + ".balances").WithDialect(dialect),
+		delegations: tables.NewDelegationsTable(schema + ".delegations").WithDialect(dialect),
+		auth:        tables.NewAuthTable(schema + ".auth").WithDialect(dialect),
+	}
+}
+
+// Balances returns the balances held by address on chainName at height.
+func (r Reader) Balances(chainName, address string, height uint64) ([]models.BalanceRow, error) {
+	var res []models.BalanceRow
+	if err := r.db.Select(&res, r.balances.SelectHistoryAt("address"), chainName, address, height); err != nil {
+		return nil, fmt.Errorf("cannot query balances history, %w", err)
+	}
+
+	return res, nil
+}
+
+// Delegations returns the delegations made by delegator on chainName at height.
+func (r Reader) Delegations(chainName, delegator string, height uint64) ([]models.DelegationRow, error) {
+	var res []models.DelegationRow
+	if err := r.db.Select(&res, r.delegations.SelectHistoryAt("delegator_address"), chainName, delegator, height); err != nil {
+		return nil, fmt.Errorf("cannot query delegations history, %w", err)
+	}
+
+	return res, nil
+}
+
+// Auth returns the auth state of address on chainName at height.
+func (r Reader) Auth(chainName, address string, height uint64) ([]models.AuthRow, error) {
+	var res []models.AuthRow
+	if err := r.db.Select(&res, r.auth.SelectHistoryAt("address"), chainName, address, height); err != nil {
+		return nil, fmt.Errorf("cannot query auth history, %w", err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,127 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+// balancesHistory returns a SQLite database holding the balances table along with its history,
+// and a func returning a balance row of address at height.
+func balancesHistory(t *testing.T) (*database.Instance, tables.BalancesTable, func(amount string, height uint64) models.BalanceRow) {
+	t.Helper()
+
+	i, err := database.NewWithDialect(filepath.Join(t.TempDir(), "tracelistener.db"), database.DialectSQLite)
+	require.NoError(t, err)
+	require.NoError(t, i.CreateSchema("staging"))
+
+	table := tables.NewBalancesTable("staging.balances").WithDialect(database.DialectSQLite)
+	for _, stmt := range append(table.Schema(), table.HistorySchema()...) {
+		_, err := i.DB.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	balance := func(amount string, height uint64) models.BalanceRow {
+		return models.BalanceRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+				ChainName: "chain",
+				Height:    height,
+				TxHash:    models.NullString("hash"),
+			},
+			Address: "address",
+			Amount:  amount,
+			Denom:   "stake",
+		}
+	}
+
+	return i, table, balance
+}
+
+func TestReader_Balances_SQLite(t *testing.T) {
+	i, table, balance := balancesHistory(t)
+
+	write := func(row models.BalanceRow) {
+		_, err := i.DB.NamedExec(table.InsertHistory(), row)
+		require.NoError(t, err)
+		_, err = i.DB.NamedExec(table.Upsert(), row)
+		require.NoError(t, err)
+	}
+
+	write(balance("10", 1))
+	write(balance("20", 5))
+
+	_, err := i.DB.NamedExec(table.DeleteHistory(), balance("", 8))
+	require.NoError(t, err)
+	_, err = i.DB.NamedExec(table.Delete(), balance("", 8))
+	require.NoError(t, err)
+
+	r := NewReaderWithSchema(i.DB, "staging", database.DialectSQLite)
+
+	tests := []struct {
+		height   uint64
+		expected []string
+	}{
+		{height: 0},
+		{height: 3, expected: []string{"10"}},
+		{height: 6, expected: []string{"20"}},
+		{height: 9},
+	}
+
+	for _, tt := range tests {
+		res, err := r.Balances("chain", "address", tt.height)
+		require.NoError(t, err)
+
+		var amounts []string
+		for _, b := range res {
+			amounts = append(amounts, b.Amount)
+		}
+		require.Equal(t, tt.expected, amounts, "height %d", tt.height)
+	}
+}
+
+func TestHistory_Replay_SQLite(t *testing.T) {
+	i, table, balance := balancesHistory(t)
+
+	apply := func(history, stmt string, row models.BalanceRow) {
+		_, err := i.DB.NamedExec(history, row)
+		require.NoError(t, err)
+		_, err = i.DB.NamedExec(stmt, row)
+		require.NoError(t, err)
+	}
+
+	blocks := func() {
+		apply(table.InsertHistory(), table.Upsert(), balance("10", 1))
+		apply(table.InsertHistory(), table.Upsert(), balance("20", 5))
+		apply(table.DeleteHistory(), table.Delete(), balance("", 8))
+	}
+
+	blocks()
+
+	type entry struct {
+		Height    uint64 `db:"height"`
+		Operation string `db:"operation"`
+	}
+
+	var expected []entry
+	require.NoError(t, i.DB.Select(&expected, "SELECT height, operation FROM staging.balances_history ORDER BY id"))
+	require.Equal(t, []entry{{1, "write"}, {5, "write"}, {8, "delete"}}, expected)
+
+	// replaying the blocks, or an older one, leaves both the table and its history untouched
+	blocks()
+	apply(table.InsertHistory(), table.Upsert(), balance("15", 3))
+
+	var entries []entry
+	require.NoError(t, i.DB.Select(&entries, "SELECT height, operation FROM staging.balances_history ORDER BY id"))
+	require.Equal(t, expected, entries)
+
+	r := NewReaderWithSchema(i.DB, "staging", database.DialectSQLite)
+	res, err := r.Balances("chain", "address", 6)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, "20", res[0].Amount)
+}
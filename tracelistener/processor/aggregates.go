@@ -0,0 +1,299 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+// Aggregates are tables summing up other ones, kept up to date at flush by applying the difference
+// each flushed row makes instead of running GROUP BY queries:
+//   - denom_totals holds the total balance and the number of holders of each denom,
+//   - delegator_totals holds the tokens delegated by each delegator, see delegatedTokens,
+//   - validator_powers holds the consensus power of each validator, and its rank among the ones
+//     having some.
+//
+// Rows already up to date are left untouched, so that replaying a block doesn't count it twice.
+
+const (
+	// upsertDenomTotals adds the difference a balance row is about to make, it must run before
+	// the row is written.
+	upsertDenomTotals = `
+	INSERT INTO %[1]s AS t (chain_name, denom, height, total_amount, holders)
+	SELECT CAST(:chain_name AS text), CAST(:denom AS text), CAST(:height AS bigint),
+	%[3]s,
+	(CASE WHEN %[4]s THEN 1 ELSE 0 END) - (CASE WHEN b.delete_height IS NULL AND %[5]s THEN 1 ELSE 0 END)
+	FROM (SELECT 1) AS one
+	LEFT JOIN %[2]s AS b ON b.chain_name = :chain_name AND b.address = :address AND b.denom = :denom
+	WHERE b.height IS NULL OR b.height < :height
+	ON CONFLICT (chain_name, denom) DO UPDATE
+	SET total_amount = %[6]s, holders = t.holders + EXCLUDED.holders, height = EXCLUDED.height`
+
+	// upsertDelegatorTotals adds the difference recomputing delegated tokens is about to make,
+	// the WHERE clause being the one of the recompute statement.
+	upsertDelegatorTotals = `
+	INSERT INTO %[1]s AS t (chain_name, delegator_address, height, delegated_tokens)
+	SELECT d.chain_name, d.delegator_address, CAST(:height AS bigint), %[4]s
+	FROM %[2]s AS d, %[3]s AS v
+	%[5]s
+	ON CONFLICT (chain_name, delegator_address) DO UPDATE
+	SET delegated_tokens = %[6]s, height = EXCLUDED.height`
+
+	// subtractDelegatorTotals removes the tokens of a deleted delegation, before they're cleared.
+	subtractDelegatorTotals = `
+	INSERT INTO %[1]s AS t (chain_name, delegator_address, height, delegated_tokens)
+	SELECT d.chain_name, d.delegator_address, CAST(:height AS bigint), %[3]s
+	FROM %[2]s AS d
+	WHERE d.chain_name = :chain_name AND d.delegator_address = :delegator_address AND d.validator_address = :validator_address
+	AND d.delete_height IS NOT NULL AND d.delegated_tokens IS NOT NULL
+	ON CONFLICT (chain_name, delegator_address) DO UPDATE
+	SET delegated_tokens = %[4]s, height = EXCLUDED.height`
+
+	// Ranks are maintained by taking the validator out of the ranking, shifting down the ones
+	// after it, then putting it back in with its new power, shifting up the ones after it.
+
+	unrankValidatorPower = `
+	UPDATE %[1]s SET rank = rank - 1
+	WHERE chain_name = :chain_name
+	AND rank > (SELECT r.rank FROM %[1]s AS r WHERE r.chain_name = :chain_name AND r.validator_address = :validator_address)`
+
+	upsertValidatorPower = `
+	INSERT INTO %s (chain_name, validator_address, height, voting_power, rank)
+	VALUES (:chain_name, :validator_address, :height, :voting_power, NULL)
+	ON CONFLICT (chain_name, validator_address) DO UPDATE
+	SET height = EXCLUDED.height, voting_power = EXCLUDED.voting_power, rank = NULL`
+
+	shiftValidatorPowers = `
+	UPDATE %s SET rank = rank + 1
+	WHERE chain_name = :chain_name AND rank IS NOT NULL AND CAST(:voting_power AS bigint) > 0
+	AND (voting_power < :voting_power OR (voting_power = :voting_power AND validator_address > :validator_address))`
+
+	rankValidatorPower = `
+	UPDATE %[1]s SET rank = 1 + (
+		SELECT count(*) FROM %[1]s AS o
+		WHERE o.chain_name = :chain_name AND o.rank IS NOT NULL
+		AND (o.voting_power > :voting_power OR (o.voting_power = :voting_power AND o.validator_address < :validator_address))
+	)
+	WHERE chain_name = :chain_name AND validator_address = :validator_address AND voting_power > 0`
+)
+
+// decimalSQL writes decimal arithmetic for a dialect, SQLite relying on the functions registered
+// by the database package.
+type decimalSQL database.Dialect
+
+func (d decimalSQL) add(a, b string) string {
+	if database.Dialect(d) == database.DialectSQLite {
+		return fmt.Sprintf("decimal_add(%s, %s)", a, b)
+	}
+
+	return fmt.Sprintf("%s + %s", a, b)
+}
+
+func (d decimalSQL) sub(a, b string) string {
+	if database.Dialect(d) == database.DialectSQLite {
+		return fmt.Sprintf("decimal_sub(%s, %s)", a, b)
+	}
+
+	return fmt.Sprintf("%s - %s", a, b)
+}
+
+func (d decimalSQL) positive(a string) string {
+	if database.Dialect(d) == database.DialectSQLite {
+		return fmt.Sprintf("COALESCE(decimal_cmp(%s, 0), 0) > 0", a)
+	}
+
+	return fmt.Sprintf("COALESCE(%s > 0, false)", a)
+}
+
+// param returns the named parameter name as a decimal.
+func (d decimalSQL) param(name string) string {
+	if database.Dialect(d) == database.DialectSQLite {
+		return ":" + name
+	}
+
+	return fmt.Sprintf("CAST(:%s AS DECIMAL)", name)
+}
+
+// aggregateStmts holds the statements maintaining aggregates, see moduleTables.
+type aggregateStmts struct {
+	denomTotals         string
+	validatorTotals     string
+	delegationTotals    string
+	subtractTotals      string
+	validatorPowerSteps []string
+}
+
+func newAggregateStatements(d database.Dialect, t moduleTables) aggregateStmts {
+	dec := decimalSQL(d)
+	amount := "COALESCE(" + dec.param("amount_numeric") + ", 0)"
+	oldAmount := "CASE WHEN b.delete_height IS NULL THEN COALESCE(b.amount_numeric, 0) ELSE 0 END"
+	tokens := "COALESCE(" + tables.DelegatedTokensExpr(d) + ", 0)"
+
+	return aggregateStmts{
+		denomTotals: fmt.Sprintf(upsertDenomTotals, t.denomTotals, t.balances.Name(),
+			dec.sub(amount, oldAmount), dec.positive(dec.param("amount_numeric")), dec.positive("b.amount_numeric"),
+			dec.add("t.total_amount", "EXCLUDED.total_amount")),
+		validatorTotals: fmt.Sprintf(upsertDelegatorTotals, t.delegatorTotals, t.delegations.Name(), t.validators.Name(),
+			dec.sub(tokens, "COALESCE(d.delegated_tokens, 0)"), delegatedTokensWhere,
+			dec.add("t.delegated_tokens", "EXCLUDED.delegated_tokens")),
+		delegationTotals: fmt.Sprintf(upsertDelegatorTotals, t.delegatorTotals, t.delegations.Name(), t.validators.Name(),
+			dec.sub(tokens, "COALESCE(d.delegated_tokens, 0)"), delegationDelegatedTokensWhere,
+			dec.add("t.delegated_tokens", "EXCLUDED.delegated_tokens")),
+		subtractTotals: fmt.Sprintf(subtractDelegatorTotals, t.delegatorTotals, t.delegations.Name(),
+			dec.sub("0", "d.delegated_tokens"), dec.add("t.delegated_tokens", "EXCLUDED.delegated_tokens")),
+		validatorPowerSteps: []string{
+			fmt.Sprintf(unrankValidatorPower, t.validatorPowers),
+			fmt.Sprintf(upsertValidatorPower, t.validatorPowers),
+			fmt.Sprintf(shiftValidatorPowers, t.validatorPowers),
+			fmt.Sprintf(rankValidatorPower, t.validatorPowers),
+		},
+	}
+}
+
+// aggregatesSeeds returns the statements seeding the aggregate tables of the enabled modules for d,
+// the tables being created by the 20261019013200_create_aggregates migration.
+// SQLite cannot sum decimals, so its aggregates must be enabled before any row is written.
+func aggregatesSeeds(d database.Dialect, t moduleTables, bank, delegatedTokens, validators bool, powerReduction int64) []string {
+	if d == database.DialectSQLite {
+		return nil
+	}
+
+	var seeds []string
+	if bank {
+		seeds = append(seeds, tables.SeedDenomTotals(t.denomTotals, t.balances.Name(), seedOnce(t.denomTotals)))
+	}
+
+	if delegatedTokens {
+		seeds = append(seeds, tables.SeedDelegatorTotals(t.delegatorTotals, t.delegations.Name(), seedOnce(t.delegatorTotals)))
+	}
+
+	if validators {
+		seeds = append(seeds, tables.SeedValidatorPowers(t.validatorPowers, t.validators.Name(), seedOnce(t.validatorPowers), powerReduction))
+	}
+
+	return seeds
+}
+
+// seedOnce returns the seed condition leaving aggregate as is once it has rows.
+func seedOnce(aggregate string) string {
+	return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s)", aggregate)
+}
+
+// aggregates keeps the consensus power of the validators seen so far, to only update
+// the ranking when it changes.
+// Powers are cached at flush, before the block is written: invalidate forgets them when it can't be.
+type aggregates struct {
+	powerReduction int64
+	stmts          aggregateStmts
+
+	mu     sync.Mutex
+	powers map[string]int64
+}
+
+// denomTotalsOps returns the WritebackOps adding the difference each balance row of entry makes
+// to denom_totals, to be run before entry.
+func (a *aggregates) denomTotalsOps(entry tracelistener.WritebackOp) []tracelistener.WritebackOp {
+	if entry.Type != tracelistener.Write {
+		return nil
+	}
+
+	res := make([]tracelistener.WritebackOp, 0, len(entry.Data))
+	for _, d := range entry.Data {
+		res = append(res, tracelistener.WritebackOp{
+			Type:         tracelistener.Write,
+			Data:         []models.DatabaseEntrier{d},
+			Statement:    a.stmts.denomTotals,
+			SourceModule: entry.SourceModule,
+		})
+	}
+
+	return res
+}
+
+// validatorPowersOps returns the WritebackOps updating the power and rank of the validators
+// written or deleted, when their power changed.
+func (a *aggregates) validatorPowersOps(validators, deletedValidators []models.DatabaseEntrier) []tracelistener.WritebackOp {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.powers == nil {
+		a.powers = map[string]int64{}
+	}
+
+	var res []tracelistener.WritebackOp
+	update := func(v models.ValidatorRow, power int64) {
+		if prev, ok := a.powers[v.ValidatorAddress]; ok && prev == power {
+			return
+		}
+
+		a.powers[v.ValidatorAddress] = power
+
+		row := models.ValidatorPowerRow{
+			TracelistenerDatabaseRow: v.TracelistenerDatabaseRow,
+			ValidatorAddress:         v.ValidatorAddress,
+			VotingPower:              power,
+		}
+
+		for _, stmt := range a.stmts.validatorPowerSteps {
+			res = append(res, tracelistener.WritebackOp{
+				Type:         tracelistener.Write,
+				Data:         []models.DatabaseEntrier{row},
+				Statement:    stmt,
+				SourceModule: tracelistener.Staking.String(),
+			})
+		}
+	}
+
+	for _, d := range validators {
+		if v, ok := d.(models.ValidatorRow); ok {
+			update(v, a.votingPower(v))
+		}
+	}
+
+	for _, d := range deletedValidators {
+		if v, ok := d.(models.ValidatorRow); ok {
+			update(v, 0)
+		}
+	}
+
+	return res
+}
+
+// invalidate forgets the powers seen so far, so that the next flush writing a validator
+// updates its power and rank.
+func (a *aggregates) invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.powers = nil
+}
+
+// votingPower returns the consensus power of v, zero if it isn't bonded.
+func (a *aggregates) votingPower(v models.ValidatorRow) int64 {
+	if v.Status != tables.BondStatusBonded || v.Jailed {
+		return 0
+	}
+
+	tokens, ok := new(big.Int).SetString(v.Tokens, 10)
+	if !ok {
+		return 0
+	}
+
+	reduction := a.powerReduction
+	if reduction <= 0 {
+		reduction = tables.DefaultPowerReduction
+	}
+
+	power := tokens.Quo(tokens, big.NewInt(reduction))
+	if !power.IsInt64() {
+		return math.MaxInt64
+	}
+
+	return power.Int64()
+}
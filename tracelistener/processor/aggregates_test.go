@@ -0,0 +1,311 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	tldatabase "github.com/emerishq/tracelistener/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+// aggregatesDB returns a SQLite database holding the bank and staking tables along with their aggregates,
+// and the tables writing to it.
+func aggregatesDB(t *testing.T) (*sqlx.DB, moduleTables) {
+	t.Helper()
+
+	tldatabase.RegisterVersionedMigration(tables.Migrations...)
+	i, err := tldatabase.NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), tldatabase.Options{
+		Dialect: database.DialectSQLite,
+	})
+	require.NoError(t, err)
+
+	mt := newModuleTables(tldatabase.DefaultSchema, database.DialectSQLite)
+	require.Empty(t, aggregatesSeeds(database.DialectSQLite, mt, true, true, true, 0))
+
+	return i.Instance.DB, mt
+}
+
+func applyOps(t *testing.T, db *sqlx.DB, ops []tracelistener.WritebackOp) {
+	t.Helper()
+
+	for _, op := range ops {
+		for _, d := range op.Data {
+			_, err := db.NamedExec(op.Statement, d)
+			require.NoError(t, err, op.Statement)
+		}
+	}
+}
+
+func TestAggregates_DenomTotals_SQLite(t *testing.T) {
+	db, mt := aggregatesDB(t)
+	a := aggregates{stmts: mt.aggregates}
+
+	balance := func(height uint64, address, amount string) models.BalanceRow {
+		return models.BalanceRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: height},
+			Address:                  address,
+			Amount:                   amount + "stake",
+			AmountNumeric:            models.Numeric(amount),
+			Denom:                    "stake",
+		}
+	}
+
+	tests := []struct {
+		name            string
+		balances        []models.DatabaseEntrier
+		expectedAmount  string
+		expectedHolders int
+	}{
+		{
+			"new holders",
+			[]models.DatabaseEntrier{balance(1, "a", "100"), balance(1, "b", "50")},
+			"150",
+			2,
+		},
+		{
+			"balance emptied",
+			[]models.DatabaseEntrier{balance(2, "a", "0")},
+			"50",
+			1,
+		},
+		{
+			"replayed block is ignored",
+			[]models.DatabaseEntrier{balance(2, "a", "0"), balance(1, "b", "50")},
+			"50",
+			1,
+		},
+		{
+			"balances changed",
+			[]models.DatabaseEntrier{balance(3, "a", "10"), balance(3, "b", "20")},
+			"30",
+			2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tracelistener.WritebackOp{Type: tracelistener.Write, Data: tt.balances}
+			applyOps(t, db, a.denomTotalsOps(entry))
+			applyOps(t, db, []tracelistener.WritebackOp{{Data: tt.balances, Statement: mt.balances.Upsert()}})
+
+			var res struct {
+				TotalAmount string `db:"total_amount"`
+				Holders     int    `db:"holders"`
+			}
+			require.NoError(t, db.Get(&res, `SELECT total_amount, holders FROM tracelistener.denom_totals WHERE chain_name = 'chain' AND denom = 'stake'`))
+			require.Equal(t, tt.expectedAmount, res.TotalAmount)
+			require.Equal(t, tt.expectedHolders, res.Holders)
+		})
+	}
+
+	require.Empty(t, a.denomTotalsOps(tracelistener.WritebackOp{Type: tracelistener.Delete, Data: []models.DatabaseEntrier{balance(4, "a", "0")}}))
+}
+
+func TestAggregates_DelegatorTotals_SQLite(t *testing.T) {
+	db, mt := aggregatesDB(t)
+	dt := delegatedTokens{stmts: mt.delegatedTokens, aggregateStmts: mt.aggregates}
+
+	validator := func(height uint64, tokens string) models.DatabaseEntrier {
+		return models.ValidatorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: height},
+			ValidatorAddress:         "validator",
+			Tokens:                   tokens,
+			TokensNumeric:            models.Numeric(tokens),
+			DelegatorShares:          "1000",
+			DelegatorSharesNumeric:   "1000",
+		}
+	}
+	delegation := func(height uint64, validator, shares string) models.DatabaseEntrier {
+		return models.DelegationRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: height},
+			Delegator:                "delegator",
+			Validator:                validator,
+			Amount:                   shares,
+			AmountNumeric:            models.Numeric(shares),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		rows     stakingRows
+		expected string
+	}{
+		{
+			"new delegations",
+			stakingRows{
+				delegations: []models.DatabaseEntrier{delegation(1, "validator", "100"), delegation(1, "other", "100")},
+				validators:  []models.DatabaseEntrier{validator(1, "1000")},
+			},
+			"100.000000000000000000",
+		},
+		{
+			"slashing",
+			stakingRows{validators: []models.DatabaseEntrier{validator(2, "900")}},
+			"90.000000000000000000",
+		},
+		{
+			"delegation deleted",
+			stakingRows{deletedDelegations: []models.DatabaseEntrier{delegation(3, "validator", "")}},
+			"0.000000000000000000",
+		},
+		{
+			"delegation restored",
+			stakingRows{delegations: []models.DatabaseEntrier{delegation(4, "validator", "200")}},
+			"180.000000000000000000",
+		},
+		{
+			"replayed delegation",
+			stakingRows{delegations: []models.DatabaseEntrier{delegation(4, "validator", "200")}},
+			"180.000000000000000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyOps(t, db, []tracelistener.WritebackOp{
+				{Data: tt.rows.delegations, Statement: mt.delegations.Upsert()},
+				{Data: tt.rows.deletedDelegations, Statement: mt.delegations.Delete()},
+				{Data: tt.rows.validators, Statement: mt.validators.Upsert()},
+			})
+			applyOps(t, db, dt.ops(tt.rows, true))
+
+			var total string
+			require.NoError(t, db.Get(&total, `SELECT delegated_tokens FROM tracelistener.delegator_totals WHERE delegator_address = 'delegator'`))
+			require.Equal(t, tt.expected, total)
+		})
+	}
+}
+
+func TestAggregates_ValidatorPowers_SQLite(t *testing.T) {
+	db, mt := aggregatesDB(t)
+	a := aggregates{stmts: mt.aggregates}
+
+	validator := func(address string, tokens string, status int32) models.DatabaseEntrier {
+		return models.ValidatorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: 1},
+			ValidatorAddress:         address,
+			Tokens:                   tokens,
+			Status:                   status,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		validators []models.DatabaseEntrier
+		deleted    []models.DatabaseEntrier
+		expectedOp int
+		expected   map[string]string
+	}{
+		{
+			"new validators",
+			[]models.DatabaseEntrier{
+				validator("a", "3000000", tables.BondStatusBonded),
+				validator("b", "1000000", tables.BondStatusBonded),
+				validator("c", "2000000", tables.BondStatusBonded),
+				validator("d", "5000000", 1),
+			},
+			nil,
+			16,
+			map[string]string{"a": "3 #1", "b": "1 #3", "c": "2 #2", "d": "0"},
+		},
+		{
+			"power changed",
+			[]models.DatabaseEntrier{validator("b", "4500000", tables.BondStatusBonded), validator("a", "3000001", tables.BondStatusBonded)},
+			nil,
+			4,
+			map[string]string{"a": "3 #2", "b": "4 #1", "c": "2 #3", "d": "0"},
+		},
+		{
+			"ties ordered by address",
+			[]models.DatabaseEntrier{validator("c", "3000000", tables.BondStatusBonded)},
+			nil,
+			4,
+			map[string]string{"a": "3 #2", "b": "4 #1", "c": "3 #3", "d": "0"},
+		},
+		{
+			"validator unbonded",
+			[]models.DatabaseEntrier{validator("b", "4500000", 2)},
+			nil,
+			4,
+			map[string]string{"a": "3 #1", "b": "0", "c": "3 #2", "d": "0"},
+		},
+		{
+			"validator bonded and another deleted",
+			[]models.DatabaseEntrier{validator("d", "5000000", tables.BondStatusBonded)},
+			[]models.DatabaseEntrier{validator("a", "", 0)},
+			8,
+			map[string]string{"a": "0", "b": "0", "c": "3 #2", "d": "5 #1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := a.validatorPowersOps(tt.validators, tt.deleted)
+			require.Len(t, ops, tt.expectedOp)
+			applyOps(t, db, ops)
+
+			var rows []models.ValidatorPowerRow
+			require.NoError(t, db.Select(&rows, `SELECT validator_address, voting_power, rank FROM tracelistener.validator_powers`))
+
+			res := map[string]string{}
+			for _, r := range rows {
+				res[r.ValidatorAddress] = fmt.Sprint(r.VotingPower)
+				if r.Rank != nil {
+					res[r.ValidatorAddress] += fmt.Sprintf(" #%d", *r.Rank)
+				}
+			}
+			require.Equal(t, tt.expected, res)
+		})
+	}
+}
+
+func TestAggregates_ValidatorPowers_Invalidate_SQLite(t *testing.T) {
+	db, mt := aggregatesDB(t)
+	a := aggregates{stmts: mt.aggregates}
+
+	validators := []models.DatabaseEntrier{
+		models.ValidatorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: 1},
+			ValidatorAddress:         "a",
+			Tokens:                   "2000000",
+			Status:                   tables.BondStatusBonded,
+		},
+		models.ValidatorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: 1},
+			ValidatorAddress:         "b",
+			Tokens:                   "1000000",
+			Status:                   tables.BondStatusBonded,
+		},
+	}
+
+	ranks := func() map[string]int64 {
+		var rows []models.ValidatorPowerRow
+		require.NoError(t, db.Select(&rows, `SELECT validator_address, voting_power, rank FROM tracelistener.validator_powers`))
+
+		res := map[string]int64{}
+		for _, r := range rows {
+			require.NotNil(t, r.Rank)
+			res[r.ValidatorAddress] = *r.Rank
+		}
+
+		return res
+	}
+
+	applyOps(t, db, a.validatorPowersOps(validators, nil))
+	require.Empty(t, a.validatorPowersOps(validators, nil))
+	require.Equal(t, map[string]int64{"a": 1, "b": 2}, ranks())
+
+	// the block caching the powers couldn't be written, they're written again
+	a.invalidate()
+	ops := a.validatorPowersOps(validators, nil)
+	require.NotEmpty(t, ops)
+	applyOps(t, db, ops)
+	require.Equal(t, map[string]int64{"a": 1, "b": 2}, ranks())
+}
@@ -11,8 +11,6 @@ import (
 	"go.uber.org/zap"
 )
 
-var authTable = tables.NewAuthTable("tracelistener.auth")
-
 type authCacheEntry struct {
 	address   string
 	accNumber uint64
@@ -20,12 +18,13 @@ type authCacheEntry struct {
 
 type authProcessor struct {
 	l           *zap.SugaredLogger
+	table       tables.AuthTable
 	heightCache map[authCacheEntry]models.AuthRow
 	m           sync.Mutex
 }
 
-func (*authProcessor) Migrations() []string {
-	return []string{authTable.CreateTable()}
+func (b *authProcessor) Table() Table {
+	return b.table
 }
 
 func (b *authProcessor) ModuleName() string {
@@ -37,17 +36,25 @@ func (b *authProcessor) SDKModuleName() tracelistener.SDKModuleName {
 }
 
 func (b *authProcessor) UpsertStatement() string {
-	return authTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *authProcessor) InsertStatement() string {
-	return authTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *authProcessor) DeleteStatement() string {
 	panic("auth processor never deletes")
 }
 
+func (b *authProcessor) HistoryStatement(t tracelistener.WritebackStatementTypes) string {
+	if t == tracelistener.Delete {
+		return b.table.DeleteHistory()
+	}
+
+	return b.table.InsertHistory()
+}
+
 func (b *authProcessor) FlushCache() []tracelistener.WritebackOp {
 	b.m.Lock()
 	defer b.m.Unlock()
@@ -84,6 +91,11 @@ func (b *authProcessor) Process(data tracelistener.TraceOperation) error {
 		return err
 	}
 
+	// keys which don't hold a user account, like module accounts, yield an empty row
+	if res.Address == "" {
+		return nil
+	}
+
 	b.heightCache[authCacheEntry{
 		address:   res.Address,
 		accNumber: res.AccountNumber,
@@ -99,6 +99,21 @@ func TestAuthProcess(t *testing.T) {
 			true,
 			0,
 		},
+		{
+			"key not holding an account - ignored",
+			authAccount{
+				Address:       "cosmos1xrnner9s783446yz3hhshpr5fpz6wzcwkvwv5j",
+				AccountNumber: 12,
+				Sequence:      11,
+			},
+			tracelistener.TraceOperation{
+				Operation:   string(tracelistener.WriteOp),
+				Key:         make([]byte, 300),
+				BlockHeight: 1,
+			},
+			false,
+			0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -12,8 +12,6 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
-var balancesTable = tables.NewBalancesTable("tracelistener.balances")
-
 type bankCacheEntry struct {
 	address string
 	denom   string
@@ -21,12 +19,13 @@ type bankCacheEntry struct {
 
 type bankProcessor struct {
 	l           *zap.SugaredLogger
+	table       tables.BalancesTable
 	heightCache map[bankCacheEntry]models.BalanceRow
 	m           sync.Mutex
 }
 
-func (*bankProcessor) Migrations() []string {
-	return []string{balancesTable.CreateTable()}
+func (b *bankProcessor) Table() Table {
+	return b.table
 }
 
 func (b *bankProcessor) ModuleName() string {
@@ -34,17 +33,25 @@ func (b *bankProcessor) ModuleName() string {
 }
 
 func (b *bankProcessor) UpsertStatement() string {
-	return balancesTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *bankProcessor) InsertStatement() string {
-	return balancesTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *bankProcessor) DeleteStatement() string {
 	panic("bank processor never deletes")
 }
 
+func (b *bankProcessor) HistoryStatement(t tracelistener.WritebackStatementTypes) string {
+	if t == tracelistener.Delete {
+		return b.table.DeleteHistory()
+	}
+
+	return b.table.InsertHistory()
+}
+
 func (b *bankProcessor) SDKModuleName() tracelistener.SDKModuleName {
 	return tracelistener.Bank
 }
@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"math/big"
+	"strings"
 	"sync"
 
 	"go.uber.org/zap"
@@ -10,8 +12,6 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
-var cw20BalanceTable = tables.NewCw20BalancesTable("tracelistener.cw20_balances")
-
 type cw20BalanceCacheEntry struct {
 	address         string
 	contractAddress string
@@ -19,12 +19,13 @@ type cw20BalanceCacheEntry struct {
 
 type cw20BalanceProcessor struct {
 	l           *zap.SugaredLogger
+	table       tables.Cw20BalancesTable
 	heightCache map[cw20BalanceCacheEntry]models.CW20BalanceRow
 	m           sync.Mutex
 }
 
-func (*cw20BalanceProcessor) Migrations() []string {
-	return []string{cw20BalanceTable.CreateTable()}
+func (b *cw20BalanceProcessor) Table() Table {
+	return b.table
 }
 
 func (b *cw20BalanceProcessor) ModuleName() string {
@@ -32,15 +33,15 @@ func (b *cw20BalanceProcessor) ModuleName() string {
 }
 
 func (b *cw20BalanceProcessor) UpsertStatement() string {
-	return cw20BalanceTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *cw20BalanceProcessor) InsertStatement() string {
-	return cw20BalanceTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *cw20BalanceProcessor) DeleteStatement() string {
-	return cw20BalanceTable.Delete()
+	return b.table.Delete()
 }
 
 func (b *cw20BalanceProcessor) SDKModuleName() tracelistener.SDKModuleName {
@@ -93,12 +94,25 @@ func (b *cw20BalanceProcessor) Process(data tracelistener.TraceOperation) error
 			ContractAddress: contractAddr,
 			Address:         holderAddr,
 			// balance trace value is the amount.
-			Amount: string(data.Value),
+			Amount:        string(data.Value),
+			AmountNumeric: cw20Amount(string(data.Value)),
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}
 	)
 	b.heightCache[key] = val
 	return nil
 }
+
+// cw20Amount returns the Uint128 amount s, which might be JSON-quoted, as a Numeric.
+// Amounts which cannot be parsed are stored as NULL.
+func cw20Amount(s string) models.Numeric {
+	i, ok := new(big.Int).SetString(strings.Trim(s, `"`), 10)
+	if !ok {
+		return ""
+	}
+
+	return models.Numeric(i.String())
+}
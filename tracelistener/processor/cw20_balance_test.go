@@ -36,6 +36,50 @@ func TestCW20BalanceProcessor(t *testing.T) {
 					ContractAddress: contractAddr,
 					Address:         holderAddr,
 					Amount:          "1000",
+					AmountNumeric:   "1000",
+					TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+						Height: 42,
+					},
+				},
+			},
+		},
+		{
+			name: "ok: quoted amount",
+			data: tracelistener.TraceOperation{
+				Key:         balanceKey,
+				Value:       []byte(`"1000"`),
+				BlockHeight: 42,
+			},
+			expectedHeightCache: map[cw20BalanceCacheEntry]models.CW20BalanceRow{
+				{
+					contractAddress: contractAddr,
+					address:         holderAddr,
+				}: {
+					ContractAddress: contractAddr,
+					Address:         holderAddr,
+					Amount:          `"1000"`,
+					AmountNumeric:   "1000",
+					TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+						Height: 42,
+					},
+				},
+			},
+		},
+		{
+			name: "ok: invalid amount",
+			data: tracelistener.TraceOperation{
+				Key:         balanceKey,
+				Value:       []byte("abc"),
+				BlockHeight: 42,
+			},
+			expectedHeightCache: map[cw20BalanceCacheEntry]models.CW20BalanceRow{
+				{
+					contractAddress: contractAddr,
+					address:         holderAddr,
+				}: {
+					ContractAddress: contractAddr,
+					Address:         holderAddr,
+					Amount:          "abc",
 					TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 						Height: 42,
 					},
@@ -12,20 +12,19 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
-var cw20TokenInfoTable = tables.NewCw20TokenInfoTable("tracelistener.cw20_token_infos")
-
 type cw20TokenInfoCacheEntry struct {
 	contractAddress string
 }
 
 type cw20TokenInfoProcessor struct {
 	l           *zap.SugaredLogger
+	table       tables.Cw20TokenInfosTable
 	heightCache map[cw20TokenInfoCacheEntry]models.CW20TokenInfoRow
 	m           sync.Mutex
 }
 
-func (*cw20TokenInfoProcessor) Migrations() []string {
-	return []string{cw20TokenInfoTable.CreateTable()}
+func (b *cw20TokenInfoProcessor) Table() Table {
+	return b.table
 }
 
 func (b *cw20TokenInfoProcessor) ModuleName() string {
@@ -33,15 +32,15 @@ func (b *cw20TokenInfoProcessor) ModuleName() string {
 }
 
 func (b *cw20TokenInfoProcessor) UpsertStatement() string {
-	return cw20TokenInfoTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *cw20TokenInfoProcessor) InsertStatement() string {
-	return cw20TokenInfoTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *cw20TokenInfoProcessor) DeleteStatement() string {
-	return cw20TokenInfoTable.Delete()
+	return b.table.Delete()
 }
 
 func (b *cw20TokenInfoProcessor) SDKModuleName() tracelistener.SDKModuleName {
@@ -93,6 +92,7 @@ func (b *cw20TokenInfoProcessor) Process(data tracelistener.TraceOperation) erro
 			ContractAddress: contractAddr,
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}
 	)
@@ -103,6 +103,7 @@ func (b *cw20TokenInfoProcessor) Process(data tracelistener.TraceOperation) erro
 	if err != nil {
 		return fmt.Errorf("unmarshal cw20 token_info value: %w", err)
 	}
+	val.TotalSupplyNumeric = cw20Amount(val.TotalSupply)
 	b.heightCache[key] = val
 	return nil
 }
@@ -37,11 +37,12 @@ func TestCW20TokenInfoProcessor(t *testing.T) {
 				{
 					contractAddress: contractAddr,
 				}: {
-					ContractAddress: contractAddr,
-					Name:            "meme",
-					Symbol:          "umeme",
-					Decimals:        18,
-					TotalSupply:     "169420",
+					ContractAddress:    contractAddr,
+					Name:               "meme",
+					Symbol:             "umeme",
+					Decimals:           18,
+					TotalSupply:        "169420",
+					TotalSupplyNumeric: "169420",
 					TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 						Height: 42,
 					},
@@ -34,6 +34,12 @@ type TestHandler interface {
 	UnbondingDelegation(u TestUnbondingDelegation) []byte
 }
 
+// StoreEntry is a key and its value, as stored by an SDK module.
+type StoreEntry struct {
+	Key   []byte
+	Value []byte
+}
+
 // Compile-time check! DataMarshaler must always implement Handler.
 // This won't compile if that assumption isn't true.
 var _ Handler = DataMarshaler{}
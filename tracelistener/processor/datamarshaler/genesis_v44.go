@@ -0,0 +1,231 @@
+//go:build sdk_v44
+
+package datamarshaler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	vestingTypes "github.com/cosmos/cosmos-sdk/x/auth/vesting/types"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	transferTypes "github.com/cosmos/ibc-go/v2/modules/apps/transfer/types"
+	clientTypes "github.com/cosmos/ibc-go/v2/modules/core/02-client/types"
+	ibcConnectionTypes "github.com/cosmos/ibc-go/v2/modules/core/03-connection/types"
+	channelTypes "github.com/cosmos/ibc-go/v2/modules/core/04-channel/types"
+	host "github.com/cosmos/ibc-go/v2/modules/core/24-host"
+	ibcTypes "github.com/cosmos/ibc-go/v2/modules/core/types"
+
+	"github.com/emerishq/tracelistener/tracelistener"
+)
+
+// GenesisEntries returns the store entries the app state of an exported genesis initializes
+// the supported SDK modules with, by SDK module.
+func GenesisEntries(appState map[string]json.RawMessage) (map[tracelistener.SDKModuleName][]StoreEntry, error) {
+	res := map[tracelistener.SDKModuleName][]StoreEntry{}
+	cdc := getCodec()
+
+	if raw, ok := appState["bank"]; ok {
+		var gs bankTypes.GenesisState
+		if err := cdc.UnmarshalJSON(raw, &gs); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal bank genesis, %w", err)
+		}
+
+		for _, b := range gs.Balances {
+			addr, err := genesisAddress(b.Address)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, c := range b.Coins {
+				// the bank module doesn't store zero balances
+				if c.IsZero() {
+					continue
+				}
+
+				c := c
+				res[tracelistener.Bank] = append(res[tracelistener.Bank], StoreEntry{
+					Key:   append(bankTypes.CreateAccountBalancesPrefix(addr), []byte(c.Denom)...),
+					Value: cdc.MustMarshal(&c),
+				})
+			}
+		}
+	}
+
+	if raw, ok := appState["auth"]; ok {
+		var gs authTypes.GenesisState
+		if err := cdc.UnmarshalJSON(raw, &gs); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal auth genesis, %w", err)
+		}
+
+		accounts, err := authTypes.UnpackAccounts(gs.Accounts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack genesis accounts, %w", err)
+		}
+
+		for _, acc := range accounts {
+			addr, err := genesisAddress(accountAddress(acc))
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := cdc.MarshalInterface(acc)
+			if err != nil {
+				return nil, fmt.Errorf("cannot marshal genesis account, %w", err)
+			}
+
+			res[tracelistener.Acc] = append(res[tracelistener.Acc], StoreEntry{
+				Key:   authTypes.AddressStoreKey(addr),
+				Value: value,
+			})
+		}
+	}
+
+	if raw, ok := appState["staking"]; ok {
+		var gs stakingTypes.GenesisState
+		if err := cdc.UnmarshalJSON(raw, &gs); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal staking genesis, %w", err)
+		}
+
+		for _, v := range gs.Validators {
+			val, err := genesisAddress(v.OperatorAddress)
+			if err != nil {
+				return nil, err
+			}
+
+			v := v
+			res[tracelistener.Staking] = append(res[tracelistener.Staking], StoreEntry{
+				Key:   stakingTypes.GetValidatorKey(sdk.ValAddress(val)),
+				Value: cdc.MustMarshal(&v),
+			})
+		}
+
+		for _, d := range gs.Delegations {
+			del, val, err := genesisAddresses(d.DelegatorAddress, d.ValidatorAddress)
+			if err != nil {
+				return nil, err
+			}
+
+			d := d
+			res[tracelistener.Staking] = append(res[tracelistener.Staking], StoreEntry{
+				Key:   stakingTypes.GetDelegationKey(del, val),
+				Value: cdc.MustMarshal(&d),
+			})
+		}
+
+		for _, u := range gs.UnbondingDelegations {
+			del, val, err := genesisAddresses(u.DelegatorAddress, u.ValidatorAddress)
+			if err != nil {
+				return nil, err
+			}
+
+			u := u
+			res[tracelistener.Staking] = append(res[tracelistener.Staking], StoreEntry{
+				Key:   stakingTypes.GetUBDKey(del, val),
+				Value: cdc.MustMarshal(&u),
+			})
+		}
+	}
+
+	if raw, ok := appState["ibc"]; ok {
+		var gs ibcTypes.GenesisState
+		if err := cdc.UnmarshalJSON(raw, &gs); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal ibc genesis, %w", err)
+		}
+
+		for _, c := range gs.ClientGenesis.Clients {
+			cs, err := clientTypes.UnpackClientState(c.ClientState)
+			if err != nil {
+				return nil, fmt.Errorf("cannot unpack state of client %s, %w", c.ClientId, err)
+			}
+
+			res[tracelistener.IBC] = append(res[tracelistener.IBC], StoreEntry{
+				Key:   host.FullClientStateKey(c.ClientId),
+				Value: clientTypes.MustMarshalClientState(cdc, cs),
+			})
+		}
+
+		for _, c := range gs.ConnectionGenesis.Connections {
+			conn := ibcConnectionTypes.NewConnectionEnd(c.State, c.ClientId, c.Counterparty, c.Versions, c.DelayPeriod)
+			res[tracelistener.IBC] = append(res[tracelistener.IBC], StoreEntry{
+				Key:   host.ConnectionKey(c.Id),
+				Value: cdc.MustMarshal(&conn),
+			})
+		}
+
+		for _, c := range gs.ChannelGenesis.Channels {
+			ch := channelTypes.NewChannel(c.State, c.Ordering, c.Counterparty, c.ConnectionHops, c.Version)
+			res[tracelistener.IBC] = append(res[tracelistener.IBC], StoreEntry{
+				Key:   host.ChannelKey(c.PortId, c.ChannelId),
+				Value: cdc.MustMarshal(&ch),
+			})
+		}
+	}
+
+	if raw, ok := appState["transfer"]; ok {
+		var gs transferTypes.GenesisState
+		if err := cdc.UnmarshalJSON(raw, &gs); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal transfer genesis, %w", err)
+		}
+
+		for _, t := range gs.DenomTraces {
+			t := t
+			res[tracelistener.Transfer] = append(res[tracelistener.Transfer], StoreEntry{
+				Key:   append(append([]byte{}, transferTypes.DenomTraceKey...), t.Hash()...),
+				Value: cdc.MustMarshal(&t),
+			})
+		}
+	}
+
+	return res, nil
+}
+
+// accountAddress returns the bech32 address of acc.
+// Genesis accounts are read without relying on the global bech32 prefixes, which
+// are the Cosmos Hub ones whatever the chain.
+func accountAddress(acc authTypes.GenesisAccount) string {
+	switch a := acc.(type) {
+	case *authTypes.BaseAccount:
+		return a.Address
+	case *authTypes.ModuleAccount:
+		return a.Address
+	case *vestingTypes.ContinuousVestingAccount:
+		return a.Address
+	case *vestingTypes.DelayedVestingAccount:
+		return a.Address
+	case *vestingTypes.PeriodicVestingAccount:
+		return a.Address
+	case *vestingTypes.PermanentLockedAccount:
+		return a.Address
+	}
+
+	return acc.GetAddress().String()
+}
+
+// genesisAddress returns the bytes of the bech32 address addr, whatever its prefix.
+func genesisAddress(addr string) (sdk.AccAddress, error) {
+	_, bz, err := bech32.DecodeAndConvert(addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode genesis address %s, %w", addr, err)
+	}
+
+	return bz, nil
+}
+
+// genesisAddresses returns the bytes of the bech32 addresses of a delegator and a validator.
+func genesisAddresses(delegator, validator string) (sdk.AccAddress, sdk.ValAddress, error) {
+	del, err := genesisAddress(delegator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	val, err := genesisAddress(validator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return del, sdk.ValAddress(val), nil
+}
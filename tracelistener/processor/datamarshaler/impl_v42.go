@@ -68,11 +68,13 @@ func (d DataMarshaler) Bank(data tracelistener.TraceOperation) (models.BalanceRo
 		"txHash", data.TxHash,
 	)
 	return models.BalanceRow{
-		Address: hAddr,
-		Amount:  coins.String(),
-		Denom:   coins.Denom,
+		Address:       hAddr,
+		Amount:        coins.String(),
+		AmountNumeric: models.Numeric(coins.Amount.String()),
+		Denom:         coins.Denom,
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -141,6 +143,7 @@ func (d DataMarshaler) Auth(data tracelistener.TraceOperation) (models.AuthRow,
 		AccountNumber:  acc.GetAccountNumber(),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -159,6 +162,7 @@ func (d DataMarshaler) Delegations(data tracelistener.TraceOperation) (models.De
 			Validator: validatorAddr,
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}, nil
 	}
@@ -189,11 +193,13 @@ func (d DataMarshaler) Delegations(data tracelistener.TraceOperation) (models.De
 	)
 
 	return models.DelegationRow{
-		Delegator: delegator,
-		Validator: validator,
-		Amount:    delegation.Shares.String(),
+		Delegator:     delegator,
+		Validator:     validator,
+		Amount:        delegation.Shares.String(),
+		AmountNumeric: models.Numeric(delegation.Shares.String()),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -229,6 +235,7 @@ func (d DataMarshaler) IBCChannels(data tracelistener.TraceOperation) (models.IB
 		State:            int32(result.State),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -262,6 +269,7 @@ func (d DataMarshaler) IBCClients(data tracelistener.TraceOperation) (models.IBC
 		TrustingPeriod: int64(dest.TrustingPeriod),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -295,6 +303,7 @@ func (d DataMarshaler) IBCConnections(data tracelistener.TraceOperation) (models
 				CounterClientID:     ce.Counterparty.ClientId,
 				TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 					Height: data.BlockHeight,
+					TxHash: models.NullString(data.TxHash),
 				},
 			}, nil
 		}
@@ -329,6 +338,7 @@ func (d DataMarshaler) IBCDenomTraces(data tracelistener.TraceOperation) (models
 		Hash:      hash,
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}
 
@@ -351,6 +361,7 @@ func (d DataMarshaler) UnbondingDelegations(data tracelistener.TraceOperation) (
 			Validator: validatorAddr,
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}, nil
 	}
@@ -399,6 +410,7 @@ func (d DataMarshaler) UnbondingDelegations(data tracelistener.TraceOperation) (
 		Entries:   entriesStore,
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, err
 }
@@ -416,6 +428,7 @@ func (d DataMarshaler) Validators(data tracelistener.TraceOperation) (models.Val
 			OperatorAddress: operatorAddress,
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}, nil
 
@@ -447,28 +460,34 @@ func (d DataMarshaler) Validators(data tracelistener.TraceOperation) (models.Val
 	)
 
 	return models.ValidatorRow{
-		ValidatorAddress:     valAddress,
-		OperatorAddress:      v.OperatorAddress,
-		ConsensusPubKeyType:  v.ConsensusPubkey.GetTypeUrl(),
-		ConsensusPubKeyValue: v.ConsensusPubkey.Value,
-		Jailed:               v.Jailed,
-		Status:               int32(v.Status),
-		Tokens:               v.Tokens.String(),
-		DelegatorShares:      v.DelegatorShares.String(),
-		Moniker:              v.Description.Moniker,
-		Identity:             v.Description.Identity,
-		Website:              v.Description.Website,
-		SecurityContact:      v.Description.SecurityContact,
-		Details:              v.Description.Details,
-		UnbondingHeight:      v.UnbondingHeight,
-		UnbondingTime:        v.UnbondingTime.String(),
-		CommissionRate:       v.Commission.CommissionRates.Rate.String(),
-		MaxRate:              v.Commission.CommissionRates.MaxRate.String(),
-		MaxChangeRate:        v.Commission.CommissionRates.MaxChangeRate.String(),
-		UpdateTime:           v.Commission.UpdateTime.String(),
-		MinSelfDelegation:    v.MinSelfDelegation.String(),
+		ValidatorAddress:       valAddress,
+		OperatorAddress:        v.OperatorAddress,
+		ConsensusPubKeyType:    v.ConsensusPubkey.GetTypeUrl(),
+		ConsensusPubKeyValue:   v.ConsensusPubkey.Value,
+		Jailed:                 v.Jailed,
+		Status:                 int32(v.Status),
+		Tokens:                 v.Tokens.String(),
+		TokensNumeric:          models.Numeric(v.Tokens.String()),
+		DelegatorShares:        v.DelegatorShares.String(),
+		DelegatorSharesNumeric: models.Numeric(v.DelegatorShares.String()),
+		Moniker:                v.Description.Moniker,
+		Identity:               v.Description.Identity,
+		Website:                v.Description.Website,
+		SecurityContact:        v.Description.SecurityContact,
+		Details:                v.Description.Details,
+		UnbondingHeight:        v.UnbondingHeight,
+		UnbondingTime:          v.UnbondingTime.String(),
+		CommissionRate:         v.Commission.CommissionRates.Rate.String(),
+		CommissionRateNumeric:  models.Numeric(v.Commission.CommissionRates.Rate.String()),
+		MaxRate:                v.Commission.CommissionRates.MaxRate.String(),
+		MaxRateNumeric:         models.Numeric(v.Commission.CommissionRates.MaxRate.String()),
+		MaxChangeRate:          v.Commission.CommissionRates.MaxChangeRate.String(),
+		MaxChangeRateNumeric:   models.Numeric(v.Commission.CommissionRates.MaxChangeRate.String()),
+		UpdateTime:             v.Commission.UpdateTime.String(),
+		MinSelfDelegation:      v.MinSelfDelegation.String(),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
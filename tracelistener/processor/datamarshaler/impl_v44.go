@@ -99,11 +99,13 @@ func (d DataMarshaler) Bank(data tracelistener.TraceOperation) (models.BalanceRo
 	)
 
 	return models.BalanceRow{
-		Address: hAddr,
-		Amount:  coins.String(),
-		Denom:   coins.Denom,
+		Address:       hAddr,
+		Amount:        coins.String(),
+		AmountNumeric: models.Numeric(coins.Amount.String()),
+		Denom:         coins.Denom,
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -173,6 +175,7 @@ func (d DataMarshaler) Auth(data tracelistener.TraceOperation) (models.AuthRow,
 		AccountNumber:  acc.GetAccountNumber(),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -203,6 +206,7 @@ func (d DataMarshaler) Delegations(data tracelistener.TraceOperation) (models.De
 			Validator: validator,
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}, nil
 	}
@@ -233,11 +237,13 @@ func (d DataMarshaler) Delegations(data tracelistener.TraceOperation) (models.De
 	)
 
 	return models.DelegationRow{
-		Delegator: delegator,
-		Validator: validator,
-		Amount:    delegation.Shares.String(),
+		Delegator:     delegator,
+		Validator:     validator,
+		Amount:        delegation.Shares.String(),
+		AmountNumeric: models.Numeric(delegation.Shares.String()),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -273,6 +279,7 @@ func (d DataMarshaler) IBCChannels(data tracelistener.TraceOperation) (models.IB
 		State:            int32(result.State),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -306,6 +313,7 @@ func (d DataMarshaler) IBCClients(data tracelistener.TraceOperation) (models.IBC
 		TrustingPeriod: int64(dest.TrustingPeriod),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
@@ -348,6 +356,7 @@ func (d DataMarshaler) IBCConnections(data tracelistener.TraceOperation) (models
 				CounterClientID:     ce.Counterparty.ClientId,
 				TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 					Height: data.BlockHeight,
+					TxHash: models.NullString(data.TxHash),
 				},
 			}, nil
 		}
@@ -382,6 +391,7 @@ func (d DataMarshaler) IBCDenomTraces(data tracelistener.TraceOperation) (models
 		Hash:      hash,
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}
 
@@ -417,6 +427,7 @@ func (d DataMarshaler) UnbondingDelegations(data tracelistener.TraceOperation) (
 			Validator: validatorAddr,
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}, nil
 	}
@@ -465,6 +476,7 @@ func (d DataMarshaler) UnbondingDelegations(data tracelistener.TraceOperation) (
 		Entries:   entriesStore,
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, err
 }
@@ -484,6 +496,7 @@ func (d DataMarshaler) Validators(data tracelistener.TraceOperation) (models.Val
 			OperatorAddress: operatorAddress,
 			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 				Height: data.BlockHeight,
+				TxHash: models.NullString(data.TxHash),
 			},
 		}, nil
 
@@ -515,28 +528,34 @@ func (d DataMarshaler) Validators(data tracelistener.TraceOperation) (models.Val
 	)
 
 	return models.ValidatorRow{
-		ValidatorAddress:     valAddress,
-		OperatorAddress:      v.OperatorAddress,
-		ConsensusPubKeyType:  v.ConsensusPubkey.GetTypeUrl(),
-		ConsensusPubKeyValue: v.ConsensusPubkey.Value,
-		Jailed:               v.Jailed,
-		Status:               int32(v.Status),
-		Tokens:               v.Tokens.String(),
-		DelegatorShares:      v.DelegatorShares.String(),
-		Moniker:              v.Description.Moniker,
-		Identity:             v.Description.Identity,
-		Website:              v.Description.Website,
-		SecurityContact:      v.Description.SecurityContact,
-		Details:              v.Description.Details,
-		UnbondingHeight:      v.UnbondingHeight,
-		UnbondingTime:        v.UnbondingTime.String(),
-		CommissionRate:       v.Commission.CommissionRates.Rate.String(),
-		MaxRate:              v.Commission.CommissionRates.MaxRate.String(),
-		MaxChangeRate:        v.Commission.CommissionRates.MaxChangeRate.String(),
-		UpdateTime:           v.Commission.UpdateTime.String(),
-		MinSelfDelegation:    v.MinSelfDelegation.String(),
+		ValidatorAddress:       valAddress,
+		OperatorAddress:        v.OperatorAddress,
+		ConsensusPubKeyType:    v.ConsensusPubkey.GetTypeUrl(),
+		ConsensusPubKeyValue:   v.ConsensusPubkey.Value,
+		Jailed:                 v.Jailed,
+		Status:                 int32(v.Status),
+		Tokens:                 v.Tokens.String(),
+		TokensNumeric:          models.Numeric(v.Tokens.String()),
+		DelegatorShares:        v.DelegatorShares.String(),
+		DelegatorSharesNumeric: models.Numeric(v.DelegatorShares.String()),
+		Moniker:                v.Description.Moniker,
+		Identity:               v.Description.Identity,
+		Website:                v.Description.Website,
+		SecurityContact:        v.Description.SecurityContact,
+		Details:                v.Description.Details,
+		UnbondingHeight:        v.UnbondingHeight,
+		UnbondingTime:          v.UnbondingTime.String(),
+		CommissionRate:         v.Commission.CommissionRates.Rate.String(),
+		CommissionRateNumeric:  models.Numeric(v.Commission.CommissionRates.Rate.String()),
+		MaxRate:                v.Commission.CommissionRates.MaxRate.String(),
+		MaxRateNumeric:         models.Numeric(v.Commission.CommissionRates.MaxRate.String()),
+		MaxChangeRate:          v.Commission.CommissionRates.MaxChangeRate.String(),
+		MaxChangeRateNumeric:   models.Numeric(v.Commission.CommissionRates.MaxChangeRate.String()),
+		UpdateTime:             v.Commission.UpdateTime.String(),
+		MinSelfDelegation:      v.MinSelfDelegation.String(),
 		TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
 			Height: data.BlockHeight,
+			TxHash: models.NullString(data.TxHash),
 		},
 	}, nil
 }
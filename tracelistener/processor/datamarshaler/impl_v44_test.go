@@ -69,8 +69,9 @@ func TestDataMarshalerBank(t *testing.T) {
 				Key:       append(types.BalancesPrefix, []byte{3, 'a', 'd', 'd'}...),
 			},
 			expectedBalanceRow: models.BalanceRow{
-				Address: "616464",
-				Amount:  "0",
+				Address:       "616464",
+				Amount:        "0",
+				AmountNumeric: "0",
 			},
 		},
 		{
@@ -81,9 +82,10 @@ func TestDataMarshalerBank(t *testing.T) {
 				Value:     coinBz,
 			},
 			expectedBalanceRow: models.BalanceRow{
-				Address: "616464",
-				Amount:  "100uatom",
-				Denom:   "uatom",
+				Address:       "616464",
+				Amount:        "100uatom",
+				AmountNumeric: "100",
+				Denom:         "uatom",
 			},
 		},
 	}
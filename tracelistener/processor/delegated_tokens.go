@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+)
+
+// Delegations hold shares, their delegated_tokens column converts them to tokens at the exchange
+// rate of their validator, tokens / delegator_shares.
+// It is recomputed after every flush for the delegations just written, and for all the delegations
+// of the validators whose tokens or shares changed, which is what slashing does.
+
+const (
+	delegatedTokensWhere = `
+	WHERE d.chain_name = :chain_name AND d.validator_address = :validator_address AND d.delete_height IS NULL
+	AND v.chain_name = d.chain_name AND v.validator_address = d.validator_address AND v.delete_height IS NULL`
+
+	delegationDelegatedTokensWhere = delegatedTokensWhere + `
+	AND d.delegator_address = :delegator_address`
+
+	// clearDelegatedTokens runs after a delegation has been deleted, so that it's recomputed
+	// from scratch if it comes back.
+	clearDelegatedTokens = `
+	UPDATE %s SET delegated_tokens = NULL
+	WHERE chain_name = :chain_name AND delegator_address = :delegator_address AND validator_address = :validator_address
+	AND delete_height IS NOT NULL`
+)
+
+// delegatedTokensStmts holds the statements recomputing delegated_tokens, see moduleTables.
+type delegatedTokensStmts struct {
+	validator  string
+	delegation string
+	clear      string
+}
+
+func newDelegatedTokensStatements(d database.Dialect, t moduleTables) delegatedTokensStmts {
+	return delegatedTokensStmts{
+		validator:  tables.RecomputeDelegatedTokens(d, t.delegations.Name(), t.validators.Name(), delegatedTokensWhere),
+		delegation: tables.RecomputeDelegatedTokens(d, t.delegations.Name(), t.validators.Name(), delegationDelegatedTokensWhere),
+		clear:      fmt.Sprintf(clearDelegatedTokens, t.delegations.Name()),
+	}
+}
+
+// delegatedTokens keeps the tokens and shares of the validators seen so far, to only recompute
+// all of a validator delegations when they change.
+// They're cached at flush, before the block is written: invalidate forgets them when it can't be.
+type delegatedTokens struct {
+	stmts          delegatedTokensStmts
+	aggregateStmts aggregateStmts
+
+	mu     sync.Mutex
+	stakes map[string]validatorStake
+}
+
+// validatorStake holds the values the exchange rate of a validator is computed from.
+type validatorStake struct {
+	tokens string
+	shares string
+}
+
+// stakingRows holds the staking rows a flush writes or deletes, which derived values depend on.
+type stakingRows struct {
+	delegations        []models.DatabaseEntrier
+	deletedDelegations []models.DatabaseEntrier
+	validators         []models.DatabaseEntrier
+	deletedValidators  []models.DatabaseEntrier
+}
+
+// ops returns the WritebackOps recomputing delegated_tokens once rows have been written,
+// preceded by the ones updating delegator_totals accordingly if totals is true.
+// Validators are recomputed at once, a delegation is only recomputed on its own when its
+// validator isn't.
+func (dt *delegatedTokens) ops(rows stakingRows, totals bool) []tracelistener.WritebackOp {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if dt.stakes == nil {
+		dt.stakes = map[string]validatorStake{}
+	}
+
+	var res []tracelistener.WritebackOp
+	add := func(row models.DatabaseEntrier, totalsStmt, stmt string) {
+		if totals {
+			res = append(res, tracelistener.WritebackOp{
+				Type:         tracelistener.Write,
+				Data:         []models.DatabaseEntrier{row},
+				Statement:    totalsStmt,
+				SourceModule: tracelistener.Staking.String(),
+			})
+		}
+
+		res = append(res, tracelistener.WritebackOp{
+			Type:         tracelistener.Write,
+			Data:         []models.DatabaseEntrier{row},
+			Statement:    stmt,
+			SourceModule: tracelistener.Staking.String(),
+		})
+	}
+
+	recomputed := map[string]struct{}{}
+	for _, d := range rows.validators {
+		v, ok := d.(models.ValidatorRow)
+		if !ok {
+			continue
+		}
+
+		stake := validatorStake{tokens: v.Tokens, shares: v.DelegatorShares}
+		if prev, ok := dt.stakes[v.ValidatorAddress]; ok && prev == stake {
+			continue
+		}
+
+		dt.stakes[v.ValidatorAddress] = stake
+		recomputed[v.ValidatorAddress] = struct{}{}
+		add(v, dt.aggregateStmts.validatorTotals, dt.stmts.validator)
+	}
+
+	for _, d := range rows.delegations {
+		del, ok := d.(models.DelegationRow)
+		if !ok {
+			continue
+		}
+
+		if _, ok := recomputed[del.Validator]; ok {
+			continue
+		}
+
+		add(del, dt.aggregateStmts.delegationTotals, dt.stmts.delegation)
+	}
+
+	for _, d := range rows.deletedDelegations {
+		add(d, dt.aggregateStmts.subtractTotals, dt.stmts.clear)
+	}
+
+	for _, d := range rows.deletedValidators {
+		if v, ok := d.(models.ValidatorRow); ok {
+			delete(dt.stakes, v.ValidatorAddress)
+		}
+	}
+
+	return res
+}
+
+// invalidate forgets the validators seen so far, so that the next flush writing them
+// recomputes all their delegations.
+func (dt *delegatedTokens) invalidate() {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	dt.stakes = nil
+}
@@ -0,0 +1,160 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	tldatabase "github.com/emerishq/tracelistener/tracelistener/database"
+)
+
+func TestDelegatedTokens_SQLite(t *testing.T) {
+	i, err := database.NewWithDialect(filepath.Join(t.TempDir(), "tracelistener.db"), database.DialectSQLite)
+	require.NoError(t, err)
+	require.NoError(t, i.CreateSchema(tldatabase.DefaultSchema))
+
+	mt := newModuleTables(tldatabase.DefaultSchema, database.DialectSQLite)
+
+	for _, stmt := range append(mt.delegations.Schema(), mt.validators.Schema()...) {
+		_, err := i.DB.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	validator := func(height uint64, tokens, shares string) models.DatabaseEntrier {
+		return models.ValidatorRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: height},
+			ValidatorAddress:         "validator",
+			OperatorAddress:          "operator",
+			Tokens:                   tokens,
+			TokensNumeric:            models.Numeric(tokens),
+			DelegatorShares:          shares,
+			DelegatorSharesNumeric:   models.Numeric(shares),
+		}
+	}
+	delegation := func(height uint64, delegator, shares string) models.DatabaseEntrier {
+		return models.DelegationRow{
+			TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{ChainName: "chain", Height: height},
+			Delegator:                delegator,
+			Validator:                "validator",
+			Amount:                   shares,
+			AmountNumeric:            models.Numeric(shares),
+		}
+	}
+
+	dt := delegatedTokens{stmts: mt.delegatedTokens, aggregateStmts: mt.aggregates}
+
+	tests := []struct {
+		name        string
+		delegations []models.DatabaseEntrier
+		validators  []models.DatabaseEntrier
+		expectedOps int
+		expected    map[string]string
+	}{
+		{
+			"new validator recomputes all its delegations",
+			[]models.DatabaseEntrier{delegation(1, "a", "100"), delegation(1, "b", "200")},
+			[]models.DatabaseEntrier{validator(1, "1000", "1000")},
+			1,
+			map[string]string{"a": "100.000000000000000000", "b": "200.000000000000000000"},
+		},
+		{
+			"slashing recomputes all delegations",
+			nil,
+			[]models.DatabaseEntrier{validator(2, "900", "1000")},
+			1,
+			map[string]string{"a": "90.000000000000000000", "b": "180.000000000000000000"},
+		},
+		{
+			"delegating recomputes all delegations",
+			[]models.DatabaseEntrier{delegation(3, "c", "50")},
+			[]models.DatabaseEntrier{validator(3, "945", "1050")},
+			1,
+			map[string]string{"a": "90.000000000000000000", "b": "180.000000000000000000", "c": "45.000000000000000000"},
+		},
+		{
+			"dust left by delegating recomputes all delegations",
+			nil,
+			[]models.DatabaseEntrier{validator(4, "945.000000000000000001", "1050")},
+			1,
+			map[string]string{"a": "90.000000000000000000", "b": "180.000000000000000000", "c": "45.000000000000000000"},
+		},
+		{
+			"unchanged validator doesn't recompute delegations",
+			nil,
+			[]models.DatabaseEntrier{validator(5, "945.000000000000000001", "1050")},
+			0,
+			map[string]string{"a": "90.000000000000000000", "b": "180.000000000000000000", "c": "45.000000000000000000"},
+		},
+		{
+			"nothing written",
+			nil,
+			nil,
+			0,
+			map[string]string{"a": "90.000000000000000000", "b": "180.000000000000000000", "c": "45.000000000000000000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, d := range tt.delegations {
+				_, err := i.DB.NamedExec(mt.delegations.Upsert(), d)
+				require.NoError(t, err)
+			}
+
+			for _, v := range tt.validators {
+				_, err := i.DB.NamedExec(mt.validators.Upsert(), v)
+				require.NoError(t, err)
+			}
+
+			ops := dt.ops(stakingRows{delegations: tt.delegations, validators: tt.validators}, false)
+			require.Len(t, ops, tt.expectedOps)
+
+			for _, op := range ops {
+				require.Equal(t, tracelistener.Write, op.Type)
+				for _, d := range op.Data {
+					_, err := i.DB.NamedExec(op.Statement, d)
+					require.NoError(t, err)
+				}
+			}
+
+			var rows []models.DelegationRow
+			require.NoError(t, i.DB.Select(&rows, `SELECT delegator_address, delegated_tokens FROM tracelistener.delegations`))
+
+			res := map[string]string{}
+			for _, r := range rows {
+				res[r.Delegator] = string(r.DelegatedTokens)
+			}
+			require.Equal(t, tt.expected, res)
+		})
+	}
+}
+
+func TestDelegatedTokens_DeletedValidator(t *testing.T) {
+	v := models.ValidatorRow{ValidatorAddress: "validator", Tokens: "1000", DelegatorShares: "1000"}
+
+	dt := delegatedTokens{}
+	written := stakingRows{validators: []models.DatabaseEntrier{v}}
+	require.Len(t, dt.ops(written, false), 1)
+	require.Len(t, dt.ops(written, false), 0)
+
+	// a validator coming back after being deleted has its delegations recomputed
+	require.Len(t, dt.ops(stakingRows{deletedValidators: []models.DatabaseEntrier{v}}, false), 0)
+	require.Len(t, dt.ops(written, false), 1)
+}
+
+func TestDelegatedTokens_Invalidate(t *testing.T) {
+	v := models.ValidatorRow{ValidatorAddress: "validator", Tokens: "1000", DelegatorShares: "1000"}
+
+	dt := delegatedTokens{}
+	written := stakingRows{validators: []models.DatabaseEntrier{v}}
+	require.Len(t, dt.ops(written, false), 1)
+	require.Len(t, dt.ops(written, false), 0)
+
+	// the block caching the validator couldn't be written, its delegations are recomputed again
+	dt.invalidate()
+	require.Len(t, dt.ops(written, false), 1)
+}
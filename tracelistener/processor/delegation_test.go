@@ -262,7 +262,7 @@ func Test_Upsert_After_Soft_Delete_Restore_Row(t *testing.T) {
 
 	// Create a delegation
 	_, err = db.Instance.DB.NamedExec(
-		delegationsTable.Upsert(),
+		testTables.delegations.Upsert(),
 		row,
 	)
 	requireT.NoError(err)
@@ -270,7 +270,7 @@ func Test_Upsert_After_Soft_Delete_Restore_Row(t *testing.T) {
 	// Delete that delegation
 	row.Height = 2
 	_, err = db.Instance.DB.NamedExec(
-		delegationsTable.Delete(),
+		testTables.delegations.Delete(),
 		row,
 	)
 	requireT.NoError(err)
@@ -279,7 +279,7 @@ func Test_Upsert_After_Soft_Delete_Restore_Row(t *testing.T) {
 	row.Amount = "42stake"
 	row.Height = 3
 	_, err = db.Instance.DB.NamedExec(
-		delegationsTable.Upsert(),
+		testTables.delegations.Upsert(),
 		row,
 	)
 	requireT.NoError(err)
@@ -316,7 +316,7 @@ func prepareDelegationDatabase(t *testing.T) (*database.Instance, error) {
 	// 	return nil, err
 	// }
 
-	_, err = di.Instance.DB.Exec(delegationsTable.CreateTable())
+	_, err = di.Instance.DB.Exec(testTables.delegations.CreateTable())
 	if err != nil {
 		return nil, err
 	}
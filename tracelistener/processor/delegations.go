@@ -12,8 +12,6 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener"
 )
 
-var delegationsTable = tables.NewDelegationsTable("tracelistener.delegations")
-
 type delegationCacheEntry struct {
 	delegator string
 	validator string
@@ -21,13 +19,14 @@ type delegationCacheEntry struct {
 
 type delegationsProcessor struct {
 	l                 *zap.SugaredLogger
+	table             tables.DelegationsTable
 	insertHeightCache map[delegationCacheEntry]models.DelegationRow
 	deleteHeightCache map[delegationCacheEntry]models.DelegationRow
 	m                 sync.Mutex
 }
 
-func (*delegationsProcessor) Migrations() []string {
-	return []string{delegationsTable.CreateTable()}
+func (b *delegationsProcessor) Table() Table {
+	return b.table
 }
 
 func (b *delegationsProcessor) ModuleName() string {
@@ -39,15 +38,23 @@ func (b *delegationsProcessor) SDKModuleName() tracelistener.SDKModuleName {
 }
 
 func (b *delegationsProcessor) UpsertStatement() string {
-	return delegationsTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *delegationsProcessor) InsertStatement() string {
-	return delegationsTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *delegationsProcessor) DeleteStatement() string {
-	return delegationsTable.Delete()
+	return b.table.Delete()
+}
+
+func (b *delegationsProcessor) HistoryStatement(t tracelistener.WritebackStatementTypes) string {
+	if t == tracelistener.Delete {
+		return b.table.DeleteHistory()
+	}
+
+	return b.table.InsertHistory()
 }
 
 func (b *delegationsProcessor) FlushCache() []tracelistener.WritebackOp {
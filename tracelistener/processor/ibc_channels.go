@@ -13,8 +13,6 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
-var channelsTable = tables.NewChannelsTable("tracelistener.channels")
-
 type channelCacheEntry struct {
 	channelID string
 	portID    string
@@ -22,12 +20,13 @@ type channelCacheEntry struct {
 
 type ibcChannelsProcessor struct {
 	l             *zap.SugaredLogger
+	table         tables.ChannelsTable
 	channelsCache map[channelCacheEntry]models.IBCChannelRow
 	m             sync.Mutex
 }
 
-func (*ibcChannelsProcessor) Migrations() []string {
-	return []string{channelsTable.CreateTable()}
+func (b *ibcChannelsProcessor) Table() Table {
+	return b.table
 }
 
 func (b *ibcChannelsProcessor) ModuleName() string {
@@ -39,11 +38,11 @@ func (b *ibcChannelsProcessor) SDKModuleName() tracelistener.SDKModuleName {
 }
 
 func (b *ibcChannelsProcessor) UpsertStatement() string {
-	return channelsTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *ibcChannelsProcessor) InsertStatement() string {
-	return channelsTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *ibcChannelsProcessor) DeleteStatement() string {
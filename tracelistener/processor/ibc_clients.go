@@ -13,8 +13,6 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
-var clientsTable = tables.NewClientsTable("tracelistener.clients")
-
 type clientCacheEntry struct {
 	chainID  string
 	clientID string
@@ -22,12 +20,13 @@ type clientCacheEntry struct {
 
 type ibcClientsProcessor struct {
 	l            *zap.SugaredLogger
+	table        tables.ClientsTable
 	clientsCache map[clientCacheEntry]models.IBCClientStateRow
 	m            sync.Mutex
 }
 
-func (*ibcClientsProcessor) Migrations() []string {
-	return []string{clientsTable.CreateTable()}
+func (b *ibcClientsProcessor) Table() Table {
+	return b.table
 }
 
 func (b *ibcClientsProcessor) ModuleName() string {
@@ -39,11 +38,11 @@ func (b *ibcClientsProcessor) SDKModuleName() tracelistener.SDKModuleName {
 }
 
 func (b *ibcClientsProcessor) UpsertStatement() string {
-	return clientsTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *ibcClientsProcessor) InsertStatement() string {
-	return clientsTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *ibcClientsProcessor) DeleteStatement() string {
@@ -13,8 +13,6 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener/tables"
 )
 
-var connectionsTable = tables.NewConnectionsTable("tracelistener.connections")
-
 type connectionCacheEntry struct {
 	connectionID string
 	clientID     string
@@ -26,12 +24,13 @@ var ibcObservedKeys = [][]byte{
 
 type ibcConnectionsProcessor struct {
 	l                *zap.SugaredLogger
+	table            tables.ConnectionsTable
 	connectionsCache map[connectionCacheEntry]models.IBCConnectionRow
 	m                sync.Mutex
 }
 
-func (*ibcConnectionsProcessor) Migrations() []string {
-	return []string{connectionsTable.CreateTable()}
+func (b *ibcConnectionsProcessor) Table() Table {
+	return b.table
 }
 
 func (b *ibcConnectionsProcessor) ModuleName() string {
@@ -43,11 +42,11 @@ func (b *ibcConnectionsProcessor) SDKModuleName() tracelistener.SDKModuleName {
 }
 
 func (b *ibcConnectionsProcessor) UpsertStatement() string {
-	return connectionsTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *ibcConnectionsProcessor) InsertStatement() string {
-	return connectionsTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *ibcConnectionsProcessor) DeleteStatement() string {
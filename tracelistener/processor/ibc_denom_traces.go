@@ -12,23 +12,15 @@ import (
 	"go.uber.org/zap"
 )
 
-var denomTracesTable = tables.NewDenomTracesTable("tracelistener.denom_traces")
-
 type ibcDenomTracesProcessor struct {
 	l                *zap.SugaredLogger
+	table            tables.DenomTracesTable
 	denomTracesCache map[string]models.IBCDenomTraceRow
 	m                sync.Mutex
 }
 
-var (
-	denomTracePathIndex = `CREATE INDEX IF NOT EXISTS denom_traces_path_idx ON ` + denomTracesTable.Name() + `(path)`
-)
-
-func (*ibcDenomTracesProcessor) Migrations() []string {
-	return []string{
-		denomTracesTable.CreateTable(),
-		denomTracePathIndex,
-	}
+func (b *ibcDenomTracesProcessor) Table() Table {
+	return b.table
 }
 
 func (b *ibcDenomTracesProcessor) ModuleName() string {
@@ -40,11 +32,11 @@ func (b *ibcDenomTracesProcessor) SDKModuleName() tracelistener.SDKModuleName {
 }
 
 func (b *ibcDenomTracesProcessor) UpsertStatement() string {
-	return denomTracesTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *ibcDenomTracesProcessor) InsertStatement() string {
-	return denomTracesTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *ibcDenomTracesProcessor) DeleteStatement() string {
@@ -3,11 +3,15 @@ package processor
 import (
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/emerishq/tracelistener/database"
 	"github.com/emerishq/tracelistener/models"
 
 	"github.com/emerishq/tracelistener/tracelistener"
 	"github.com/emerishq/tracelistener/tracelistener/config"
+	tldatabase "github.com/emerishq/tracelistener/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
 	"go.uber.org/zap"
 )
 
@@ -17,12 +21,28 @@ type Module interface {
 	Process(data tracelistener.TraceOperation) error
 	ModuleName() string
 	SDKModuleName() tracelistener.SDKModuleName
-	Migrations() []string
 	UpsertStatement() string
 	InsertStatement() string
 	DeleteStatement() string
 }
 
+// Table describes the database table a module writes to.
+type Table interface {
+	Name() string
+	UniqueColumns() []string
+}
+
+// TableModule is implemented by modules backed by a generated table.
+type TableModule interface {
+	Table() Table
+}
+
+// HistoryModule is implemented by modules whose table can keep an append-only
+// history of every write and delete, when history is enabled.
+type HistoryModule interface {
+	HistoryStatement(t tracelistener.WritebackStatementTypes) string
+}
+
 var defaultProcessors = []string{
 	"auth",
 	"bank",
@@ -37,18 +57,33 @@ var defaultProcessors = []string{
 	"cw20_token_infos",
 }
 
+// writebackBuffer is the number of flushed blocks which can wait to be written, before
+// processing is paced by database writes.
+const writebackBuffer = 64
+
 type Processor struct {
 	l                *zap.SugaredLogger
 	writeChan        chan tracelistener.TraceOperation
-	writebackChan    chan []tracelistener.WritebackOp
+	writebackChan    chan tracelistener.BlockWriteback
 	errorsChan       chan error
-	migrations       []string
 	lastHeight       uint64
 	chainName        string
 	moduleProcessors []Module
 	sdkModuleMapping map[tracelistener.SDKModuleName][]Module
 	lifecycleStop    chan struct{}
 	useDBUpsert      bool
+	backfill         bool
+	stateChanges     bool
+	history          bool
+	tables           moduleTables
+	delegatedTokens  delegatedTokens
+
+	aggregatesEnabled bool
+	aggregates        aggregates
+	seeds             []string
+
+	// traceOps counts the trace operations processed by each module since the last flush.
+	traceOps models.Counts
 
 	processingData sync.Mutex
 }
@@ -57,12 +92,14 @@ func (p *Processor) OpsChan() chan tracelistener.TraceOperation {
 	return p.writeChan
 }
 
-func (p *Processor) WritebackChan() chan []tracelistener.WritebackOp {
+func (p *Processor) WritebackChan() chan tracelistener.BlockWriteback {
 	return p.writebackChan
 }
 
-func (p *Processor) DatabaseMigrations() []string {
-	return p.migrations
+// DatabaseSeeds returns the statements filling the aggregate tables, to be run
+// after versioned migrations.
+func (p *Processor) DatabaseSeeds() []string {
+	return p.seeds
 }
 
 func (p *Processor) ErrorsChan() chan error {
@@ -72,47 +109,138 @@ func (p *Processor) ErrorsChan() chan error {
 func New(logger *zap.SugaredLogger, cfg *config.Config) (tracelistener.DataProcessor, error) {
 	c := cfg.Processor
 
+	dialect, err := database.ParseDialect(cfg.DatabaseDialect)
+	if err != nil {
+		return nil, err
+	}
+	schema := cfg.DatabaseName
+	if schema == "" {
+		schema = tldatabase.DefaultSchema
+	}
+	mt := newModuleTables(schema, dialect)
+
 	if c.ProcessorsEnabled == nil {
 		c.ProcessorsEnabled = defaultProcessors
 	}
 
 	mp := make([]Module, 0)
-	migrations := make([]string, 0)
 
 	sdkModuleMapping := map[tracelistener.SDKModuleName][]Module{}
 
 	for _, ep := range c.ProcessorsEnabled {
-		p, err := processorByName(ep, logger)
+		p, err := processorByName(ep, logger, mt)
 		if err != nil {
 			return nil, err
 		}
 
 		mp = append(mp, p)
-		migrations = append(migrations, p.Migrations()...)
 		sdkModuleMapping[p.SDKModuleName()] = append(sdkModuleMapping[p.SDKModuleName()], p)
 	}
 
-	logger.Infow("processor initialized", "processors", c.ProcessorsEnabled)
+	var seeds []string
+	if c.AggregatesEnabled {
+		enabled := map[string]bool{}
+		for _, m := range mp {
+			enabled[m.ModuleName()] = true
+		}
+
+		seeds = aggregatesSeeds(dialect, mt, enabled["bank"], enabled["delegations"] && enabled["validators"], enabled["validators"], c.PowerReduction)
+	}
+
+	logger.Infow("processor initialized", "processors", c.ProcessorsEnabled, "state_changes", c.StateChangesEnabled, "history", c.HistoryEnabled, "aggregates", c.AggregatesEnabled, "dialect", dialect, "schema", schema)
 
 	p := Processor{
 		chainName:        cfg.ChainName,
 		l:                logger,
 		writeChan:        make(chan tracelistener.TraceOperation),
-		writebackChan:    make(chan []tracelistener.WritebackOp),
+		writebackChan:    make(chan tracelistener.BlockWriteback, writebackBuffer),
 		errorsChan:       make(chan error),
 		moduleProcessors: mp,
-		migrations:       migrations,
 		sdkModuleMapping: sdkModuleMapping,
 		lifecycleStop:    make(chan struct{}),
+		stateChanges:     c.StateChangesEnabled,
+		history:          c.HistoryEnabled,
+		tables:           mt,
+		delegatedTokens:  delegatedTokens{stmts: mt.delegatedTokens, aggregateStmts: mt.aggregates},
+
+		aggregatesEnabled: c.AggregatesEnabled,
+		aggregates:        aggregates{powerReduction: c.PowerReduction, stmts: mt.aggregates},
+		seeds:             seeds,
 	}
 
 	return &p, nil
 }
 
+// moduleTables holds the tables of the modules, qualified with the configured schema and
+// writing statements for the configured dialect, along with the statements deriving other
+// tables from them.
+type moduleTables struct {
+	auth                 tables.AuthTable
+	balances             tables.BalancesTable
+	delegations          tables.DelegationsTable
+	unbondingDelegations tables.UnbondingDelegationsTable
+	clients              tables.ClientsTable
+	channels             tables.ChannelsTable
+	connections          tables.ConnectionsTable
+	denomTraces          tables.DenomTracesTable
+	validators           tables.ValidatorsTable
+	cw20Balances         tables.Cw20BalancesTable
+	cw20TokenInfos       tables.Cw20TokenInfosTable
+
+	// qualified names of the tables maintained by the processor itself
+	stateChanges    string
+	denomTotals     string
+	delegatorTotals string
+	validatorPowers string
+
+	delegatedTokens delegatedTokensStmts
+	aggregates      aggregateStmts
+}
+
+// newModuleTables returns the module tables living in schema, their statements targeting dialect d.
+func newModuleTables(schema string, d database.Dialect) moduleTables {
+	t := moduleTables{
+		auth:                 tables.NewAuthTable(schema + ".auth").WithDialect(d),
+		balances:             tables.NewBalancesTable(schema + ".balances").WithDialect(d),
+		delegations:          tables.NewDelegationsTable(schema + ".delegations").WithDialect(d),
+		unbondingDelegations: tables.NewUnbondingDelegationsTable(schema + ".unbonding_delegations").WithDialect(d),
+		clients:              tables.NewClientsTable(schema + ".clients").WithDialect(d),
+		channels:             tables.NewChannelsTable(schema + ".channels").WithDialect(d),
+		connections:          tables.NewConnectionsTable(schema + ".connections").WithDialect(d),
+		denomTraces:          tables.NewDenomTracesTable(schema + ".denom_traces").WithDialect(d),
+		validators:           tables.NewValidatorsTable(schema + ".validators").WithDialect(d),
+		cw20Balances:         tables.NewCw20BalancesTable(schema + ".cw20_balances").WithDialect(d),
+		cw20TokenInfos:       tables.NewCw20TokenInfosTable(schema + ".cw20_token_infos").WithDialect(d),
+		stateChanges:         schema + ".state_changes",
+		denomTotals:          schema + ".denom_totals",
+		delegatorTotals:      schema + ".delegator_totals",
+		validatorPowers:      schema + ".validator_powers",
+	}
+
+	t.delegatedTokens = newDelegatedTokensStatements(d, t)
+	t.aggregates = newAggregateStatements(d, t)
+
+	return t
+}
+
+// InvalidateCaches forgets the values cached at flush, which might not be in the database
+// anymore once a flushed block couldn't be written.
+func (p *Processor) InvalidateCaches() {
+	p.delegatedTokens.invalidate()
+	p.aggregates.invalidate()
+}
+
 func (p *Processor) SetDBUpsertEnabled(enabled bool) {
 	p.useDBUpsert = enabled
 }
 
+// SetBackfill enables the backfill mode, in which rows are written into a database already
+// filled by live tracing: they're upserted, only replacing older ones, and neither history
+// nor state changes are recorded since the rows they'd replace might be newer.
+func (p *Processor) SetBackfill(enabled bool) {
+	p.backfill = enabled
+}
+
 func (p *Processor) StartBackgroundProcessing() {
 	go p.lifecycle()
 }
@@ -134,75 +262,148 @@ func (p *Processor) AddModule(m Module) error {
 	return nil
 }
 
-func processorByName(name string, logger *zap.SugaredLogger) (Module, error) {
+// Modules returns the enabled modules.
+func (p *Processor) Modules() []Module {
+	return append([]Module(nil), p.moduleProcessors...)
+}
+
+// SDKModules returns the names of the Cosmos SDK modules the enabled modules process, each once.
+func (p *Processor) SDKModules() []string {
+	seen := map[tracelistener.SDKModuleName]bool{}
+	var res []string
+	for _, m := range p.moduleProcessors {
+		if sm := m.SDKModuleName(); !seen[sm] {
+			seen[sm] = true
+			res = append(res, sm.String())
+		}
+	}
+
+	return res
+}
+
+// hasModule returns true if the module named name is enabled.
+func (p *Processor) hasModule(name string) bool {
+	for _, m := range p.moduleProcessors {
+		if m.ModuleName() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// summaryTableName returns the name m rows are counted under in block summaries,
+// its table name when it has one.
+func summaryTableName(m Module) string {
+	if tm, ok := m.(TableModule); ok {
+		return database.Unqualified(tm.Table().Name())
+	}
+
+	return m.ModuleName()
+}
+
+func processorByName(name string, logger *zap.SugaredLogger, t moduleTables) (Module, error) {
 	switch name {
 	default:
 		return nil, fmt.Errorf("unknown Processor %s", name)
 	case (&bankProcessor{}).ModuleName():
 		return &bankProcessor{
+			table:       t.balances,
 			heightCache: map[bankCacheEntry]models.BalanceRow{},
 			l:           logger,
 		}, nil
 	case (&ibcConnectionsProcessor{}).ModuleName():
 		return &ibcConnectionsProcessor{
+			table:            t.connections,
 			connectionsCache: map[connectionCacheEntry]models.IBCConnectionRow{},
 			l:                logger,
 		}, nil
 	case (&delegationsProcessor{}).ModuleName():
 		return &delegationsProcessor{
+			table:             t.delegations,
 			insertHeightCache: map[delegationCacheEntry]models.DelegationRow{},
 			deleteHeightCache: map[delegationCacheEntry]models.DelegationRow{},
 			l:                 logger,
 		}, nil
 	case (&unbondingDelegationsProcessor{}).ModuleName():
 		return &unbondingDelegationsProcessor{
+			table:             t.unbondingDelegations,
 			insertHeightCache: map[unbondingDelegationCacheEntry]models.UnbondingDelegationRow{},
 			deleteHeightCache: map[unbondingDelegationCacheEntry]models.UnbondingDelegationRow{},
 			l:                 logger,
 		}, nil
 	case (&ibcDenomTracesProcessor{}).ModuleName():
 		return &ibcDenomTracesProcessor{
+			table:            t.denomTraces,
 			l:                logger,
 			denomTracesCache: map[string]models.IBCDenomTraceRow{},
 		}, nil
 	case (&ibcChannelsProcessor{}).ModuleName():
 		return &ibcChannelsProcessor{
+			table:         t.channels,
 			channelsCache: map[channelCacheEntry]models.IBCChannelRow{},
 			l:             logger,
 		}, nil
 	case (&ibcClientsProcessor{}).ModuleName():
 		return &ibcClientsProcessor{
+			table:        t.clients,
 			l:            logger,
 			clientsCache: map[clientCacheEntry]models.IBCClientStateRow{},
 		}, nil
 	case (&authProcessor{}).ModuleName():
 		return &authProcessor{
+			table:       t.auth,
 			l:           logger,
 			heightCache: map[authCacheEntry]models.AuthRow{},
 		}, nil
 	case (&validatorsProcessor{}).ModuleName():
 		return &validatorsProcessor{
+			table:                 t.validators,
 			l:                     logger,
 			insertValidatorsCache: map[validatorCacheEntry]models.ValidatorRow{},
 			deleteValidatorsCache: map[validatorCacheEntry]models.ValidatorRow{},
 		}, nil
 	case (&cw20BalanceProcessor{}).ModuleName():
 		return &cw20BalanceProcessor{
+			table:       t.cw20Balances,
 			l:           logger,
 			heightCache: map[cw20BalanceCacheEntry]models.CW20BalanceRow{},
 		}, nil
 	case (&cw20TokenInfoProcessor{}).ModuleName():
 		return &cw20TokenInfoProcessor{
+			table:       t.cw20TokenInfos,
 			l:           logger,
 			heightCache: map[cw20TokenInfoCacheEntry]models.CW20TokenInfoRow{},
 		}, nil
 	}
 }
 
+// Flush sends the block built from the processor caches to writebackChan.
+// Blocks must reach the database in the order they've been flushed: writebackChan
+// is buffered and read by a single writer, processing only waits on it once full.
 func (p *Processor) Flush() error {
+	bw := p.flushBlock()
+
+	select {
+	case p.writebackChan <- bw:
+	default:
+		p.l.Warnw("writeback buffer full, waiting for the database", "height", bw.Height, "buffer", writebackBuffer)
+		p.writebackChan <- bw
+	}
+
+	return nil
+}
+
+// flushBlock empties the processor caches into the block at the last height.
+func (p *Processor) flushBlock() tracelistener.BlockWriteback {
 	p.processingData.Lock()
 	defer p.processingData.Unlock()
+	start := time.Now()
 	wb := make([]tracelistener.WritebackOp, 0, len(p.moduleProcessors))
+	rowsWritten, rowsDeleted := models.Counts{}, models.Counts{}
+
+	// rows delegated tokens and aggregates depend on
+	var staking stakingRows
 
 	for _, mp := range p.moduleProcessors {
 		cd := mp.FlushCache()
@@ -219,7 +420,7 @@ func (p *Processor) Flush() error {
 			case tracelistener.Delete:
 				entry.Statement = mp.DeleteStatement()
 			case tracelistener.Write:
-				if p.useDBUpsert {
+				if p.useDBUpsert || p.backfill {
 					entry.Statement = mp.UpsertStatement()
 				} else {
 					entry.Statement = mp.InsertStatement()
@@ -230,17 +431,84 @@ func (p *Processor) Flush() error {
 
 			entry.SourceModule = mp.SDKModuleName().String()
 
+			if entry.Type == tracelistener.Write {
+				rowsWritten[summaryTableName(mp)] += uint64(len(entry.Data))
+			} else {
+				rowsDeleted[summaryTableName(mp)] += uint64(len(entry.Data))
+			}
+
+			// aggregates read the value entry is about to replace too.
+			if p.aggregatesEnabled && mp.ModuleName() == (&bankProcessor{}).ModuleName() {
+				wb = append(wb, p.aggregates.denomTotalsOps(entry)...)
+			}
+
+			// history rows must be written before entry, so that they can
+			// capture the value entry is about to replace.
+			if hm, ok := mp.(HistoryModule); ok && p.history && !p.backfill {
+				wb = append(wb, tracelistener.WritebackOp{
+					Type:         entry.Type,
+					Data:         entry.Data,
+					Statement:    hm.HistoryStatement(entry.Type),
+					SourceModule: entry.SourceModule,
+				})
+			}
+
 			wb = append(wb, entry)
+
+			switch {
+			case mp.ModuleName() == (&delegationsProcessor{}).ModuleName() && entry.Type == tracelistener.Write:
+				staking.delegations = append(staking.delegations, entry.Data...)
+			case mp.ModuleName() == (&delegationsProcessor{}).ModuleName():
+				staking.deletedDelegations = append(staking.deletedDelegations, entry.Data...)
+			case mp.ModuleName() == (&validatorsProcessor{}).ModuleName() && entry.Type == tracelistener.Write:
+				staking.validators = append(staking.validators, entry.Data...)
+			case mp.ModuleName() == (&validatorsProcessor{}).ModuleName():
+				staking.deletedValidators = append(staking.deletedValidators, entry.Data...)
+			}
+
+			if !p.stateChanges || p.backfill {
+				continue
+			}
+
+			tm, ok := mp.(TableModule)
+			if !ok {
+				continue
+			}
+
+			sc, err := stateChanges(p.tables.stateChanges, tm.Table(), entry)
+			if err != nil {
+				p.l.Errorw("cannot build state changes", "module", mp.ModuleName(), "error", err)
+				continue
+			}
+
+			wb = append(wb, sc)
 		}
 	}
 
-	p.l.Debugw("flush call", "content", wb)
+	// delegated_tokens reads both tables, so it's recomputed once both have been written.
+	if p.hasModule((&delegationsProcessor{}).ModuleName()) && p.hasModule((&validatorsProcessor{}).ModuleName()) {
+		wb = append(wb, p.delegatedTokens.ops(staking, p.aggregatesEnabled)...)
+	}
 
-	go func() {
-		p.writebackChan <- wb
-	}()
+	if p.aggregatesEnabled && p.hasModule((&validatorsProcessor{}).ModuleName()) {
+		wb = append(wb, p.aggregates.validatorPowersOps(staking.validators, staking.deletedValidators)...)
+	}
 
-	return nil
+	p.l.Debugw("flush call", "height", p.lastHeight, "content", wb)
+
+	bw := tracelistener.BlockWriteback{
+		Height: p.lastHeight,
+		Ops:    wb,
+		Summary: &models.BlockSummaryRow{
+			TraceOps:       p.traceOps,
+			RowsWritten:    rowsWritten,
+			RowsDeleted:    rowsDeleted,
+			FlushLatencyUs: time.Since(start).Microseconds(),
+		},
+	}
+	p.traceOps = nil
+
+	return bw
 }
 
 func (p *Processor) lifecycle() {
@@ -290,6 +558,10 @@ func (p *Processor) ProcessData(data tracelistener.TraceOperation) error {
 func (p *Processor) processData(processorList []Module, data tracelistener.TraceOperation) {
 	p.processingData.Lock()
 	defer p.processingData.Unlock()
+	if p.traceOps == nil {
+		p.traceOps = models.Counts{}
+	}
+
 	for _, mp := range processorList {
 		if !mp.OwnsKey(data.Key) {
 			continue
@@ -303,6 +575,8 @@ func (p *Processor) processData(processorList []Module, data tracelistener.Trace
 			p.l.Infow("Probe", "c", "gaia", "n", mn)
 		}
 
+		p.traceOps[mn]++
+
 		if err := mp.Process(data); err != nil {
 			p.errorsChan <- tracelistener.TracingError{
 				InnerError: err,
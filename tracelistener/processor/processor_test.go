@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/emerishq/tracelistener/models"
 	"github.com/emerishq/tracelistener/tracelistener"
 
 	"go.uber.org/zap"
@@ -25,7 +26,6 @@ type dumbModule struct {
 	key           []byte
 	alwaysOwnsKey bool
 	processFunc   func(data tracelistener.TraceOperation) error
-	migrations    []string
 	moduleName    string
 }
 
@@ -56,10 +56,6 @@ func (d dumbModule) ModuleName() string {
 	return "dumbModule"
 }
 
-func (d dumbModule) Migrations() []string {
-	return d.migrations
-}
-
 func (d dumbModule) UpsertStatement() string {
 	return ""
 }
@@ -72,6 +68,14 @@ func (d dumbModule) DeleteStatement() string {
 	return ""
 }
 
+type historyDumbModule struct {
+	dumbModule
+}
+
+func (d historyDumbModule) HistoryStatement(t tracelistener.WritebackStatementTypes) string {
+	return "history " + t.String()
+}
+
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -238,7 +242,7 @@ func TestLifecycle(t *testing.T) {
 
 			if tt.shouldSendWb {
 				require.Eventually(t, func() bool {
-					return <-p.WritebackChan() != nil
+					return (<-p.WritebackChan()).Ops != nil
 				}, 10*time.Second, 500*time.Millisecond)
 
 				return
@@ -298,3 +302,323 @@ func TestProcessor_AddModule(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessor_FlushHistory(t *testing.T) {
+	historyModule := historyDumbModule{
+		dumbModule: dumbModule{
+			moduleName: "dumb",
+			wbOp: []tracelistener.WritebackOp{
+				{
+					Type: tracelistener.Write,
+					Data: []models.DatabaseEntrier{models.BalanceRow{}},
+				},
+				{
+					Type: tracelistener.Delete,
+					Data: []models.DatabaseEntrier{models.BalanceRow{}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		module        processor.Module
+		history       bool
+		backfill      bool
+		expectedStmts []string
+	}{
+		{
+			"module without history",
+			dumbModule{
+				moduleName: "dumb",
+				wbOp: []tracelistener.WritebackOp{
+					{
+						Type: tracelistener.Write,
+						Data: []models.DatabaseEntrier{models.BalanceRow{}},
+					},
+				},
+			},
+			true,
+			false,
+			[]string{""},
+		},
+		{
+			"module with history, history statement comes first",
+			historyModule,
+			true,
+			false,
+			[]string{"history Write", "", "history Delete", ""},
+		},
+		{
+			"history disabled",
+			historyModule,
+			false,
+			false,
+			[]string{"", ""},
+		},
+		{
+			"backfill doesn't record history",
+			historyModule,
+			true,
+			true,
+			[]string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+				Processor: config.ProcessorConfig{
+					HistoryEnabled: tt.history,
+				},
+			})
+			require.NoError(t, err)
+
+			gp := p.(*processor.Processor)
+			require.NoError(t, gp.AddModule(tt.module))
+			gp.SetBackfill(tt.backfill)
+
+			wb := flush(t, p).Ops
+
+			stmts := make([]string, 0, len(wb))
+			for _, op := range wb {
+				stmts = append(stmts, op.Statement)
+			}
+
+			require.Equal(t, tt.expectedStmts, stmts)
+		})
+	}
+}
+
+func TestProcessor_FlushDelegatedTokens(t *testing.T) {
+	delegation := models.DelegationRow{Delegator: "delegator", Validator: "validator"}
+	validator := models.ValidatorRow{ValidatorAddress: "validator", Tokens: "1000", DelegatorShares: "1000"}
+
+	tests := []struct {
+		name         string
+		modules      []processor.Module
+		moduleOps    int
+		expectedData []models.DatabaseEntrier
+	}{
+		{
+			"delegation written alone",
+			[]processor.Module{
+				dumbModule{moduleName: "delegations", wbOp: []tracelistener.WritebackOp{
+					{Type: tracelistener.Write, Data: []models.DatabaseEntrier{delegation}},
+				}},
+				dumbModule{moduleName: "validators"},
+			},
+			1,
+			[]models.DatabaseEntrier{delegation},
+		},
+		{
+			"validator recomputed with its delegations",
+			[]processor.Module{
+				dumbModule{moduleName: "delegations", wbOp: []tracelistener.WritebackOp{
+					{Type: tracelistener.Write, Data: []models.DatabaseEntrier{delegation}},
+				}},
+				dumbModule{moduleName: "validators", wbOp: []tracelistener.WritebackOp{
+					{Type: tracelistener.Write, Data: []models.DatabaseEntrier{validator}},
+				}},
+			},
+			2,
+			[]models.DatabaseEntrier{validator},
+		},
+		{
+			"validators module disabled",
+			[]processor.Module{
+				dumbModule{moduleName: "delegations", wbOp: []tracelistener.WritebackOp{
+					{Type: tracelistener.Write, Data: []models.DatabaseEntrier{delegation}},
+				}},
+			},
+			1,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+				Processor: config.ProcessorConfig{
+					ProcessorsEnabled: []string{"bank"},
+				},
+			})
+			require.NoError(t, err)
+
+			gp := p.(*processor.Processor)
+			for _, m := range tt.modules {
+				require.NoError(t, gp.AddModule(m))
+			}
+
+			wb := flush(t, p).Ops
+			require.Len(t, wb, tt.moduleOps+len(tt.expectedData))
+
+			// the modules own ops come first, then the delegated tokens ones
+			var data []models.DatabaseEntrier
+			for _, op := range wb[tt.moduleOps:] {
+				require.NotEmpty(t, op.Statement)
+				data = append(data, op.Data...)
+			}
+
+			require.Equal(t, tt.expectedData, data)
+		})
+	}
+}
+
+func TestProcessor_FlushBlockSummary(t *testing.T) {
+	p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+		Processor: config.ProcessorConfig{
+			ProcessorsEnabled: []string{"bank"},
+		},
+	})
+	require.NoError(t, err)
+
+	gp := p.(*processor.Processor)
+	require.NoError(t, gp.AddModule(dumbModule{
+		moduleName:  "dumb",
+		key:         []byte("dumb"),
+		processFunc: func(tracelistener.TraceOperation) error { return nil },
+		wbOp: []tracelistener.WritebackOp{
+			{Type: tracelistener.Write, Data: []models.DatabaseEntrier{models.BalanceRow{}, models.BalanceRow{}}},
+			{Type: tracelistener.Delete, Data: []models.DatabaseEntrier{models.BalanceRow{}}},
+		},
+	}))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, gp.ProcessData(tracelistener.TraceOperation{Key: []byte("dumb")}))
+	}
+	require.NoError(t, gp.ProcessData(tracelistener.TraceOperation{Key: []byte("unowned")}))
+
+	s := flush(t, p).Summary
+	require.NotNil(t, s)
+	require.Equal(t, models.Counts{"dumb": 3}, s.TraceOps)
+	require.Equal(t, models.Counts{"dumb": 2}, s.RowsWritten)
+	require.Equal(t, models.Counts{"dumb": 1}, s.RowsDeleted)
+	require.GreaterOrEqual(t, s.FlushLatencyUs, int64(0))
+
+	// trace ops are counted again from zero after a flush
+	require.Empty(t, flush(t, p).Summary.TraceOps)
+}
+
+func TestNew_Aggregates(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors []string
+		seeds      int
+	}{
+		{"bank only", []string{"bank"}, 1},
+		{"validators only", []string{"validators"}, 1},
+		{"bank and staking", []string{"bank", "delegations", "validators"}, 3},
+		{"no aggregated module", []string{"auth"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+				Processor: config.ProcessorConfig{
+					ProcessorsEnabled: tt.processors,
+					AggregatesEnabled: true,
+				},
+			})
+			require.NoError(t, err)
+			require.Len(t, p.DatabaseSeeds(), tt.seeds)
+		})
+	}
+}
+
+func TestNew_Schema(t *testing.T) {
+	newProcessor := func(schema string) *processor.Processor {
+		p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+			DatabaseName: schema,
+			Processor: config.ProcessorConfig{
+				ProcessorsEnabled: []string{"bank"},
+			},
+		})
+		require.NoError(t, err)
+
+		return p.(*processor.Processor)
+	}
+
+	staging := newProcessor("staging")
+	production := newProcessor("production")
+
+	// each processor writes to the tables of its own schema
+	for p, schema := range map[*processor.Processor]string{staging: "staging", production: "production"} {
+		tm, ok := p.Modules()[0].(processor.TableModule)
+		require.True(t, ok)
+		require.Equal(t, schema+".balances", tm.Table().Name())
+		require.Contains(t, p.Modules()[0].InsertStatement(), schema+".balances")
+	}
+}
+
+func TestProcessor_SDKModules(t *testing.T) {
+	p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+		Processor: config.ProcessorConfig{
+			ProcessorsEnabled: []string{"bank", "delegations", "validators", "auth"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		tracelistener.Bank.String(),
+		tracelistener.Staking.String(),
+		tracelistener.Acc.String(),
+	}, p.(*processor.Processor).SDKModules())
+}
+
+// flush flushes p and returns the block it sent.
+func flush(t *testing.T, p tracelistener.DataProcessor) tracelistener.BlockWriteback {
+	t.Helper()
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- p.Flush()
+	}()
+
+	b := <-p.WritebackChan()
+	require.NoError(t, <-flushed)
+
+	return b
+}
+
+func TestProcessor_FlushOrder(t *testing.T) {
+	p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+		Processor: config.ProcessorConfig{
+			ProcessorsEnabled: []string{"bank"},
+		},
+	})
+	require.NoError(t, err)
+
+	p.StartBackgroundProcessing()
+	defer p.StopBackgroundProcessing()
+
+	// each new height flushes the previous one, blocks must come out in the same order
+	go func() {
+		for h := uint64(1); h <= 20; h++ {
+			p.OpsChan() <- tracelistener.TraceOperation{BlockHeight: h, Key: []byte("unowned")}
+		}
+	}()
+
+	for h := uint64(0); h < 20; h++ {
+		require.Equal(t, h, (<-p.WritebackChan()).Height)
+	}
+}
+
+func TestProcessor_FlushWithoutWriter(t *testing.T) {
+	p, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+		Processor: config.ProcessorConfig{
+			ProcessorsEnabled: []string{"bank"},
+		},
+	})
+	require.NoError(t, err)
+
+	// a slow writer doesn't hold flushes until the buffer is full
+	for h := uint64(0); h < 10; h++ {
+		require.NoError(t, p.Flush())
+	}
+
+	for h := uint64(0); h < 10; h++ {
+		require.Len(t, p.WritebackChan(), int(10-h))
+		<-p.WritebackChan()
+	}
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+)
+
+// insertStateChanges writes to the state changes table, created by the
+// 20261019005410_create_state_changes migration.
+const insertStateChanges = `
+	INSERT INTO %s (chain_name, height, tx_hash, table_name, unique_key, operation)
+	VALUES (:chain_name, :height, :last_tx_hash, :table_name, :unique_key, :operation)`
+
+// dbMapper maps database column names to struct fields the same way sqlx does.
+var dbMapper = reflectx.NewMapperFunc("db", strings.ToLower)
+
+// stateChanges returns a WritebackOp recording each row of entry in the state
+// changes table, named stateChangesTable.
+func stateChanges(stateChangesTable string, t Table, entry tracelistener.WritebackOp) (tracelistener.WritebackOp, error) {
+	op := tracelistener.WriteOp.String()
+	if entry.Type == tracelistener.Delete {
+		op = tracelistener.DeleteOp.String()
+	}
+
+	data := make([]models.DatabaseEntrier, 0, len(entry.Data))
+	for _, d := range entry.Data {
+		key, err := uniqueKey(t, d)
+		if err != nil {
+			return tracelistener.WritebackOp{}, err
+		}
+
+		row := models.StateChangeRow{
+			TableName: t.Name(),
+			UniqueKey: key,
+			Operation: op,
+		}
+
+		row.TracelistenerDatabaseRow = dbRow(d)
+
+		data = append(data, row)
+	}
+
+	return tracelistener.WritebackOp{
+		Type:         tracelistener.Write,
+		Data:         data,
+		Statement:    fmt.Sprintf(insertStateChanges, stateChangesTable),
+		SourceModule: entry.SourceModule,
+	}, nil
+}
+
+// uniqueKey renders the unique columns of t held by d as a JSON object,
+// chain name excluded.
+func uniqueKey(t Table, d models.DatabaseEntrier) (string, error) {
+	v := reflect.ValueOf(d)
+	fields := dbMapper.TypeMap(v.Type()).Names
+	key := map[string]interface{}{}
+
+	for _, c := range t.UniqueColumns() {
+		if c == "chain_name" {
+			continue
+		}
+
+		f, ok := fields[c]
+		if !ok {
+			return "", fmt.Errorf("unique column %s not found in %T", c, d)
+		}
+
+		key[c] = reflectx.FieldByIndexesReadOnly(v, f.Index).Interface()
+	}
+
+	res, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal unique key, %w", err)
+	}
+
+	return string(res), nil
+}
+
+// dbRow returns the TracelistenerDatabaseRow embedded in d.
+func dbRow(d models.DatabaseEntrier) models.TracelistenerDatabaseRow {
+	f := reflect.ValueOf(d).FieldByName("TracelistenerDatabaseRow")
+	if !f.IsValid() {
+		return models.TracelistenerDatabaseRow{}
+	}
+
+	return f.Interface().(models.TracelistenerDatabaseRow)
+}
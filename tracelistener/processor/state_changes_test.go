@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	tldatabase "github.com/emerishq/tracelistener/tracelistener/database"
+)
+
+// testTables are the tables of the modules as configured by default.
+var testTables = newModuleTables(tldatabase.DefaultSchema, database.DialectCockroachDB)
+
+func TestStateChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    Table
+		entry    tracelistener.WritebackOp
+		expected []models.DatabaseEntrier
+	}{
+		{
+			"write on balances",
+			testTables.balances,
+			tracelistener.WritebackOp{
+				Type: tracelistener.Write,
+				Data: []models.DatabaseEntrier{
+					models.BalanceRow{
+						TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+							ChainName: "chain",
+							Height:    10,
+							TxHash:    models.NullString("hash"),
+						},
+						Address: "address",
+						Amount:  "10stake",
+						Denom:   "stake",
+					},
+				},
+			},
+			[]models.DatabaseEntrier{
+				models.StateChangeRow{
+					TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+						ChainName: "chain",
+						Height:    10,
+						TxHash:    models.NullString("hash"),
+					},
+					TableName: testTables.balances.Name(),
+					UniqueKey: `{"address":"address","denom":"stake"}`,
+					Operation: tracelistener.WriteOp.String(),
+				},
+			},
+		},
+		{
+			"delete on delegations",
+			testTables.delegations,
+			tracelistener.WritebackOp{
+				Type: tracelistener.Delete,
+				Data: []models.DatabaseEntrier{
+					models.DelegationRow{
+						TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+							ChainName: "chain",
+							Height:    11,
+						},
+						Delegator: "delegator",
+						Validator: "validator",
+					},
+				},
+			},
+			[]models.DatabaseEntrier{
+				models.StateChangeRow{
+					TracelistenerDatabaseRow: models.TracelistenerDatabaseRow{
+						ChainName: "chain",
+						Height:    11,
+					},
+					TableName: testTables.delegations.Name(),
+					UniqueKey: `{"delegator_address":"delegator","validator_address":"validator"}`,
+					Operation: tracelistener.DeleteOp.String(),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := stateChanges(testTables.stateChanges, tt.table, tt.entry)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf(insertStateChanges, testTables.stateChanges), res.Statement)
+			require.Equal(t, tracelistener.Write, res.Type)
+			require.Equal(t, tt.expected, res.Data)
+		})
+	}
+}
+
+func TestStateChanges_MissingColumn(t *testing.T) {
+	_, err := stateChanges(testTables.stateChanges, testTables.balances, tracelistener.WritebackOp{
+		Type: tracelistener.Write,
+		Data: []models.DatabaseEntrier{models.AuthRow{}},
+	})
+	require.Error(t, err)
+}
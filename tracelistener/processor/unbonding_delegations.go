@@ -12,8 +12,6 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener"
 )
 
-var unbondingDelegationsTable = tables.NewUnbondingDelegationsTable("tracelistener.unbonding_delegations")
-
 type unbondingDelegationCacheEntry struct {
 	delegator string
 	validator string
@@ -21,15 +19,14 @@ type unbondingDelegationCacheEntry struct {
 
 type unbondingDelegationsProcessor struct {
 	l                 *zap.SugaredLogger
+	table             tables.UnbondingDelegationsTable
 	insertHeightCache map[unbondingDelegationCacheEntry]models.UnbondingDelegationRow
 	deleteHeightCache map[unbondingDelegationCacheEntry]models.UnbondingDelegationRow
 	m                 sync.Mutex
 }
 
-func (*unbondingDelegationsProcessor) Migrations() []string {
-	return []string{
-		unbondingDelegationsTable.CreateTable(),
-	}
+func (b *unbondingDelegationsProcessor) Table() Table {
+	return b.table
 }
 
 func (b *unbondingDelegationsProcessor) ModuleName() string {
@@ -41,15 +38,15 @@ func (b *unbondingDelegationsProcessor) SDKModuleName() tracelistener.SDKModuleN
 }
 
 func (b *unbondingDelegationsProcessor) InsertStatement() string {
-	return unbondingDelegationsTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *unbondingDelegationsProcessor) UpsertStatement() string {
-	return unbondingDelegationsTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *unbondingDelegationsProcessor) DeleteStatement() string {
-	return unbondingDelegationsTable.Delete()
+	return b.table.Delete()
 }
 
 func (b *unbondingDelegationsProcessor) FlushCache() []tracelistener.WritebackOp {
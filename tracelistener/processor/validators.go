@@ -12,27 +12,19 @@ import (
 	"github.com/emerishq/tracelistener/tracelistener"
 )
 
-var validatorsTable = tables.NewValidatorsTable("tracelistener.validators")
-
 type validatorCacheEntry struct {
 	operator string
 }
 type validatorsProcessor struct {
 	l                     *zap.SugaredLogger
+	table                 tables.ValidatorsTable
 	insertValidatorsCache map[validatorCacheEntry]models.ValidatorRow
 	deleteValidatorsCache map[validatorCacheEntry]models.ValidatorRow
 	m                     sync.Mutex
 }
 
-var (
-	addValAddressColumn = `ALTER TABLE ` + validatorsTable.Name() + ` ADD COLUMN IF NOT EXISTS validator_address text DEFAULT '';`
-)
-
-func (*validatorsProcessor) Migrations() []string {
-	return []string{
-		validatorsTable.CreateTable(),
-		addValAddressColumn,
-	}
+func (b *validatorsProcessor) Table() Table {
+	return b.table
 }
 
 func (b *validatorsProcessor) ModuleName() string {
@@ -44,15 +36,15 @@ func (b *validatorsProcessor) SDKModuleName() tracelistener.SDKModuleName {
 }
 
 func (b *validatorsProcessor) InsertStatement() string {
-	return validatorsTable.Insert()
+	return b.table.Insert()
 }
 
 func (b *validatorsProcessor) UpsertStatement() string {
-	return validatorsTable.Upsert()
+	return b.table.Upsert()
 }
 
 func (b *validatorsProcessor) DeleteStatement() string {
-	return validatorsTable.Delete()
+	return b.table.Delete()
 }
 
 func (b *validatorsProcessor) FlushCache() []tracelistener.WritebackOp {
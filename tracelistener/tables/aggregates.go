@@ -0,0 +1,97 @@
+package tables
+
+import (
+	"fmt"
+
+	"github.com/emerishq/tracelistener/database"
+)
+
+// Aggregates are tables summing up other ones, maintained by the processor at flush.
+// Seeds fill them from the live rows of their source table: the processor runs them the first
+// time aggregates are enabled, and resetchain to rebuild the rows of a chain.
+
+// Delegations hold shares, their delegated_tokens column converts them to tokens at the
+// exchange rate of their validator, tokens / delegator_shares.
+
+// BondStatusBonded is the value of stakingtypes.Bonded, the status of validators in the active set.
+const BondStatusBonded = 3
+
+// DefaultPowerReduction is the Cosmos SDK sdk.DefaultPowerReduction, the amount of tokens
+// making a unit of consensus power.
+const DefaultPowerReduction = 1_000_000
+
+const (
+	recomputeDelegatedTokens = `
+	UPDATE %[1]s AS d SET delegated_tokens = %[3]s
+	FROM %[2]s AS v
+	%[4]s`
+
+	delegatedTokensExpr = `CASE WHEN v.delegator_shares_numeric = 0 THEN 0
+	ELSE round(d.amount_numeric * v.tokens_numeric / v.delegator_shares_numeric, %d) END`
+
+	// tokens_from_shares is registered by the database package.
+	delegatedTokensExprSQLite = `tokens_from_shares(d.amount_numeric, v.tokens_numeric, v.delegator_shares_numeric)`
+
+	seedDenomTotals = `
+	INSERT INTO %[1]s (chain_name, denom, height, total_amount, holders)
+	SELECT chain_name, denom, max(height), COALESCE(sum(amount_numeric), 0), count(CASE WHEN amount_numeric > 0 THEN 1 END)
+	FROM %[2]s
+	WHERE delete_height IS NULL AND %[3]s
+	GROUP BY chain_name, denom`
+
+	seedDelegatorTotals = `
+	INSERT INTO %[1]s (chain_name, delegator_address, height, delegated_tokens)
+	SELECT chain_name, delegator_address, max(height), COALESCE(sum(delegated_tokens), 0)
+	FROM %[2]s
+	WHERE delete_height IS NULL AND %[3]s
+	GROUP BY chain_name, delegator_address`
+
+	seedValidatorPowers = `
+	INSERT INTO %[1]s (chain_name, validator_address, height, voting_power, rank)
+	SELECT chain_name, validator_address, height, voting_power,
+	CASE WHEN voting_power > 0 THEN row_number() OVER (PARTITION BY chain_name ORDER BY voting_power DESC, validator_address) END
+	FROM (
+		SELECT chain_name, validator_address, height,
+		CASE WHEN status = %[4]d AND NOT jailed THEN COALESCE(CAST(floor(tokens_numeric / %[5]d) AS bigint), 0) ELSE 0 END AS voting_power
+		FROM %[2]s
+		WHERE delete_height IS NULL AND %[3]s
+	) AS v`
+)
+
+// SeedDenomTotals returns the statement filling the denomTotals aggregate from the live rows
+// of balances matching cond.
+func SeedDenomTotals(denomTotals, balances, cond string) string {
+	return fmt.Sprintf(seedDenomTotals, denomTotals, balances, cond)
+}
+
+// SeedDelegatorTotals returns the statement filling the delegatorTotals aggregate from the live
+// rows of delegations matching cond.
+func SeedDelegatorTotals(delegatorTotals, delegations, cond string) string {
+	return fmt.Sprintf(seedDelegatorTotals, delegatorTotals, delegations, cond)
+}
+
+// SeedValidatorPowers returns the statement filling the validatorPowers aggregate from the live
+// rows of validators matching cond, DefaultPowerReduction being used if powerReduction isn't set.
+func SeedValidatorPowers(validatorPowers, validators, cond string, powerReduction int64) string {
+	if powerReduction <= 0 {
+		powerReduction = DefaultPowerReduction
+	}
+
+	return fmt.Sprintf(seedValidatorPowers, validatorPowers, validators, cond, BondStatusBonded, powerReduction)
+}
+
+// DelegatedTokensExpr returns the expression converting the shares of delegation d to tokens
+// at the exchange rate of validator v for dialect.
+func DelegatedTokensExpr(dialect database.Dialect) string {
+	if dialect == database.DialectSQLite {
+		return delegatedTokensExprSQLite
+	}
+
+	return fmt.Sprintf(delegatedTokensExpr, database.DecimalScale)
+}
+
+// RecomputeDelegatedTokens returns the statement setting the delegated tokens of the delegations
+// d matched with their validator v by where.
+func RecomputeDelegatedTokens(dialect database.Dialect, delegations, validators, where string) string {
+	return fmt.Sprintf(recomputeDelegatedTokens, delegations, validators, DelegatedTokensExpr(dialect), where)
+}
@@ -4,41 +4,105 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type AuthTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewAuthTable(tableName string) AuthTable {
 	return AuthTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r AuthTable) WithDialect(dialect database.Dialect) AuthTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r AuthTable) Name() string { return r.tableName }
 
+func (r AuthTable) UniqueColumns() []string {
+	return []string{"chain_name", "address", "account_number"}
+}
+
 func (r AuthTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, sequence_number numeric NOT NULL, account_number numeric NOT NULL, UNIQUE (chain_name, address, account_number))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, sequence_number text NOT NULL, account_number text NOT NULL, UNIQUE (chain_name, address, account_number))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, address text NOT NULL, sequence_number numeric NOT NULL, account_number numeric NOT NULL, UNIQUE (chain_name, address, account_number))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, sequence_number numeric NOT NULL, account_number numeric NOT NULL, UNIQUE (chain_name, address, account_number))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r AuthTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "auth_chain_name_id_idx"), database.Unqualified(r.tableName)),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (address) WHERE delete_height IS NULL`, database.SameSchema(r.tableName, "auth_address_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS auth_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS auth_address_idx ON %s (address) WHERE delete_height IS NULL`, r.tableName),
+	}
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r AuthTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'accounts sequence and account numbers'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r AuthTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r AuthTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, address, sequence_number, account_number)
-		VALUES (:height, :chain_name, :address, :sequence_number, :account_number)
+		INSERT INTO %s (height, chain_name, last_tx_hash, address, sequence_number, account_number)
+		VALUES (:height, :chain_name, :last_tx_hash, :address, :sequence_number, :account_number)
 	`, r.tableName)
 }
 
 func (r AuthTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, address, sequence_number, account_number)
-		VALUES (:height, :chain_name, :address, :sequence_number, :account_number)
+		INSERT INTO %s (height, chain_name, last_tx_hash, address, sequence_number, account_number)
+		VALUES (:height, :chain_name, :last_tx_hash, :address, :sequence_number, :account_number)
 		ON CONFLICT (chain_name, address, account_number)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, address = EXCLUDED.address, sequence_number = EXCLUDED.sequence_number, account_number = EXCLUDED.account_number
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, address = EXCLUDED.address, sequence_number = EXCLUDED.sequence_number, account_number = EXCLUDED.account_number
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +110,180 @@ func (r AuthTable) Upsert() string {
 func (r AuthTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND address=:address AND account_number=:account_number
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+func (r AuthTable) HistoryName() string { return r.tableName + "_history" }
+
+func (r AuthTable) CreateHistoryTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, account_number numeric NOT NULL, height bigint NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, account_number text NOT NULL, height integer NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id serial PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, account_number numeric NOT NULL, height integer NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+}
+
+func (r AuthTable) CreateHistoryIndex() string {
+	if r.dialect == database.DialectSQLite {
+		return fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS %s
+		ON %s (chain_name, address, account_number, height)
+	`, database.SameSchema(r.HistoryName(), "auth_history_height_idx"), database.Unqualified(r.HistoryName()))
+	}
+
+	return fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS auth_history_height_idx
+		ON %s (chain_name, address, account_number, height)
+	`, r.HistoryName())
+}
+
+// HistorySchema returns the statements defining the history table, in execution order.
+func (r AuthTable) HistorySchema() []string {
+	return []string{r.CreateHistoryTable(), r.CreateHistoryIndex()}
+}
+
+// InsertHistory returns the statement recording a write in the history table,
+// skipped when the row is already at that height or a later one, like Upsert.
+func (r AuthTable) InsertHistory() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, account_number, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:account_number AS numeric), CAST(:height AS bigint), CAST(:last_tx_hash AS text), 'write',
+		(SELECT to_jsonb(t) FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.delete_height IS NULL),
+		jsonb_build_object('height', CAST(:height AS bigint), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'address', CAST(:address AS text), 'sequence_number', CAST(:sequence_number AS numeric), 'account_number', CAST(:account_number AS numeric))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, account_number, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:account_number AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'write',
+		(SELECT json_object('id', t.id, 'height', t.height, 'delete_height', t.delete_height, 'chain_name', t.chain_name, 'last_tx_hash', t.last_tx_hash, 'address', t.address, 'sequence_number', t.sequence_number, 'account_number', t.account_number) FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.delete_height IS NULL),
+		json_object('height', CAST(:height AS integer), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'address', CAST(:address AS text), 'sequence_number', CAST(:sequence_number AS text), 'account_number', CAST(:account_number AS text))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, account_number, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:account_number AS numeric), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'write',
+		(SELECT to_jsonb(t) FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.delete_height IS NULL),
+		jsonb_build_object('height', CAST(:height AS integer), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'address', CAST(:address AS text), 'sequence_number', CAST(:sequence_number AS numeric), 'account_number', CAST(:account_number AS numeric))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+}
+
+// DeleteHistory returns the statement recording a delete in the history table,
+// skipped when there's no live row at that height or an earlier one, like Delete.
+func (r AuthTable) DeleteHistory() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, account_number, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:account_number AS numeric), CAST(:height AS bigint), CAST(:last_tx_hash AS text), 'delete',
+		to_jsonb(t),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, account_number, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:account_number AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'delete',
+		json_object('id', t.id, 'height', t.height, 'delete_height', t.delete_height, 'chain_name', t.chain_name, 'last_tx_hash', t.last_tx_hash, 'address', t.address, 'sequence_number', t.sequence_number, 'account_number', t.account_number),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, account_number, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:account_number AS numeric), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'delete',
+		to_jsonb(t),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.address = :address AND t.account_number = :account_number AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+}
+
+// SelectHistoryAt returns a query rebuilding the rows matching filterColumn as
+// they were at a given height. Parameters are chain name, filterColumn value
+// and height, in this order.
+func (r AuthTable) SelectHistoryAt(filterColumn string) string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS bigint) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'address' AS text) AS address, CAST(h.new_value->>'sequence_number' AS numeric) AS sequence_number, CAST(h.new_value->>'account_number' AS numeric) AS account_number
+		FROM (
+			SELECT DISTINCT ON (chain_name, address, account_number) operation, new_value
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+			ORDER BY chain_name, address, account_number, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS integer) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'address' AS text) AS address, CAST(h.new_value->>'sequence_number' AS text) AS sequence_number, CAST(h.new_value->>'account_number' AS text) AS account_number
+		FROM (
+			SELECT operation, new_value,
+			ROW_NUMBER() OVER (PARTITION BY chain_name, address, account_number ORDER BY height DESC, id DESC) AS rn
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+		) AS h
+		WHERE h.rn = 1 AND h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+	}
+
+	return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS integer) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'address' AS text) AS address, CAST(h.new_value->>'sequence_number' AS numeric) AS sequence_number, CAST(h.new_value->>'account_number' AS numeric) AS account_number
+		FROM (
+			SELECT DISTINCT ON (chain_name, address, account_number) operation, new_value
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+			ORDER BY chain_name, address, account_number, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+}
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r AuthTable) SelectByUnique(q sqlx.Queryer, chainName string, address string, accountNumber uint64) (models.AuthRow, error) {
+	var row models.AuthRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, address, sequence_number, account_number
+		FROM %s
+		WHERE chain_name=$1 AND address=$2 AND account_number=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, address, accountNumber)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r AuthTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.AuthRow, error) {
+	var rows []models.AuthRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, address, sequence_number, account_number
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
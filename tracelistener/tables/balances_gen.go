@@ -4,41 +4,122 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type BalancesTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewBalancesTable(tableName string) BalancesTable {
 	return BalancesTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r BalancesTable) WithDialect(dialect database.Dialect) BalancesTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r BalancesTable) Name() string { return r.tableName }
 
+func (r BalancesTable) UniqueColumns() []string {
+	return []string{"chain_name", "address", "denom"}
+}
+
 func (r BalancesTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, amount text NOT NULL, amount_numeric decimal, denom text NOT NULL, UNIQUE (chain_name, address, denom))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, amount text NOT NULL, amount_numeric text, denom text NOT NULL, UNIQUE (chain_name, address, denom))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, address text NOT NULL, amount text NOT NULL, denom text NOT NULL, UNIQUE (chain_name, address, denom))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, address text NOT NULL, amount text NOT NULL, amount_numeric decimal, denom text NOT NULL, UNIQUE (chain_name, address, denom))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r BalancesTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "balances_chain_name_id_idx"), database.Unqualified(r.tableName)),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (address) WHERE delete_height IS NULL`, database.SameSchema(r.tableName, "balances_address_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS balances_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS balances_address_idx ON %s (address) WHERE delete_height IS NULL`, r.tableName),
+	}
+}
+
+// ViewName returns the name of the view exposing the live rows only.
+func (r BalancesTable) ViewName() string { return r.tableName + "_current" }
+
+func (r BalancesTable) CreateView() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`CREATE OR REPLACE VIEW %s AS SELECT id, height, chain_name, last_tx_hash, address, amount, amount_numeric, denom FROM %s WHERE delete_height IS NULL`, r.ViewName(), r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`CREATE VIEW IF NOT EXISTS %s AS SELECT id, height, chain_name, last_tx_hash, address, amount, amount_numeric, denom FROM %s WHERE delete_height IS NULL`, r.ViewName(), database.Unqualified(r.tableName))
+	}
+
+	return fmt.Sprintf(`CREATE VIEW IF NOT EXISTS %s AS SELECT id, height, chain_name, last_tx_hash, address, amount, amount_numeric, denom FROM %s WHERE delete_height IS NULL`, r.ViewName(), r.tableName)
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r BalancesTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'bank balances, one row per address and denom'`, r.tableName),
+		fmt.Sprintf(`COMMENT ON COLUMN %s.amount IS 'coins string, e.g. 100uatom'`, r.tableName),
+		fmt.Sprintf(`COMMENT ON COLUMN %s.amount_numeric IS 'coins amount, without the denom'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r BalancesTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.CreateView())
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r BalancesTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, address, amount, denom)
-		VALUES (:height, :chain_name, :address, :amount, :denom)
+		INSERT INTO %s (height, chain_name, last_tx_hash, address, amount, amount_numeric, denom)
+		VALUES (:height, :chain_name, :last_tx_hash, :address, :amount, :amount_numeric, :denom)
 	`, r.tableName)
 }
 
 func (r BalancesTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, address, amount, denom)
-		VALUES (:height, :chain_name, :address, :amount, :denom)
+		INSERT INTO %s (height, chain_name, last_tx_hash, address, amount, amount_numeric, denom)
+		VALUES (:height, :chain_name, :last_tx_hash, :address, :amount, :amount_numeric, :denom)
 		ON CONFLICT (chain_name, address, denom)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, address = EXCLUDED.address, amount = EXCLUDED.amount, denom = EXCLUDED.denom
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, address = EXCLUDED.address, amount = EXCLUDED.amount, amount_numeric = EXCLUDED.amount_numeric, denom = EXCLUDED.denom
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +127,180 @@ func (r BalancesTable) Upsert() string {
 func (r BalancesTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND address=:address AND denom=:denom
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+func (r BalancesTable) HistoryName() string { return r.tableName + "_history" }
+
+func (r BalancesTable) CreateHistoryTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, denom text NOT NULL, height bigint NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, denom text NOT NULL, height integer NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id serial PRIMARY KEY NOT NULL, chain_name text NOT NULL, address text NOT NULL, denom text NOT NULL, height integer NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+}
+
+func (r BalancesTable) CreateHistoryIndex() string {
+	if r.dialect == database.DialectSQLite {
+		return fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS %s
+		ON %s (chain_name, address, denom, height)
+	`, database.SameSchema(r.HistoryName(), "balances_history_height_idx"), database.Unqualified(r.HistoryName()))
+	}
+
+	return fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS balances_history_height_idx
+		ON %s (chain_name, address, denom, height)
+	`, r.HistoryName())
+}
+
+// HistorySchema returns the statements defining the history table, in execution order.
+func (r BalancesTable) HistorySchema() []string {
+	return []string{r.CreateHistoryTable(), r.CreateHistoryIndex()}
+}
+
+// InsertHistory returns the statement recording a write in the history table,
+// skipped when the row is already at that height or a later one, like Upsert.
+func (r BalancesTable) InsertHistory() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, denom, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:denom AS text), CAST(:height AS bigint), CAST(:last_tx_hash AS text), 'write',
+		(SELECT to_jsonb(t) FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.delete_height IS NULL),
+		jsonb_build_object('height', CAST(:height AS bigint), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'address', CAST(:address AS text), 'amount', CAST(:amount AS text), 'amount_numeric', CAST(:amount_numeric AS decimal), 'denom', CAST(:denom AS text))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, denom, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:denom AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'write',
+		(SELECT json_object('id', t.id, 'height', t.height, 'delete_height', t.delete_height, 'chain_name', t.chain_name, 'last_tx_hash', t.last_tx_hash, 'address', t.address, 'amount', t.amount, 'amount_numeric', t.amount_numeric, 'denom', t.denom) FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.delete_height IS NULL),
+		json_object('height', CAST(:height AS integer), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'address', CAST(:address AS text), 'amount', CAST(:amount AS text), 'amount_numeric', CAST(:amount_numeric AS text), 'denom', CAST(:denom AS text))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, denom, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:denom AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'write',
+		(SELECT to_jsonb(t) FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.delete_height IS NULL),
+		jsonb_build_object('height', CAST(:height AS integer), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'address', CAST(:address AS text), 'amount', CAST(:amount AS text), 'amount_numeric', CAST(:amount_numeric AS decimal), 'denom', CAST(:denom AS text))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+}
+
+// DeleteHistory returns the statement recording a delete in the history table,
+// skipped when there's no live row at that height or an earlier one, like Delete.
+func (r BalancesTable) DeleteHistory() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, denom, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:denom AS text), CAST(:height AS bigint), CAST(:last_tx_hash AS text), 'delete',
+		to_jsonb(t),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, denom, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:denom AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'delete',
+		json_object('id', t.id, 'height', t.height, 'delete_height', t.delete_height, 'chain_name', t.chain_name, 'last_tx_hash', t.last_tx_hash, 'address', t.address, 'amount', t.amount, 'amount_numeric', t.amount_numeric, 'denom', t.denom),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, address, denom, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:address AS text), CAST(:denom AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'delete',
+		to_jsonb(t),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.address = :address AND t.denom = :denom AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+}
+
+// SelectHistoryAt returns a query rebuilding the rows matching filterColumn as
+// they were at a given height. Parameters are chain name, filterColumn value
+// and height, in this order.
+func (r BalancesTable) SelectHistoryAt(filterColumn string) string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS bigint) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'address' AS text) AS address, CAST(h.new_value->>'amount' AS text) AS amount, CAST(h.new_value->>'amount_numeric' AS decimal) AS amount_numeric, CAST(h.new_value->>'denom' AS text) AS denom
+		FROM (
+			SELECT DISTINCT ON (chain_name, address, denom) operation, new_value
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+			ORDER BY chain_name, address, denom, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS integer) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'address' AS text) AS address, CAST(h.new_value->>'amount' AS text) AS amount, CAST(h.new_value->>'amount_numeric' AS text) AS amount_numeric, CAST(h.new_value->>'denom' AS text) AS denom
+		FROM (
+			SELECT operation, new_value,
+			ROW_NUMBER() OVER (PARTITION BY chain_name, address, denom ORDER BY height DESC, id DESC) AS rn
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+		) AS h
+		WHERE h.rn = 1 AND h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+	}
+
+	return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS integer) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'address' AS text) AS address, CAST(h.new_value->>'amount' AS text) AS amount, CAST(h.new_value->>'amount_numeric' AS decimal) AS amount_numeric, CAST(h.new_value->>'denom' AS text) AS denom
+		FROM (
+			SELECT DISTINCT ON (chain_name, address, denom) operation, new_value
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+			ORDER BY chain_name, address, denom, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+}
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r BalancesTable) SelectByUnique(q sqlx.Queryer, chainName string, address string, denom string) (models.BalanceRow, error) {
+	var row models.BalanceRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, address, amount, amount_numeric, denom
+		FROM %s
+		WHERE chain_name=$1 AND address=$2 AND denom=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, address, denom)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r BalancesTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.BalanceRow, error) {
+	var rows []models.BalanceRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, address, amount, amount_numeric, denom
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
@@ -4,41 +4,103 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type ChannelsTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewChannelsTable(tableName string) ChannelsTable {
 	return ChannelsTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r ChannelsTable) WithDialect(dialect database.Dialect) ChannelsTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r ChannelsTable) Name() string { return r.tableName }
 
+func (r ChannelsTable) UniqueColumns() []string {
+	return []string{"chain_name", "channel_id", "port"}
+}
+
 func (r ChannelsTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, channel_id text NOT NULL, counter_channel_id text NOT NULL, port text NOT NULL, state bigint NOT NULL, hops text[] NOT NULL, UNIQUE (chain_name, channel_id, port))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, channel_id text NOT NULL, counter_channel_id text NOT NULL, port text NOT NULL, state integer NOT NULL, hops text NOT NULL, UNIQUE (chain_name, channel_id, port))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, channel_id text NOT NULL, counter_channel_id text NOT NULL, port text NOT NULL, state integer NOT NULL, hops text[] NOT NULL, UNIQUE (chain_name, channel_id, port))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, channel_id text NOT NULL, counter_channel_id text NOT NULL, port text NOT NULL, state integer NOT NULL, hops text[] NOT NULL, UNIQUE (chain_name, channel_id, port))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r ChannelsTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "channels_chain_name_id_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS channels_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+	}
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r ChannelsTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'IBC channels'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r ChannelsTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r ChannelsTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, channel_id, counter_channel_id, port, state, hops)
-		VALUES (:height, :chain_name, :channel_id, :counter_channel_id, :port, :state, :hops)
+		INSERT INTO %s (height, chain_name, last_tx_hash, channel_id, counter_channel_id, port, state, hops)
+		VALUES (:height, :chain_name, :last_tx_hash, :channel_id, :counter_channel_id, :port, :state, :hops)
 	`, r.tableName)
 }
 
 func (r ChannelsTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, channel_id, counter_channel_id, port, state, hops)
-		VALUES (:height, :chain_name, :channel_id, :counter_channel_id, :port, :state, :hops)
+		INSERT INTO %s (height, chain_name, last_tx_hash, channel_id, counter_channel_id, port, state, hops)
+		VALUES (:height, :chain_name, :last_tx_hash, :channel_id, :counter_channel_id, :port, :state, :hops)
 		ON CONFLICT (chain_name, channel_id, port)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, channel_id = EXCLUDED.channel_id, counter_channel_id = EXCLUDED.counter_channel_id, port = EXCLUDED.port, state = EXCLUDED.state, hops = EXCLUDED.hops
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, channel_id = EXCLUDED.channel_id, counter_channel_id = EXCLUDED.counter_channel_id, port = EXCLUDED.port, state = EXCLUDED.state, hops = EXCLUDED.hops
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +108,33 @@ func (r ChannelsTable) Upsert() string {
 func (r ChannelsTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND channel_id=:channel_id AND port=:port
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r ChannelsTable) SelectByUnique(q sqlx.Queryer, chainName string, channelID string, port string) (models.IBCChannelRow, error) {
+	var row models.IBCChannelRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, channel_id, counter_channel_id, port, state, hops
+		FROM %s
+		WHERE chain_name=$1 AND channel_id=$2 AND port=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, channelID, port)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r ChannelsTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.IBCChannelRow, error) {
+	var rows []models.IBCChannelRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, channel_id, counter_channel_id, port, state, hops
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
@@ -4,41 +4,103 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type ClientsTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewClientsTable(tableName string) ClientsTable {
 	return ClientsTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r ClientsTable) WithDialect(dialect database.Dialect) ClientsTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r ClientsTable) Name() string { return r.tableName }
 
+func (r ClientsTable) UniqueColumns() []string {
+	return []string{"chain_name", "chain_id", "client_id"}
+}
+
 func (r ClientsTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, chain_id text NOT NULL, client_id text NOT NULL, latest_height numeric NOT NULL, trusting_period numeric NOT NULL, UNIQUE (chain_name, chain_id, client_id))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, chain_id text NOT NULL, client_id text NOT NULL, latest_height text NOT NULL, trusting_period text NOT NULL, UNIQUE (chain_name, chain_id, client_id))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, chain_id text NOT NULL, client_id text NOT NULL, latest_height numeric NOT NULL, trusting_period numeric NOT NULL, UNIQUE (chain_name, chain_id, client_id))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, chain_id text NOT NULL, client_id text NOT NULL, latest_height numeric NOT NULL, trusting_period numeric NOT NULL, UNIQUE (chain_name, chain_id, client_id))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r ClientsTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "clients_chain_name_id_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS clients_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+	}
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r ClientsTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'IBC clients'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r ClientsTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r ClientsTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, chain_id, client_id, latest_height, trusting_period)
-		VALUES (:height, :chain_name, :chain_id, :client_id, :latest_height, :trusting_period)
+		INSERT INTO %s (height, chain_name, last_tx_hash, chain_id, client_id, latest_height, trusting_period)
+		VALUES (:height, :chain_name, :last_tx_hash, :chain_id, :client_id, :latest_height, :trusting_period)
 	`, r.tableName)
 }
 
 func (r ClientsTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, chain_id, client_id, latest_height, trusting_period)
-		VALUES (:height, :chain_name, :chain_id, :client_id, :latest_height, :trusting_period)
+		INSERT INTO %s (height, chain_name, last_tx_hash, chain_id, client_id, latest_height, trusting_period)
+		VALUES (:height, :chain_name, :last_tx_hash, :chain_id, :client_id, :latest_height, :trusting_period)
 		ON CONFLICT (chain_name, chain_id, client_id)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, chain_id = EXCLUDED.chain_id, client_id = EXCLUDED.client_id, latest_height = EXCLUDED.latest_height, trusting_period = EXCLUDED.trusting_period
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, chain_id = EXCLUDED.chain_id, client_id = EXCLUDED.client_id, latest_height = EXCLUDED.latest_height, trusting_period = EXCLUDED.trusting_period
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +108,33 @@ func (r ClientsTable) Upsert() string {
 func (r ClientsTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND chain_id=:chain_id AND client_id=:client_id
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r ClientsTable) SelectByUnique(q sqlx.Queryer, chainName string, chainID string, clientID string) (models.IBCClientStateRow, error) {
+	var row models.IBCClientStateRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, chain_id, client_id, latest_height, trusting_period
+		FROM %s
+		WHERE chain_name=$1 AND chain_id=$2 AND client_id=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, chainID, clientID)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r ClientsTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.IBCClientStateRow, error) {
+	var rows []models.IBCClientStateRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, chain_id, client_id, latest_height, trusting_period
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
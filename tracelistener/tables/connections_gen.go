@@ -4,41 +4,103 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type ConnectionsTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewConnectionsTable(tableName string) ConnectionsTable {
 	return ConnectionsTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r ConnectionsTable) WithDialect(dialect database.Dialect) ConnectionsTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r ConnectionsTable) Name() string { return r.tableName }
 
+func (r ConnectionsTable) UniqueColumns() []string {
+	return []string{"chain_name", "connection_id", "client_id"}
+}
+
 func (r ConnectionsTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, connection_id text NOT NULL, client_id text NOT NULL, state text NOT NULL, counter_connection_id text NOT NULL, counter_client_id text NOT NULL, UNIQUE (chain_name, connection_id, client_id))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, connection_id text NOT NULL, client_id text NOT NULL, state text NOT NULL, counter_connection_id text NOT NULL, counter_client_id text NOT NULL, UNIQUE (chain_name, connection_id, client_id))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, connection_id text NOT NULL, client_id text NOT NULL, state text NOT NULL, counter_connection_id text NOT NULL, counter_client_id text NOT NULL, UNIQUE (chain_name, connection_id, client_id))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, connection_id text NOT NULL, client_id text NOT NULL, state text NOT NULL, counter_connection_id text NOT NULL, counter_client_id text NOT NULL, UNIQUE (chain_name, connection_id, client_id))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r ConnectionsTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "connections_chain_name_id_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS connections_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+	}
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r ConnectionsTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'IBC connections'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r ConnectionsTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r ConnectionsTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, connection_id, client_id, state, counter_connection_id, counter_client_id)
-		VALUES (:height, :chain_name, :connection_id, :client_id, :state, :counter_connection_id, :counter_client_id)
+		INSERT INTO %s (height, chain_name, last_tx_hash, connection_id, client_id, state, counter_connection_id, counter_client_id)
+		VALUES (:height, :chain_name, :last_tx_hash, :connection_id, :client_id, :state, :counter_connection_id, :counter_client_id)
 	`, r.tableName)
 }
 
 func (r ConnectionsTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, connection_id, client_id, state, counter_connection_id, counter_client_id)
-		VALUES (:height, :chain_name, :connection_id, :client_id, :state, :counter_connection_id, :counter_client_id)
+		INSERT INTO %s (height, chain_name, last_tx_hash, connection_id, client_id, state, counter_connection_id, counter_client_id)
+		VALUES (:height, :chain_name, :last_tx_hash, :connection_id, :client_id, :state, :counter_connection_id, :counter_client_id)
 		ON CONFLICT (chain_name, connection_id, client_id)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, connection_id = EXCLUDED.connection_id, client_id = EXCLUDED.client_id, state = EXCLUDED.state, counter_connection_id = EXCLUDED.counter_connection_id, counter_client_id = EXCLUDED.counter_client_id
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, connection_id = EXCLUDED.connection_id, client_id = EXCLUDED.client_id, state = EXCLUDED.state, counter_connection_id = EXCLUDED.counter_connection_id, counter_client_id = EXCLUDED.counter_client_id
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +108,33 @@ func (r ConnectionsTable) Upsert() string {
 func (r ConnectionsTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND connection_id=:connection_id AND client_id=:client_id
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r ConnectionsTable) SelectByUnique(q sqlx.Queryer, chainName string, connectionID string, clientID string) (models.IBCConnectionRow, error) {
+	var row models.IBCConnectionRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, connection_id, client_id, state, counter_connection_id, counter_client_id
+		FROM %s
+		WHERE chain_name=$1 AND connection_id=$2 AND client_id=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, connectionID, clientID)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r ConnectionsTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.IBCConnectionRow, error) {
+	var rows []models.IBCConnectionRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, connection_id, client_id, state, counter_connection_id, counter_client_id
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
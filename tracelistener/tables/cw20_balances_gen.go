@@ -4,39 +4,105 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type Cw20BalancesTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewCw20BalancesTable(tableName string) Cw20BalancesTable {
 	return Cw20BalancesTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r Cw20BalancesTable) WithDialect(dialect database.Dialect) Cw20BalancesTable {
+	r.dialect = dialect
+	return r
+}
+
+func (r Cw20BalancesTable) Name() string { return r.tableName }
+
+func (r Cw20BalancesTable) UniqueColumns() []string {
+	return []string{"chain_name", "contract_address", "address"}
+}
+
 func (r Cw20BalancesTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, contract_address text NOT NULL, address text NOT NULL, amount text NOT NULL, amount_numeric decimal, UNIQUE (chain_name, contract_address, address))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, contract_address text NOT NULL, address text NOT NULL, amount text NOT NULL, amount_numeric text, UNIQUE (chain_name, contract_address, address))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, contract_address text NOT NULL, address text NOT NULL, amount text NOT NULL, UNIQUE (chain_name, contract_address, address))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, contract_address text NOT NULL, address text NOT NULL, amount text NOT NULL, amount_numeric decimal, UNIQUE (chain_name, contract_address, address))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r Cw20BalancesTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "cw20_balances_chain_name_id_idx"), database.Unqualified(r.tableName)),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (address) WHERE delete_height IS NULL`, database.SameSchema(r.tableName, "cw20_balances_address_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS cw20_balances_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS cw20_balances_address_idx ON %s (address) WHERE delete_height IS NULL`, r.tableName),
+	}
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r Cw20BalancesTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'cw20 token balances, one row per contract and holder'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r Cw20BalancesTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r Cw20BalancesTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, contract_address, address, amount)
-		VALUES (:height, :chain_name, :contract_address, :address, :amount)
+		INSERT INTO %s (height, chain_name, last_tx_hash, contract_address, address, amount, amount_numeric)
+		VALUES (:height, :chain_name, :last_tx_hash, :contract_address, :address, :amount, :amount_numeric)
 	`, r.tableName)
 }
 
 func (r Cw20BalancesTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, contract_address, address, amount)
-		VALUES (:height, :chain_name, :contract_address, :address, :amount)
+		INSERT INTO %s (height, chain_name, last_tx_hash, contract_address, address, amount, amount_numeric)
+		VALUES (:height, :chain_name, :last_tx_hash, :contract_address, :address, :amount, :amount_numeric)
 		ON CONFLICT (chain_name, contract_address, address)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, contract_address = EXCLUDED.contract_address, address = EXCLUDED.address, amount = EXCLUDED.amount
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, contract_address = EXCLUDED.contract_address, address = EXCLUDED.address, amount = EXCLUDED.amount, amount_numeric = EXCLUDED.amount_numeric
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -44,8 +110,33 @@ func (r Cw20BalancesTable) Upsert() string {
 func (r Cw20BalancesTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND contract_address=:contract_address AND address=:address
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r Cw20BalancesTable) SelectByUnique(q sqlx.Queryer, chainName string, contractAddress string, address string) (models.CW20BalanceRow, error) {
+	var row models.CW20BalanceRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, contract_address, address, amount, amount_numeric
+		FROM %s
+		WHERE chain_name=$1 AND contract_address=$2 AND address=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, contractAddress, address)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r Cw20BalancesTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.CW20BalanceRow, error) {
+	var rows []models.CW20BalanceRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, contract_address, address, amount, amount_numeric
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
@@ -4,41 +4,125 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type DelegationsTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewDelegationsTable(tableName string) DelegationsTable {
 	return DelegationsTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r DelegationsTable) WithDialect(dialect database.Dialect) DelegationsTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r DelegationsTable) Name() string { return r.tableName }
 
+func (r DelegationsTable) UniqueColumns() []string {
+	return []string{"chain_name", "delegator_address", "validator_address"}
+}
+
 func (r DelegationsTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, amount text NOT NULL, amount_numeric decimal, delegated_tokens decimal, UNIQUE (chain_name, delegator_address, validator_address))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, amount text NOT NULL, amount_numeric text, delegated_tokens text, UNIQUE (chain_name, delegator_address, validator_address))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, delegator_address text NOT NULL, validator_address text NOT NULL, amount text NOT NULL, UNIQUE (chain_name, delegator_address, validator_address))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, amount text NOT NULL, amount_numeric decimal, delegated_tokens decimal, UNIQUE (chain_name, delegator_address, validator_address))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r DelegationsTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "delegations_chain_name_id_idx"), database.Unqualified(r.tableName)),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (delegator_address) WHERE delete_height IS NULL`, database.SameSchema(r.tableName, "delegations_delegator_address_idx"), database.Unqualified(r.tableName)),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (validator_address) WHERE delete_height IS NULL`, database.SameSchema(r.tableName, "delegations_validator_address_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS delegations_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS delegations_delegator_address_idx ON %s (delegator_address) WHERE delete_height IS NULL`, r.tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS delegations_validator_address_idx ON %s (validator_address) WHERE delete_height IS NULL`, r.tableName),
+	}
+}
+
+// ViewName returns the name of the view exposing the live rows only.
+func (r DelegationsTable) ViewName() string { return r.tableName + "_current" }
+
+func (r DelegationsTable) CreateView() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`CREATE OR REPLACE VIEW %s AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric, delegated_tokens FROM %s WHERE delete_height IS NULL`, r.ViewName(), r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`CREATE VIEW IF NOT EXISTS %s AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric, delegated_tokens FROM %s WHERE delete_height IS NULL`, r.ViewName(), database.Unqualified(r.tableName))
+	}
+
+	return fmt.Sprintf(`CREATE VIEW IF NOT EXISTS %s AS SELECT id, height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric, delegated_tokens FROM %s WHERE delete_height IS NULL`, r.ViewName(), r.tableName)
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r DelegationsTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'staking delegations, one row per delegator and validator'`, r.tableName),
+		fmt.Sprintf(`COMMENT ON COLUMN %s.amount IS 'delegator shares, as a decimal string'`, r.tableName),
+		fmt.Sprintf(`COMMENT ON COLUMN %s.amount_numeric IS 'delegator shares'`, r.tableName),
+		fmt.Sprintf(`COMMENT ON COLUMN %s.delegated_tokens IS 'delegator shares converted to tokens at the validator exchange rate'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r DelegationsTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.CreateView())
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r DelegationsTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, delegator_address, validator_address, amount)
-		VALUES (:height, :chain_name, :delegator_address, :validator_address, :amount)
+		INSERT INTO %s (height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric)
+		VALUES (:height, :chain_name, :last_tx_hash, :delegator_address, :validator_address, :amount, :amount_numeric)
 	`, r.tableName)
 }
 
 func (r DelegationsTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, delegator_address, validator_address, amount)
-		VALUES (:height, :chain_name, :delegator_address, :validator_address, :amount)
+		INSERT INTO %s (height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric)
+		VALUES (:height, :chain_name, :last_tx_hash, :delegator_address, :validator_address, :amount, :amount_numeric)
 		ON CONFLICT (chain_name, delegator_address, validator_address)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, delegator_address = EXCLUDED.delegator_address, validator_address = EXCLUDED.validator_address, amount = EXCLUDED.amount
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, delegator_address = EXCLUDED.delegator_address, validator_address = EXCLUDED.validator_address, amount = EXCLUDED.amount, amount_numeric = EXCLUDED.amount_numeric
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +130,180 @@ func (r DelegationsTable) Upsert() string {
 func (r DelegationsTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND delegator_address=:delegator_address AND validator_address=:validator_address
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+func (r DelegationsTable) HistoryName() string { return r.tableName + "_history" }
+
+func (r DelegationsTable) CreateHistoryTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, chain_name text NOT NULL, delegator_address text NOT NULL, validator_address text NOT NULL, height bigint NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, chain_name text NOT NULL, delegator_address text NOT NULL, validator_address text NOT NULL, height integer NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id serial PRIMARY KEY NOT NULL, chain_name text NOT NULL, delegator_address text NOT NULL, validator_address text NOT NULL, height integer NOT NULL, tx_hash text, operation text NOT NULL, old_value jsonb, new_value jsonb)
+	`, r.HistoryName())
+}
+
+func (r DelegationsTable) CreateHistoryIndex() string {
+	if r.dialect == database.DialectSQLite {
+		return fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS %s
+		ON %s (chain_name, delegator_address, validator_address, height)
+	`, database.SameSchema(r.HistoryName(), "delegations_history_height_idx"), database.Unqualified(r.HistoryName()))
+	}
+
+	return fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS delegations_history_height_idx
+		ON %s (chain_name, delegator_address, validator_address, height)
+	`, r.HistoryName())
+}
+
+// HistorySchema returns the statements defining the history table, in execution order.
+func (r DelegationsTable) HistorySchema() []string {
+	return []string{r.CreateHistoryTable(), r.CreateHistoryIndex()}
+}
+
+// InsertHistory returns the statement recording a write in the history table,
+// skipped when the row is already at that height or a later one, like Upsert.
+func (r DelegationsTable) InsertHistory() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, delegator_address, validator_address, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:delegator_address AS text), CAST(:validator_address AS text), CAST(:height AS bigint), CAST(:last_tx_hash AS text), 'write',
+		(SELECT to_jsonb(t) FROM %s AS t WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.delete_height IS NULL),
+		jsonb_build_object('height', CAST(:height AS bigint), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'delegator_address', CAST(:delegator_address AS text), 'validator_address', CAST(:validator_address AS text), 'amount', CAST(:amount AS text), 'amount_numeric', CAST(:amount_numeric AS decimal))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, delegator_address, validator_address, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:delegator_address AS text), CAST(:validator_address AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'write',
+		(SELECT json_object('id', t.id, 'height', t.height, 'delete_height', t.delete_height, 'chain_name', t.chain_name, 'last_tx_hash', t.last_tx_hash, 'delegator_address', t.delegator_address, 'validator_address', t.validator_address, 'amount', t.amount, 'amount_numeric', t.amount_numeric, 'delegated_tokens', t.delegated_tokens) FROM %s AS t WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.delete_height IS NULL),
+		json_object('height', CAST(:height AS integer), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'delegator_address', CAST(:delegator_address AS text), 'validator_address', CAST(:validator_address AS text), 'amount', CAST(:amount AS text), 'amount_numeric', CAST(:amount_numeric AS text))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, delegator_address, validator_address, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:delegator_address AS text), CAST(:validator_address AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'write',
+		(SELECT to_jsonb(t) FROM %s AS t WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.delete_height IS NULL),
+		jsonb_build_object('height', CAST(:height AS integer), 'chain_name', CAST(:chain_name AS text), 'last_tx_hash', CAST(:last_tx_hash AS text), 'delegator_address', CAST(:delegator_address AS text), 'validator_address', CAST(:validator_address AS text), 'amount', CAST(:amount AS text), 'amount_numeric', CAST(:amount_numeric AS decimal))
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.height >= :height)
+	`, r.HistoryName(), r.tableName, r.tableName)
+}
+
+// DeleteHistory returns the statement recording a delete in the history table,
+// skipped when there's no live row at that height or an earlier one, like Delete.
+func (r DelegationsTable) DeleteHistory() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, delegator_address, validator_address, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:delegator_address AS text), CAST(:validator_address AS text), CAST(:height AS bigint), CAST(:last_tx_hash AS text), 'delete',
+		to_jsonb(t),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, delegator_address, validator_address, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:delegator_address AS text), CAST(:validator_address AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'delete',
+		json_object('id', t.id, 'height', t.height, 'delete_height', t.delete_height, 'chain_name', t.chain_name, 'last_tx_hash', t.last_tx_hash, 'delegator_address', t.delegator_address, 'validator_address', t.validator_address, 'amount', t.amount, 'amount_numeric', t.amount_numeric, 'delegated_tokens', t.delegated_tokens),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (chain_name, delegator_address, validator_address, height, tx_hash, operation, old_value, new_value)
+		SELECT CAST(:chain_name AS text), CAST(:delegator_address AS text), CAST(:validator_address AS text), CAST(:height AS integer), CAST(:last_tx_hash AS text), 'delete',
+		to_jsonb(t),
+		NULL
+		FROM %s AS t
+		WHERE t.chain_name = :chain_name AND t.delegator_address = :delegator_address AND t.validator_address = :validator_address AND t.delete_height IS NULL AND t.height <= :height
+	`, r.HistoryName(), r.tableName)
+}
+
+// SelectHistoryAt returns a query rebuilding the rows matching filterColumn as
+// they were at a given height. Parameters are chain name, filterColumn value
+// and height, in this order.
+func (r DelegationsTable) SelectHistoryAt(filterColumn string) string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS bigint) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'delegator_address' AS text) AS delegator_address, CAST(h.new_value->>'validator_address' AS text) AS validator_address, CAST(h.new_value->>'amount' AS text) AS amount, CAST(h.new_value->>'amount_numeric' AS decimal) AS amount_numeric
+		FROM (
+			SELECT DISTINCT ON (chain_name, delegator_address, validator_address) operation, new_value
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+			ORDER BY chain_name, delegator_address, validator_address, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS integer) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'delegator_address' AS text) AS delegator_address, CAST(h.new_value->>'validator_address' AS text) AS validator_address, CAST(h.new_value->>'amount' AS text) AS amount, CAST(h.new_value->>'amount_numeric' AS text) AS amount_numeric
+		FROM (
+			SELECT operation, new_value,
+			ROW_NUMBER() OVER (PARTITION BY chain_name, delegator_address, validator_address ORDER BY height DESC, id DESC) AS rn
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+		) AS h
+		WHERE h.rn = 1 AND h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+	}
+
+	return fmt.Sprintf(`
+		SELECT CAST(h.new_value->>'height' AS integer) AS height, CAST(h.new_value->>'chain_name' AS text) AS chain_name, CAST(h.new_value->>'last_tx_hash' AS text) AS last_tx_hash, CAST(h.new_value->>'delegator_address' AS text) AS delegator_address, CAST(h.new_value->>'validator_address' AS text) AS validator_address, CAST(h.new_value->>'amount' AS text) AS amount, CAST(h.new_value->>'amount_numeric' AS decimal) AS amount_numeric
+		FROM (
+			SELECT DISTINCT ON (chain_name, delegator_address, validator_address) operation, new_value
+			FROM %s
+			WHERE chain_name = $1 AND %s = $2 AND height <= $3
+			ORDER BY chain_name, delegator_address, validator_address, height DESC, id DESC
+		) AS h
+		WHERE h.operation <> 'delete'
+	`, r.HistoryName(), filterColumn)
+}
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r DelegationsTable) SelectByUnique(q sqlx.Queryer, chainName string, delegator string, validator string) (models.DelegationRow, error) {
+	var row models.DelegationRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric, delegated_tokens
+		FROM %s
+		WHERE chain_name=$1 AND delegator_address=$2 AND validator_address=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, delegator, validator)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r DelegationsTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.DelegationRow, error) {
+	var rows []models.DelegationRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, delegator_address, validator_address, amount, amount_numeric, delegated_tokens
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
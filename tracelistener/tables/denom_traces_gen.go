@@ -4,41 +4,105 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type DenomTracesTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewDenomTracesTable(tableName string) DenomTracesTable {
 	return DenomTracesTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r DenomTracesTable) WithDialect(dialect database.Dialect) DenomTracesTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r DenomTracesTable) Name() string { return r.tableName }
 
+func (r DenomTracesTable) UniqueColumns() []string {
+	return []string{"chain_name", "hash"}
+}
+
 func (r DenomTracesTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, path text NOT NULL, base_denom text NOT NULL, hash text NOT NULL, UNIQUE (chain_name, hash))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, path text NOT NULL, base_denom text NOT NULL, hash text NOT NULL, UNIQUE (chain_name, hash))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, path text NOT NULL, base_denom text NOT NULL, hash text NOT NULL, UNIQUE (chain_name, hash))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, path text NOT NULL, base_denom text NOT NULL, hash text NOT NULL, UNIQUE (chain_name, hash))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r DenomTracesTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "denom_traces_chain_name_id_idx"), database.Unqualified(r.tableName)),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (path)`, database.SameSchema(r.tableName, "denom_traces_path_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS denom_traces_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS denom_traces_path_idx ON %s (path)`, r.tableName),
+	}
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r DenomTracesTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'IBC transfer denom traces, one row per denom hash'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r DenomTracesTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r DenomTracesTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, path, base_denom, hash)
-		VALUES (:height, :chain_name, :path, :base_denom, :hash)
+		INSERT INTO %s (height, chain_name, last_tx_hash, path, base_denom, hash)
+		VALUES (:height, :chain_name, :last_tx_hash, :path, :base_denom, :hash)
 	`, r.tableName)
 }
 
 func (r DenomTracesTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, path, base_denom, hash)
-		VALUES (:height, :chain_name, :path, :base_denom, :hash)
+		INSERT INTO %s (height, chain_name, last_tx_hash, path, base_denom, hash)
+		VALUES (:height, :chain_name, :last_tx_hash, :path, :base_denom, :hash)
 		ON CONFLICT (chain_name, hash)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, path = EXCLUDED.path, base_denom = EXCLUDED.base_denom, hash = EXCLUDED.hash
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, path = EXCLUDED.path, base_denom = EXCLUDED.base_denom, hash = EXCLUDED.hash
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +110,33 @@ func (r DenomTracesTable) Upsert() string {
 func (r DenomTracesTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND hash=:hash
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r DenomTracesTable) SelectByUnique(q sqlx.Queryer, chainName string, hash string) (models.IBCDenomTraceRow, error) {
+	var row models.IBCDenomTraceRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, path, base_denom, hash
+		FROM %s
+		WHERE chain_name=$1 AND hash=$2
+		AND delete_height IS NULL
+	`, r.tableName), chainName, hash)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r DenomTracesTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.IBCDenomTraceRow, error) {
+	var rows []models.IBCDenomTraceRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, path, base_denom, hash
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
@@ -4,41 +4,105 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type UnbondingDelegationsTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewUnbondingDelegationsTable(tableName string) UnbondingDelegationsTable {
 	return UnbondingDelegationsTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r UnbondingDelegationsTable) WithDialect(dialect database.Dialect) UnbondingDelegationsTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r UnbondingDelegationsTable) Name() string { return r.tableName }
 
+func (r UnbondingDelegationsTable) UniqueColumns() []string {
+	return []string{"chain_name", "delegator_address", "validator_address"}
+}
+
 func (r UnbondingDelegationsTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, entries jsonb NOT NULL, UNIQUE (chain_name, delegator_address, validator_address))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, entries jsonb NOT NULL, UNIQUE (chain_name, delegator_address, validator_address))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, delegator_address text NOT NULL, validator_address text NOT NULL, entries jsonb NOT NULL, UNIQUE (chain_name, delegator_address, validator_address))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, delegator_address text NOT NULL, validator_address text NOT NULL, entries jsonb NOT NULL, UNIQUE (chain_name, delegator_address, validator_address))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r UnbondingDelegationsTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "unbonding_delegations_chain_name_id_idx"), database.Unqualified(r.tableName)),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (delegator_address) WHERE delete_height IS NULL`, database.SameSchema(r.tableName, "unbonding_delegations_delegator_address_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS unbonding_delegations_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS unbonding_delegations_delegator_address_idx ON %s (delegator_address) WHERE delete_height IS NULL`, r.tableName),
+	}
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r UnbondingDelegationsTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'staking unbonding delegations, one row per delegator and validator'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r UnbondingDelegationsTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r UnbondingDelegationsTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, delegator_address, validator_address, entries)
-		VALUES (:height, :chain_name, :delegator_address, :validator_address, :entries)
+		INSERT INTO %s (height, chain_name, last_tx_hash, delegator_address, validator_address, entries)
+		VALUES (:height, :chain_name, :last_tx_hash, :delegator_address, :validator_address, :entries)
 	`, r.tableName)
 }
 
 func (r UnbondingDelegationsTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, delegator_address, validator_address, entries)
-		VALUES (:height, :chain_name, :delegator_address, :validator_address, :entries)
+		INSERT INTO %s (height, chain_name, last_tx_hash, delegator_address, validator_address, entries)
+		VALUES (:height, :chain_name, :last_tx_hash, :delegator_address, :validator_address, :entries)
 		ON CONFLICT (chain_name, delegator_address, validator_address)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, delegator_address = EXCLUDED.delegator_address, validator_address = EXCLUDED.validator_address, entries = EXCLUDED.entries
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, delegator_address = EXCLUDED.delegator_address, validator_address = EXCLUDED.validator_address, entries = EXCLUDED.entries
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +110,33 @@ func (r UnbondingDelegationsTable) Upsert() string {
 func (r UnbondingDelegationsTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND delegator_address=:delegator_address AND validator_address=:validator_address
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r UnbondingDelegationsTable) SelectByUnique(q sqlx.Queryer, chainName string, delegator string, validator string) (models.UnbondingDelegationRow, error) {
+	var row models.UnbondingDelegationRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, delegator_address, validator_address, entries
+		FROM %s
+		WHERE chain_name=$1 AND delegator_address=$2 AND validator_address=$3
+		AND delete_height IS NULL
+	`, r.tableName), chainName, delegator, validator)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r UnbondingDelegationsTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.UnbondingDelegationRow, error) {
+	var rows []models.UnbondingDelegationRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, delegator_address, validator_address, entries
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
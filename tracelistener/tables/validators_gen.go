@@ -4,41 +4,118 @@ package tables
 
 import (
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
 )
 
 type ValidatorsTable struct {
 	tableName string
+	dialect   database.Dialect
 }
 
 func NewValidatorsTable(tableName string) ValidatorsTable {
 	return ValidatorsTable{
 		tableName: tableName,
+		dialect:   database.DialectCockroachDB,
 	}
 }
 
+// WithDialect returns a copy of r whose statements are written for dialect.
+func (r ValidatorsTable) WithDialect(dialect database.Dialect) ValidatorsTable {
+	r.dialect = dialect
+	return r
+}
+
 func (r ValidatorsTable) Name() string { return r.tableName }
 
+func (r ValidatorsTable) UniqueColumns() []string {
+	return []string{"chain_name", "operator_address"}
+}
+
 func (r ValidatorsTable) CreateTable() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id bigserial PRIMARY KEY NOT NULL, height bigint NOT NULL, delete_height bigint, chain_name text NOT NULL, last_tx_hash text, validator_address text NOT NULL, operator_address text NOT NULL, consensus_pubkey_type text, consensus_pubkey_value bytea, jailed bool NOT NULL, status bigint NOT NULL, tokens text NOT NULL, tokens_numeric decimal, delegator_shares text NOT NULL, delegator_shares_numeric decimal, moniker text, identity text, website text, security_contact text, details text, unbonding_height bigint, unbonding_time text, commission_rate text NOT NULL, commission_rate_numeric decimal, max_rate text NOT NULL, max_rate_numeric decimal, max_change_rate text NOT NULL, max_change_rate_numeric decimal, update_time text NOT NULL, min_self_delegation text NOT NULL, UNIQUE (chain_name, operator_address))
+	`, r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(id integer PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, validator_address text NOT NULL, operator_address text NOT NULL, consensus_pubkey_type text, consensus_pubkey_value blob, jailed bool NOT NULL, status integer NOT NULL, tokens text NOT NULL, tokens_numeric text, delegator_shares text NOT NULL, delegator_shares_numeric text, moniker text, identity text, website text, security_contact text, details text, unbonding_height bigint, unbonding_time text, commission_rate text NOT NULL, commission_rate_numeric text, max_rate text NOT NULL, max_rate_numeric text, max_change_rate text NOT NULL, max_change_rate_numeric text, update_time text NOT NULL, min_self_delegation text NOT NULL, UNIQUE (chain_name, operator_address))
+	`, r.tableName)
+	}
+
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s
-		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, validator_address text NOT NULL, operator_address text NOT NULL, consensus_pubkey_type text, consensus_pubkey_value bytes, jailed bool NOT NULL, status integer NOT NULL, tokens text NOT NULL, delegator_shares text NOT NULL, moniker text, identity text, website text, security_contact text, details text, unbonding_height bigint, unbonding_time text, commission_rate text NOT NULL, max_rate text NOT NULL, max_change_rate text NOT NULL, update_time text NOT NULL, min_self_delegation text NOT NULL, UNIQUE (chain_name, operator_address))
+		(id serial PRIMARY KEY NOT NULL, height integer NOT NULL, delete_height integer, chain_name text NOT NULL, last_tx_hash text, validator_address text NOT NULL, operator_address text NOT NULL, consensus_pubkey_type text, consensus_pubkey_value bytes, jailed bool NOT NULL, status integer NOT NULL, tokens text NOT NULL, tokens_numeric decimal, delegator_shares text NOT NULL, delegator_shares_numeric decimal, moniker text, identity text, website text, security_contact text, details text, unbonding_height bigint, unbonding_time text, commission_rate text NOT NULL, commission_rate_numeric decimal, max_rate text NOT NULL, max_rate_numeric decimal, max_change_rate text NOT NULL, max_change_rate_numeric decimal, update_time text NOT NULL, min_self_delegation text NOT NULL, UNIQUE (chain_name, operator_address))
 	`, r.tableName)
 }
 
+// CreateIndexes returns the statements creating the table secondary indexes.
+func (r ValidatorsTable) CreateIndexes() []string {
+	if r.dialect == database.DialectSQLite {
+		return []string{
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (chain_name, id)`, database.SameSchema(r.tableName, "validators_chain_name_id_idx"), database.Unqualified(r.tableName)),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS validators_chain_name_id_idx ON %s (chain_name, id)`, r.tableName),
+	}
+}
+
+// ViewName returns the name of the view exposing the live rows only.
+func (r ValidatorsTable) ViewName() string { return r.tableName + "_current" }
+
+func (r ValidatorsTable) CreateView() string {
+	switch r.dialect {
+	case database.DialectPostgres:
+		return fmt.Sprintf(`CREATE OR REPLACE VIEW %s AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation FROM %s WHERE delete_height IS NULL`, r.ViewName(), r.tableName)
+	case database.DialectSQLite:
+		return fmt.Sprintf(`CREATE VIEW IF NOT EXISTS %s AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation FROM %s WHERE delete_height IS NULL`, r.ViewName(), database.Unqualified(r.tableName))
+	}
+
+	return fmt.Sprintf(`CREATE VIEW IF NOT EXISTS %s AS SELECT id, height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation FROM %s WHERE delete_height IS NULL`, r.ViewName(), r.tableName)
+}
+
+// Comments returns the statements setting the table and columns comments.
+func (r ValidatorsTable) Comments() []string {
+	if r.dialect == database.DialectSQLite {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(`COMMENT ON TABLE %s IS 'staking validators, one row per operator address'`, r.tableName),
+	}
+}
+
+// Schema returns all the statements defining the table, in execution order.
+func (r ValidatorsTable) Schema() []string {
+	stmts := []string{r.CreateTable()}
+	stmts = append(stmts, r.CreateIndexes()...)
+	stmts = append(stmts, r.CreateView())
+	stmts = append(stmts, r.Comments()...)
+	return stmts
+}
+
 func (r ValidatorsTable) Insert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, delegator_shares, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, max_rate, max_change_rate, update_time, min_self_delegation)
-		VALUES (:height, :chain_name, :validator_address, :operator_address, :consensus_pubkey_type, :consensus_pubkey_value, :jailed, :status, :tokens, :delegator_shares, :moniker, :identity, :website, :security_contact, :details, :unbonding_height, :unbonding_time, :commission_rate, :max_rate, :max_change_rate, :update_time, :min_self_delegation)
+		INSERT INTO %s (height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation)
+		VALUES (:height, :chain_name, :last_tx_hash, :validator_address, :operator_address, :consensus_pubkey_type, :consensus_pubkey_value, :jailed, :status, :tokens, :tokens_numeric, :delegator_shares, :delegator_shares_numeric, :moniker, :identity, :website, :security_contact, :details, :unbonding_height, :unbonding_time, :commission_rate, :commission_rate_numeric, :max_rate, :max_rate_numeric, :max_change_rate, :max_change_rate_numeric, :update_time, :min_self_delegation)
 	`, r.tableName)
 }
 
 func (r ValidatorsTable) Upsert() string {
 	return fmt.Sprintf(`
-		INSERT INTO %s (height, chain_name, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, delegator_shares, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, max_rate, max_change_rate, update_time, min_self_delegation)
-		VALUES (:height, :chain_name, :validator_address, :operator_address, :consensus_pubkey_type, :consensus_pubkey_value, :jailed, :status, :tokens, :delegator_shares, :moniker, :identity, :website, :security_contact, :details, :unbonding_height, :unbonding_time, :commission_rate, :max_rate, :max_change_rate, :update_time, :min_self_delegation)
+		INSERT INTO %s (height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation)
+		VALUES (:height, :chain_name, :last_tx_hash, :validator_address, :operator_address, :consensus_pubkey_type, :consensus_pubkey_value, :jailed, :status, :tokens, :tokens_numeric, :delegator_shares, :delegator_shares_numeric, :moniker, :identity, :website, :security_contact, :details, :unbonding_height, :unbonding_time, :commission_rate, :commission_rate_numeric, :max_rate, :max_rate_numeric, :max_change_rate, :max_change_rate_numeric, :update_time, :min_self_delegation)
 		ON CONFLICT (chain_name, operator_address)
 		DO UPDATE
-		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, validator_address = EXCLUDED.validator_address, operator_address = EXCLUDED.operator_address, consensus_pubkey_type = EXCLUDED.consensus_pubkey_type, consensus_pubkey_value = EXCLUDED.consensus_pubkey_value, jailed = EXCLUDED.jailed, status = EXCLUDED.status, tokens = EXCLUDED.tokens, delegator_shares = EXCLUDED.delegator_shares, moniker = EXCLUDED.moniker, identity = EXCLUDED.identity, website = EXCLUDED.website, security_contact = EXCLUDED.security_contact, details = EXCLUDED.details, unbonding_height = EXCLUDED.unbonding_height, unbonding_time = EXCLUDED.unbonding_time, commission_rate = EXCLUDED.commission_rate, max_rate = EXCLUDED.max_rate, max_change_rate = EXCLUDED.max_change_rate, update_time = EXCLUDED.update_time, min_self_delegation = EXCLUDED.min_self_delegation
+		SET delete_height = NULL, height = EXCLUDED.height, chain_name = EXCLUDED.chain_name, last_tx_hash = EXCLUDED.last_tx_hash, validator_address = EXCLUDED.validator_address, operator_address = EXCLUDED.operator_address, consensus_pubkey_type = EXCLUDED.consensus_pubkey_type, consensus_pubkey_value = EXCLUDED.consensus_pubkey_value, jailed = EXCLUDED.jailed, status = EXCLUDED.status, tokens = EXCLUDED.tokens, tokens_numeric = EXCLUDED.tokens_numeric, delegator_shares = EXCLUDED.delegator_shares, delegator_shares_numeric = EXCLUDED.delegator_shares_numeric, moniker = EXCLUDED.moniker, identity = EXCLUDED.identity, website = EXCLUDED.website, security_contact = EXCLUDED.security_contact, details = EXCLUDED.details, unbonding_height = EXCLUDED.unbonding_height, unbonding_time = EXCLUDED.unbonding_time, commission_rate = EXCLUDED.commission_rate, commission_rate_numeric = EXCLUDED.commission_rate_numeric, max_rate = EXCLUDED.max_rate, max_rate_numeric = EXCLUDED.max_rate_numeric, max_change_rate = EXCLUDED.max_change_rate, max_change_rate_numeric = EXCLUDED.max_change_rate_numeric, update_time = EXCLUDED.update_time, min_self_delegation = EXCLUDED.min_self_delegation
 		WHERE %s.height < EXCLUDED.height
 	`, r.tableName, r.tableName)
 }
@@ -46,8 +123,33 @@ func (r ValidatorsTable) Upsert() string {
 func (r ValidatorsTable) Delete() string {
 	return fmt.Sprintf(`
 		UPDATE %s
-		SET delete_height = :height, height = :height
+		SET delete_height = :height, height = :height, last_tx_hash = :last_tx_hash
 		WHERE chain_name=:chain_name AND operator_address=:operator_address
 		AND delete_height IS NULL
+		AND height <= :height
 	`, r.tableName)
 }
+
+// SelectByUnique returns the live row matching the given unique columns values.
+func (r ValidatorsTable) SelectByUnique(q sqlx.Queryer, chainName string, operatorAddress string) (models.ValidatorRow, error) {
+	var row models.ValidatorRow
+	err := sqlx.Get(q, &row, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation
+		FROM %s
+		WHERE chain_name=$1 AND operator_address=$2
+		AND delete_height IS NULL
+	`, r.tableName), chainName, operatorAddress)
+	return row, err
+}
+
+// ListByChain returns all the live rows of chainName.
+func (r ValidatorsTable) ListByChain(q sqlx.Queryer, chainName string) ([]models.ValidatorRow, error) {
+	var rows []models.ValidatorRow
+	err := sqlx.Select(q, &rows, fmt.Sprintf(`
+		SELECT id, height, delete_height, chain_name, last_tx_hash, validator_address, operator_address, consensus_pubkey_type, consensus_pubkey_value, jailed, status, tokens, tokens_numeric, delegator_shares, delegator_shares_numeric, moniker, identity, website, security_contact, details, unbonding_height, unbonding_time, commission_rate, commission_rate_numeric, max_rate, max_rate_numeric, max_change_rate, max_change_rate_numeric, update_time, min_self_delegation
+		FROM %s
+		WHERE chain_name=$1
+		AND delete_height IS NULL
+	`, r.tableName), chainName)
+	return rows, err
+}
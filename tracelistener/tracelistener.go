@@ -112,6 +112,16 @@ type WritebackOp struct {
 	SourceModule string
 }
 
+// BlockWriteback contains all the WritebackOps produced by a single processor flush,
+// along with the height of the block they belong to.
+type BlockWriteback struct {
+	Height uint64
+	Ops    []WritebackOp
+
+	// Summary describes the block changes, it's recorded along with Ops when not nil.
+	Summary *models.BlockSummaryRow
+}
+
 // InterfaceSlice returns Data as a slice of interface{}.
 func (wo WritebackOp) InterfaceSlice() []interface{} {
 	dataIface := make([]interface{}, 0, len(wo.Data))
@@ -241,11 +251,13 @@ func buildEntrierChunks(slice []models.DatabaseEntrier, chunkSize int64) [][]mod
 
 type DataProcessor interface {
 	OpsChan() chan TraceOperation
-	WritebackChan() chan []WritebackOp
+	WritebackChan() chan BlockWriteback
 	ErrorsChan() chan error
-	DatabaseMigrations() []string
+	DatabaseSeeds() []string
 	Flush() error
+	InvalidateCaches()
 	SetDBUpsertEnabled(enabled bool)
+	SetBackfill(enabled bool)
 	StartBackgroundProcessing()
 	StopBackgroundProcessing()
 }
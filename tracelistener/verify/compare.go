@@ -0,0 +1,232 @@
+package verify
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+
+	"github.com/emerishq/tracelistener/models"
+)
+
+var dbMapper = reflectx.NewMapperFunc("db", strings.ToLower)
+
+// entryColumns returns the values of d keyed by database column name.
+func entryColumns(d models.DatabaseEntrier) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(d))
+	ret := map[string]interface{}{}
+
+	for _, fi := range dbMapper.TypeMap(v.Type()).Index {
+		if fi.Embedded || strings.Contains(fi.Path, ".") {
+			continue
+		}
+
+		ret[fi.Path] = reflectx.FieldByIndexesReadOnly(v, fi.Index).Interface()
+	}
+
+	return ret
+}
+
+// rowKey returns the values of the key columns of a row, joined.
+func rowKey(keys []string, columns map[string]interface{}) string {
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, text(columns[k]))
+	}
+
+	return strings.Join(values, "/")
+}
+
+// differingColumns returns the columns whose expected value differs from the stored one, sorted.
+func differingColumns(expected, stored map[string]interface{}) []string {
+	var res []string
+	for c, e := range expected {
+		if ignoredColumns[c] {
+			continue
+		}
+
+		s, ok := stored[c]
+		if !ok || !equalValues(e, s) {
+			res = append(res, c)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+// equalValues returns true if the value of a model field matches the one scanned from the
+// database, whose type depends on the dialect and driver.
+func equalValues(expected, stored interface{}) bool {
+	// arrays are compared element by element and entries as JSON, whatever their text format
+	switch e := expected.(type) {
+	case models.StringArray:
+		expected = []string(e)
+	case models.UnbondingDelegationEntries, models.RedelegationEntries:
+		return equalJSON(expected, stored)
+	}
+
+	if v, ok := expected.(driver.Valuer); ok {
+		ev, err := v.Value()
+		if err != nil {
+			return false
+		}
+
+		expected = ev
+	}
+
+	if expected == nil || stored == nil {
+		return expected == nil && stored == nil
+	}
+
+	switch e := expected.(type) {
+	case []byte:
+		return bytes.Equal(e, storedBytes(stored))
+	case string:
+		return equalText(e, text(stored))
+	case bool:
+		switch s := stored.(type) {
+		case bool:
+			return e == s
+		case int64:
+			return e == (s != 0)
+		}
+
+		return false
+	case time.Time:
+		s, ok := stored.(time.Time)
+		return ok && e.Equal(s)
+	case []string:
+		s := textArray(stored)
+		return (len(e) == 0 && len(s) == 0) || reflect.DeepEqual(e, s)
+	}
+
+	switch reflect.ValueOf(expected).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return equalText(fmt.Sprint(expected), text(stored))
+	}
+
+	// other values, like unbonding delegation entries, are stored as JSON
+	return equalJSON(expected, stored)
+}
+
+// equalText returns true if a and b are the same text, or the same decimal number.
+func equalText(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	ra, ok := new(big.Rat).SetString(a)
+	if !ok {
+		return false
+	}
+
+	rb, ok := new(big.Rat).SetString(b)
+	return ok && ra.Cmp(rb) == 0
+}
+
+func equalJSON(expected, stored interface{}) bool {
+	bz, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+
+	var e, s interface{}
+	if err := json.Unmarshal(bz, &e); err != nil {
+		return false
+	}
+
+	switch sv := stored.(type) {
+	case string:
+		bz = []byte(sv)
+	case []byte:
+		bz = sv
+	default:
+		if bz, err = json.Marshal(sv); err != nil {
+			return false
+		}
+	}
+
+	if err := json.Unmarshal(bz, &s); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(e, s)
+}
+
+// text returns a stored value as text.
+func text(v interface{}) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	case []byte:
+		return string(vv)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// storedBytes returns the bytes of a bytea value, scanned as is or in the hex text format.
+func storedBytes(v interface{}) []byte {
+	s := text(v)
+	if strings.HasPrefix(s, `\x`) {
+		if bz, err := hex.DecodeString(s[2:]); err == nil {
+			return bz
+		}
+	}
+
+	if bz, ok := v.([]byte); ok {
+		return bz
+	}
+
+	return []byte(s)
+}
+
+// textArray returns the elements of a stored text array, scanned as a list or in the
+// PostgreSQL array text format.
+func textArray(v interface{}) []string {
+	switch vv := v.(type) {
+	case []string:
+		return vv
+	case []interface{}:
+		res := make([]string, 0, len(vv))
+		for _, e := range vv {
+			res = append(res, text(e))
+		}
+
+		return res
+	}
+
+	var res models.StringArray
+	if err := res.Scan(text(v)); err != nil {
+		return nil
+	}
+
+	return res
+}
+
+// toUint64 returns a stored integer value.
+func toUint64(v interface{}) uint64 {
+	switch vv := v.(type) {
+	case int64:
+		return uint64(vv)
+	case uint64:
+		return vv
+	}
+
+	n, _ := strconv.ParseUint(text(v), 10, 64)
+	return n
+}
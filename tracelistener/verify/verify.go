@@ -0,0 +1,383 @@
+// Package verify compares the rows tracelistener derives from the chain state with the ones
+// stored in the database, to measure the drift left by writes which failed silently.
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener"
+	"github.com/emerishq/tracelistener/tracelistener/bulk"
+	"github.com/emerishq/tracelistener/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/processor"
+)
+
+// TableReport counts the rows of a table by verification outcome.
+type TableReport struct {
+	Table string
+
+	// Expected is the number of rows the chain state yields.
+	Expected int
+
+	Matching int
+
+	// Missing rows are yielded by the chain state but aren't in the database, or are deleted there.
+	Missing int
+
+	// Extra rows are in the database but aren't yielded by the chain state.
+	Extra int
+
+	// Differing rows are in both, with different values.
+	Differing int
+
+	// Newer rows have been written to the database after the verified height, and can't be compared.
+	Newer int
+}
+
+// Drifted returns true if the table doesn't match the chain state.
+func (t TableReport) Drifted() bool {
+	return t.Missing+t.Extra+t.Differing > 0
+}
+
+// Report is the outcome of a verification, table by table.
+type Report struct {
+	Height int64
+	Tables []TableReport
+}
+
+// Drifted returns true if any table doesn't match the chain state.
+func (r Report) Drifted() bool {
+	for _, t := range r.Tables {
+		if t.Drifted() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verifier compares the rows the chain state read by Importer yields with the rows of
+// ChainName in Database.
+type Verifier struct {
+	// Importer reads the chain state of the modules to verify, at the height to verify.
+	// Its rows are collected instead of being written, and it isn't resumable.
+	Importer  *bulk.Importer
+	Database  *database.Instance
+	ChainName string
+	Logger    *zap.SugaredLogger
+
+	// Repair, if set, receives the statements bringing the database in line with the chain state:
+	// inserts and updates of missing and differing rows, deletes of extra ones.
+	// Rows written after the verified height are left untouched when they're replayed.
+	Repair *database.DeadLetter
+}
+
+// tableRows holds the rows the chain state yields for a table, by key.
+type tableRows struct {
+	table  processor.Table
+	module string
+	insert string
+	rows   map[string]models.DatabaseEntrier
+}
+
+// Do reads the chain state and compares it with the database.
+// Expected rows are held in memory until compared, modules can be verified one at a time
+// to bound it.
+func (v *Verifier) Do() (Report, error) {
+	pp, ok := v.Importer.Processor.(*processor.Processor)
+	if !ok {
+		return Report{}, fmt.Errorf("cannot verify rows of processor %T", v.Importer.Processor)
+	}
+
+	tables, byStatement := v.tables(pp.Modules())
+
+	m := sync.Mutex{}
+	v.Importer.ChainName = ""
+	v.Importer.Backfill = false
+	v.Importer.Writeback = func(ops []tracelistener.WritebackOp) {
+		m.Lock()
+		defer m.Unlock()
+
+		for _, op := range ops {
+			// history, state changes and aggregates aren't verified
+			t, ok := byStatement[op.Statement]
+			if !ok || op.Type != tracelistener.Write {
+				continue
+			}
+
+			for _, d := range op.Data {
+				t.rows[rowKey(t.table.UniqueColumns(), entryColumns(d))] = d
+			}
+		}
+	}
+
+	if err := v.Importer.Do(); err != nil {
+		return Report{}, fmt.Errorf("cannot read chain state, %w", err)
+	}
+
+	r := Report{
+		Height: v.Importer.ImportedHeight(),
+	}
+
+	for _, t := range tables {
+		tr, err := v.compare(t, uint64(r.Height))
+		if err != nil {
+			return Report{}, err
+		}
+
+		r.Tables = append(r.Tables, tr)
+	}
+
+	return r, nil
+}
+
+// tables returns the tables of the modules verified, sorted by name and keyed by the
+// statements writing their rows.
+func (v *Verifier) tables(modules []processor.Module) ([]*tableRows, map[string]*tableRows) {
+	verified := map[string]bool{}
+	for _, m := range v.Importer.Modules {
+		verified[m] = true
+	}
+
+	var res []*tableRows
+	byStatement := map[string]*tableRows{}
+	for _, m := range modules {
+		tm, ok := m.(processor.TableModule)
+		if !ok {
+			continue
+		}
+
+		if len(verified) != 0 && !verified[m.SDKModuleName().String()] {
+			continue
+		}
+
+		t := &tableRows{
+			table:  tm.Table(),
+			module: m.SDKModuleName().String(),
+			insert: m.InsertStatement(),
+			rows:   map[string]models.DatabaseEntrier{},
+		}
+
+		res = append(res, t)
+		byStatement[m.InsertStatement()] = t
+		byStatement[m.UpsertStatement()] = t
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].table.Name() < res[j].table.Name()
+	})
+
+	return res, byStatement
+}
+
+// repairs holds the statements repairing a table, by kind.
+type repairs struct {
+	inserts []models.DatabaseEntrier
+	updates []models.DatabaseEntrier
+	deletes []map[string]interface{}
+}
+
+// compare compares the rows of t with the ones stored for the chain, and writes the repairs.
+func (v *Verifier) compare(t *tableRows, height uint64) (TableReport, error) {
+	tr := TableReport{
+		Table:    t.table.Name(),
+		Expected: len(t.rows),
+	}
+
+	rows, err := v.Database.Instance.DB.Queryx(
+		fmt.Sprintf("SELECT * FROM %s WHERE chain_name = $1", t.table.Name()),
+		v.ChainName,
+	)
+	if err != nil {
+		return TableReport{}, fmt.Errorf("cannot read %s rows, %w", t.table.Name(), err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	rep := repairs{}
+	for rows.Next() {
+		stored := map[string]interface{}{}
+		if err := rows.MapScan(stored); err != nil {
+			return TableReport{}, fmt.Errorf("cannot scan %s row, %w", t.table.Name(), err)
+		}
+
+		key := rowKey(t.table.UniqueColumns(), stored)
+		expected, ok := t.rows[key]
+		delete(t.rows, key)
+
+		deleted := stored["delete_height"] != nil
+
+		switch {
+		case !ok && deleted:
+			// deleted from both
+		case toUint64(stored["height"]) > height:
+			tr.Newer++
+		case !ok:
+			tr.Extra++
+			rep.deletes = append(rep.deletes, deleteRow(t.table.UniqueColumns(), stored, height))
+			v.Logger.Debugw("extra row", "table", t.table.Name(), "key", key)
+		case deleted:
+			tr.Missing++
+			rep.updates = append(rep.updates, expected)
+			v.Logger.Debugw("deleted row", "table", t.table.Name(), "key", key)
+		default:
+			columns := differingColumns(entryColumns(expected), stored)
+			if len(columns) == 0 {
+				tr.Matching++
+				continue
+			}
+
+			tr.Differing++
+			rep.updates = append(rep.updates, expected)
+			v.Logger.Debugw("differing row", "table", t.table.Name(), "key", key, "columns", columns)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return TableReport{}, fmt.Errorf("cannot read %s rows, %w", t.table.Name(), err)
+	}
+
+	for key, expected := range t.rows {
+		tr.Missing++
+		rep.inserts = append(rep.inserts, expected)
+		v.Logger.Debugw("missing row", "table", t.table.Name(), "key", key)
+	}
+
+	if err := v.writeRepairs(t, rep, height); err != nil {
+		return TableReport{}, err
+	}
+
+	return tr, nil
+}
+
+// writeRepairs writes the statements repairing t to the repair file, if any.
+// Updates and deletes can't be batched, they're written one row at a time like the
+// processors' deletes.
+func (v *Verifier) writeRepairs(t *tableRows, rep repairs, height uint64) error {
+	if v.Repair == nil {
+		return nil
+	}
+
+	b := tracelistener.BlockWriteback{
+		Height: height,
+	}
+
+	if len(rep.inserts) != 0 {
+		b.Ops = append(b.Ops, tracelistener.WritebackOp{
+			Type:         tracelistener.Write,
+			Statement:    t.insert,
+			Data:         rep.inserts,
+			SourceModule: t.module,
+		}.SplitStatementToDBLimit()...)
+	}
+
+	for _, u := range rep.updates {
+		b.Ops = append(b.Ops, tracelistener.WritebackOp{
+			Type:         tracelistener.Write,
+			Statement:    updateStatement(t.table, entryColumns(u)),
+			Data:         []models.DatabaseEntrier{u},
+			SourceModule: t.module,
+		})
+	}
+
+	if err := v.Repair.Write(v.ChainName, b, errRepair); err != nil {
+		return err
+	}
+
+	entries := make([]database.DeadLetterEntry, 0, len(rep.deletes))
+	for _, d := range rep.deletes {
+		entries = append(entries, database.DeadLetterEntry{
+			Time:      time.Now().UTC(),
+			ChainName: v.ChainName,
+			Height:    height,
+			Module:    t.module,
+			Type:      tracelistener.Delete.String(),
+			Statement: deleteStatement(t.table),
+			Rows:      []map[string]interface{}{d},
+			Error:     errRepair.Error(),
+		})
+	}
+
+	return v.Repair.WriteEntries(entries)
+}
+
+// errRepair is recorded as the cause of repair statements.
+var errRepair = errors.New("state drift found by verify")
+
+// ignoredColumns aren't compared: bulk imports don't know transaction hashes, and rows are
+// stamped with the height they were last written at.
+var ignoredColumns = map[string]bool{
+	"id":            true,
+	"height":        true,
+	"delete_height": true,
+	"last_tx_hash":  true,
+}
+
+// updateStatement returns the statement replacing the values of a row of table with the
+// ones of columns, and restoring it if deleted, unless it has been written after the
+// verified height.
+func updateStatement(table processor.Table, columns map[string]interface{}) string {
+	keys := map[string]bool{}
+	for _, c := range table.UniqueColumns() {
+		keys[c] = true
+	}
+
+	var set []string
+	for c := range columns {
+		if keys[c] || c == "id" || c == "delete_height" {
+			continue
+		}
+
+		set = append(set, fmt.Sprintf("%s = :%s", c, c))
+	}
+
+	sort.Strings(set)
+	set = append(set, "delete_height = NULL")
+
+	return fmt.Sprintf(`
+		UPDATE %s
+		SET %s
+		WHERE %s AND height <= :height
+	`, table.Name(), strings.Join(set, ", "), keyCondition(table))
+}
+
+// deleteStatement returns the statement deleting a row of table, unless it has been written
+// after the verified height.
+func deleteStatement(table processor.Table) string {
+	return fmt.Sprintf(`
+		UPDATE %s
+		SET delete_height = :height, height = :height
+		WHERE %s AND delete_height IS NULL AND height <= :height
+	`, table.Name(), keyCondition(table))
+}
+
+func keyCondition(table processor.Table) string {
+	conds := make([]string, 0, len(table.UniqueColumns()))
+	for _, c := range table.UniqueColumns() {
+		conds = append(conds, fmt.Sprintf("%s = :%s", c, c))
+	}
+
+	return strings.Join(conds, " AND ")
+}
+
+// deleteRow returns the named parameters of the statement deleting stored.
+func deleteRow(keys []string, stored map[string]interface{}, height uint64) map[string]interface{} {
+	row := map[string]interface{}{
+		"height": height,
+	}
+
+	for _, k := range keys {
+		row[k] = text(stored[k])
+	}
+
+	return row
+}
@@ -0,0 +1,152 @@
+//go:build sdk_v44
+
+package verify_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	dbutils "github.com/emerishq/tracelistener/database"
+	"github.com/emerishq/tracelistener/models"
+	"github.com/emerishq/tracelistener/tracelistener/bulk"
+	"github.com/emerishq/tracelistener/tracelistener/config"
+	"github.com/emerishq/tracelistener/tracelistener/database"
+	"github.com/emerishq/tracelistener/tracelistener/processor"
+	"github.com/emerishq/tracelistener/tracelistener/tables"
+	"github.com/emerishq/tracelistener/tracelistener/verify"
+)
+
+const applicationDB = "../bulk/testdata/application.db"
+
+// newImporter returns an importer of the testdata chain state with a new processor.
+func newImporter(t *testing.T, di *database.Instance, modules ...string) *bulk.Importer {
+	t.Helper()
+
+	dpi, err := processor.New(zap.NewNop().Sugar(), &config.Config{
+		ChainName:       "gaia",
+		DatabaseDialect: "sqlite",
+	})
+	require.NoError(t, err)
+
+	dpi.StartBackgroundProcessing()
+
+	return &bulk.Importer{
+		Path:      applicationDB,
+		Processor: dpi,
+		Logger:    zap.NewNop().Sugar(),
+		Database:  di,
+		Modules:   modules,
+	}
+}
+
+// newDatabase returns a database holding the testdata chain state.
+func newDatabase(t *testing.T) *database.Instance {
+	t.Helper()
+
+	database.RegisterVersionedMigration(tables.Migrations...)
+	di, err := database.NewWithOptions(filepath.Join(t.TempDir(), "tracelistener.db"), database.Options{
+		Dialect: dbutils.DialectSQLite,
+	})
+	require.NoError(t, err)
+
+	im := newImporter(t, di)
+
+	im.ChainName = "gaia"
+	require.NoError(t, im.Do())
+
+	return di
+}
+
+func TestVerifier_Do_SQLite(t *testing.T) {
+	di := newDatabase(t)
+
+	var balances []models.BalanceRow
+	require.NoError(t, di.Instance.DB.Select(&balances, "SELECT id, address, amount, denom FROM tracelistener.balances ORDER BY id"))
+	require.Len(t, balances, 3)
+
+	var accounts []models.AuthRow
+	require.NoError(t, di.Instance.DB.Select(&accounts, "SELECT id, address FROM tracelistener.auth ORDER BY id"))
+	require.NotEmpty(t, accounts)
+
+	// channels and unbonding delegations can't be written to SQLite
+	modules := []string{"acc", "bank"}
+	v := verify.Verifier{
+		Importer:  newImporter(t, di, modules...),
+		Database:  di,
+		ChainName: "gaia",
+		Logger:    zap.NewNop().Sugar(),
+	}
+
+	r, err := v.Do()
+	require.NoError(t, err)
+	require.False(t, r.Drifted())
+	require.Equal(t, []verify.TableReport{
+		{Table: "tracelistener.auth", Expected: len(accounts), Matching: len(accounts)},
+		{Table: "tracelistener.balances", Expected: 3, Matching: 3},
+	}, r.Tables)
+
+	stmts := []struct {
+		stmt string
+		id   uint64
+	}{
+		{"UPDATE tracelistener.balances SET amount = '1' WHERE id = $1", balances[0].ID},
+		{"DELETE FROM tracelistener.balances WHERE id = $1", balances[1].ID},
+		{"UPDATE tracelistener.balances SET delete_height = height WHERE id = $1", balances[2].ID},
+		{"UPDATE tracelistener.auth SET sequence_number = 42, height = 100 WHERE id = $1", accounts[0].ID},
+	}
+	for _, s := range stmts {
+		_, err := di.Instance.DB.Exec(s.stmt, s.id)
+		require.NoError(t, err)
+	}
+
+	_, err = di.Instance.DB.Exec(`
+		INSERT INTO tracelistener.balances (chain_name, height, address, amount, denom)
+		VALUES ('gaia', 1, 'address', '42', 'denom'), ('other', 1, 'address', '42', 'denom')
+	`)
+	require.NoError(t, err)
+
+	repair := filepath.Join(t.TempDir(), "repair.jsonl")
+	v.Importer = newImporter(t, di, modules...)
+	v.Repair = database.NewDeadLetter(repair)
+
+	r, err = v.Do()
+	require.NoError(t, err)
+	require.True(t, r.Drifted())
+
+	auth := verify.TableReport{Table: "tracelistener.auth", Expected: len(accounts), Matching: len(accounts) - 1, Newer: 1}
+	require.Equal(t, []verify.TableReport{
+		auth,
+		{Table: "tracelistener.balances", Expected: 3, Missing: 2, Extra: 1, Differing: 1},
+	}, r.Tables)
+
+	// one insert of the missing rows, one update per differing or deleted row, one delete per extra row
+	replayed, failed, err := di.ReplayDeadLetter(repair)
+	require.NoError(t, err)
+	require.Empty(t, failed)
+	require.Equal(t, 4, replayed)
+
+	v.Importer = newImporter(t, di, modules...)
+	v.Repair = nil
+
+	r, err = v.Do()
+	require.NoError(t, err)
+	require.False(t, r.Drifted())
+
+	// the row written after the verified height is left untouched
+	require.Equal(t, []verify.TableReport{
+		auth,
+		{Table: "tracelistener.balances", Expected: 3, Matching: 3},
+	}, r.Tables)
+}
+
+func TestVerifier_Do_Processor(t *testing.T) {
+	v := verify.Verifier{
+		Importer: &bulk.Importer{},
+	}
+
+	_, err := v.Do()
+	require.Error(t, err)
+}